@@ -1,15 +1,21 @@
 package auth
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/pingcap/errors"
+	"github.com/tjfoc/gmsm/sm2"
 
 	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/crypto"
 )
 
 var (
@@ -17,7 +23,15 @@ var (
 )
 
 type Auth struct {
-	secretKey []byte
+	secretKey       []byte
+	keyring         *crypto.Keyring
+	keyID           string
+	signingKey      interface{}
+	verificationKey interface{}
+	keySource       KeySource
+	policy          *ValidationPolicy
+	revocationStore RevocationStore
+	maxTokenSize    int
 }
 
 // NewAuth returns a new *Auth
@@ -27,20 +41,121 @@ func NewAuth(secretKey []byte) *Auth {
 	}
 }
 
+// NewAuthWithKeyring returns a new *Auth with given keyring,
+// the tokens are signed by the active key with the kid header, and verified by the key of the kid header
+func NewAuthWithKeyring(keyring *crypto.Keyring) *Auth {
+	return &Auth{
+		keyring: keyring,
+	}
+}
+
+// NewAuthWithKeyPair returns a new *Auth with given asymmetric key pair,
+// the private key must be one of *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey and *sm2.PrivateKey,
+// it could be nil if the auth is only used to verify, if the public key is nil, it will be derived from the private key,
+// if kid is not empty, it will be put into the kid header of the signed tokens
+func NewAuthWithKeyPair(kid string, privateKey, publicKey interface{}) (*Auth, error) {
+	if privateKey == nil && publicKey == nil {
+		return nil, errors.New("private key and public key should not be both nil")
+	}
+
+	if publicKey == nil {
+		key, err := crypto.NewAsymmetricKey(kid, privateKey, nil)
+		if err != nil {
+			return nil, err
+		}
+		publicKey = key.PublicKey
+	}
+
+	return &Auth{
+		keyID:           kid,
+		signingKey:      privateKey,
+		verificationKey: publicKey,
+	}, nil
+}
+
+// NewAuthWithKeySource returns a new *Auth which verifies the tokens with the key of the kid header returned by the key source,
+// for example, the jwks served by the issuer, it could not sign the tokens
+func NewAuthWithKeySource(keySource KeySource) *Auth {
+	return &Auth{
+		keySource: keySource,
+	}
+}
+
 // NewAuthWithDefault returns a new *Auth with empty secret key
 func NewAuthWithDefault() *Auth {
 	return NewAuth([]byte{})
 }
 
+// SetValidationPolicy sets the validation policy, the claims of the verified tokens will be validated by it
+func (a *Auth) SetValidationPolicy(policy *ValidationPolicy) {
+	a.policy = policy
+}
+
+// SetRevocationStore sets the revocation store, the revoked tokens will be rejected when parsing
+func (a *Auth) SetRevocationStore(store RevocationStore) {
+	a.revocationStore = store
+}
+
+// SetMaxTokenSize sets the maximum byte length of the signed tokens, the tokens larger than it will not be signed,
+// it is useful to keep the tokens fit in the http headers, 0 means unlimited
+func (a *Auth) SetMaxTokenSize(size int) {
+	a.maxTokenSize = size
+}
+
 // Sign signs with the default method and claims
 func (a *Auth) Sign() (string, error) {
 	return a.SignWithMethodAndClaims(DefaultSignMethod, jwt.MapClaims{}, nil)
 }
 
-// SignWithMethodAndClaims signs with the given method and claims
+// SignWithClaims signs with the given claims, the signing method is chosen by the signing key,
+// it only works with the keyring or the key pair
+func (a *Auth) SignWithClaims(claims jwt.MapClaims, ef EncodeFunc) (string, error) {
+	_, key, err := a.getSigningKey()
+	if err != nil {
+		return constant.EmptyString, err
+	}
+	if key == nil {
+		return constant.EmptyString, errors.New("signing key should not be nil, use SignWithMethodAndClaims() with the secret key instead")
+	}
+	method, err := GetSigningMethod(key)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return a.SignWithMethodAndClaims(method, claims, ef)
+}
+
+// SignWithMethodAndClaims signs with the given method and claims,
+// it returns error if the signed token is larger than the maximum token size
 func (a *Auth) SignWithMethodAndClaims(method jwt.SigningMethod, claims jwt.MapClaims, ef EncodeFunc) (string, error) {
+	tokenString, err := a.signWithMethodAndClaims(method, claims, ef)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+	if a.maxTokenSize > constant.ZeroInt && len(tokenString) > a.maxTokenSize {
+		return constant.EmptyString, errors.Errorf("token is too large, try to reduce the claims or compress the payload. max size: %d, actual: %d",
+			a.maxTokenSize, len(tokenString))
+	}
+
+	return tokenString, nil
+}
+
+// signWithMethodAndClaims signs with the given method and claims
+func (a *Auth) signWithMethodAndClaims(method jwt.SigningMethod, claims jwt.MapClaims, ef EncodeFunc) (string, error) {
 	token := NewTokenWithClaims(method, claims)
 
+	kid, signingKey, err := a.getSigningKey()
+	if err != nil {
+		return constant.EmptyString, err
+	}
+	if signingKey != nil {
+		if kid != constant.EmptyString {
+			token.Header[tokenKeyIDHeader] = kid
+		}
+
+		return token.SignedString(signingKey, ef)
+	}
+
 	var key interface{}
 
 	switch method {
@@ -63,11 +178,20 @@ func (a *Auth) SignWithMethodAndClaims(method jwt.SigningMethod, claims jwt.MapC
 
 // Parse parses the payload from the token, it verifies the signature
 func (a *Auth) Parse(tokenString string, in interface{}) error {
-	parser := NewParserWithDefault()
-	token, err := parser.Parse(tokenString, a.secretKey)
+	return a.ParseWithContext(context.Background(), tokenString, in)
+}
+
+// ParseWithContext parses the payload from the token, it verifies the signature,
+// validates the claims if the validation policy is set, and checks the revocation if the revocation store is set,
+// the refresh tokens are rejected, they could only be used by RefreshTokenPair()
+func (a *Auth) ParseWithContext(ctx context.Context, tokenString string, in interface{}) error {
+	token, err := a.verify(ctx, tokenString)
 	if err != nil {
 		return err
 	}
+	if token.Claims[ClaimTokenType] == TokenTypeRefresh {
+		return errors.New("refresh token could not be used as access token")
+	}
 
 	return a.unmarshal(token, in)
 }
@@ -83,6 +207,109 @@ func (a *Auth) ParseUnverified(tokenString string, in interface{}) error {
 	return a.unmarshal(token, in)
 }
 
+// verify parses the token, verifies the signature, validates the claims and checks the revocation
+func (a *Auth) verify(ctx context.Context, tokenString string) (*Token, error) {
+	parser := NewParserWithDefault()
+	token, err := parser.ParseWithKeyFunc(tokenString, a.getVerificationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.policy != nil {
+		err = a.policy.Validate(token.Claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if a.revocationStore != nil {
+		jti, ok := token.Claims[ClaimJWTID].(string)
+		if ok && jti != constant.EmptyString {
+			revoked, err := a.revocationStore.IsRevoked(ctx, jti)
+			if err != nil {
+				return nil, err
+			}
+			if revoked {
+				return nil, errors.Errorf("token has been revoked. jti: %s", jti)
+			}
+		}
+	}
+
+	return token, nil
+}
+
+// getSigningKey returns the kid and the key which is used to sign the token,
+// it returns nil key if neither the keyring nor the key pair is set
+func (a *Auth) getSigningKey() (string, interface{}, error) {
+	if a.keyring != nil {
+		activeKey, err := a.keyring.GetActiveKey()
+		if err != nil {
+			return constant.EmptyString, nil, err
+		}
+		key, err := activeKey.GetSigningKey()
+		if err != nil {
+			return constant.EmptyString, nil, err
+		}
+
+		return activeKey.ID, key, nil
+	}
+	if a.keySource != nil {
+		return constant.EmptyString, nil, errors.New("auth with key source could not sign the token")
+	}
+	if a.verificationKey != nil && a.signingKey == nil {
+		return constant.EmptyString, nil, errors.New("private key is missing, auth with public key could not sign the token")
+	}
+
+	return a.keyID, a.signingKey, nil
+}
+
+// getVerificationKey returns the key which is used to verify the token,
+// if the keyring is set, it returns the key of the kid header, if the token does not have the kid header, the active key will be used,
+// if the key source is set, it returns the key of the kid header returned by the key source
+func (a *Auth) getVerificationKey(token *Token) (interface{}, error) {
+	if a.keySource != nil {
+		return a.keySource.GetKey(token.GetKeyID())
+	}
+	if a.verificationKey != nil {
+		return a.verificationKey, nil
+	}
+
+	if a.keyring != nil {
+		var (
+			key *crypto.Key
+			err error
+		)
+		kid := token.GetKeyID()
+		if kid == constant.EmptyString {
+			key, err = a.keyring.GetActiveKey()
+		} else {
+			key, err = a.keyring.GetKey(kid)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return key.GetVerificationKey()
+	}
+
+	switch token.Method {
+	case jwt.SigningMethodRS256, jwt.SigningMethodRS384, jwt.SigningMethodRS512:
+		// the secret key is the private key, use the public key of it to verify
+		secretKey, err := base64.StdEncoding.DecodeString(common.BytesToString(a.secretKey))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		privateKey, err := x509.ParsePKCS1PrivateKey(secretKey)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		return &privateKey.PublicKey, nil
+	default:
+		return a.secretKey, nil
+	}
+}
+
 func (a *Auth) unmarshal(token *Token, in interface{}) error {
 	bytes, err := json.Marshal(token.Claims)
 	if err != nil {
@@ -96,3 +323,39 @@ func (a *Auth) unmarshal(token *Token, in interface{}) error {
 
 	return nil
 }
+
+// GetSigningMethod returns the default signing method of given key, the key could be the private key or the public key,
+// the hmac secret uses HS256, the rsa keys use RS256, the ecdsa keys use ES256, ES384 or ES512 by the curve,
+// the ed25519 keys use EdDSA, and the sm2 keys use SM2-SM3
+func GetSigningMethod(key interface{}) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case []byte:
+		return jwt.SigningMethodHS256, nil
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		return getECDSASigningMethod(&k.PublicKey)
+	case *ecdsa.PublicKey:
+		return getECDSASigningMethod(k)
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *sm2.PrivateKey, *sm2.PublicKey:
+		return SigningMethodSM2SM3, nil
+	default:
+		return nil, errors.Errorf("unsupported key type: %T", key)
+	}
+}
+
+// getECDSASigningMethod returns the signing method of the ecdsa public key by the curve
+func getECDSASigningMethod(publicKey *ecdsa.PublicKey) (jwt.SigningMethod, error) {
+	switch publicKey.Curve.Params().BitSize {
+	case jwt.SigningMethodES256.CurveBits:
+		return jwt.SigningMethodES256, nil
+	case jwt.SigningMethodES384.CurveBits:
+		return jwt.SigningMethodES384, nil
+	case jwt.SigningMethodES512.CurveBits:
+		return jwt.SigningMethodES512, nil
+	default:
+		return nil, errors.Errorf("unsupported ecdsa curve: %s", publicKey.Curve.Params().Name)
+	}
+}
@@ -1,12 +1,17 @@
 package auth
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"testing"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/crypto"
 )
 
 const (
@@ -58,3 +63,107 @@ func TestAuth_ParseUnverified(t *testing.T) {
 	asst.Nil(err, common.CombineMessageWithError("test ParseUnverified() failed", err))
 	t.Logf("%+v", u)
 }
+
+func TestAuth_Parse(t *testing.T) {
+	asst := assert.New(t)
+
+	r, err := crypto.NewRSA()
+	asst.Nil(err, common.CombineMessageWithError("test Parse() failed", err))
+	privateKey, err := r.GetPrivateKey()
+	asst.Nil(err, common.CombineMessageWithError("test Parse() failed", err))
+	a := NewAuth(common.StringToBytes(privateKey))
+
+	claims := jwt.MapClaims{
+		"username": "test",
+	}
+	token, err := a.SignWithMethodAndClaims(DefaultSignMethod, claims, nil)
+	asst.Nil(err, common.CombineMessageWithError("test Parse() failed", err))
+
+	u := &struct {
+		Username string `json:"username"`
+	}{}
+	err = a.Parse(token, &u)
+	asst.Nil(err, common.CombineMessageWithError("test Parse() failed", err))
+	asst.Equal("test", u.Username, "test Parse() failed")
+}
+
+func TestAuth_ParseWithKeyring(t *testing.T) {
+	asst := assert.New(t)
+
+	kr := crypto.NewKeyring()
+	err := kr.AddHexKey("hmac-1", crypto.KeyTypeHMAC, "000102030405060708090a0b0c0d0e0f")
+	asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+	a := NewAuthWithKeyring(kr)
+
+	claims := jwt.MapClaims{
+		"username": "test",
+	}
+	oldToken, err := a.SignWithMethodAndClaims(jwt.SigningMethodHS256, claims, nil)
+	asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+
+	// rotate the signing key
+	err = kr.AddHexKey("hmac-2", crypto.KeyTypeHMAC, "0f0e0d0c0b0a09080706050403020100")
+	asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+	err = kr.SetActive("hmac-2")
+	asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+	newToken, err := a.SignWithMethodAndClaims(jwt.SigningMethodHS256, claims, nil)
+	asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+
+	for _, token := range []string{oldToken, newToken} {
+		u := &struct {
+			Username string `json:"username"`
+		}{}
+		err = a.Parse(token, &u)
+		asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+		asst.Equal("test", u.Username, "test ParseWithKeyring() failed")
+	}
+
+	t.Log(newToken)
+	parsed, err := NewParserWithDefault().ParseUnverified(newToken)
+	asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+	asst.Equal("hmac-2", parsed.GetKeyID(), "test ParseWithKeyring() failed")
+
+	// the token signed by the removed key could not be verified
+	err = kr.RemoveKey("hmac-1")
+	asst.Nil(err, common.CombineMessageWithError("test ParseWithKeyring() failed", err))
+	err = a.Parse(oldToken, &struct{}{})
+	asst.NotNil(err, "test ParseWithKeyring() failed")
+}
+
+func TestAuth_SignWithKeyPair(t *testing.T) {
+	asst := assert.New(t)
+
+	r, err := crypto.NewRSA()
+	asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+	s, err := crypto.NewSM2()
+	asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+
+	rsaPrivateKey, err := r.GetPrivateKey()
+	asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+	rsaAuth := NewAuth(common.StringToBytes(rsaPrivateKey))
+
+	for _, privateKey := range []interface{}{ecdsaKey, ed25519Key, s.GetPrivateKey()} {
+		a, err := NewAuthWithKeyPair("kid-1", privateKey, nil)
+		asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+		token, err := a.SignWithClaims(jwt.MapClaims{"username": "test"}, nil)
+		asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+
+		u := &struct {
+			Username string `json:"username"`
+		}{}
+		err = a.Parse(token, u)
+		asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+		asst.Equal("test", u.Username, "test SignWithKeyPair() failed")
+
+		// the token signed by other key could not be verified
+		other, err := rsaAuth.SignWithMethodAndClaims(DefaultSignMethod, jwt.MapClaims{"username": "test"}, nil)
+		asst.Nil(err, common.CombineMessageWithError("test SignWithKeyPair() failed", err))
+		err = a.Parse(other, u)
+		asst.NotNil(err, "test SignWithKeyPair() failed")
+		t.Log(token)
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	ClaimIssuer     = "iss"
+	ClaimSubject    = "sub"
+	ClaimAudience   = "aud"
+	ClaimExpiresAt  = "exp"
+	ClaimNotBefore  = "nbf"
+	ClaimIssuedAt   = "iat"
+	ClaimJWTID      = "jti"
+	ClaimTokenType  = "token_type"
+	TokenTypeAccess = "access"
+	// TokenTypeRefresh is the token type of the refresh tokens, they could only be used to issue new token pairs
+	TokenTypeRefresh = "refresh"
+
+	DefaultLeeway = time.Minute
+)
+
+// ValidationPolicy validates the registered claims of the verified tokens,
+// the time based claims are compared with the current time with the leeway to tolerate the clock skew
+type ValidationPolicy struct {
+	// Issuer is the expected issuer, if it is empty, the issuer will not be checked
+	Issuer string
+	// Audience is the accepted audiences, the token must contain at least one of them, if it is empty, the audience will not be checked
+	Audience []string
+	// Leeway is the tolerated clock skew between the issuer and the verifier
+	Leeway time.Duration
+	// MaxAge is the maximum age of the token since it was issued, the iat claim is required if it is larger than 0
+	MaxAge time.Duration
+	// RequireExp means the exp claim is required
+	RequireExp bool
+	// RequireNbf means the nbf claim is required
+	RequireNbf bool
+	// Now returns the current time, it is used in tests
+	Now func() time.Time
+}
+
+// NewValidationPolicy returns a new *ValidationPolicy
+func NewValidationPolicy(issuer string, audience []string, leeway, maxAge time.Duration, requireExp, requireNbf bool) *ValidationPolicy {
+	return &ValidationPolicy{
+		Issuer:     issuer,
+		Audience:   audience,
+		Leeway:     leeway,
+		MaxAge:     maxAge,
+		RequireExp: requireExp,
+		RequireNbf: requireNbf,
+		Now:        time.Now,
+	}
+}
+
+// NewValidationPolicyWithDefault returns a new *ValidationPolicy which requires the exp claim with the default leeway
+func NewValidationPolicyWithDefault() *ValidationPolicy {
+	return NewValidationPolicy(constant.EmptyString, nil, DefaultLeeway, constant.ZeroInt, true, false)
+}
+
+// Validate validates the claims, the returned error wraps the errors of golang-jwt, for example, jwt.ErrTokenExpired,
+// so it could be checked by errors.Is()
+func (vp *ValidationPolicy) Validate(claims jwt.MapClaims) error {
+	now := time.Now()
+	if vp.Now != nil {
+		now = vp.Now()
+	}
+
+	// exp
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if exp == nil {
+		if vp.RequireExp {
+			return errors.Annotatef(jwt.ErrTokenRequiredClaimMissing, "claim is missing. claim: %s", ClaimExpiresAt)
+		}
+	} else if now.After(exp.Add(vp.Leeway)) {
+		return errors.Annotatef(jwt.ErrTokenExpired, "token expired at %s", exp.Format(time.RFC3339))
+	}
+	// nbf
+	nbf, err := claims.GetNotBefore()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if nbf == nil {
+		if vp.RequireNbf {
+			return errors.Annotatef(jwt.ErrTokenRequiredClaimMissing, "claim is missing. claim: %s", ClaimNotBefore)
+		}
+	} else if now.Add(vp.Leeway).Before(nbf.Time) {
+		return errors.Annotatef(jwt.ErrTokenNotValidYet, "token is not valid before %s", nbf.Format(time.RFC3339))
+	}
+	// iat
+	iat, err := claims.GetIssuedAt()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if iat == nil {
+		if vp.MaxAge > constant.ZeroInt {
+			return errors.Annotatef(jwt.ErrTokenRequiredClaimMissing, "claim is missing. claim: %s", ClaimIssuedAt)
+		}
+	} else {
+		if now.Add(vp.Leeway).Before(iat.Time) {
+			return errors.Annotatef(jwt.ErrTokenUsedBeforeIssued, "token is issued at %s", iat.Format(time.RFC3339))
+		}
+		if vp.MaxAge > constant.ZeroInt && now.Sub(iat.Time) > vp.MaxAge+vp.Leeway {
+			return errors.Annotatef(jwt.ErrTokenExpired, "token is too old. issued at: %s, max age: %s", iat.Format(time.RFC3339), vp.MaxAge)
+		}
+	}
+	// iss
+	if vp.Issuer != constant.EmptyString {
+		iss, err := claims.GetIssuer()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if iss != vp.Issuer {
+			return errors.Annotatef(jwt.ErrTokenInvalidIssuer, "expected: %s, actual: %s", vp.Issuer, iss)
+		}
+	}
+	// aud
+	if len(vp.Audience) > constant.ZeroInt {
+		aud, err := claims.GetAudience()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if !containsAudience(aud, vp.Audience) {
+			return errors.Annotatef(jwt.ErrTokenInvalidAudience, "expected one of: %v, actual: %v", vp.Audience, aud)
+		}
+	}
+
+	return nil
+}
+
+// containsAudience returns if the audience of the token contains any of the accepted audiences
+func containsAudience(aud jwt.ClaimStrings, accepted []string) bool {
+	for _, a := range aud {
+		for _, acc := range accepted {
+			if a == acc {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationPolicy_Validate(t *testing.T) {
+	asst := assert.New(t)
+
+	now := time.Now()
+	vp := NewValidationPolicy("issuer", []string{"api", "web"}, 30*time.Second, time.Hour, true, false)
+	vp.Now = func() time.Time { return now }
+
+	// the numeric claims of the parsed tokens are float64 or json.Number
+	claims := jwt.MapClaims{
+		ClaimIssuer:    "issuer",
+		ClaimAudience:  []string{"web"},
+		ClaimIssuedAt:  float64(now.Add(-time.Minute).Unix()),
+		ClaimNotBefore: float64(now.Add(-time.Minute).Unix()),
+		ClaimExpiresAt: float64(now.Add(time.Minute).Unix()),
+	}
+	asst.Nil(vp.Validate(claims), "test Validate() failed")
+
+	// within the leeway
+	claims[ClaimExpiresAt] = float64(now.Add(-10 * time.Second).Unix())
+	asst.Nil(vp.Validate(claims), "test Validate() failed")
+	claims[ClaimNotBefore] = float64(now.Add(10 * time.Second).Unix())
+	asst.Nil(vp.Validate(claims), "test Validate() failed")
+
+	testCases := []struct {
+		claim    string
+		value    interface{}
+		expected error
+	}{
+		{ClaimExpiresAt, float64(now.Add(-time.Minute).Unix()), jwt.ErrTokenExpired},
+		{ClaimExpiresAt, nil, jwt.ErrTokenRequiredClaimMissing},
+		{ClaimNotBefore, float64(now.Add(time.Minute).Unix()), jwt.ErrTokenNotValidYet},
+		{ClaimIssuedAt, float64(now.Add(-2 * time.Hour).Unix()), jwt.ErrTokenExpired},
+		{ClaimIssuedAt, nil, jwt.ErrTokenRequiredClaimMissing},
+		{ClaimIssuer, "other", jwt.ErrTokenInvalidIssuer},
+		{ClaimAudience, "other", jwt.ErrTokenInvalidAudience},
+	}
+	for _, tc := range testCases {
+		c := jwt.MapClaims{}
+		for k, v := range claims {
+			c[k] = v
+		}
+		if tc.value == nil {
+			delete(c, tc.claim)
+		} else {
+			c[tc.claim] = tc.value
+		}
+		err := vp.Validate(c)
+		asst.True(errors.Is(err, tc.expected), "test Validate() failed. claim: %s, error: %v", tc.claim, err)
+	}
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	// TokenCompressionDeflate is the raw deflate compression defined in RFC 7516
+	TokenCompressionDeflate = "DEF"
+	TokenCompressionGZIP    = tokenGZipType
+	TokenCompressionZSTD    = "ZSTD"
+	TokenCompressionSnappy  = "SNAPPY"
+
+	// DefaultMaxDecompressedSize is the default maximum size of the decompressed payload, it protects the parser from the decompression bombs
+	DefaultMaxDecompressedSize = 1024 * 1024
+)
+
+var (
+	compressors      = make(map[string]Compressor)
+	compressorsMutex sync.RWMutex
+)
+
+func init() {
+	RegisterCompressor(&deflateCompressor{})
+	RegisterCompressor(&gzipCompressor{})
+	RegisterCompressor(newZSTDCompressor())
+	RegisterCompressor(&snappyCompressor{})
+}
+
+// Compressor compresses the payload of the token, the name is used as the zip header of the token,
+// so that the parser could choose the compressor to decompress the payload automatically
+type Compressor interface {
+	// Name returns the value of the zip header
+	Name() string
+	// Compress compresses the data
+	Compress(data []byte) ([]byte, error)
+	// Decompress decompresses the data, it returns error if the decompressed data is larger than maxSize
+	Decompress(data []byte, maxSize int) ([]byte, error)
+}
+
+// RegisterCompressor registers the compressor, the compressor with the same name will be replaced
+func RegisterCompressor(c Compressor) {
+	compressorsMutex.Lock()
+	defer compressorsMutex.Unlock()
+
+	compressors[c.Name()] = c
+}
+
+// GetCompressor returns the registered compressor of given name
+func GetCompressor(name string) (Compressor, error) {
+	compressorsMutex.RLock()
+	defer compressorsMutex.RUnlock()
+
+	c, ok := compressors[name]
+	if !ok {
+		return nil, errors.Errorf("unsupported compression. zip: %s", name)
+	}
+
+	return c, nil
+}
+
+// NewCompressEncodeFunc returns a new EncodeFunc which compresses the payload with the registered compressor of given name,
+// the zip header of the token will be set to the name
+func NewCompressEncodeFunc(name string) (EncodeFunc, error) {
+	c, err := GetCompressor(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(token *Token, payload []byte) (string, error) {
+		token.Header[tokenZIPHeader] = c.Name()
+
+		compressed, err := c.Compress(payload)
+		if err != nil {
+			return constant.EmptyString, err
+		}
+
+		return base64.RawURLEncoding.EncodeToString(compressed), nil
+	}, nil
+}
+
+// NewDeflateEncodeFunc returns a new EncodeFunc with raw deflate compression, the zip header is DEF
+func NewDeflateEncodeFunc() EncodeFunc {
+	ef, _ := NewCompressEncodeFunc(TokenCompressionDeflate)
+
+	return ef
+}
+
+// NewZSTDEncodeFunc returns a new EncodeFunc with zstd compression, the zip header is ZSTD
+func NewZSTDEncodeFunc() EncodeFunc {
+	ef, _ := NewCompressEncodeFunc(TokenCompressionZSTD)
+
+	return ef
+}
+
+// NewSnappyEncodeFunc returns a new EncodeFunc with snappy compression, the zip header is SNAPPY
+func NewSnappyEncodeFunc() EncodeFunc {
+	ef, _ := NewCompressEncodeFunc(TokenCompressionSnappy)
+
+	return ef
+}
+
+// readAllWithLimit reads all the data from the reader, it returns error if the data is larger than maxSize
+func readAllWithLimit(r io.Reader, maxSize int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+constant.OneInt))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(data) > maxSize {
+		return nil, errors.Errorf("decompressed payload is too large. max size: %d", maxSize)
+	}
+
+	return data, nil
+}
+
+type deflateCompressor struct{}
+
+// Name returns the value of the zip header
+func (dc *deflateCompressor) Name() string {
+	return TokenCompressionDeflate
+}
+
+// Compress compresses the data
+func (dc *deflateCompressor) Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w, err := flate.NewWriter(&buffer, flate.BestCompression)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Decompress decompresses the data
+func (dc *deflateCompressor) Decompress(data []byte, maxSize int) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer func() { _ = r.Close() }()
+
+	return readAllWithLimit(r, maxSize)
+}
+
+type gzipCompressor struct{}
+
+// Name returns the value of the zip header
+func (gc *gzipCompressor) Name() string {
+	return TokenCompressionGZIP
+}
+
+// Compress compresses the data
+func (gc *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w := gzip.NewWriter(&buffer)
+	_, err := w.Write(data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Decompress decompresses the data
+func (gc *gzipCompressor) Decompress(data []byte, maxSize int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	return readAllWithLimit(r, maxSize)
+}
+
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+}
+
+// newZSTDCompressor returns a new *zstdCompressor, the encoder is shared as EncodeAll() is safe for concurrent use
+func newZSTDCompressor() *zstdCompressor {
+	encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+
+	return &zstdCompressor{
+		encoder: encoder,
+	}
+}
+
+// Name returns the value of the zip header
+func (zc *zstdCompressor) Name() string {
+	return TokenCompressionZSTD
+}
+
+// Compress compresses the data
+func (zc *zstdCompressor) Compress(data []byte) ([]byte, error) {
+	return zc.encoder.EncodeAll(data, nil), nil
+}
+
+// Decompress decompresses the data
+func (zc *zstdCompressor) Decompress(data []byte, maxSize int) ([]byte, error) {
+	decoder, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(constant.OneInt), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer decoder.Close()
+
+	return readAllWithLimit(decoder, maxSize)
+}
+
+type snappyCompressor struct{}
+
+// Name returns the value of the zip header
+func (sc *snappyCompressor) Name() string {
+	return TokenCompressionSnappy
+}
+
+// Compress compresses the data
+func (sc *snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+// Decompress decompresses the data
+func (sc *snappyCompressor) Decompress(data []byte, maxSize int) ([]byte, error) {
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if size > maxSize {
+		return nil, errors.Errorf("decompressed payload is too large. max size: %d", maxSize)
+	}
+
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return decoded, nil
+}
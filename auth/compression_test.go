@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/common"
+)
+
+func getTestPermissionClaims() jwt.MapClaims {
+	permissions := make([]string, 200)
+	for i := range permissions {
+		permissions[i] = fmt.Sprintf("resource:%d:read", i)
+	}
+
+	return jwt.MapClaims{
+		"username":    "test",
+		"permissions": permissions,
+	}
+}
+
+func TestCompression_EncodeFunc(t *testing.T) {
+	asst := assert.New(t)
+
+	a, err := NewAuthWithKeyPair("kid-1", mustGenerateTestEd25519Key(t), nil)
+	asst.Nil(err, common.CombineMessageWithError("test EncodeFunc failed", err))
+	claims := getTestPermissionClaims()
+
+	plain, err := a.SignWithClaims(claims, nil)
+	asst.Nil(err, common.CombineMessageWithError("test EncodeFunc failed", err))
+
+	for _, ef := range []EncodeFunc{NewDeflateEncodeFunc(), NewGZIPEncodeFunc(), NewZSTDEncodeFunc(), NewSnappyEncodeFunc()} {
+		token, err := a.SignWithClaims(claims, ef)
+		asst.Nil(err, common.CombineMessageWithError("test EncodeFunc failed", err))
+		asst.Less(len(token), len(plain), "test EncodeFunc failed")
+
+		u := &struct {
+			Username    string   `json:"username"`
+			Permissions []string `json:"permissions"`
+		}{}
+		err = a.Parse(token, u)
+		asst.Nil(err, common.CombineMessageWithError("test EncodeFunc failed", err))
+		asst.Equal("test", u.Username, "test EncodeFunc failed")
+		asst.Equal(200, len(u.Permissions), "test EncodeFunc failed")
+
+		parsed, err := NewParserWithDefault().ParseUnverified(token)
+		asst.Nil(err, common.CombineMessageWithError("test EncodeFunc failed", err))
+		t.Logf("zip: %s, plain size: %d, compressed size: %d", parsed.Header[tokenZIPHeader], len(plain), len(token))
+	}
+
+	_, err = NewCompressEncodeFunc("UNKNOWN")
+	asst.NotNil(err, "test EncodeFunc failed")
+}
+
+func TestCompression_MaxSize(t *testing.T) {
+	asst := assert.New(t)
+
+	a, err := NewAuthWithKeyPair("kid-1", mustGenerateTestEd25519Key(t), nil)
+	asst.Nil(err, common.CombineMessageWithError("test MaxSize failed", err))
+	a.SetMaxTokenSize(2048)
+	claims := getTestPermissionClaims()
+
+	// the plain token exceeds the size budget, the compressed one does not
+	_, err = a.SignWithClaims(claims, nil)
+	asst.NotNil(err, "test MaxSize failed")
+	token, err := a.SignWithClaims(claims, NewDeflateEncodeFunc())
+	asst.Nil(err, common.CombineMessageWithError("test MaxSize failed", err))
+
+	// the decompressed payload exceeds the limit of the parser
+	p := NewParserWithDefault()
+	p.SetMaxDecompressedSize(1024)
+	_, err = p.ParseUnverified(token)
+	asst.NotNil(err, "test MaxSize failed")
+
+	// the zip header is unknown
+	parts := strings.Split(token, ".")
+	tampered := strings.Replace(parts[0], parts[0], "eyJhbGciOiJFZERTQSIsInppcCI6IlhYWCJ9", 1) + "." + parts[1] + "." + parts[2]
+	_, err = NewParserWithDefault().ParseUnverified(tampered)
+	asst.NotNil(err, "test MaxSize failed")
+}
@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/tjfoc/gmsm/sm2"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/crypto"
+)
+
+const (
+	JWKKeyTypeRSA = "RSA"
+	JWKKeyTypeEC  = "EC"
+	JWKKeyTypeOKP = "OKP"
+
+	JWKCurveP256    = "P-256"
+	JWKCurveP384    = "P-384"
+	JWKCurveP521    = "P-521"
+	JWKCurveSM2     = "SM2"
+	JWKCurveEd25519 = "Ed25519"
+
+	JWKUseSignature = "sig"
+
+	DefaultJWKSRefreshInterval = 5 * time.Minute
+	// DefaultJWKSMinRefreshInterval is the minimum interval of fetching the jwks, no matter if the last fetch succeeded
+	DefaultJWKSMinRefreshInterval = 10 * time.Second
+
+	defaultJWKSContentType = "application/json"
+	defaultJWKSTimeout     = 10 * time.Second
+	maxJWKSSize            = 1 << 20
+)
+
+// KeySource returns the verification key of given kid
+type KeySource interface {
+	// GetKey returns the verification key of given kid
+	GetKey(kid string) (interface{}, error)
+}
+
+// JWK is the json web key of the public key as defined in RFC 7517,
+// the sm2 public keys use the EC key type with the SM2 curve
+type JWK struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid,omitempty"`
+	Use     string `json:"use,omitempty"`
+	Alg     string `json:"alg,omitempty"`
+	Curve   string `json:"crv,omitempty"`
+	N       string `json:"n,omitempty"`
+	E       string `json:"e,omitempty"`
+	X       string `json:"x,omitempty"`
+	Y       string `json:"y,omitempty"`
+}
+
+// NewJWK returns a new *JWK of the public key, the public key must be one of
+// *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey and *sm2.PublicKey
+func NewJWK(kid string, publicKey interface{}) (*JWK, error) {
+	method, err := GetSigningMethod(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := &JWK{
+		KeyID: kid,
+		Use:   JWKUseSignature,
+		Alg:   method.Alg(),
+	}
+
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = JWKKeyTypeRSA
+		jwk.N = encodeJWKBytes(pk.N.Bytes())
+		jwk.E = encodeJWKBytes(big.NewInt(int64(pk.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.KeyType = JWKKeyTypeEC
+		jwk.Curve = pk.Curve.Params().Name
+		size := (pk.Curve.Params().BitSize + 7) / 8
+		jwk.X = encodeJWKBytes(pk.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeJWKBytes(pk.Y.FillBytes(make([]byte, size)))
+	case *sm2.PublicKey:
+		jwk.KeyType = JWKKeyTypeEC
+		jwk.Curve = JWKCurveSM2
+		jwk.X = encodeJWKBytes(pk.X.FillBytes(make([]byte, sm2KeySize)))
+		jwk.Y = encodeJWKBytes(pk.Y.FillBytes(make([]byte, sm2KeySize)))
+	case ed25519.PublicKey:
+		jwk.KeyType = JWKKeyTypeOKP
+		jwk.Curve = JWKCurveEd25519
+		jwk.X = encodeJWKBytes(pk)
+	default:
+		return nil, errors.Errorf("only the public keys could be published, %T is not valid", publicKey)
+	}
+
+	return jwk, nil
+}
+
+// GetPublicKey returns the public key of the jwk
+func (j *JWK) GetPublicKey() (interface{}, error) {
+	switch j.KeyType {
+	case JWKKeyTypeRSA:
+		n, err := decodeJWKBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case JWKKeyTypeEC:
+		x, err := decodeJWKBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		var curve elliptic.Curve
+		switch j.Curve {
+		case JWKCurveP256:
+			curve = elliptic.P256()
+		case JWKCurveP384:
+			curve = elliptic.P384()
+		case JWKCurveP521:
+			curve = elliptic.P521()
+		case JWKCurveSM2:
+			curve = sm2.P256Sm2()
+			if !curve.IsOnCurve(x, y) {
+				return nil, errors.Errorf("invalid public key, (x, y) is not on the curve. kid: %s", j.KeyID)
+			}
+			return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+		default:
+			return nil, errors.Errorf("unsupported curve. kid: %s, crv: %s", j.KeyID, j.Curve)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.Errorf("invalid public key, (x, y) is not on the curve. kid: %s", j.KeyID)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case JWKKeyTypeOKP:
+		if j.Curve != JWKCurveEd25519 {
+			return nil, errors.Errorf("unsupported curve. kid: %s, crv: %s", j.KeyID, j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("invalid ed25519 public key size. kid: %s, size: %d", j.KeyID, len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Errorf("unsupported key type. kid: %s, kty: %s", j.KeyID, j.KeyType)
+	}
+}
+
+// JWKS is the json web key set, it could be served by http as the jwks endpoint
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// NewJWKS returns a new *JWKS
+func NewJWKS(keys ...*JWK) *JWKS {
+	return &JWKS{
+		Keys: keys,
+	}
+}
+
+// NewJWKSWithJSON returns a new *JWKS with given json data
+func NewJWKSWithJSON(data []byte) (*JWKS, error) {
+	jwks := &JWKS{}
+	err := json.Unmarshal(data, jwks)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return jwks, nil
+}
+
+// NewJWKSWithKeyring returns a new *JWKS which contains the public keys of the asymmetric keys of the keyring,
+// the symmetric keys are never published
+func NewJWKSWithKeyring(keyring *crypto.Keyring) (*JWKS, error) {
+	jwks := NewJWKS()
+	for _, kid := range keyring.GetKeyIDs() {
+		key, err := keyring.GetKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.PublicKey == nil {
+			continue
+		}
+		jwk, err := NewJWK(kid, key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+// GetKey returns the public key of given kid, it implements KeySource interface
+func (j *JWKS) GetKey(kid string) (interface{}, error) {
+	for _, jwk := range j.Keys {
+		if jwk.KeyID == kid {
+			return jwk.GetPublicKey()
+		}
+	}
+
+	return nil, errors.Errorf("could not find the key in the jwks. kid: %s", kid)
+}
+
+// ServeHTTP writes the json of the jwks, so that the jwks could be served locally as the jwks endpoint
+func (j *JWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(j)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", defaultJWKSContentType)
+	_, _ = w.Write(data)
+}
+
+// RemoteJWKS fetches the jwks from the remote endpoint and caches it,
+// the jwks will be refreshed if it is expired or the kid is unknown, so the rotated keys could be found,
+// the fetches are at least minRefreshInterval apart, even if they failed, and the cached jwks is used while fetching
+// or when the endpoint is unavailable
+type RemoteJWKS struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	// fetchMutex serializes the fetches, it is not held when reading the cache
+	fetchMutex  sync.Mutex
+	mutex       sync.Mutex
+	jwks        *JWKS
+	lastRefresh time.Time
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// NewRemoteJWKS returns a new *RemoteJWKS
+func NewRemoteJWKS(url string, client *http.Client, refreshInterval, minRefreshInterval time.Duration) *RemoteJWKS {
+	return &RemoteJWKS{
+		url:                url,
+		client:             client,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+	}
+}
+
+// NewRemoteJWKSWithDefault returns a new *RemoteJWKS with default options
+func NewRemoteJWKSWithDefault(url string) *RemoteJWKS {
+	return NewRemoteJWKS(url, &http.Client{Timeout: defaultJWKSTimeout}, DefaultJWKSRefreshInterval, DefaultJWKSMinRefreshInterval)
+}
+
+// GetKey returns the public key of given kid, it implements KeySource interface
+func (rj *RemoteJWKS) GetKey(kid string) (interface{}, error) {
+	rj.mutex.Lock()
+	jwks := rj.jwks
+	expired := jwks == nil || time.Since(rj.lastRefresh) > rj.refreshInterval
+	rj.mutex.Unlock()
+
+	if expired {
+		var err error
+		// if the endpoint is unavailable, keep using the cached jwks
+		jwks, err = rj.refresh()
+		if jwks == nil {
+			return nil, err
+		}
+	}
+
+	key, err := jwks.GetKey(kid)
+	if err == nil {
+		return key, nil
+	}
+	// the key may be rotated, refresh the jwks and try again
+	refreshed, refreshErr := rj.refresh()
+	if refreshed == nil || refreshed == jwks {
+		// the jwks is not refreshed
+		if refreshErr != nil {
+			return nil, errors.Annotatef(err, "refresh jwks failed. error: %s", refreshErr.Error())
+		}
+		return nil, err
+	}
+
+	return refreshed.GetKey(kid)
+}
+
+// refresh fetches the jwks from the remote endpoint and returns the latest jwks,
+// it does not fetch if the last attempt is within the minimum refresh interval,
+// or another fetch is in progress and there is a cached jwks,
+// if the fetch fails, the cached jwks is returned with the error
+func (rj *RemoteJWKS) refresh() (*JWKS, error) {
+	if !rj.fetchMutex.TryLock() {
+		rj.mutex.Lock()
+		jwks := rj.jwks
+		rj.mutex.Unlock()
+		if jwks != nil {
+			return jwks, nil
+		}
+		// there is no cached jwks, wait for the fetch
+		rj.fetchMutex.Lock()
+	}
+	defer rj.fetchMutex.Unlock()
+
+	rj.mutex.Lock()
+	if !rj.lastAttempt.IsZero() && time.Since(rj.lastAttempt) < rj.minRefreshInterval {
+		jwks, err := rj.jwks, rj.lastErr
+		rj.mutex.Unlock()
+		return jwks, err
+	}
+	rj.lastAttempt = time.Now()
+	rj.mutex.Unlock()
+
+	jwks, err := rj.fetch()
+
+	rj.mutex.Lock()
+	defer rj.mutex.Unlock()
+
+	rj.lastErr = err
+	if err != nil {
+		return rj.jwks, err
+	}
+	rj.jwks = jwks
+	rj.lastRefresh = time.Now()
+
+	return jwks, nil
+}
+
+// fetch fetches the jwks from the remote endpoint
+func (rj *RemoteJWKS) fetch() (*JWKS, error) {
+	resp, err := rj.client.Get(rj.url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetch jwks failed. url: %s, status code: %d", rj.url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return NewJWKSWithJSON(data)
+}
+
+// encodeJWKBytes encodes the bytes with base64url without padding
+func encodeJWKBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeJWKBigInt decodes the base64url string to big int
+func decodeJWKBigInt(s string) (*big.Int, error) {
+	if s == constant.EmptyString {
+		return nil, errors.New("jwk parameter should not be empty")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
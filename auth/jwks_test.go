@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/crypto"
+)
+
+func generateTestKeyring(t *testing.T) *crypto.Keyring {
+	asst := assert.New(t)
+
+	kr := crypto.NewKeyring()
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	asst.Nil(err, common.CombineMessageWithError("generate test keyring failed", err))
+	key, err := crypto.NewAsymmetricKey("ecdsa-1", ecdsaKey, nil)
+	asst.Nil(err, common.CombineMessageWithError("generate test keyring failed", err))
+	err = kr.AddKey(key)
+	asst.Nil(err, common.CombineMessageWithError("generate test keyring failed", err))
+
+	s, err := crypto.NewSM2()
+	asst.Nil(err, common.CombineMessageWithError("generate test keyring failed", err))
+	key, err = crypto.NewAsymmetricKey("sm2-1", s.GetPrivateKey(), nil)
+	asst.Nil(err, common.CombineMessageWithError("generate test keyring failed", err))
+	err = kr.AddKey(key)
+	asst.Nil(err, common.CombineMessageWithError("generate test keyring failed", err))
+	// the hmac key will not be published
+	err = kr.AddHexKey("hmac-1", crypto.KeyTypeHMAC, "000102030405060708090a0b0c0d0e0f")
+	asst.Nil(err, common.CombineMessageWithError("generate test keyring failed", err))
+
+	return kr
+}
+
+func mustGenerateTestEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key failed. error: %s", err.Error())
+	}
+
+	return privateKey
+}
+
+func TestJWKS_All(t *testing.T) {
+	TestJWKS_NewJWK(t)
+	TestJWKS_RemoteJWKS(t)
+	TestJWKS_RemoteJWKSUnavailable(t)
+}
+
+func TestJWKS_NewJWK(t *testing.T) {
+	asst := assert.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+	ed25519PublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+	s, err := crypto.NewSM2()
+	asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+
+	publicKeys := []interface{}{&rsaKey.PublicKey, &ecdsaKey.PublicKey, ed25519PublicKey, s.GetPublicKey()}
+	algs := []string{"RS256", "ES384", "EdDSA", SigningMethodSM2SM3Alg}
+	for i, publicKey := range publicKeys {
+		jwk, err := NewJWK("kid", publicKey)
+		asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+		asst.Equal(algs[i], jwk.Alg, "test NewJWK() failed")
+
+		data, err := json.Marshal(NewJWKS(jwk))
+		asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+		jwks, err := NewJWKSWithJSON(data)
+		asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+		key, err := jwks.GetKey("kid")
+		asst.Nil(err, common.CombineMessageWithError("test NewJWK() failed", err))
+		asst.Equal(publicKey, key, "test NewJWK() failed")
+	}
+
+	_, err = NewJWK("kid", []byte("secret"))
+	asst.NotNil(err, "test NewJWK() failed")
+}
+
+func TestJWKS_RemoteJWKS(t *testing.T) {
+	asst := assert.New(t)
+
+	kr := generateTestKeyring(t)
+	jwks, err := NewJWKSWithKeyring(kr)
+	asst.Nil(err, common.CombineMessageWithError("test RemoteJWKS() failed", err))
+	asst.Equal(2, len(jwks.Keys), "test RemoteJWKS() failed")
+
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	issuer := NewAuthWithKeyring(kr)
+	verifier := NewAuthWithKeySource(NewRemoteJWKSWithDefault(server.URL))
+
+	for _, kid := range []string{"ecdsa-1", "sm2-1"} {
+		err = kr.SetActive(kid)
+		asst.Nil(err, common.CombineMessageWithError("test RemoteJWKS() failed", err))
+		token, err := issuer.SignWithClaims(jwt.MapClaims{"username": "test"}, nil)
+		asst.Nil(err, common.CombineMessageWithError("test RemoteJWKS() failed", err))
+
+		u := &struct {
+			Username string `json:"username"`
+		}{}
+		err = verifier.Parse(token, u)
+		asst.Nil(err, common.CombineMessageWithError("test RemoteJWKS() failed", err))
+		asst.Equal("test", u.Username, "test RemoteJWKS() failed")
+	}
+
+	// the symmetric key is not published, so the token could not be verified by the jwks
+	err = kr.SetActive("hmac-1")
+	asst.Nil(err, common.CombineMessageWithError("test RemoteJWKS() failed", err))
+	token, err := issuer.SignWithClaims(jwt.MapClaims{"username": "test"}, nil)
+	asst.Nil(err, common.CombineMessageWithError("test RemoteJWKS() failed", err))
+	err = verifier.Parse(token, &struct{}{})
+	asst.NotNil(err, "test RemoteJWKS() failed")
+}
+
+func TestJWKS_RemoteJWKSUnavailable(t *testing.T) {
+	asst := assert.New(t)
+
+	kr := generateTestKeyring(t)
+	jwks, err := NewJWKSWithKeyring(kr)
+	asst.Nil(err, common.CombineMessageWithError("test RemoteJWKSUnavailable() failed", err))
+
+	// the endpoint serves the jwks only once, and fails after that
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		jwks.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	rj := NewRemoteJWKS(server.URL, server.Client(), time.Millisecond, 200*time.Millisecond)
+	_, err = rj.GetKey("ecdsa-1")
+	asst.Nil(err, common.CombineMessageWithError("test RemoteJWKSUnavailable() failed", err))
+
+	time.Sleep(300 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		// the jwks is expired and the fetch fails, but the cached jwks is still used
+		key, err := rj.GetKey("ecdsa-1")
+		asst.Nil(err, common.CombineMessageWithError("test RemoteJWKSUnavailable() failed", err))
+		asst.NotNil(key, "test RemoteJWKSUnavailable() failed")
+		// the failed fetch is also throttled by the minimum refresh interval
+		_, err = rj.GetKey("unknown")
+		asst.NotNil(err, "test RemoteJWKSUnavailable() failed")
+	}
+	asst.Equal(int32(2), atomic.LoadInt32(&requests), "test RemoteJWKSUnavailable() failed")
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+
+	jwtIDSize = 16
+)
+
+// TokenPair is the access token and the refresh token,
+// the access token is short-lived and used to access the resources,
+// the refresh token is long-lived and only used to issue a new token pair when the access token expires
+type TokenPair struct {
+	AccessToken           string    `json:"accessToken"`
+	RefreshToken          string    `json:"refreshToken"`
+	AccessTokenExpiresAt  time.Time `json:"accessTokenExpiresAt"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+}
+
+// IssueTokenPair issues a new token pair with given claims, the registered time claims, the jti and the token type are set automatically,
+// the tokens are signed by the signing key, if the signing key is not set, the secret key and the default sign method will be used
+func (a *Auth) IssueTokenPair(claims jwt.MapClaims, accessTTL, refreshTTL time.Duration) (*TokenPair, error) {
+	now := time.Now()
+	accessClaims, accessExp, err := a.getTokenClaims(claims, TokenTypeAccess, now, accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshClaims, refreshExp, err := a.getTokenClaims(claims, TokenTypeRefresh, now, refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := a.sign(accessClaims)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := a.sign(refreshClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:           accessToken,
+		RefreshToken:          refreshToken,
+		AccessTokenExpiresAt:  accessExp,
+		RefreshTokenExpiresAt: refreshExp,
+	}, nil
+}
+
+// IssueTokenPairWithDefault issues a new token pair with default ttl
+func (a *Auth) IssueTokenPairWithDefault(claims jwt.MapClaims) (*TokenPair, error) {
+	return a.IssueTokenPair(claims, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
+}
+
+// RefreshTokenPair verifies the refresh token and issues a new token pair with the claims of it,
+// if the revocation store is set, the refresh token will be revoked, so that each refresh token could only be used once,
+// revoking is atomic, so only one of the concurrent refreshes with the same refresh token succeeds
+func (a *Auth) RefreshTokenPair(ctx context.Context, refreshToken string, accessTTL, refreshTTL time.Duration) (*TokenPair, error) {
+	token, err := a.verify(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if token.Claims[ClaimTokenType] != TokenTypeRefresh {
+		return nil, errors.New("token is not a refresh token")
+	}
+
+	if a.revocationStore != nil {
+		err = a.revokeToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	claims := jwt.MapClaims{}
+	for k, v := range token.Claims {
+		switch k {
+		case ClaimJWTID, ClaimIssuedAt, ClaimNotBefore, ClaimExpiresAt, ClaimTokenType:
+			continue
+		default:
+			claims[k] = v
+		}
+	}
+
+	return a.IssueTokenPair(claims, accessTTL, refreshTTL)
+}
+
+// Revoke verifies the token and revokes it until it expires, the revocation store must be set,
+// for example, revoke both the access token and the refresh token when the user logs out
+func (a *Auth) Revoke(ctx context.Context, tokenString string) error {
+	if a.revocationStore == nil {
+		return errors.New("revocation store should not be nil")
+	}
+
+	token, err := a.verify(ctx, tokenString)
+	if err != nil {
+		return err
+	}
+
+	return a.revokeToken(ctx, token)
+}
+
+// revokeToken revokes the verified token, the token must have the jti and exp claims
+func (a *Auth) revokeToken(ctx context.Context, token *Token) error {
+	jti, ok := token.Claims[ClaimJWTID].(string)
+	if !ok || jti == constant.EmptyString {
+		return errors.Errorf("token could not be revoked without the claim. claim: %s", ClaimJWTID)
+	}
+	exp, err := token.Claims.GetExpirationTime()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if exp == nil {
+		return errors.Errorf("token could not be revoked without the claim. claim: %s", ClaimExpiresAt)
+	}
+
+	return a.revocationStore.Revoke(ctx, jti, exp.Time)
+}
+
+// getTokenClaims returns a copy of the claims with the jti, iat, nbf, exp and token type claims
+func (a *Auth) getTokenClaims(claims jwt.MapClaims, tokenType string, now time.Time, ttl time.Duration) (jwt.MapClaims, time.Time, error) {
+	jti, err := newJWTID()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	exp := now.Add(ttl)
+	tokenClaims := jwt.MapClaims{}
+	for k, v := range claims {
+		tokenClaims[k] = v
+	}
+	tokenClaims[ClaimJWTID] = jti
+	tokenClaims[ClaimIssuedAt] = now.Unix()
+	tokenClaims[ClaimNotBefore] = now.Unix()
+	tokenClaims[ClaimExpiresAt] = exp.Unix()
+	tokenClaims[ClaimTokenType] = tokenType
+
+	return tokenClaims, time.Unix(exp.Unix(), constant.ZeroInt), nil
+}
+
+// sign signs the claims with the signing key, if the signing key is not set, the secret key and the default sign method will be used
+func (a *Auth) sign(claims jwt.MapClaims) (string, error) {
+	_, key, err := a.getSigningKey()
+	if err != nil {
+		return constant.EmptyString, err
+	}
+	if key == nil {
+		return a.SignWithMethodAndClaims(DefaultSignMethod, claims, nil)
+	}
+
+	return a.SignWithClaims(claims, nil)
+}
+
+// newJWTID returns a new random token id
+func newJWTID() (string, error) {
+	b := make([]byte, jwtIDSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/crypto"
+)
+
+func TestAuth_TokenPair(t *testing.T) {
+	asst := assert.New(t)
+
+	ctx := context.Background()
+	s, err := crypto.NewSM2()
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+	a, err := NewAuthWithKeyPair("sm2-1", s.GetPrivateKey(), nil)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+	a.SetValidationPolicy(NewValidationPolicy("issuer", []string{"api"}, DefaultLeeway, 24*time.Hour, true, true))
+	a.SetRevocationStore(NewMemoryRevocationStore())
+
+	pair, err := a.IssueTokenPair(jwt.MapClaims{ClaimIssuer: "issuer", ClaimAudience: "api", "username": "test"}, time.Minute, time.Hour)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+
+	u := &struct {
+		Username string `json:"username"`
+	}{}
+	err = a.ParseWithContext(ctx, pair.AccessToken, u)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+	asst.Equal("test", u.Username, "test TokenPair() failed")
+	// the refresh token could not be used as the access token
+	err = a.ParseWithContext(ctx, pair.RefreshToken, u)
+	asst.NotNil(err, "test TokenPair() failed")
+	// the access token could not be used to refresh
+	_, err = a.RefreshTokenPair(ctx, pair.AccessToken, time.Minute, time.Hour)
+	asst.NotNil(err, "test TokenPair() failed")
+
+	newPair, err := a.RefreshTokenPair(ctx, pair.RefreshToken, time.Minute, time.Hour)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+	u.Username = ""
+	err = a.ParseWithContext(ctx, newPair.AccessToken, u)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+	asst.Equal("test", u.Username, "test TokenPair() failed")
+	// the refresh token could only be used once
+	_, err = a.RefreshTokenPair(ctx, pair.RefreshToken, time.Minute, time.Hour)
+	asst.NotNil(err, "test TokenPair() failed")
+
+	// logout
+	err = a.Revoke(ctx, newPair.AccessToken)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+	err = a.Revoke(ctx, newPair.RefreshToken)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+	err = a.ParseWithContext(ctx, newPair.AccessToken, u)
+	asst.NotNil(err, "test TokenPair() failed")
+	_, err = a.RefreshTokenPair(ctx, newPair.RefreshToken, time.Minute, time.Hour)
+	asst.NotNil(err, "test TokenPair() failed")
+	// the old access token is still valid, as it is not revoked
+	err = a.ParseWithContext(ctx, pair.AccessToken, u)
+	asst.Nil(err, common.CombineMessageWithError("test TokenPair() failed", err))
+}
+
+func TestAuth_RefreshTokenPairConcurrently(t *testing.T) {
+	asst := assert.New(t)
+
+	ctx := context.Background()
+	s, err := crypto.NewSM2()
+	asst.Nil(err, common.CombineMessageWithError("test RefreshTokenPair() failed", err))
+	a, err := NewAuthWithKeyPair("sm2-1", s.GetPrivateKey(), nil)
+	asst.Nil(err, common.CombineMessageWithError("test RefreshTokenPair() failed", err))
+	a.SetRevocationStore(NewMemoryRevocationStore())
+
+	pair, err := a.IssueTokenPairWithDefault(jwt.MapClaims{"username": "test"})
+	asst.Nil(err, common.CombineMessageWithError("test RefreshTokenPair() failed", err))
+
+	const concurrency = 10
+	var (
+		wg        sync.WaitGroup
+		succeeded int32
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := a.RefreshTokenPair(ctx, pair.RefreshToken, time.Minute, time.Hour)
+			if err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	// the refresh token could only be used once
+	asst.Equal(int32(1), succeeded, "test RefreshTokenPair() failed")
+}
@@ -2,7 +2,6 @@ package auth
 
 import (
 	"bytes"
-	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
 	"strings"
@@ -19,28 +18,51 @@ const (
 )
 
 type Parser struct {
-	useJSONNumber bool
+	useJSONNumber       bool
+	maxDecompressedSize int
 }
 
 // NewParser returns a new *Parser
 func NewParser(useJSONNumber bool) *Parser {
 	return &Parser{
-		useJSONNumber: useJSONNumber,
+		useJSONNumber:       useJSONNumber,
+		maxDecompressedSize: DefaultMaxDecompressedSize,
 	}
 }
 
+// SetMaxDecompressedSize sets the maximum size of the decompressed payload
+func (p *Parser) SetMaxDecompressedSize(size int) {
+	p.maxDecompressedSize = size
+}
+
 // NewParserWithDefault returns a new *Parser with default options
 func NewParserWithDefault() *Parser {
 	return NewParser(true)
 }
 
+// KeyFunc returns the key which is used to verify the signature of the token,
+// it receives the parsed but unverified token, so the key could be chosen by the headers, for example, the kid header
+type KeyFunc func(token *Token) (interface{}, error)
+
 // Parse parses the token string and verifies the signature
 func (p *Parser) Parse(tokenString string, key []byte) (*Token, error) {
+	return p.ParseWithKeyFunc(tokenString, func(token *Token) (interface{}, error) {
+		return key, nil
+	})
+}
+
+// ParseWithKeyFunc parses the token string and verifies the signature with the key returned by the key function
+func (p *Parser) ParseWithKeyFunc(tokenString string, keyFunc KeyFunc) (*Token, error) {
 	token, err := p.ParseUnverified(tokenString)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
+	key, err := keyFunc(token)
+	if err != nil {
+		return token, err
+	}
+
 	decoded, err := base64.RawURLEncoding.DecodeString(common.BytesToString(token.Signature))
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -86,48 +108,24 @@ func (p *Parser) ParseUnverified(tokenString string) (*Token, error) {
 		return nil, errors.Errorf("could not decode token header, tokenString: %s", tokenString)
 	}
 	// payload
-	var payloadBytes []byte
-	jwtType, ok := token.Header[tokenTypeHeader]
+	typ, ok := token.Header[tokenTypeHeader]
+	if ok && typ != tokenJWTType {
+		return nil, errors.Errorf("unsupported token type. key: %s, value: %s", tokenTypeHeader, typ)
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[constant.OneInt])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// decompress the payload by the zip header
+	zip, ok := token.Header[tokenZIPHeader]
 	if ok {
-		if jwtType != tokenJWTType {
-			return nil, errors.Errorf("unsupported token type. key: %s, value: %s", tokenTypeHeader, jwtType)
-		}
-
-		payloadBytes, err = base64.RawURLEncoding.DecodeString(parts[constant.OneInt])
+		c, err := GetCompressor(zip)
 		if err != nil {
-			return nil, errors.Trace(err)
+			return nil, err
 		}
-	} else {
-		gzipType, ok := token.Header[tokenZIPHeader]
-		if ok {
-			if gzipType != tokenGZipType {
-				return nil, errors.Errorf("unsupported token type. key: %s, value: %s", tokenZIPHeader, gzipType)
-			}
-
-			decoded, err := base64.RawURLEncoding.DecodeString(parts[constant.OneInt])
-			if err != nil {
-				return nil, errors.Trace(err)
-			}
-
-			r, err := gzip.NewReader(bytes.NewReader(decoded))
-			if err != nil {
-				return nil, errors.Trace(err)
-			}
-
-			err = r.Close()
-			if err != nil {
-				return nil, errors.Trace(err)
-			}
-
-			var buffer bytes.Buffer
-			_, err = buffer.ReadFrom(r)
-			if err != nil {
-				return nil, errors.Trace(err)
-			}
-
-			payloadBytes = buffer.Bytes()
-		} else {
-			return nil, errors.Errorf("unsupported token type. header: %s", token.Header)
+		payloadBytes, err = c.Decompress(payloadBytes, p.maxDecompressedSize)
+		if err != nil {
+			return nil, err
 		}
 	}
 
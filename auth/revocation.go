@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware/etcd"
+)
+
+const (
+	DefaultEtcdRevocationPrefix = "/auth/revoked/"
+
+	defaultRevokedValue = "1"
+	// defaultPurgeInterval is the interval of purging the expired revocations of the memory store
+	defaultPurgeInterval = time.Minute
+)
+
+// RevocationStore stores the ids (the jti claims) of the revoked tokens until they expire,
+// so that the revoked tokens, for example, the tokens of the logged out users, could be rejected before they expire
+type RevocationStore interface {
+	// Revoke revokes the token of given id, the revocation could be dropped after expiresAt,
+	// checking and revoking must be atomic, if the token has already been revoked, it returns error
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsRevoked returns if the token of given id is revoked
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// MemoryRevocationStore stores the revocations in memory, it is only valid in a single process
+type MemoryRevocationStore struct {
+	mutex     sync.RWMutex
+	revoked   map[string]time.Time
+	lastPurge time.Time
+}
+
+// NewMemoryRevocationStore returns a new *MemoryRevocationStore
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked:   make(map[string]time.Time),
+		lastPurge: time.Now(),
+	}
+}
+
+// Revoke revokes the token of given id, if the token has already been revoked, it returns error,
+// it implements RevocationStore interface
+func (mrs *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == constant.EmptyString {
+		return errors.New("jti should not be empty")
+	}
+
+	mrs.mutex.Lock()
+	defer mrs.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(mrs.lastPurge) > defaultPurgeInterval {
+		for id, exp := range mrs.revoked {
+			if now.After(exp) {
+				delete(mrs.revoked, id)
+			}
+		}
+		mrs.lastPurge = now
+	}
+
+	exp, ok := mrs.revoked[jti]
+	if ok && !now.After(exp) {
+		return errors.Errorf("token has already been revoked. jti: %s", jti)
+	}
+	mrs.revoked[jti] = expiresAt
+
+	return nil
+}
+
+// IsRevoked returns if the token of given id is revoked, it implements RevocationStore interface
+func (mrs *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	mrs.mutex.RLock()
+	defer mrs.mutex.RUnlock()
+
+	exp, ok := mrs.revoked[jti]
+	if !ok {
+		return false, nil
+	}
+
+	return !time.Now().After(exp), nil
+}
+
+// EtcdRevocationStore stores the revocations in etcd with ttl keys, so that the revocations are shared by all the processes,
+// and they are removed by etcd automatically after the tokens expire
+type EtcdRevocationStore struct {
+	conn   *etcd.Conn
+	prefix string
+}
+
+// NewEtcdRevocationStore returns a new *EtcdRevocationStore
+func NewEtcdRevocationStore(conn *etcd.Conn, prefix string) *EtcdRevocationStore {
+	return &EtcdRevocationStore{
+		conn:   conn,
+		prefix: prefix,
+	}
+}
+
+// NewEtcdRevocationStoreWithDefault returns a new *EtcdRevocationStore with default prefix
+func NewEtcdRevocationStoreWithDefault(conn *etcd.Conn) *EtcdRevocationStore {
+	return NewEtcdRevocationStore(conn, DefaultEtcdRevocationPrefix)
+}
+
+// Revoke revokes the token of given id, the key will be removed by etcd after the token expires,
+// the key is only created if it does not exist, so if the token has already been revoked, it returns error,
+// it implements RevocationStore interface
+func (ers *EtcdRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == constant.EmptyString {
+		return errors.New("jti should not be empty")
+	}
+
+	ttl := int64(math.Ceil(time.Until(expiresAt).Seconds()))
+	if ttl < etcd.MinimumTTL {
+		// the token has already expired
+		return nil
+	}
+
+	// a lease is granted for each key, as the keys expire at different time
+	leaseResp, err := ers.conn.Grant(ctx, ttl)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	key := ers.getKey(jti)
+	txnResp, err := ers.conn.Client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", etcd.ZeroRevision)).
+		Then(clientv3.OpPut(key, defaultRevokedValue, clientv3.WithLease(leaseResp.ID))).
+		Commit()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if !txnResp.Succeeded {
+		// the lease is not attached to any key, release it
+		_, _ = ers.conn.Client.Revoke(ctx, leaseResp.ID)
+		return errors.Errorf("token has already been revoked. jti: %s", jti)
+	}
+
+	return nil
+}
+
+// IsRevoked returns if the token of given id is revoked, it implements RevocationStore interface
+func (ers *EtcdRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	resp, err := ers.conn.Client.Get(ctx, ers.getKey(jti), clientv3.WithCountOnly())
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	return resp.Count > constant.ZeroInt, nil
+}
+
+// getKey returns the etcd key of given token id
+func (ers *EtcdRevocationStore) getKey(jti string) string {
+	return ers.prefix + jti
+}
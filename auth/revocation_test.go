@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/middleware/etcd"
+)
+
+func testRevocationStore(t *testing.T, store RevocationStore) {
+	asst := assert.New(t)
+
+	ctx := context.Background()
+	jti, err := newJWTID()
+	asst.Nil(err, common.CombineMessageWithError("test RevocationStore failed", err))
+
+	revoked, err := store.IsRevoked(ctx, jti)
+	asst.Nil(err, common.CombineMessageWithError("test RevocationStore failed", err))
+	asst.False(revoked, "test RevocationStore failed")
+
+	err = store.Revoke(ctx, jti, time.Now().Add(2*time.Second))
+	asst.Nil(err, common.CombineMessageWithError("test RevocationStore failed", err))
+	revoked, err = store.IsRevoked(ctx, jti)
+	asst.Nil(err, common.CombineMessageWithError("test RevocationStore failed", err))
+	asst.True(revoked, "test RevocationStore failed")
+	// the token could only be revoked once
+	err = store.Revoke(ctx, jti, time.Now().Add(2*time.Second))
+	asst.NotNil(err, "test RevocationStore failed")
+
+	// the revocation expires with the token
+	time.Sleep(3 * time.Second)
+	revoked, err = store.IsRevoked(ctx, jti)
+	asst.Nil(err, common.CombineMessageWithError("test RevocationStore failed", err))
+	asst.False(revoked, "test RevocationStore failed")
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	testRevocationStore(t, NewMemoryRevocationStore())
+}
+
+func TestEtcdRevocationStore(t *testing.T) {
+	asst := assert.New(t)
+
+	endpoints := []string{"192.168.137.11:2379"}
+	conn, err := etcd.NewEtcdConnWithConnectTimeout(endpoints, 3*time.Second)
+	asst.Nil(err, common.CombineMessageWithError("test EtcdRevocationStore failed", err))
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = conn.Close()
+		asst.Nil(err, common.CombineMessageWithError("test EtcdRevocationStore failed", err))
+	}()
+
+	testRevocationStore(t, NewEtcdRevocationStoreWithDefault(conn))
+}
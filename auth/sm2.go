@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pingcap/errors"
+	"github.com/tjfoc/gmsm/sm2"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/crypto"
+)
+
+const (
+	SigningMethodSM2SM3Alg = "SM2-SM3"
+
+	sm2KeySize = 32
+)
+
+var (
+	// SigningMethodSM2SM3 signs the token with sm2 and sm3 digest as defined in GM/T 0003,
+	// the default user id is used, the signature is r and s in big endian, each of which is 32 bytes,
+	// the same as the ecdsa signing methods of the jws
+	SigningMethodSM2SM3 = &SigningMethodSM2{}
+)
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodSM2SM3Alg, func() jwt.SigningMethod {
+		return SigningMethodSM2SM3
+	})
+}
+
+type SigningMethodSM2 struct{}
+
+// Alg returns the name of the signing method
+func (m *SigningMethodSM2) Alg() string {
+	return SigningMethodSM2SM3Alg
+}
+
+// Sign signs the signing string with *sm2.PrivateKey
+func (m *SigningMethodSM2) Sign(signingString string, key interface{}) ([]byte, error) {
+	privateKey, ok := key.(*sm2.PrivateKey)
+	if !ok {
+		return nil, errors.Trace(jwt.ErrInvalidKeyType)
+	}
+
+	r, s, err := sm2.Sm2Sign(privateKey, common.StringToBytes(signingString), common.StringToBytes(crypto.DefaultSM2UserID), rand.Reader)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	sig := make([]byte, sm2KeySize*2)
+	r.FillBytes(sig[:sm2KeySize])
+	s.FillBytes(sig[sm2KeySize:])
+
+	return sig, nil
+}
+
+// Verify verifies the signature of the signing string with *sm2.PublicKey
+func (m *SigningMethodSM2) Verify(signingString string, sig []byte, key interface{}) error {
+	publicKey, ok := key.(*sm2.PublicKey)
+	if !ok {
+		return errors.Trace(jwt.ErrInvalidKeyType)
+	}
+	if len(sig) != sm2KeySize*2 {
+		return errors.Trace(jwt.ErrSignatureInvalid)
+	}
+
+	r := new(big.Int).SetBytes(sig[:sm2KeySize])
+	s := new(big.Int).SetBytes(sig[sm2KeySize:])
+	if !sm2.Sm2Verify(publicKey, common.StringToBytes(signingString), common.StringToBytes(crypto.DefaultSM2UserID), r, s) {
+		return errors.Trace(jwt.ErrSignatureInvalid)
+	}
+
+	return nil
+}
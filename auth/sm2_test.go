@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/crypto"
+)
+
+func TestSigningMethodSM2_Sign(t *testing.T) {
+	asst := assert.New(t)
+
+	s, err := crypto.NewSM2()
+	asst.Nil(err, common.CombineMessageWithError("test SigningMethodSM2.Sign() failed", err))
+
+	signingString := "header.payload"
+	sig, err := SigningMethodSM2SM3.Sign(signingString, s.GetPrivateKey())
+	asst.Nil(err, common.CombineMessageWithError("test SigningMethodSM2.Sign() failed", err))
+	asst.Equal(64, len(sig), "test SigningMethodSM2.Sign() failed")
+
+	err = SigningMethodSM2SM3.Verify(signingString, sig, s.GetPublicKey())
+	asst.Nil(err, common.CombineMessageWithError("test SigningMethodSM2.Sign() failed", err))
+	err = SigningMethodSM2SM3.Verify("header.other", sig, s.GetPublicKey())
+	asst.NotNil(err, "test SigningMethodSM2.Sign() failed")
+	// the hmac secret is not a valid key
+	_, err = SigningMethodSM2SM3.Sign(signingString, []byte("secret"))
+	asst.NotNil(err, "test SigningMethodSM2.Sign() failed")
+
+	asst.Equal(SigningMethodSM2SM3, jwt.GetSigningMethod(SigningMethodSM2SM3Alg), "test SigningMethodSM2.Sign() failed")
+}
@@ -13,6 +13,7 @@ import (
 const (
 	tokenTypeHeader      = "typ"
 	tokenAlgorithmHeader = "alg"
+	tokenKeyIDHeader     = "kid"
 	tokenZIPHeader       = "zip"
 	tokenGZipType        = "GZIP"
 	tokenJWTType         = "JWT"
@@ -54,6 +55,11 @@ func NewTokenWithClaims(method jwt.SigningMethod, claims jwt.MapClaims) *Token {
 	}
 }
 
+// GetKeyID returns the kid header of the token, it returns empty string if the header does not exist
+func (t *Token) GetKeyID() string {
+	return t.Header[tokenKeyIDHeader]
+}
+
 // SignedString creates and returns a complete, signed JWT.
 func (t *Token) SignedString(key interface{}, ef EncodeFunc) (string, error) {
 	ss, err := t.SigningString(ef)
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"time"
 
 	"github.com/pingcap/errors"
@@ -13,6 +14,8 @@ const (
 	minInterval      = 10 * time.Millisecond
 )
 
+// Bucket is the local token bucket, it implements RateLimiter interface,
+// but all the keys share the same bucket
 type Bucket struct {
 	capacity int
 	num      int
@@ -78,6 +81,27 @@ func (b *Bucket) GetForever() {
 	<-b.ch
 }
 
+// Allow gets a token from bucket without waiting, the key is ignored, it implements RateLimiter interface
+func (b *Bucket) Allow(ctx context.Context, key string) (bool, error) {
+	select {
+	case <-b.ch:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// Wait gets a token from bucket, if bucket is empty, it waits until it gets a token or the context is done,
+// the key is ignored, it implements RateLimiter interface
+func (b *Bucket) Wait(ctx context.Context, key string) error {
+	select {
+	case <-b.ch:
+		return nil
+	case <-ctx.Done():
+		return errors.Trace(ctx.Err())
+	}
+}
+
 func (b *Bucket) Pause() {
 	b.pause = true
 }
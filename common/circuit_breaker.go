@@ -0,0 +1,405 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+
+	DefaultCircuitWindowSize           = 10 * time.Second
+	DefaultCircuitWindowBuckets        = 10
+	DefaultCircuitMinRequests          = 10
+	DefaultCircuitFailureRateThreshold = 0.5
+	DefaultCircuitOpenTimeout          = 30 * time.Second
+	DefaultCircuitHalfOpenMaxRequests  = 1
+	// DefaultCircuitMaxConcurrentRequests means the concurrent requests of each target are not limited
+	DefaultCircuitMaxConcurrentRequests = 0
+)
+
+var (
+	// ErrCircuitOpen is returned when the circuit of the target is open, the caller should fail fast
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrBulkheadFull is returned when the concurrent requests of the target reach the maximum
+	ErrBulkheadFull = errors.New("maximum concurrent requests reached")
+)
+
+// CircuitState is the state of the circuit of a target
+type CircuitState int
+
+// String returns the string of the state
+func (cs CircuitState) String() string {
+	switch cs {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitEvent is the state change event of the circuit of a target
+type CircuitEvent struct {
+	Target string
+	From   CircuitState
+	To     CircuitState
+	Time   time.Time
+}
+
+// IsCircuitBreakerError returns if the error is returned by the circuit breaker without calling the target
+func IsCircuitBreakerError(err error) bool {
+	cause := errors.Cause(err)
+
+	return cause == ErrCircuitOpen || cause == ErrBulkheadFull
+}
+
+// CircuitBreakerOption is the options of the circuit breaker
+type CircuitBreakerOption struct {
+	// WindowSize is the duration of the sliding window which the failure rate is calculated in
+	WindowSize time.Duration
+	// WindowBuckets is the number of the buckets of the sliding window
+	WindowBuckets int
+	// MinRequests is the minimum number of the requests in the window before the circuit could be opened
+	MinRequests int
+	// FailureRateThreshold opens the circuit when the failure rate in the window reaches it, it must be between 0 and 1
+	FailureRateThreshold float64
+	// OpenTimeout is the duration of the open state, after which the circuit becomes half-open
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests is the number of the probe requests in the half-open state,
+	// the circuit is closed if all of them succeed
+	HalfOpenMaxRequests int
+	// MaxConcurrentRequests is the bulkhead of each target, 0 means unlimited
+	MaxConcurrentRequests int
+	// IsFailure classifies the errors, if it is nil, all the errors are failures
+	IsFailure func(err error) bool
+	// OnStateChange is called when the state of a circuit changes, it must not block
+	OnStateChange func(event *CircuitEvent)
+}
+
+// NewCircuitBreakerOption returns a new *CircuitBreakerOption
+func NewCircuitBreakerOption(windowSize time.Duration, windowBuckets, minRequests int, failureRateThreshold float64,
+	openTimeout time.Duration, halfOpenMaxRequests, maxConcurrentRequests int) *CircuitBreakerOption {
+	return &CircuitBreakerOption{
+		WindowSize:            windowSize,
+		WindowBuckets:         windowBuckets,
+		MinRequests:           minRequests,
+		FailureRateThreshold:  failureRateThreshold,
+		OpenTimeout:           openTimeout,
+		HalfOpenMaxRequests:   halfOpenMaxRequests,
+		MaxConcurrentRequests: maxConcurrentRequests,
+	}
+}
+
+// NewCircuitBreakerOptionWithDefault returns a new *CircuitBreakerOption with default values
+func NewCircuitBreakerOptionWithDefault() *CircuitBreakerOption {
+	return NewCircuitBreakerOption(DefaultCircuitWindowSize, DefaultCircuitWindowBuckets, DefaultCircuitMinRequests,
+		DefaultCircuitFailureRateThreshold, DefaultCircuitOpenTimeout, DefaultCircuitHalfOpenMaxRequests, DefaultCircuitMaxConcurrentRequests)
+}
+
+// Validate validates the options
+func (cbo *CircuitBreakerOption) Validate() error {
+	if cbo.WindowSize <= constant.ZeroInt {
+		return errors.Errorf("window size must be larger than 0, %s is not valid", cbo.WindowSize)
+	}
+	if cbo.WindowBuckets <= constant.ZeroInt {
+		return errors.Errorf("window buckets must be larger than 0, %d is not valid", cbo.WindowBuckets)
+	}
+	if cbo.MinRequests < constant.ZeroInt {
+		return errors.Errorf("minimum requests must not be smaller than 0, %d is not valid", cbo.MinRequests)
+	}
+	if cbo.FailureRateThreshold <= constant.ZeroInt || cbo.FailureRateThreshold > constant.OneInt {
+		return errors.Errorf("failure rate threshold must be in (0, 1], %f is not valid", cbo.FailureRateThreshold)
+	}
+	if cbo.OpenTimeout <= constant.ZeroInt {
+		return errors.Errorf("open timeout must be larger than 0, %s is not valid", cbo.OpenTimeout)
+	}
+	if cbo.HalfOpenMaxRequests <= constant.ZeroInt {
+		return errors.Errorf("half-open maximum requests must be larger than 0, %d is not valid", cbo.HalfOpenMaxRequests)
+	}
+	if cbo.MaxConcurrentRequests < constant.ZeroInt {
+		return errors.Errorf("maximum concurrent requests must not be smaller than 0, %d is not valid", cbo.MaxConcurrentRequests)
+	}
+
+	return nil
+}
+
+// CircuitBreaker keeps a circuit for each target, for example, the address of a database or a downstream service,
+// the circuit opens when the failure rate in the sliding window reaches the threshold, and the calls fail fast with ErrCircuitOpen,
+// after the open timeout, the circuit becomes half-open and lets a few probe calls through,
+// if all of them succeed, the circuit is closed, otherwise it opens again
+type CircuitBreaker struct {
+	option   *CircuitBreakerOption
+	mutex    sync.Mutex
+	circuits map[string]*circuit
+	now      func() time.Time
+}
+
+// NewCircuitBreaker returns a new *CircuitBreaker
+func NewCircuitBreaker(option *CircuitBreakerOption) (*CircuitBreaker, error) {
+	err := option.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CircuitBreaker{
+		option:   option,
+		circuits: make(map[string]*circuit),
+		now:      time.Now,
+	}, nil
+}
+
+// NewCircuitBreakerWithDefault returns a new *CircuitBreaker with default options
+func NewCircuitBreakerWithDefault() *CircuitBreaker {
+	cb, _ := NewCircuitBreaker(NewCircuitBreakerOptionWithDefault())
+
+	return cb
+}
+
+// Execute calls the function if the circuit of the target allows, and records the result
+func (cb *CircuitBreaker) Execute(target string, doFunc func() error) error {
+	err := cb.Allow(target)
+	if err != nil {
+		return err
+	}
+
+	err = doFunc()
+	cb.Report(target, err)
+
+	return err
+}
+
+// Allow returns nil if the call to the target is allowed, otherwise it returns ErrCircuitOpen or ErrBulkheadFull,
+// each allowed call must be reported by Report()
+func (cb *CircuitBreaker) Allow(target string) error {
+	cb.mutex.Lock()
+	now := cb.now()
+	c := cb.getCircuit(target)
+	event := c.tryHalfOpen(target, now, cb.option)
+
+	var err error
+	switch {
+	case c.state == CircuitOpen:
+		err = errors.Annotatef(ErrCircuitOpen, "target: %s", target)
+	case c.state == CircuitHalfOpen && c.probes >= cb.option.HalfOpenMaxRequests:
+		err = errors.Annotatef(ErrCircuitOpen, "target: %s, waiting for the probe requests", target)
+	case cb.option.MaxConcurrentRequests > constant.ZeroInt && c.inflight >= cb.option.MaxConcurrentRequests:
+		err = errors.Annotatef(ErrBulkheadFull, "target: %s, maximum: %d", target, cb.option.MaxConcurrentRequests)
+	default:
+		c.inflight++
+		if c.state == CircuitHalfOpen {
+			c.probes++
+		}
+	}
+	cb.mutex.Unlock()
+
+	cb.emit(event)
+
+	return err
+}
+
+// Report records the result of the call to the target which was allowed by Allow()
+func (cb *CircuitBreaker) Report(target string, err error) {
+	isFailure := err != nil
+	if isFailure && cb.option.IsFailure != nil {
+		isFailure = cb.option.IsFailure(err)
+	}
+
+	cb.mutex.Lock()
+	now := cb.now()
+	c := cb.getCircuit(target)
+	if c.inflight > constant.ZeroInt {
+		c.inflight--
+	}
+
+	var event *CircuitEvent
+	switch c.state {
+	case CircuitClosed:
+		c.window.record(now, isFailure)
+		total, failures := c.window.count(now)
+		if total >= cb.option.MinRequests && total > constant.ZeroInt &&
+			float64(failures)/float64(total) >= cb.option.FailureRateThreshold {
+			event = c.setState(target, CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if isFailure {
+			event = c.setState(target, CircuitOpen, now)
+			break
+		}
+		c.successes++
+		if c.successes >= cb.option.HalfOpenMaxRequests {
+			event = c.setState(target, CircuitClosed, now)
+		}
+	default:
+		// the call was allowed before the circuit opened, ignore it
+	}
+	cb.mutex.Unlock()
+
+	cb.emit(event)
+}
+
+// GetState returns the state of the circuit of the target
+func (cb *CircuitBreaker) GetState(target string) CircuitState {
+	cb.mutex.Lock()
+	now := cb.now()
+	c := cb.getCircuit(target)
+	event := c.tryHalfOpen(target, now, cb.option)
+	state := c.state
+	cb.mutex.Unlock()
+
+	cb.emit(event)
+
+	return state
+}
+
+// Reset closes the circuit of the target and clears its statistics
+func (cb *CircuitBreaker) Reset(target string) {
+	cb.mutex.Lock()
+	now := cb.now()
+	c := cb.getCircuit(target)
+	event := c.setState(target, CircuitClosed, now)
+	cb.mutex.Unlock()
+
+	cb.emit(event)
+}
+
+// getCircuit returns the circuit of the target, it creates a new one if not exists
+func (cb *CircuitBreaker) getCircuit(target string) *circuit {
+	c, ok := cb.circuits[target]
+	if !ok {
+		c = newCircuit(cb.option)
+		cb.circuits[target] = c
+	}
+
+	return c
+}
+
+// emit calls the state change hook, it is called without holding the lock
+func (cb *CircuitBreaker) emit(event *CircuitEvent) {
+	if event != nil && cb.option.OnStateChange != nil {
+		cb.option.OnStateChange(event)
+	}
+}
+
+type circuit struct {
+	state     CircuitState
+	openedAt  time.Time
+	inflight  int
+	probes    int
+	successes int
+	window    *slidingWindow
+}
+
+// newCircuit returns a new closed *circuit
+func newCircuit(option *CircuitBreakerOption) *circuit {
+	return &circuit{
+		state:  CircuitClosed,
+		window: newSlidingWindow(option.WindowSize, option.WindowBuckets),
+	}
+}
+
+// tryHalfOpen turns the open circuit to half-open if the open timeout is reached
+func (c *circuit) tryHalfOpen(target string, now time.Time, option *CircuitBreakerOption) *CircuitEvent {
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= option.OpenTimeout {
+		return c.setState(target, CircuitHalfOpen, now)
+	}
+
+	return nil
+}
+
+// setState changes the state of the circuit and returns the event, it returns nil if the state is not changed
+func (c *circuit) setState(target string, state CircuitState, now time.Time) *CircuitEvent {
+	from := c.state
+	c.state = state
+	c.probes = constant.ZeroInt
+	c.successes = constant.ZeroInt
+	switch state {
+	case CircuitOpen:
+		c.openedAt = now
+	case CircuitClosed:
+		c.window.reset()
+	}
+
+	if from == state {
+		return nil
+	}
+
+	return &CircuitEvent{
+		Target: target,
+		From:   from,
+		To:     state,
+		Time:   now,
+	}
+}
+
+type windowBucket struct {
+	epoch    int64
+	total    int
+	failures int
+}
+
+// slidingWindow counts the requests and the failures in the recent window, the window is divided into buckets,
+// the expired buckets are reused
+type slidingWindow struct {
+	bucketSize time.Duration
+	buckets    []windowBucket
+}
+
+// newSlidingWindow returns a new *slidingWindow
+func newSlidingWindow(size time.Duration, buckets int) *slidingWindow {
+	bucketSize := size / time.Duration(buckets)
+	if bucketSize <= constant.ZeroInt {
+		bucketSize = time.Nanosecond
+	}
+	sw := &slidingWindow{
+		bucketSize: bucketSize,
+		buckets:    make([]windowBucket, buckets),
+	}
+	sw.reset()
+
+	return sw
+}
+
+// record records a request
+func (sw *slidingWindow) record(now time.Time, isFailure bool) {
+	epoch := now.UnixNano() / int64(sw.bucketSize)
+	b := &sw.buckets[epoch%int64(len(sw.buckets))]
+	if b.epoch != epoch {
+		*b = windowBucket{epoch: epoch}
+	}
+	b.total++
+	if isFailure {
+		b.failures++
+	}
+}
+
+// count returns the numbers of the requests and the failures in the window
+func (sw *slidingWindow) count(now time.Time) (int, int) {
+	epoch := now.UnixNano() / int64(sw.bucketSize)
+
+	var total, failures int
+	for _, b := range sw.buckets {
+		if epoch-b.epoch < int64(len(sw.buckets)) {
+			total += b.total
+			failures += b.failures
+		}
+	}
+
+	return total, failures
+}
+
+// reset clears the window
+func (sw *slidingWindow) reset() {
+	for i := range sw.buckets {
+		sw.buckets[i] = windowBucket{epoch: -constant.OneInt}
+	}
+}
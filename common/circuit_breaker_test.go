@@ -0,0 +1,114 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCircuitTarget = "192.168.137.11:3306"
+
+func newTestCircuitBreaker(t *testing.T, events *[]*CircuitEvent) (*CircuitBreaker, *time.Time) {
+	option := NewCircuitBreakerOption(10*time.Second, 10, 4, 0.5, 5*time.Second, 2, 0)
+	option.OnStateChange = func(event *CircuitEvent) {
+		*events = append(*events, event)
+	}
+	cb, err := NewCircuitBreaker(option)
+	if err != nil {
+		t.Fatalf("create circuit breaker failed. error: %s", err.Error())
+	}
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+
+	return cb, &now
+}
+
+func TestCircuitBreaker_All(t *testing.T) {
+	TestCircuitBreaker_State(t)
+	TestCircuitBreaker_Bulkhead(t)
+}
+
+func TestCircuitBreaker_State(t *testing.T) {
+	asst := assert.New(t)
+
+	var events []*CircuitEvent
+	cb, now := newTestCircuitBreaker(t, &events)
+	testErr := errors.New("test error")
+
+	// the failure rate is 50% but the minimum requests are not reached
+	asst.Equal(testErr, cb.Execute(testCircuitTarget, func() error { return testErr }), "test State() failed")
+	asst.Nil(cb.Execute(testCircuitTarget, func() error { return nil }), "test State() failed")
+	asst.Equal(testErr, cb.Execute(testCircuitTarget, func() error { return testErr }), "test State() failed")
+	asst.Equal(CircuitClosed, cb.GetState(testCircuitTarget), "test State() failed")
+	// the failure rate reaches 75%
+	asst.Equal(testErr, cb.Execute(testCircuitTarget, func() error { return testErr }), "test State() failed")
+	asst.Equal(CircuitOpen, cb.GetState(testCircuitTarget), "test State() failed")
+	// fail fast
+	called := false
+	err := cb.Execute(testCircuitTarget, func() error {
+		called = true
+		return nil
+	})
+	asst.True(IsCircuitBreakerError(err), "test State() failed")
+	asst.False(called, "test State() failed")
+	// other targets are not affected
+	asst.Equal(CircuitClosed, cb.GetState("192.168.137.12:3306"), "test State() failed")
+
+	// half-open, the probe fails
+	*now = now.Add(6 * time.Second)
+	asst.Equal(CircuitHalfOpen, cb.GetState(testCircuitTarget), "test State() failed")
+	asst.Equal(testErr, cb.Execute(testCircuitTarget, func() error { return testErr }), "test State() failed")
+	asst.Equal(CircuitOpen, cb.GetState(testCircuitTarget), "test State() failed")
+
+	// half-open, the probes succeed
+	*now = now.Add(6 * time.Second)
+	asst.Nil(cb.Allow(testCircuitTarget), "test State() failed")
+	asst.Nil(cb.Allow(testCircuitTarget), "test State() failed")
+	// only 2 probes are allowed
+	asst.True(IsCircuitBreakerError(cb.Allow(testCircuitTarget)), "test State() failed")
+	cb.Report(testCircuitTarget, nil)
+	cb.Report(testCircuitTarget, nil)
+	asst.Equal(CircuitClosed, cb.GetState(testCircuitTarget), "test State() failed")
+
+	var states []string
+	for _, event := range events {
+		asst.Equal(testCircuitTarget, event.Target, "test State() failed")
+		states = append(states, event.To.String())
+	}
+	asst.Equal([]string{"open", "half-open", "open", "half-open", "closed"}, states, "test State() failed")
+
+	// the failures out of the window are not counted
+	for i := 0; i < 3; i++ {
+		cb.Report(testCircuitTarget, testErr)
+	}
+	*now = now.Add(11 * time.Second)
+	cb.Report(testCircuitTarget, testErr)
+	asst.Equal(CircuitClosed, cb.GetState(testCircuitTarget), "test State() failed")
+}
+
+func TestCircuitBreaker_Bulkhead(t *testing.T) {
+	asst := assert.New(t)
+
+	option := NewCircuitBreakerOptionWithDefault()
+	option.MaxConcurrentRequests = 1
+	cb, err := NewCircuitBreaker(option)
+	asst.Nil(err, "test Bulkhead() failed")
+
+	asst.Nil(cb.Allow(testCircuitTarget), "test Bulkhead() failed")
+	err = cb.Allow(testCircuitTarget)
+	asst.Equal(ErrBulkheadFull, errors.Cause(err), "test Bulkhead() failed")
+	cb.Report(testCircuitTarget, nil)
+	asst.Nil(cb.Allow(testCircuitTarget), "test Bulkhead() failed")
+	cb.Report(testCircuitTarget, nil)
+
+	// the retry stops when the circuit breaker rejects the call
+	count := 0
+	err = Retry(func() error {
+		count++
+		return errors.Trace(ErrCircuitOpen)
+	}, NewRetryOption(DefaultMaxRetryCount, DefaultMaxWaitTime, time.Millisecond, nil))
+	asst.True(IsCircuitBreakerError(err), "test Bulkhead() failed")
+	asst.Equal(1, count, "test Bulkhead() failed")
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	// defaultRateLimiterPurgeInterval is the interval of purging the idle keys of the local limiter
+	defaultRateLimiterPurgeInterval = time.Minute
+)
+
+var _ RateLimiter = (*Bucket)(nil)
+var _ RateLimiter = (*GCRALimiter)(nil)
+
+// RateLimiter limits the rate of the requests of each key, for example, the user or the tenant
+type RateLimiter interface {
+	// Allow returns if a request of the key could happen now, if true, the request is counted
+	Allow(ctx context.Context, key string) (bool, error)
+	// Wait blocks until a request of the key could happen or the context is done
+	Wait(ctx context.Context, key string) error
+}
+
+// RateLimit allows Rate requests per Period, and at most Burst requests at once,
+// it is enforced by the generic cell rate algorithm (GCRA), which only keeps the theoretical arrival time (TAT) of each key
+type RateLimit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// NewRateLimit returns a new *RateLimit
+func NewRateLimit(rate int, period time.Duration, burst int) (*RateLimit, error) {
+	rl := &RateLimit{
+		Rate:   rate,
+		Period: period,
+		Burst:  burst,
+	}
+
+	err := rl.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return rl, nil
+}
+
+// Validate validates the rate limit
+func (rl *RateLimit) Validate() error {
+	if rl.Rate <= constant.ZeroInt {
+		return errors.Errorf("rate must be larger than 0, %d is not valid", rl.Rate)
+	}
+	if rl.Period <= constant.ZeroInt {
+		return errors.Errorf("period must be larger than 0, %s is not valid", rl.Period)
+	}
+	if rl.Burst <= constant.ZeroInt {
+		return errors.Errorf("burst must be larger than 0, %d is not valid", rl.Burst)
+	}
+	if rl.GetEmissionInterval() <= constant.ZeroInt {
+		return errors.Errorf("rate is too large for the period. rate: %d, period: %s", rl.Rate, rl.Period)
+	}
+
+	return nil
+}
+
+// GetEmissionInterval returns the interval between two requests at the steady rate
+func (rl *RateLimit) GetEmissionInterval() time.Duration {
+	return rl.Period / time.Duration(rl.Rate)
+}
+
+// GetTolerance returns the maximum duration the TAT could be ahead of the current time
+func (rl *RateLimit) GetTolerance() time.Duration {
+	return rl.GetEmissionInterval() * time.Duration(rl.Burst)
+}
+
+// Reserve calculates the new TAT of the request arriving at now with given TAT, the zero TAT means no request before,
+// if the request is not allowed, it returns the old TAT and the duration after which the request could be allowed
+func (rl *RateLimit) Reserve(tat, now time.Time) (time.Time, time.Duration, bool) {
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(rl.GetEmissionInterval())
+	allowAt := newTAT.Add(-rl.GetTolerance())
+	if now.Before(allowAt) {
+		return tat, allowAt.Sub(now), false
+	}
+
+	return newTAT, constant.ZeroInt, true
+}
+
+// WaitRateLimit calls the reserve function until the request is allowed or the context is done,
+// the reserve function returns if the request is allowed, and if not, the duration to wait before trying again
+func WaitRateLimit(ctx context.Context, reserve func() (bool, time.Duration, error)) error {
+	for {
+		allowed, retryAfter, err := reserve()
+		if err != nil {
+			return err
+		}
+		if allowed {
+			return nil
+		}
+
+		deadline, ok := ctx.Deadline()
+		if ok && time.Until(deadline) < retryAfter {
+			return errors.Errorf("rate limit exceeded, the request could not be allowed before the context deadline. retry after: %s", retryAfter)
+		}
+
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Trace(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// GCRALimiter is the local rate limiter of each key with the generic cell rate algorithm,
+// unlike Bucket, it does not need a goroutine to refill the tokens, and the limit of each key could be different
+type GCRALimiter struct {
+	mutex     sync.Mutex
+	limit     *RateLimit
+	keyLimits map[string]*RateLimit
+	tats      map[string]time.Time
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+// NewGCRALimiter returns a new *GCRALimiter with the default limit of all the keys
+func NewGCRALimiter(limit *RateLimit) (*GCRALimiter, error) {
+	err := limit.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCRALimiter{
+		limit:     limit,
+		keyLimits: make(map[string]*RateLimit),
+		tats:      make(map[string]time.Time),
+		lastPurge: time.Now(),
+		now:       time.Now,
+	}, nil
+}
+
+// SetKeyLimit sets the limit of the key, it overrides the default limit
+func (gl *GCRALimiter) SetKeyLimit(key string, limit *RateLimit) error {
+	err := limit.Validate()
+	if err != nil {
+		return err
+	}
+
+	gl.mutex.Lock()
+	defer gl.mutex.Unlock()
+
+	gl.keyLimits[key] = limit
+
+	return nil
+}
+
+// Allow returns if a request of the key could happen now, it implements RateLimiter interface
+func (gl *GCRALimiter) Allow(ctx context.Context, key string) (bool, error) {
+	allowed, _, err := gl.reserve(key)
+
+	return allowed, err
+}
+
+// Wait blocks until a request of the key could happen or the context is done, it implements RateLimiter interface
+func (gl *GCRALimiter) Wait(ctx context.Context, key string) error {
+	return WaitRateLimit(ctx, func() (bool, time.Duration, error) {
+		return gl.reserve(key)
+	})
+}
+
+// reserve reserves a request of the key
+func (gl *GCRALimiter) reserve(key string) (bool, time.Duration, error) {
+	gl.mutex.Lock()
+	defer gl.mutex.Unlock()
+
+	now := gl.now()
+	if now.Sub(gl.lastPurge) > defaultRateLimiterPurgeInterval {
+		// the keys whose TAT had passed are the same as the new keys
+		for k, tat := range gl.tats {
+			if tat.Before(now) {
+				delete(gl.tats, k)
+			}
+		}
+		gl.lastPurge = now
+	}
+
+	limit, ok := gl.keyLimits[key]
+	if !ok {
+		limit = gl.limit
+	}
+	tat, retryAfter, allowed := limit.Reserve(gl.tats[key], now)
+	if allowed {
+		gl.tats[key] = tat
+	}
+
+	return allowed, retryAfter, nil
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testRateLimiterUser   = "user01"
+	testRateLimiterTenant = "tenant01"
+)
+
+func TestRateLimiter_All(t *testing.T) {
+	TestRateLimiter_Reserve(t)
+	TestRateLimiter_GCRALimiter(t)
+	TestRateLimiter_Wait(t)
+	TestRateLimiter_Bucket(t)
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	asst := assert.New(t)
+
+	limit, err := NewRateLimit(10, time.Second, 2)
+	asst.Nil(err, "test Reserve() failed")
+	asst.Equal(100*time.Millisecond, limit.GetEmissionInterval(), "test Reserve() failed")
+
+	now := time.Now()
+	tat, _, allowed := limit.Reserve(time.Time{}, now)
+	asst.True(allowed, "test Reserve() failed")
+	tat, _, allowed = limit.Reserve(tat, now)
+	asst.True(allowed, "test Reserve() failed")
+	// the burst is used up
+	_, retryAfter, allowed := limit.Reserve(tat, now)
+	asst.False(allowed, "test Reserve() failed")
+	asst.Equal(100*time.Millisecond, retryAfter, "test Reserve() failed")
+	_, _, allowed = limit.Reserve(tat, now.Add(retryAfter))
+	asst.True(allowed, "test Reserve() failed")
+
+	_, err = NewRateLimit(0, time.Second, 1)
+	asst.NotNil(err, "test Reserve() failed")
+}
+
+func TestRateLimiter_GCRALimiter(t *testing.T) {
+	asst := assert.New(t)
+
+	limit, err := NewRateLimit(1, time.Second, 3)
+	asst.Nil(err, "test GCRALimiter() failed")
+	limiter, err := NewGCRALimiter(limit)
+	asst.Nil(err, "test GCRALimiter() failed")
+	tenantLimit, err := NewRateLimit(1, time.Second, 1)
+	asst.Nil(err, "test GCRALimiter() failed")
+	err = limiter.SetKeyLimit(testRateLimiterTenant, tenantLimit)
+	asst.Nil(err, "test GCRALimiter() failed")
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(ctx, testRateLimiterUser)
+		asst.Nil(err, "test GCRALimiter() failed")
+		asst.True(allowed, "test GCRALimiter() failed")
+	}
+	allowed, err := limiter.Allow(ctx, testRateLimiterUser)
+	asst.Nil(err, "test GCRALimiter() failed")
+	asst.False(allowed, "test GCRALimiter() failed")
+
+	// the tenant has its own limit
+	allowed, err = limiter.Allow(ctx, testRateLimiterTenant)
+	asst.Nil(err, "test GCRALimiter() failed")
+	asst.True(allowed, "test GCRALimiter() failed")
+	allowed, err = limiter.Allow(ctx, testRateLimiterTenant)
+	asst.Nil(err, "test GCRALimiter() failed")
+	asst.False(allowed, "test GCRALimiter() failed")
+
+	// a token is refilled every second
+	now = now.Add(time.Second)
+	allowed, err = limiter.Allow(ctx, testRateLimiterUser)
+	asst.Nil(err, "test GCRALimiter() failed")
+	asst.True(allowed, "test GCRALimiter() failed")
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	asst := assert.New(t)
+
+	limit, err := NewRateLimit(20, time.Second, 1)
+	asst.Nil(err, "test Wait() failed")
+	limiter, err := NewGCRALimiter(limit)
+	asst.Nil(err, "test Wait() failed")
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err = limiter.Wait(ctx, testRateLimiterUser)
+		asst.Nil(err, "test Wait() failed")
+	}
+	asst.True(time.Since(start) >= 100*time.Millisecond, "test Wait() failed")
+
+	// the request could not be allowed before the deadline
+	slowLimit, err := NewRateLimit(1, time.Hour, 1)
+	asst.Nil(err, "test Wait() failed")
+	err = limiter.SetKeyLimit(testRateLimiterTenant, slowLimit)
+	asst.Nil(err, "test Wait() failed")
+	err = limiter.Wait(ctx, testRateLimiterTenant)
+	asst.Nil(err, "test Wait() failed")
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	err = limiter.Wait(ctx, testRateLimiterTenant)
+	asst.NotNil(err, "test Wait() failed")
+}
+
+func TestRateLimiter_Bucket(t *testing.T) {
+	asst := assert.New(t)
+
+	var limiter RateLimiter
+	limiter, err := NewBucket(1, 1, time.Hour)
+	asst.Nil(err, "test Bucket() failed")
+
+	allowed, err := limiter.Allow(context.Background(), testRateLimiterUser)
+	asst.Nil(err, "test Bucket() failed")
+	asst.True(allowed, "test Bucket() failed")
+	// all the keys share the same bucket
+	allowed, err = limiter.Allow(context.Background(), testRateLimiterTenant)
+	asst.Nil(err, "test Bucket() failed")
+	asst.False(allowed, "test Bucket() failed")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = limiter.Wait(ctx, testRateLimiterUser)
+	asst.NotNil(err, "test Bucket() failed")
+}
@@ -1,6 +1,9 @@
 package common
 
 import (
+	"context"
+	"math"
+	"math/rand"
 	"reflect"
 	"runtime"
 	"time"
@@ -18,17 +21,41 @@ const (
 	DefaultMaxRetryCount = 10
 	DefaultDelayTime     = 500 * time.Millisecond
 	DefaultMaxWaitTime   = 10 * time.Second
+
+	DefaultBackoffMultiplier = 2.0
+	DefaultBackoffJitter     = 0.2
+	DefaultMaxDelayTime      = 30 * time.Second
+
+	// UnlimitedWaitTime means Retry() will not stop retrying because of the wait time
+	UnlimitedWaitTime = time.Duration(-1)
 )
 
-// RetryOption is options for Retry()
+// RetryableFunc returns if the error is retryable
+type RetryableFunc func(err error) bool
+
+// OnRetryFunc is called before each retry, attempt starts from 0
+type OnRetryFunc func(attempt int, delay time.Duration, err error)
+
+// RetryOption is options for Retry(), it is the retry policy shared by the clients and the pools of the library,
+// the delay of the nth retry is DelayTime * Multiplier^n, capped by MaxDelayTime, and randomized by Jitter
 type RetryOption struct {
 	MaxRetryCount int
 	MaxWaitTime   time.Duration
 	DelayTime     time.Duration
-	Logger        *log.Logger
+	// MaxDelayTime caps the delay, if it is 0, the delay is not capped
+	MaxDelayTime time.Duration
+	// Multiplier is the factor of the exponential backoff, if it is smaller than 1, the delay is fixed
+	Multiplier float64
+	// Jitter randomizes the delay in [delay * (1 - Jitter), delay * (1 + Jitter)], it must be between 0 and 1
+	Jitter float64
+	// IsRetryable classifies the errors, if it is nil, all the errors are retryable
+	IsRetryable RetryableFunc
+	// OnRetry is called before each retry
+	OnRetry OnRetryFunc
+	Logger  *log.Logger
 }
 
-// NewRetryOption returns RetryOption
+// NewRetryOption returns RetryOption with fixed delay time
 func NewRetryOption(maxRetryCount int, maxWaitTime, delayTime time.Duration, logger *log.Logger) *RetryOption {
 	return &RetryOption{
 		MaxRetryCount: maxRetryCount,
@@ -38,6 +65,26 @@ func NewRetryOption(maxRetryCount int, maxWaitTime, delayTime time.Duration, log
 	}
 }
 
+// NewRetryOptionWithBackoff returns RetryOption with exponential backoff
+func NewRetryOptionWithBackoff(maxRetryCount int, maxWaitTime, delayTime, maxDelayTime time.Duration,
+	multiplier, jitter float64, logger *log.Logger) *RetryOption {
+	return &RetryOption{
+		MaxRetryCount: maxRetryCount,
+		MaxWaitTime:   maxWaitTime,
+		DelayTime:     delayTime,
+		MaxDelayTime:  maxDelayTime,
+		Multiplier:    multiplier,
+		Jitter:        jitter,
+		Logger:        logger,
+	}
+}
+
+// NewRetryOptionWithDefaultBackoff returns RetryOption with default exponential backoff values
+func NewRetryOptionWithDefaultBackoff() *RetryOption {
+	return NewRetryOptionWithBackoff(DefaultMaxRetryCount, DefaultMaxWaitTime, DefaultDelayTime, DefaultMaxDelayTime,
+		DefaultBackoffMultiplier, DefaultBackoffJitter, log.L())
+}
+
 // NewRetryOptionWithLogger returns RetryOption with given logger
 func NewRetryOptionWithLogger(logger *log.Logger) *RetryOption {
 	return &RetryOption{
@@ -63,14 +110,74 @@ func (ro *RetryOption) Validate() error {
 	if ro.MaxRetryCount < MinMaxRetryCount || ro.MaxRetryCount > MaxMaxRetryCount {
 		return errors.Errorf("max retry count must be between %d and %d, %d is not valid", MinMaxRetryCount, MaxMaxRetryCount, ro.MaxRetryCount)
 	}
+	if ro.DelayTime < constant.ZeroInt {
+		return errors.Errorf("delay time must not be smaller than 0, %s is not valid", ro.DelayTime)
+	}
+	if ro.MaxDelayTime < constant.ZeroInt {
+		return errors.Errorf("max delay time must not be smaller than 0, %s is not valid", ro.MaxDelayTime)
+	}
+	if ro.Jitter < constant.ZeroInt || ro.Jitter > constant.OneInt {
+		return errors.Errorf("jitter must be between 0 and 1, %f is not valid", ro.Jitter)
+	}
 
 	return nil
 }
 
+// ShouldRetry returns if the function should be retried after given attempt failed with the error, attempt starts from 0,
+// the errors of the circuit breaker are never retried
+func (ro *RetryOption) ShouldRetry(attempt int, err error) bool {
+	if ro.MaxRetryCount >= constant.ZeroInt && attempt >= ro.MaxRetryCount {
+		return false
+	}
+	if IsCircuitBreakerError(err) {
+		// fail fast
+		return false
+	}
+	if ro.IsRetryable != nil && !ro.IsRetryable(err) {
+		return false
+	}
+
+	return true
+}
+
+// GetDelay returns the delay before the retry of given attempt, attempt starts from 0
+func (ro *RetryOption) GetDelay(attempt int) time.Duration {
+	delay := float64(ro.DelayTime)
+	if ro.Multiplier > constant.OneInt {
+		delay *= math.Pow(ro.Multiplier, float64(attempt))
+	}
+	if ro.MaxDelayTime > constant.ZeroInt && delay > float64(ro.MaxDelayTime) {
+		delay = float64(ro.MaxDelayTime)
+	}
+	if ro.Jitter > constant.ZeroInt {
+		delay *= constant.OneInt - ro.Jitter + constant.TwoInt*ro.Jitter*rand.Float64()
+	}
+	if delay >= math.MaxInt64 {
+		// the delay is not capped and overflows after many attempts
+		return math.MaxInt64
+	}
+
+	return time.Duration(delay)
+}
+
+// Notify calls the OnRetry hook before the retry of given attempt
+func (ro *RetryOption) Notify(attempt int, delay time.Duration, err error) {
+	if ro.OnRetry != nil {
+		ro.OnRetry(attempt, delay, err)
+	}
+}
+
 // Retry retries the function until it returns no error or reaches max retry count or
 // max wait time, either one is earlier, if option is nil,
 // it will only call the function once, and no retry.
 func Retry(doFunc func() error, option *RetryOption) error {
+	return RetryWithContext(context.Background(), doFunc, option)
+}
+
+// RetryWithContext retries the function until it returns no error, the error is not retryable,
+// or it reaches max retry count, max wait time or the context is done, either one is earlier,
+// if option is nil, it will only call the function once, and no retry.
+func RetryWithContext(ctx context.Context, doFunc func() error, option *RetryOption) error {
 	if option == nil {
 		return doFunc()
 	}
@@ -79,35 +186,54 @@ func Retry(doFunc func() error, option *RetryOption) error {
 		return err
 	}
 
-	timer := time.NewTimer(option.MaxWaitTime)
-	defer timer.Stop()
+	var deadline <-chan time.Time
+	if option.MaxWaitTime >= constant.ZeroInt {
+		timer := time.NewTimer(option.MaxWaitTime)
+		defer timer.Stop()
+		deadline = timer.C
+	}
 
 	var i int
 
 	for {
 		// run the function
 		err = doFunc()
-		if err != nil {
-			if option.Logger != nil {
-				funName := runtime.FuncForPC(reflect.ValueOf(doFunc).Pointer()).Name()
-				option.Logger.Errorf("common.Retry(): execute function failed. function name: %s, error:\n%+v", funName, err)
-			}
-			// check retry count
-			if option.MaxRetryCount >= constant.ZeroInt && i >= option.MaxRetryCount {
-				return errors.Trace(err)
-			}
-			// check wait timeout
-			select {
-			case <-timer.C:
-				return errors.Trace(err)
-			default:
-				time.Sleep(option.DelayTime)
-			}
-
-			i++
-			continue
+		if err == nil {
+			return nil
+		}
+		if option.Logger != nil {
+			funName := runtime.FuncForPC(reflect.ValueOf(doFunc).Pointer()).Name()
+			option.Logger.Errorf("common.Retry(): execute function failed. function name: %s, error:\n%+v", funName, err)
+		}
+		// check retry count and the error
+		if !option.ShouldRetry(i, err) {
+			return errors.Trace(err)
+		}
+
+		// check wait timeout and context before waiting,
+		// otherwise the expired deadline and the delay timer are both ready, and the select picks one randomly
+		select {
+		case <-ctx.Done():
+			return errors.Annotatef(ctx.Err(), "retry canceled. last error: %s", err.Error())
+		case <-deadline:
+			return errors.Trace(err)
+		default:
+		}
+
+		delay := option.GetDelay(i)
+		option.Notify(i, delay, err)
+		// wait for the delay, wait timeout and context
+		delayTimer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			delayTimer.Stop()
+			return errors.Annotatef(ctx.Err(), "retry canceled. last error: %s", err.Error())
+		case <-deadline:
+			delayTimer.Stop()
+			return errors.Trace(err)
+		case <-delayTimer.C:
 		}
 
-		return nil
+		i++
 	}
 }
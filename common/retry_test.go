@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -44,3 +45,83 @@ func TestRetry(t *testing.T) {
 	err := Retry(ts.testFunc, option)
 	asst.Nil(err, "test Retry() failed")
 }
+
+func TestRetry_Backoff(t *testing.T) {
+	asst := assert.New(t)
+
+	option := NewRetryOptionWithBackoff(testMaxRetryCount, testMaxWaitTime, 100*time.Millisecond, 300*time.Millisecond, DefaultBackoffMultiplier, constant.ZeroInt, nil)
+	asst.Equal(100*time.Millisecond, option.GetDelay(0), "test Backoff() failed")
+	asst.Equal(200*time.Millisecond, option.GetDelay(1), "test Backoff() failed")
+	asst.Equal(300*time.Millisecond, option.GetDelay(2), "test Backoff() failed")
+
+	option.Jitter = DefaultBackoffJitter
+	for i := 0; i < 100; i++ {
+		delay := option.GetDelay(1)
+		asst.True(delay >= 160*time.Millisecond && delay <= 240*time.Millisecond, "test Backoff() failed")
+	}
+
+	// the delay does not overflow if it is not capped
+	option = NewRetryOptionWithBackoff(-1, testMaxWaitTime, 100*time.Millisecond, constant.ZeroInt, DefaultBackoffMultiplier, DefaultBackoffJitter, nil)
+	for _, attempt := range []int{35, 64, 2000} {
+		asst.True(option.GetDelay(attempt) > option.GetDelay(1), "test Backoff() failed")
+	}
+
+	var delays []time.Duration
+	option = NewRetryOptionWithBackoff(testMaxRetryCount, testMaxWaitTime, time.Millisecond, 10*time.Millisecond, DefaultBackoffMultiplier, constant.ZeroInt, nil)
+	option.OnRetry = func(attempt int, delay time.Duration, err error) {
+		delays = append(delays, delay)
+	}
+	ts := newTestStruct()
+	err := Retry(ts.testFunc, option)
+	asst.Nil(err, "test Backoff() failed")
+	asst.Equal([]time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond}, delays, "test Backoff() failed")
+}
+
+func TestRetry_IsRetryable(t *testing.T) {
+	asst := assert.New(t)
+
+	errNotRetryable := errors.New("not retryable")
+	count := 0
+	option := NewRetryOption(testMaxRetryCount, testMaxWaitTime, time.Millisecond, nil)
+	option.IsRetryable = func(err error) bool {
+		return errors.Cause(err) != errNotRetryable
+	}
+	err := Retry(func() error {
+		count++
+		return errNotRetryable
+	}, option)
+	asst.NotNil(err, "test IsRetryable() failed")
+	asst.Equal(constant.OneInt, count, "test IsRetryable() failed")
+}
+
+func TestRetry_WithContext(t *testing.T) {
+	asst := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	option := NewRetryOption(MinMaxRetryCount, UnlimitedWaitTime, 10*time.Millisecond, nil)
+	start := time.Now()
+	err := RetryWithContext(ctx, func() error {
+		return errors.New("test error")
+	}, option)
+	asst.NotNil(err, "test RetryWithContext() failed")
+	asst.Less(time.Since(start), time.Second, "test RetryWithContext() failed")
+}
+
+func TestRetry_ZeroWaitTime(t *testing.T) {
+	asst := assert.New(t)
+
+	// the wait time is expired before the first retry, so the function should be called only once,
+	// even if the delay timer is also ready
+	option := NewRetryOption(MinMaxRetryCount, constant.ZeroInt, constant.ZeroInt, nil)
+	for i := 0; i < 100; i++ {
+		count := constant.ZeroInt
+		err := RetryWithContext(context.Background(), func() error {
+			count++
+			return errors.New("test error")
+		}, option)
+		asst.NotNil(err, "test RetryWithContext() failed")
+		asst.Equal(constant.OneInt, count, "test RetryWithContext() failed")
+	}
+}
@@ -0,0 +1,240 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/pingcap/errors"
+	"github.com/tjfoc/gmsm/sm4"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	AEADVersion1       byte = 1
+	AEADCurrentVersion      = AEADVersion1
+	// AEADHeaderSize is the size of the version and the algorithm in the ciphertext header
+	AEADHeaderSize = 2
+
+	AEADAlgorithmUnknown AEADAlgorithm = 0
+	AEADAlgorithmAESGCM  AEADAlgorithm = 1
+	AEADAlgorithmSM4GCM  AEADAlgorithm = 2
+)
+
+type AEADAlgorithm byte
+
+// String returns the string of the algorithm
+func (aa AEADAlgorithm) String() string {
+	switch aa {
+	case AEADAlgorithmAESGCM:
+		return "AES-GCM"
+	case AEADAlgorithmSM4GCM:
+		return "SM4-GCM"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// GetKeySize returns the default key size of the algorithm
+func (aa AEADAlgorithm) GetKeySize() int {
+	switch aa {
+	case AEADAlgorithmAESGCM:
+		return DefaultAESKeySize
+	case AEADAlgorithmSM4GCM:
+		return SM4KeySize
+	default:
+		return constant.ZeroInt
+	}
+}
+
+// AEAD is the authenticated encryption with associated data,
+// the ciphertext is in the format of: version(1 byte) | algorithm(1 byte) | nonce | sealed data and tag,
+// the header is also authenticated, so the tampered header or ciphertext could be detected when decrypting
+type AEAD struct {
+	algorithm AEADAlgorithm
+	key       []byte
+	aead      cipher.AEAD
+}
+
+// NewAESGCM returns a new *AEAD of AES-GCM with a random key of default size
+func NewAESGCM() (*AEAD, error) {
+	return newAEADWithRandomKey(AEADAlgorithmAESGCM)
+}
+
+// NewAESGCMWithKey returns a new *AEAD of AES-GCM with given key, the key size must be 16, 24 or 32
+func NewAESGCMWithKey(key []byte) (*AEAD, error) {
+	return NewAEADWithKey(AEADAlgorithmAESGCM, key)
+}
+
+// NewSM4GCM returns a new *AEAD of SM4-GCM with a random key
+func NewSM4GCM() (*AEAD, error) {
+	return newAEADWithRandomKey(AEADAlgorithmSM4GCM)
+}
+
+// NewSM4GCMWithKey returns a new *AEAD of SM4-GCM with given key, the key size must be 16
+func NewSM4GCMWithKey(key []byte) (*AEAD, error) {
+	return NewAEADWithKey(AEADAlgorithmSM4GCM, key)
+}
+
+// NewAEADWithKey returns a new *AEAD with given algorithm and key
+func NewAEADWithKey(algorithm AEADAlgorithm, key []byte) (*AEAD, error) {
+	var (
+		block cipher.Block
+		err   error
+	)
+
+	switch algorithm {
+	case AEADAlgorithmAESGCM:
+		block, err = aes.NewCipher(key)
+	case AEADAlgorithmSM4GCM:
+		if len(key) != SM4KeySize {
+			return nil, errors.Errorf("sm4 key size must be %d, %d is not valid", SM4KeySize, len(key))
+		}
+		block, err = sm4.NewCipher(key)
+	default:
+		return nil, errors.Errorf("aead algorithm must be one of [%s, %s], %d is not valid",
+			AEADAlgorithmAESGCM.String(), AEADAlgorithmSM4GCM.String(), algorithm)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &AEAD{
+		algorithm: algorithm,
+		key:       append([]byte{}, key...),
+		aead:      aead,
+	}, nil
+}
+
+// newAEADWithRandomKey returns a new *AEAD with a random key of the default size of the algorithm
+func newAEADWithRandomKey(algorithm AEADAlgorithm) (*AEAD, error) {
+	key, err := generateAEADKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAEADWithKey(algorithm, key)
+}
+
+// generateAEADKey generates a random key of the default size of the algorithm
+func generateAEADKey(algorithm AEADAlgorithm) ([]byte, error) {
+	size := algorithm.GetKeySize()
+	if size == constant.ZeroInt {
+		return nil, errors.Errorf("aead algorithm must be one of [%s, %s], %d is not valid",
+			AEADAlgorithmAESGCM.String(), AEADAlgorithmSM4GCM.String(), algorithm)
+	}
+
+	key := make([]byte, size)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return key, nil
+}
+
+// GetAlgorithm returns the algorithm
+func (a *AEAD) GetAlgorithm() AEADAlgorithm {
+	return a.algorithm
+}
+
+// GetKey returns a copy of the key
+func (a *AEAD) GetKey() []byte {
+	return append([]byte{}, a.key...)
+}
+
+// Seal encrypts and authenticates the plaintext, the additional data is authenticated but not encrypted,
+// the same additional data must be provided when opening the ciphertext
+func (a *AEAD) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonceSize := a.aead.NonceSize()
+	ciphertext := make([]byte, AEADHeaderSize+nonceSize, AEADHeaderSize+nonceSize+len(plaintext)+a.aead.Overhead())
+	ciphertext[constant.ZeroInt] = AEADCurrentVersion
+	ciphertext[constant.OneInt] = byte(a.algorithm)
+
+	nonce := ciphertext[AEADHeaderSize:]
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	header := ciphertext[:AEADHeaderSize]
+
+	return a.aead.Seal(ciphertext, nonce, plaintext, a.getAdditionalData(header, additionalData)), nil
+}
+
+// Open verifies and decrypts the ciphertext, it returns error if the header, the ciphertext or the additional data was tampered
+func (a *AEAD) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	nonceSize := a.aead.NonceSize()
+	if len(ciphertext) < AEADHeaderSize+nonceSize+a.aead.Overhead() {
+		return nil, errors.Errorf("invalid ciphertext length. length: %d", len(ciphertext))
+	}
+	if ciphertext[constant.ZeroInt] != AEADVersion1 {
+		return nil, errors.Errorf("unsupported ciphertext version. version: %d", ciphertext[constant.ZeroInt])
+	}
+	algorithm := AEADAlgorithm(ciphertext[constant.OneInt])
+	if algorithm != a.algorithm {
+		return nil, errors.Errorf("algorithm of the ciphertext does not match. expected: %s, actual: %s",
+			a.algorithm.String(), algorithm.String())
+	}
+
+	header := ciphertext[:AEADHeaderSize]
+	nonce := ciphertext[AEADHeaderSize : AEADHeaderSize+nonceSize]
+
+	plaintext, err := a.aead.Open(nil, nonce, ciphertext[AEADHeaderSize+nonceSize:], a.getAdditionalData(header, additionalData))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return plaintext, nil
+}
+
+// Encrypt encrypts the message and returns the base64 string
+func (a *AEAD) Encrypt(message string) (string, error) {
+	return a.EncryptWithAdditionalData(message, constant.EmptyString)
+}
+
+// Decrypt decrypts the base64 string
+func (a *AEAD) Decrypt(cipher string) (string, error) {
+	return a.DecryptWithAdditionalData(cipher, constant.EmptyString)
+}
+
+// EncryptWithAdditionalData encrypts the message with additional data and returns the base64 string
+func (a *AEAD) EncryptWithAdditionalData(message, additionalData string) (string, error) {
+	ciphertext, err := a.Seal(common.StringToBytes(message), common.StringToBytes(additionalData))
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptWithAdditionalData decrypts the base64 string with additional data
+func (a *AEAD) DecryptWithAdditionalData(cipher, additionalData string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(cipher)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	plaintext, err := a.Open(ciphertext, common.StringToBytes(additionalData))
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return string(plaintext), nil
+}
+
+// getAdditionalData returns the actual additional data which contains the header
+func (a *AEAD) getAdditionalData(header, additionalData []byte) []byte {
+	ad := make([]byte, constant.ZeroInt, len(header)+len(additionalData))
+	ad = append(ad, header...)
+
+	return append(ad, additionalData...)
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAdditionalData = "user-001"
+
+func TestAEAD_All(t *testing.T) {
+	TestAEAD_Encrypt(t)
+	TestAEAD_Open(t)
+}
+
+func TestAEAD_Encrypt(t *testing.T) {
+	asst := assert.New(t)
+
+	aesGCM, err := NewAESGCM()
+	asst.Nil(err, "test AEAD.Encrypt() failed")
+	sm4GCM, err := NewSM4GCM()
+	asst.Nil(err, "test AEAD.Encrypt() failed")
+
+	for _, a := range []*AEAD{aesGCM, sm4GCM} {
+		cipherText, err := a.EncryptWithAdditionalData(defaultMessage, testAdditionalData)
+		asst.Nil(err, "test AEAD.Encrypt() failed")
+		message, err := a.DecryptWithAdditionalData(cipherText, testAdditionalData)
+		asst.Nil(err, "test AEAD.Encrypt() failed")
+		asst.Equal(defaultMessage, message, "test AEAD.Encrypt() failed")
+		// wrong additional data
+		_, err = a.DecryptWithAdditionalData(cipherText, defaultMessage)
+		asst.NotNil(err, "test AEAD.Encrypt() failed")
+
+		cipherText, err = a.Encrypt(defaultMessage)
+		asst.Nil(err, "test AEAD.Encrypt() failed")
+		message, err = a.Decrypt(cipherText)
+		asst.Nil(err, "test AEAD.Encrypt() failed")
+		asst.Equal(defaultMessage, message, "test AEAD.Encrypt() failed")
+		t.Logf("algorithm: %s, cipher text: %s", a.GetAlgorithm().String(), cipherText)
+	}
+}
+
+func TestAEAD_Open(t *testing.T) {
+	asst := assert.New(t)
+
+	a, err := NewAESGCM()
+	asst.Nil(err, "test AEAD.Open() failed")
+	ciphertext, err := a.Seal([]byte(defaultMessage), nil)
+	asst.Nil(err, "test AEAD.Open() failed")
+
+	// tampered ciphertext
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = a.Open(tampered, nil)
+	asst.NotNil(err, "test AEAD.Open() failed")
+	// tampered version
+	tampered = append([]byte{}, ciphertext...)
+	tampered[0] = 2
+	_, err = a.Open(tampered, nil)
+	asst.NotNil(err, "test AEAD.Open() failed")
+	// truncated ciphertext
+	_, err = a.Open(ciphertext[:AEADHeaderSize], nil)
+	asst.NotNil(err, "test AEAD.Open() failed")
+	// different algorithm with the same key
+	s, err := NewSM4GCMWithKey(a.GetKey()[:SM4KeySize])
+	asst.Nil(err, "test AEAD.Open() failed")
+	_, err = s.Open(ciphertext, nil)
+	asst.NotNil(err, "test AEAD.Open() failed")
+	// different key
+	other, err := NewAESGCM()
+	asst.Nil(err, "test AEAD.Open() failed")
+	_, err = other.Decrypt(base64.StdEncoding.EncodeToString(ciphertext))
+	asst.NotNil(err, "test AEAD.Open() failed")
+
+	plaintext, err := a.Open(ciphertext, nil)
+	asst.Nil(err, "test AEAD.Open() failed")
+	asst.Equal(defaultMessage, string(plaintext), "test AEAD.Open() failed")
+}
@@ -1,9 +1,7 @@
 package crypto
 
 import (
-	"bytes"
 	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
 
@@ -57,6 +55,7 @@ func newAESWithKey(key string) *AES {
 	}
 }
 
+// Encrypt encrypts the message in cbc mode and returns the base64 string
 func (a *AES) Encrypt(message string) (string, error) {
 	// create block
 	block, err := aes.NewCipher(common.StringToBytes(a.key))
@@ -64,57 +63,16 @@ func (a *AES) Encrypt(message string) (string, error) {
 		return constant.EmptyString, err
 	}
 
-	plaintext := common.StringToBytes(message)
-	// pad message
-	blockSize := block.BlockSize()
-	padText := a.applyPKCS5Padding(plaintext, blockSize)
-
-	// generate a random initialization vector
-	ciphertext := make([]byte, blockSize+len(padText))
-	iv := ciphertext[:blockSize]
-	if _, err := rand.Read(iv); err != nil {
-		return constant.EmptyString, err
-	}
-
-	// create a new CBC encryptor
-	stream := cipher.NewCBCEncrypter(block, iv)
-	stream.CryptBlocks(ciphertext[blockSize:], padText)
-
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return encryptCBC(block, message)
 }
 
+// Decrypt decrypts the base64 string in cbc mode
 func (a *AES) Decrypt(ciphertextBase64 string) (string, error) {
-	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextBase64)
-	if err != nil {
-		return constant.EmptyString, err
-	}
-
 	// create block
 	block, err := aes.NewCipher(common.StringToBytes(a.key))
 	if err != nil {
-		return "", err
+		return constant.EmptyString, err
 	}
 
-	// get IV and ciphertext
-	blockSize := block.BlockSize()
-	iv := ciphertext[:blockSize]
-	ciphertext = ciphertext[blockSize:]
-
-	// create a new CBC decrypter
-	stream := cipher.NewCBCDecrypter(block, iv)
-	// decrypt cipher
-	stream.CryptBlocks(ciphertext, ciphertext)
-
-	// remove padding
-	padding := int(ciphertext[len(ciphertext)-1])
-	plaintext := ciphertext[:len(ciphertext)-padding]
-
-	return string(plaintext), nil
-}
-
-func (a *AES) applyPKCS5Padding(plaintext []byte, blockSize int) []byte {
-	padding := blockSize - len(plaintext)%blockSize
-	padText := bytes.Repeat([]byte{byte(padding)}, padding)
-
-	return append(plaintext, padText...)
+	return decryptCBC(block, ciphertextBase64)
 }
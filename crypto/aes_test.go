@@ -39,6 +39,10 @@ func TestAES_Decrypt(t *testing.T) {
 	message, err := a.Decrypt(cipherText)
 	asst.Nil(err, "test AES.Decrypt() failed")
 	asst.Equal(defaultMessage, message, "test AES.Decrypt() failed")
+
+	// invalid ciphertext should not panic
+	_, err = a.Decrypt(cipherText[:8])
+	asst.NotNil(err, "test AES.Decrypt() failed")
 }
 
 func TestAES_Temp(t *testing.T) {
@@ -65,3 +65,33 @@ func DecryptWithSM2PrivateKeyString(privateKeyStr, cipher string) (string, error
 
 	return sm2.Decrypt(cipher)
 }
+
+// SignWithSM2PrivateKeyString signs the message with private key hex string and the default user id
+func SignWithSM2PrivateKeyString(privateKeyStr, message string) (string, error) {
+	sm2, err := NewSM2WithPrivateKeyHexString(privateKeyStr)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return sm2.Sign(message)
+}
+
+// VerifyWithSM2PublicKeyString verifies the signature of the message with public key hex string and the default user id
+func VerifyWithSM2PublicKeyString(publicKeyStr, message, signature string) (bool, error) {
+	sm2, err := NewSM2WithPublicKeyHexString(publicKeyStr)
+	if err != nil {
+		return false, err
+	}
+
+	return sm2.Verify(message, signature)
+}
+
+// EncryptWithSM4Key encrypts the message with sm4 key
+func EncryptWithSM4Key(key, message string) (string, error) {
+	return NewSM4WithKey(key).Encrypt(message)
+}
+
+// DecryptWithSM4Key decrypts the cipher with sm4 key
+func DecryptWithSM4Key(key, cipher string) (string, error) {
+	return NewSM4WithKey(key).Decrypt(cipher)
+}
@@ -0,0 +1,228 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	EnvelopeVersion1       byte = 1
+	EnvelopeCurrentVersion      = EnvelopeVersion1
+	// EnvelopeHeaderSize is the size of the version, the wrap algorithm and the length of the wrapped key
+	EnvelopeHeaderSize = 4
+
+	WrapAlgorithmUnknown WrapAlgorithm = 0
+	WrapAlgorithmRSAOAEP WrapAlgorithm = 1
+	WrapAlgorithmSM2     WrapAlgorithm = 2
+)
+
+type WrapAlgorithm byte
+
+// String returns the string of the wrap algorithm
+func (wa WrapAlgorithm) String() string {
+	switch wa {
+	case WrapAlgorithmRSAOAEP:
+		return "RSA-OAEP"
+	case WrapAlgorithmSM2:
+		return "SM2"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// KeyWrapper wraps and unwraps the data keys with the master key
+type KeyWrapper interface {
+	// GetWrapAlgorithm returns the wrap algorithm
+	GetWrapAlgorithm() WrapAlgorithm
+	// WrapKey encrypts the data key
+	WrapKey(key []byte) ([]byte, error)
+	// UnwrapKey decrypts the wrapped data key
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// Envelope is the envelope encryption, each message is encrypted by a random data key with AEAD,
+// and the data key is wrapped by the master key of the key wrapper,
+// the ciphertext is in the format of: version(1 byte) | wrap algorithm(1 byte) | length of the wrapped key(2 bytes) | wrapped key | AEAD ciphertext,
+// when rotating the master key, only the data key needs to be rewrapped, the AEAD ciphertext stays unchanged
+type Envelope struct {
+	wrapper   KeyWrapper
+	algorithm AEADAlgorithm
+}
+
+// NewEnvelope returns a new *Envelope
+func NewEnvelope(wrapper KeyWrapper, algorithm AEADAlgorithm) *Envelope {
+	return &Envelope{
+		wrapper:   wrapper,
+		algorithm: algorithm,
+	}
+}
+
+// NewEnvelopeWithRSA returns a new *Envelope which wraps the data keys with rsa and encrypts the messages with AES-GCM
+func NewEnvelopeWithRSA(r *RSA) *Envelope {
+	return NewEnvelope(r, AEADAlgorithmAESGCM)
+}
+
+// NewEnvelopeWithSM2 returns a new *Envelope which wraps the data keys with sm2 and encrypts the messages with SM4-GCM
+func NewEnvelopeWithSM2(s *SM2) *Envelope {
+	return NewEnvelope(s, AEADAlgorithmSM4GCM)
+}
+
+// GetWrapper returns the key wrapper
+func (e *Envelope) GetWrapper() KeyWrapper {
+	return e.wrapper
+}
+
+// GetAlgorithm returns the AEAD algorithm
+func (e *Envelope) GetAlgorithm() AEADAlgorithm {
+	return e.algorithm
+}
+
+// Seal encrypts the plaintext with a new data key and wraps the data key
+func (e *Envelope) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	a, err := newAEADWithRandomKey(e.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := a.Seal(plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.seal(a.key, ciphertext)
+}
+
+// Open unwraps the data key and decrypts the ciphertext
+func (e *Envelope) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	key, aeadCiphertext, err := e.open(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := NewAEADWithKey(e.algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Open(aeadCiphertext, additionalData)
+}
+
+// Rewrap unwraps the data key with the key wrapper of the envelope and wraps it with the new key wrapper,
+// the encrypted message is not changed, it is used to rotate the master key
+func (e *Envelope) Rewrap(ciphertext []byte, wrapper KeyWrapper) ([]byte, error) {
+	key, aeadCiphertext, err := e.open(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEnvelope(wrapper, e.algorithm).seal(key, aeadCiphertext)
+}
+
+// Encrypt encrypts the message and returns the base64 string
+func (e *Envelope) Encrypt(message string) (string, error) {
+	return e.EncryptWithAdditionalData(message, constant.EmptyString)
+}
+
+// Decrypt decrypts the base64 string
+func (e *Envelope) Decrypt(cipher string) (string, error) {
+	return e.DecryptWithAdditionalData(cipher, constant.EmptyString)
+}
+
+// EncryptWithAdditionalData encrypts the message with additional data and returns the base64 string
+func (e *Envelope) EncryptWithAdditionalData(message, additionalData string) (string, error) {
+	ciphertext, err := e.Seal(common.StringToBytes(message), common.StringToBytes(additionalData))
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptWithAdditionalData decrypts the base64 string with additional data
+func (e *Envelope) DecryptWithAdditionalData(cipher, additionalData string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(cipher)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	plaintext, err := e.Open(ciphertext, common.StringToBytes(additionalData))
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return string(plaintext), nil
+}
+
+// RewrapString rewraps the base64 string with the new key wrapper and returns the new base64 string
+func (e *Envelope) RewrapString(cipher string, wrapper KeyWrapper) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(cipher)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	ciphertext, err = e.Rewrap(ciphertext, wrapper)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// seal wraps the data key and concatenates the header, the wrapped key and the AEAD ciphertext
+func (e *Envelope) seal(key, aeadCiphertext []byte) ([]byte, error) {
+	if e.wrapper == nil {
+		return nil, errors.New("key wrapper should not be nil")
+	}
+
+	wrappedKey, err := e.wrapper.WrapKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrappedKey) > math.MaxUint16 {
+		return nil, errors.Errorf("wrapped key is too long. length: %d", len(wrappedKey))
+	}
+
+	ciphertext := make([]byte, EnvelopeHeaderSize, EnvelopeHeaderSize+len(wrappedKey)+len(aeadCiphertext))
+	ciphertext[constant.ZeroInt] = EnvelopeCurrentVersion
+	ciphertext[constant.OneInt] = byte(e.wrapper.GetWrapAlgorithm())
+	binary.BigEndian.PutUint16(ciphertext[constant.TwoInt:EnvelopeHeaderSize], uint16(len(wrappedKey)))
+	ciphertext = append(ciphertext, wrappedKey...)
+
+	return append(ciphertext, aeadCiphertext...), nil
+}
+
+// open parses the ciphertext, unwraps the data key and returns the data key and the AEAD ciphertext
+func (e *Envelope) open(ciphertext []byte) ([]byte, []byte, error) {
+	if e.wrapper == nil {
+		return nil, nil, errors.New("key wrapper should not be nil")
+	}
+	if len(ciphertext) < EnvelopeHeaderSize {
+		return nil, nil, errors.Errorf("invalid ciphertext length. length: %d", len(ciphertext))
+	}
+	if ciphertext[constant.ZeroInt] != EnvelopeVersion1 {
+		return nil, nil, errors.Errorf("unsupported envelope version. version: %d", ciphertext[constant.ZeroInt])
+	}
+	wrapAlgorithm := WrapAlgorithm(ciphertext[constant.OneInt])
+	if wrapAlgorithm != e.wrapper.GetWrapAlgorithm() {
+		return nil, nil, errors.Errorf("wrap algorithm of the ciphertext does not match. expected: %s, actual: %s",
+			e.wrapper.GetWrapAlgorithm().String(), wrapAlgorithm.String())
+	}
+
+	wrappedKeyLength := int(binary.BigEndian.Uint16(ciphertext[constant.TwoInt:EnvelopeHeaderSize]))
+	if len(ciphertext) < EnvelopeHeaderSize+wrappedKeyLength {
+		return nil, nil, errors.Errorf("invalid ciphertext length. length: %d, wrapped key length: %d", len(ciphertext), wrappedKeyLength)
+	}
+
+	key, err := e.wrapper.UnwrapKey(ciphertext[EnvelopeHeaderSize : EnvelopeHeaderSize+wrappedKeyLength])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, ciphertext[EnvelopeHeaderSize+wrappedKeyLength:], nil
+}
@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope_All(t *testing.T) {
+	TestEnvelope_Encrypt(t)
+	TestEnvelope_Rewrap(t)
+}
+
+func TestEnvelope_Encrypt(t *testing.T) {
+	asst := assert.New(t)
+
+	r, err := NewRSA()
+	asst.Nil(err, "test Envelope.Encrypt() failed")
+	s, err := NewSM2()
+	asst.Nil(err, "test Envelope.Encrypt() failed")
+
+	for _, e := range []*Envelope{NewEnvelopeWithRSA(r), NewEnvelopeWithSM2(s)} {
+		cipherText, err := e.EncryptWithAdditionalData(defaultMessage, testAdditionalData)
+		asst.Nil(err, "test Envelope.Encrypt() failed")
+		message, err := e.DecryptWithAdditionalData(cipherText, testAdditionalData)
+		asst.Nil(err, "test Envelope.Encrypt() failed")
+		asst.Equal(defaultMessage, message, "test Envelope.Encrypt() failed")
+		_, err = e.Decrypt(cipherText)
+		asst.NotNil(err, "test Envelope.Encrypt() failed")
+		t.Logf("wrap algorithm: %s, cipher text: %s", e.GetWrapper().GetWrapAlgorithm().String(), cipherText)
+	}
+
+	// the public key only envelope could encrypt but could not decrypt
+	pub := NewEmptyRSA()
+	publicKey, err := r.GetPublicKey()
+	asst.Nil(err, "test Envelope.Encrypt() failed")
+	err = pub.SetPublicKey(publicKey)
+	asst.Nil(err, "test Envelope.Encrypt() failed")
+	cipherText, err := NewEnvelopeWithRSA(pub).Encrypt(defaultMessage)
+	asst.Nil(err, "test Envelope.Encrypt() failed")
+	_, err = NewEnvelopeWithRSA(pub).Decrypt(cipherText)
+	asst.NotNil(err, "test Envelope.Encrypt() failed")
+	message, err := NewEnvelopeWithRSA(r).Decrypt(cipherText)
+	asst.Nil(err, "test Envelope.Encrypt() failed")
+	asst.Equal(defaultMessage, message, "test Envelope.Encrypt() failed")
+}
+
+func TestEnvelope_Rewrap(t *testing.T) {
+	asst := assert.New(t)
+
+	oldRSA, err := NewRSA()
+	asst.Nil(err, "test Envelope.Rewrap() failed")
+	newRSA, err := NewRSA()
+	asst.Nil(err, "test Envelope.Rewrap() failed")
+
+	oldEnvelope := NewEnvelopeWithRSA(oldRSA)
+	newEnvelope := NewEnvelopeWithRSA(newRSA)
+
+	cipherText, err := oldEnvelope.Encrypt(defaultMessage)
+	asst.Nil(err, "test Envelope.Rewrap() failed")
+	_, err = newEnvelope.Decrypt(cipherText)
+	asst.NotNil(err, "test Envelope.Rewrap() failed")
+
+	cipherText, err = oldEnvelope.RewrapString(cipherText, newRSA)
+	asst.Nil(err, "test Envelope.Rewrap() failed")
+	message, err := newEnvelope.Decrypt(cipherText)
+	asst.Nil(err, "test Envelope.Rewrap() failed")
+	asst.Equal(defaultMessage, message, "test Envelope.Rewrap() failed")
+	_, err = oldEnvelope.Decrypt(cipherText)
+	asst.NotNil(err, "test Envelope.Rewrap() failed")
+}
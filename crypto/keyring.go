@@ -0,0 +1,560 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/tjfoc/gmsm/sm2"
+	gmx509 "github.com/tjfoc/gmsm/x509"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	KeyTypeAES     KeyType = "AES"
+	KeyTypeSM4     KeyType = "SM4"
+	KeyTypeHMAC    KeyType = "HMAC"
+	KeyTypeRSA     KeyType = "RSA"
+	KeyTypeSM2     KeyType = "SM2"
+	KeyTypeECDSA   KeyType = "ECDSA"
+	KeyTypeEd25519 KeyType = "Ed25519"
+
+	KeyFormatPEM    = "pem"
+	KeyFormatHex    = "hex"
+	KeyFormatBase64 = "base64"
+
+	pemTypeRSAPrivateKey = "RSA PRIVATE KEY"
+	pemTypeRSAPublicKey  = "RSA PUBLIC KEY"
+	pemTypeECPrivateKey  = "EC PRIVATE KEY"
+	pemTypePrivateKey    = "PRIVATE KEY"
+	pemTypePublicKey     = "PUBLIC KEY"
+
+	sm2PrivateKeyHexLength = 64
+	maxKeyIDLength         = 255
+)
+
+type KeyType string
+
+// Key is a versioned key of the keyring, the symmetric keys use the secret,
+// the asymmetric keys use the private key and the public key, the private key may be nil if the key is only used for verification or encryption
+type Key struct {
+	ID         string
+	Type       KeyType
+	Secret     []byte
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+// NewSymmetricKey returns a new *Key with given secret, the key type must be one of AES, SM4 and HMAC
+func NewSymmetricKey(id string, keyType KeyType, secret []byte) (*Key, error) {
+	switch keyType {
+	case KeyTypeAES, KeyTypeSM4, KeyTypeHMAC:
+	default:
+		return nil, errors.Errorf("symmetric key type must be one of [%s, %s, %s], %s is not valid", KeyTypeAES, KeyTypeSM4, KeyTypeHMAC, keyType)
+	}
+	if len(secret) == constant.ZeroInt {
+		return nil, errors.Errorf("secret of the key should not be empty. id: %s", id)
+	}
+
+	return &Key{
+		ID:     id,
+		Type:   keyType,
+		Secret: append([]byte{}, secret...),
+	}, nil
+}
+
+// NewAsymmetricKey returns a new *Key with given private key or public key, the key type is detected by the key,
+// privateKey could be nil if the key is only used for verification or encryption
+func NewAsymmetricKey(id string, privateKey, publicKey interface{}) (*Key, error) {
+	if privateKey != nil {
+		switch pk := privateKey.(type) {
+		case *rsa.PrivateKey:
+			return &Key{ID: id, Type: KeyTypeRSA, PrivateKey: pk, PublicKey: &pk.PublicKey}, nil
+		case *sm2.PrivateKey:
+			return &Key{ID: id, Type: KeyTypeSM2, PrivateKey: pk, PublicKey: &pk.PublicKey}, nil
+		case *ecdsa.PrivateKey:
+			return &Key{ID: id, Type: KeyTypeECDSA, PrivateKey: pk, PublicKey: &pk.PublicKey}, nil
+		case ed25519.PrivateKey:
+			return &Key{ID: id, Type: KeyTypeEd25519, PrivateKey: pk, PublicKey: pk.Public()}, nil
+		default:
+			return nil, errors.Errorf("unsupported private key type: %T", privateKey)
+		}
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Type: KeyTypeRSA, PublicKey: publicKey}, nil
+	case *sm2.PublicKey:
+		return &Key{ID: id, Type: KeyTypeSM2, PublicKey: publicKey}, nil
+	case *ecdsa.PublicKey:
+		return &Key{ID: id, Type: KeyTypeECDSA, PublicKey: publicKey}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Type: KeyTypeEd25519, PublicKey: publicKey}, nil
+	default:
+		return nil, errors.Errorf("unsupported public key type: %T", publicKey)
+	}
+}
+
+// NewKeyWithPEM returns a new *Key with given pem data, the key type is detected by the pem block,
+// it supports PKCS#1, PKCS#8, SEC 1 and PKIX encoded rsa, ecdsa, ed25519 and sm2 keys
+func NewKeyWithPEM(id string, pemData []byte) (*Key, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.Errorf("could not decode pem data. id: %s", id)
+	}
+
+	switch block.Type {
+	case pemTypeRSAPrivateKey:
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return NewAsymmetricKey(id, privateKey, nil)
+	case pemTypeRSAPublicKey:
+		publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return NewAsymmetricKey(id, nil, publicKey)
+	case pemTypeECPrivateKey:
+		privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return NewAsymmetricKey(id, privateKey, nil)
+	case pemTypePrivateKey:
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			// the standard library does not support the sm2 curve
+			sm2PrivateKey, sm2Err := gmx509.ParsePKCS8UnecryptedPrivateKey(block.Bytes)
+			if sm2Err != nil {
+				return nil, errors.Trace(err)
+			}
+			return NewAsymmetricKey(id, sm2PrivateKey, nil)
+		}
+		return NewAsymmetricKey(id, privateKey, nil)
+	case pemTypePublicKey:
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			sm2PublicKey, sm2Err := gmx509.ParseSm2PublicKey(block.Bytes)
+			if sm2Err != nil {
+				return nil, errors.Trace(err)
+			}
+			return NewAsymmetricKey(id, nil, sm2PublicKey)
+		}
+		return NewAsymmetricKey(id, nil, publicKey)
+	default:
+		return nil, errors.Errorf("unsupported pem block type. id: %s, type: %s", id, block.Type)
+	}
+}
+
+// NewKeyWithHex returns a new *Key with given hex string,
+// for the sm2 keys, the hex string could be the private key or the public key, for other key types, the hex string is the secret
+func NewKeyWithHex(id string, keyType KeyType, hexStr string) (*Key, error) {
+	if keyType == KeyTypeSM2 {
+		if len(hexStr) == sm2PrivateKeyHexLength {
+			privateKey, err := ConvertHexToSM2PrivateKey(hexStr)
+			if err != nil {
+				return nil, err
+			}
+			return NewAsymmetricKey(id, privateKey, nil)
+		}
+
+		publicKey, err := ConvertHexToSM2PublicKey(hexStr)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsymmetricKey(id, nil, publicKey)
+	}
+
+	secret, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return NewSymmetricKey(id, keyType, secret)
+}
+
+// IsPrivate returns if the key could be used to decrypt or sign
+func (k *Key) IsPrivate() bool {
+	return len(k.Secret) > constant.ZeroInt || k.PrivateKey != nil
+}
+
+// GetSigningKey returns the key which is used to sign,
+// the secret of the hmac keys, or the private key of the asymmetric keys
+func (k *Key) GetSigningKey() (interface{}, error) {
+	switch k.Type {
+	case KeyTypeHMAC:
+		return k.Secret, nil
+	case KeyTypeRSA, KeyTypeSM2, KeyTypeECDSA, KeyTypeEd25519:
+		if k.PrivateKey == nil {
+			return nil, errors.Errorf("private key of the key is missing. id: %s", k.ID)
+		}
+		return k.PrivateKey, nil
+	default:
+		return nil, errors.Errorf("key could not be used to sign. id: %s, type: %s", k.ID, k.Type)
+	}
+}
+
+// GetVerificationKey returns the key which is used to verify,
+// the secret of the hmac keys, or the public key of the asymmetric keys
+func (k *Key) GetVerificationKey() (interface{}, error) {
+	switch k.Type {
+	case KeyTypeHMAC:
+		return k.Secret, nil
+	case KeyTypeRSA, KeyTypeSM2, KeyTypeECDSA, KeyTypeEd25519:
+		return k.PublicKey, nil
+	default:
+		return nil, errors.Errorf("key could not be used to verify. id: %s, type: %s", k.ID, k.Type)
+	}
+}
+
+// GetEncryptor returns the AEAD of the symmetric keys or the envelope of the rsa and sm2 keys
+func (k *Key) GetEncryptor() (Encryptor, error) {
+	switch k.Type {
+	case KeyTypeAES:
+		return NewAESGCMWithKey(k.Secret)
+	case KeyTypeSM4:
+		return NewSM4GCMWithKey(k.Secret)
+	case KeyTypeRSA:
+		r := &RSA{publicKey: k.PublicKey.(*rsa.PublicKey)}
+		if k.PrivateKey != nil {
+			r.privateKey = k.PrivateKey.(*rsa.PrivateKey)
+		}
+		return NewEnvelopeWithRSA(r), nil
+	case KeyTypeSM2:
+		s := &SM2{publicKey: k.PublicKey.(*sm2.PublicKey)}
+		if k.PrivateKey != nil {
+			s.privateKey = k.PrivateKey.(*sm2.PrivateKey)
+		}
+		return NewEnvelopeWithSM2(s), nil
+	default:
+		return nil, errors.Errorf("key could not be used to encrypt. id: %s, type: %s", k.ID, k.Type)
+	}
+}
+
+// Encryptor encrypts and decrypts the data, both AEAD and Envelope implement it
+type Encryptor interface {
+	// Seal encrypts the plaintext with additional data
+	Seal(plaintext, additionalData []byte) ([]byte, error)
+	// Open decrypts the ciphertext with additional data
+	Open(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// Keyring holds multiple versioned keys, the active key is used to encrypt and sign,
+// the other keys are kept to decrypt and verify the data which were protected by them before the rotation
+type Keyring struct {
+	mutex    sync.RWMutex
+	keys     map[string]*Key
+	keyIDs   []string
+	activeID string
+}
+
+// NewKeyring returns a new empty *Keyring
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[string]*Key),
+	}
+}
+
+// NewKeyringWithJSON returns a new *Keyring with given json data, the json data is in the format of:
+// {"active": "key-2", "keys": [{"kid": "key-1", "type": "AES", "format": "hex", "key": "..."}, {"kid": "key-2", "format": "pem", "key": "-----BEGIN..."}]},
+// the format must be one of pem, hex and base64, the type could be omitted if the format is pem,
+// if active is empty, the last private key will be the active key
+func NewKeyringWithJSON(data []byte) (*Keyring, error) {
+	kc := &keyringConfig{}
+	err := json.Unmarshal(data, kc)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	kr := NewKeyring()
+	for _, kec := range kc.Keys {
+		var key *Key
+		switch strings.ToLower(kec.Format) {
+		case KeyFormatPEM:
+			key, err = NewKeyWithPEM(kec.ID, []byte(kec.Key))
+		case KeyFormatHex:
+			key, err = NewKeyWithHex(kec.ID, kec.Type, kec.Key)
+		case KeyFormatBase64:
+			var secret []byte
+			secret, err = base64.StdEncoding.DecodeString(kec.Key)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			key, err = NewSymmetricKey(kec.ID, kec.Type, secret)
+		default:
+			return nil, errors.Errorf("key format must be one of [%s, %s, %s], %s is not valid. id: %s",
+				KeyFormatPEM, KeyFormatHex, KeyFormatBase64, kec.Format, kec.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = kr.AddKey(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if kc.Active == constant.EmptyString {
+		// the public key could not be the active key, so use the last private key
+		for i := len(kr.keyIDs) - constant.OneInt; i >= constant.ZeroInt; i-- {
+			if kr.keys[kr.keyIDs[i]].IsPrivate() {
+				kc.Active = kr.keyIDs[i]
+				break
+			}
+		}
+	}
+	if kc.Active != constant.EmptyString {
+		err = kr.SetActive(kc.Active)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return kr, nil
+}
+
+// NewKeyringWithJSONFile returns a new *Keyring with given json file
+func NewKeyringWithJSONFile(fileName string) (*Keyring, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return NewKeyringWithJSON(data)
+}
+
+// AddKey adds the key to the keyring, the first added private key will be the active key,
+// the public key will never be the active key, as it could not sign or decrypt
+func (kr *Keyring) AddKey(key *Key) error {
+	if key == nil || key.ID == constant.EmptyString {
+		return errors.New("key id should not be empty")
+	}
+
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+
+	_, ok := kr.keys[key.ID]
+	if ok {
+		return errors.Errorf("key already exists. id: %s", key.ID)
+	}
+
+	kr.keys[key.ID] = key
+	kr.keyIDs = append(kr.keyIDs, key.ID)
+	if kr.activeID == constant.EmptyString && key.IsPrivate() {
+		kr.activeID = key.ID
+	}
+
+	return nil
+}
+
+// AddPEMKey adds the key with given pem data to the keyring
+func (kr *Keyring) AddPEMKey(id string, pemData []byte) error {
+	key, err := NewKeyWithPEM(id, pemData)
+	if err != nil {
+		return err
+	}
+
+	return kr.AddKey(key)
+}
+
+// AddPEMKeyFile adds the key with given pem file to the keyring
+func (kr *Keyring) AddPEMKeyFile(id string, fileName string) error {
+	pemData, err := os.ReadFile(fileName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return kr.AddPEMKey(id, pemData)
+}
+
+// AddHexKey adds the key with given hex string to the keyring
+func (kr *Keyring) AddHexKey(id string, keyType KeyType, hexStr string) error {
+	key, err := NewKeyWithHex(id, keyType, hexStr)
+	if err != nil {
+		return err
+	}
+
+	return kr.AddKey(key)
+}
+
+// RemoveKey removes the key from the keyring, the active key could not be removed
+func (kr *Keyring) RemoveKey(id string) error {
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+
+	if id == kr.activeID {
+		return errors.Errorf("could not remove the active key. id: %s", id)
+	}
+	_, ok := kr.keys[id]
+	if !ok {
+		return errors.Errorf("key does not exist. id: %s", id)
+	}
+
+	delete(kr.keys, id)
+	for i, keyID := range kr.keyIDs {
+		if keyID == id {
+			kr.keyIDs = append(kr.keyIDs[:i], kr.keyIDs[i+constant.OneInt:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// SetActive marks the key as the active key, the active key must contain the secret or the private key
+func (kr *Keyring) SetActive(id string) error {
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+
+	key, ok := kr.keys[id]
+	if !ok {
+		return errors.Errorf("key does not exist. id: %s", id)
+	}
+	if !key.IsPrivate() {
+		return errors.Errorf("public key could not be the active key. id: %s", id)
+	}
+
+	kr.activeID = id
+
+	return nil
+}
+
+// GetActiveKeyID returns the id of the active key
+func (kr *Keyring) GetActiveKeyID() string {
+	kr.mutex.RLock()
+	defer kr.mutex.RUnlock()
+
+	return kr.activeID
+}
+
+// GetActiveKey returns the active key
+func (kr *Keyring) GetActiveKey() (*Key, error) {
+	kr.mutex.RLock()
+	defer kr.mutex.RUnlock()
+
+	if kr.activeID == constant.EmptyString {
+		return nil, errors.New("keyring does not have an active key")
+	}
+
+	return kr.keys[kr.activeID], nil
+}
+
+// GetKey returns the key of given id
+func (kr *Keyring) GetKey(id string) (*Key, error) {
+	kr.mutex.RLock()
+	defer kr.mutex.RUnlock()
+
+	key, ok := kr.keys[id]
+	if !ok {
+		return nil, errors.Errorf("key does not exist. id: %s", id)
+	}
+
+	return key, nil
+}
+
+// GetKeyIDs returns the ids of all the keys in the order of adding
+func (kr *Keyring) GetKeyIDs() []string {
+	kr.mutex.RLock()
+	defer kr.mutex.RUnlock()
+
+	return append([]string{}, kr.keyIDs...)
+}
+
+// Seal encrypts the plaintext with the active key, the ciphertext is in the format of:
+// length of the key id(1 byte) | key id | ciphertext of the AEAD or the envelope,
+// so that it could be decrypted after the active key is rotated
+func (kr *Keyring) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	key, err := kr.GetActiveKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(key.ID) > maxKeyIDLength {
+		return nil, errors.Errorf("key id is too long. id: %s", key.ID)
+	}
+
+	encryptor, err := key.GetEncryptor()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := encryptor.Seal(plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, constant.ZeroInt, constant.OneInt+len(key.ID)+len(ciphertext))
+	result = append(result, byte(len(key.ID)))
+	result = append(result, key.ID...)
+
+	return append(result, ciphertext...), nil
+}
+
+// Open decrypts the ciphertext with the key which encrypted it
+func (kr *Keyring) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) == constant.ZeroInt {
+		return nil, errors.New("ciphertext should not be empty")
+	}
+	keyIDLength := int(ciphertext[constant.ZeroInt])
+	if len(ciphertext) < constant.OneInt+keyIDLength {
+		return nil, errors.Errorf("invalid ciphertext length. length: %d", len(ciphertext))
+	}
+
+	key, err := kr.GetKey(string(ciphertext[constant.OneInt : constant.OneInt+keyIDLength]))
+	if err != nil {
+		return nil, err
+	}
+	encryptor, err := key.GetEncryptor()
+	if err != nil {
+		return nil, err
+	}
+
+	return encryptor.Open(ciphertext[constant.OneInt+keyIDLength:], additionalData)
+}
+
+// Encrypt encrypts the message with the active key and returns the base64 string
+func (kr *Keyring) Encrypt(message string) (string, error) {
+	ciphertext, err := kr.Seal([]byte(message), nil)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt decrypts the base64 string with the key which encrypted it
+func (kr *Keyring) Decrypt(cipher string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(cipher)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	plaintext, err := kr.Open(ciphertext, nil)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return string(plaintext), nil
+}
+
+type keyringConfig struct {
+	Active string            `json:"active"`
+	Keys   []*keyEntryConfig `json:"keys"`
+}
+
+type keyEntryConfig struct {
+	ID     string  `json:"kid"`
+	Type   KeyType `json:"type"`
+	Format string  `json:"format"`
+	Key    string  `json:"key"`
+}
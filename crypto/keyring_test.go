@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAESKeyHex = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testSM4KeyHex = "00112233445566778899aabbccddeeff"
+)
+
+func TestKeyring_All(t *testing.T) {
+	TestKeyring_Encrypt(t)
+	TestKeyring_NewKeyringWithJSON(t)
+}
+
+func TestKeyring_Encrypt(t *testing.T) {
+	asst := assert.New(t)
+
+	kr := NewKeyring()
+	err := kr.AddHexKey("key-1", KeyTypeAES, testAESKeyHex)
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+	asst.Equal("key-1", kr.GetActiveKeyID(), "test Keyring.Encrypt() failed")
+
+	oldCipher, err := kr.Encrypt(defaultMessage)
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+
+	// rotate the active key
+	err = kr.AddHexKey("key-2", KeyTypeSM2, sm2PrivateKeyStr)
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+	err = kr.SetActive("key-2")
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+	newCipher, err := kr.Encrypt(defaultMessage)
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+
+	for _, cipher := range []string{oldCipher, newCipher} {
+		message, err := kr.Decrypt(cipher)
+		asst.Nil(err, "test Keyring.Encrypt() failed")
+		asst.Equal(defaultMessage, message, "test Keyring.Encrypt() failed")
+	}
+
+	// the active key could not be removed
+	err = kr.RemoveKey("key-2")
+	asst.NotNil(err, "test Keyring.Encrypt() failed")
+	err = kr.RemoveKey("key-1")
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+	_, err = kr.Decrypt(oldCipher)
+	asst.NotNil(err, "test Keyring.Encrypt() failed")
+	asst.Equal([]string{"key-2"}, kr.GetKeyIDs(), "test Keyring.Encrypt() failed")
+
+	// the public key could not be the active key
+	err = kr.AddHexKey("key-3", KeyTypeSM2, sm2PublicKeyStr)
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+	err = kr.SetActive("key-3")
+	asst.NotNil(err, "test Keyring.Encrypt() failed")
+
+	// the public key will not be the active key even if it is added first
+	kr = NewKeyring()
+	err = kr.AddHexKey("key-1", KeyTypeSM2, sm2PublicKeyStr)
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+	asst.Equal("", kr.GetActiveKeyID(), "test Keyring.Encrypt() failed")
+	_, err = kr.Encrypt(defaultMessage)
+	asst.NotNil(err, "test Keyring.Encrypt() failed")
+	err = kr.AddHexKey("key-2", KeyTypeAES, testAESKeyHex)
+	asst.Nil(err, "test Keyring.Encrypt() failed")
+	asst.Equal("key-2", kr.GetActiveKeyID(), "test Keyring.Encrypt() failed")
+}
+
+func TestKeyring_NewKeyringWithJSON(t *testing.T) {
+	asst := assert.New(t)
+
+	r, err := NewRSA()
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: pemTypeRSAPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(r.privateKey)})
+	publicKey, err := x509.MarshalPKIXPublicKey(r.publicKey)
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: pemTypePublicKey, Bytes: publicKey})
+
+	config := map[string]interface{}{
+		"active": "rsa-1",
+		"keys": []map[string]string{
+			{"kid": "aes-1", "type": string(KeyTypeAES), "format": KeyFormatHex, "key": testAESKeyHex},
+			{"kid": "sm4-1", "type": string(KeyTypeSM4), "format": KeyFormatHex, "key": testSM4KeyHex},
+			{"kid": "rsa-1", "format": KeyFormatPEM, "key": string(privatePEM)},
+			{"kid": "rsa-1-public", "format": KeyFormatPEM, "key": string(publicPEM)},
+		},
+	}
+	data, err := json.Marshal(config)
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+
+	kr, err := NewKeyringWithJSON(data)
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	asst.Equal("rsa-1", kr.GetActiveKeyID(), "test NewKeyringWithJSON() failed")
+	asst.Equal(4, len(kr.GetKeyIDs()), "test NewKeyringWithJSON() failed")
+
+	key, err := kr.GetKey("sm4-1")
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	asst.Equal(testSM4KeyHex, hex.EncodeToString(key.Secret), "test NewKeyringWithJSON() failed")
+	key, err = kr.GetKey("rsa-1-public")
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	asst.Equal(KeyTypeRSA, key.Type, "test NewKeyringWithJSON() failed")
+	asst.False(key.IsPrivate(), "test NewKeyringWithJSON() failed")
+
+	cipher, err := kr.Encrypt(defaultMessage)
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	message, err := kr.Decrypt(cipher)
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	asst.Equal(defaultMessage, message, "test NewKeyringWithJSON() failed")
+
+	// the public key will not be the active key when active is empty
+	config = map[string]interface{}{
+		"keys": []map[string]string{
+			{"kid": "aes-1", "type": string(KeyTypeAES), "format": KeyFormatHex, "key": testAESKeyHex},
+			{"kid": "partner-pub", "format": KeyFormatPEM, "key": string(publicPEM)},
+		},
+	}
+	data, err = json.Marshal(config)
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+
+	kr, err = NewKeyringWithJSON(data)
+	asst.Nil(err, "test NewKeyringWithJSON() failed")
+	asst.Equal("aes-1", kr.GetActiveKeyID(), "test NewKeyringWithJSON() failed")
+}
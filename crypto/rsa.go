@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 
@@ -183,3 +184,36 @@ func (r *RSA) DecryptWithPublicKey(cipher string) (string, error) {
 
 	return string(b), nil
 }
+
+// GetWrapAlgorithm returns the key wrap algorithm, it implements KeyWrapper interface
+func (r *RSA) GetWrapAlgorithm() WrapAlgorithm {
+	return WrapAlgorithmRSAOAEP
+}
+
+// WrapKey encrypts the data key with public key by RSA-OAEP with SHA-256, it implements KeyWrapper interface
+func (r *RSA) WrapKey(key []byte) ([]byte, error) {
+	if r.publicKey == nil {
+		return nil, errors.New("public key should not be nil")
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, r.publicKey, key, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return wrappedKey, nil
+}
+
+// UnwrapKey decrypts the wrapped data key with private key, it implements KeyWrapper interface
+func (r *RSA) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	if r.privateKey == nil {
+		return nil, errors.New("private key should not be nil")
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, r.privateKey, wrappedKey, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return key, nil
+}
@@ -12,6 +12,11 @@ import (
 	"github.com/romberli/go-util/constant"
 )
 
+const (
+	// DefaultSM2UserID is the default user id defined in GM/T 0009
+	DefaultSM2UserID = "1234567812345678"
+)
+
 type SM2 struct {
 	privateKey *sm2.PrivateKey
 	publicKey  *sm2.PublicKey
@@ -141,3 +146,85 @@ func (s *SM2) DecryptWithPrivateKey(cipher string, mode int) (string, error) {
 
 	return common.BytesToString(message), nil
 }
+
+// Sign signs the message with the private key and the default user id, it returns the upper case hex string of the asn.1 encoded signature
+func (s *SM2) Sign(message string) (string, error) {
+	return s.SignWithUserID(message, DefaultSM2UserID)
+}
+
+// Verify verifies the hex string signature of the message with the public key and the default user id
+func (s *SM2) Verify(message, signature string) (bool, error) {
+	return s.VerifyWithUserID(message, signature, DefaultSM2UserID)
+}
+
+// SignWithUserID signs the message with the private key and given user id,
+// the sm3 digest of the message is calculated with the user id and the public key as defined in GM/T 0003,
+// it returns the upper case hex string of the asn.1 encoded signature
+func (s *SM2) SignWithUserID(message, userID string) (string, error) {
+	if s.privateKey == nil {
+		return constant.EmptyString, errors.New("private key should not be nil")
+	}
+
+	r, sig, err := sm2.Sm2Sign(s.privateKey, common.StringToBytes(message), common.StringToBytes(userID), rand.Reader)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+	signature, err := sm2.SignDigitToSignData(r, sig)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	return strings.ToUpper(hex.EncodeToString(signature)), nil
+}
+
+// VerifyWithUserID verifies the hex string signature of the message with the public key and given user id,
+// it returns error only if the signature could not be decoded
+func (s *SM2) VerifyWithUserID(message, signature, userID string) (bool, error) {
+	if s.publicKey == nil {
+		return false, errors.New("public key should not be nil")
+	}
+
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	r, sig, err := sm2.SignDataToSignDigit(signatureBytes)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	return sm2.Sm2Verify(s.publicKey, common.StringToBytes(message), common.StringToBytes(userID), r, sig), nil
+}
+
+// GetWrapAlgorithm returns the key wrap algorithm, it implements KeyWrapper interface
+func (s *SM2) GetWrapAlgorithm() WrapAlgorithm {
+	return WrapAlgorithmSM2
+}
+
+// WrapKey encrypts the data key with public key in C1C3C2 mode, it implements KeyWrapper interface
+func (s *SM2) WrapKey(key []byte) ([]byte, error) {
+	if s.publicKey == nil {
+		return nil, errors.New("public key should not be nil")
+	}
+
+	wrappedKey, err := sm2.Encrypt(s.publicKey, key, rand.Reader, sm2.C1C3C2)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return wrappedKey, nil
+}
+
+// UnwrapKey decrypts the wrapped data key with private key, it implements KeyWrapper interface
+func (s *SM2) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	if s.privateKey == nil {
+		return nil, errors.New("private key should not be nil")
+	}
+
+	key, err := sm2.Decrypt(s.privateKey, wrappedKey, sm2.C1C3C2)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return key, nil
+}
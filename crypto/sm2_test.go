@@ -27,6 +27,8 @@ func TestSM2_All(t *testing.T) {
 	TestSM2_GetKeyHexString(t)
 	TestSM2_Encrypt(t)
 	TestSM2_Decrypt(t)
+	TestSM2_Sign(t)
+	TestSM2_VerifyKey(t)
 }
 
 func TestSM2_GetKeyHexString(t *testing.T) {
@@ -79,3 +81,32 @@ func TestSM2_VerifyKey(t *testing.T) {
 	isMatch := verifyKeyMatch(privateKey, publicKey)
 	asst.True(isMatch, "test SM2.VerifyKey() failed")
 }
+
+func TestSM2_Sign(t *testing.T) {
+	asst := assert.New(t)
+
+	signature, err := SignWithSM2PrivateKeyString(sm2PrivateKeyStr, defaultMessage)
+	asst.Nil(err, "test SM2.Sign() failed")
+	ok, err := VerifyWithSM2PublicKeyString(sm2PublicKeyStr, defaultMessage, signature)
+	asst.Nil(err, "test SM2.Sign() failed")
+	asst.True(ok, "test SM2.Sign() failed")
+	ok, err = VerifyWithSM2PublicKeyString(sm2PublicKeyStr, "other message", signature)
+	asst.Nil(err, "test SM2.Sign() failed")
+	asst.False(ok, "test SM2.Sign() failed")
+
+	// the user id takes part in the digest
+	s, err := NewSM2WithPrivateKeyHexString(sm2PrivateKeyStr)
+	asst.Nil(err, "test SM2.Sign() failed")
+	signature, err = s.SignWithUserID(defaultMessage, "alice@example.com")
+	asst.Nil(err, "test SM2.Sign() failed")
+	ok, err = s.VerifyWithUserID(defaultMessage, signature, "alice@example.com")
+	asst.Nil(err, "test SM2.Sign() failed")
+	asst.True(ok, "test SM2.Sign() failed")
+	ok, err = s.Verify(defaultMessage, signature)
+	asst.Nil(err, "test SM2.Sign() failed")
+	asst.False(ok, "test SM2.Sign() failed")
+
+	_, err = s.Verify(defaultMessage, "not hex")
+	asst.NotNil(err, "test SM2.Sign() failed")
+	t.Logf("signature: %s", signature)
+}
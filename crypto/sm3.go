@@ -0,0 +1,51 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/tjfoc/gmsm/sm3"
+
+	"github.com/romberli/go-util/common"
+)
+
+// SM3Sum returns the sm3 digest of the data
+func SM3Sum(data []byte) []byte {
+	return sm3.Sm3Sum(data)
+}
+
+// SM3HexString returns the upper case hex string of the sm3 digest of the message
+func SM3HexString(message string) string {
+	return strings.ToUpper(hex.EncodeToString(SM3Sum(common.StringToBytes(message))))
+}
+
+// SM3Base64String returns the base64 string of the sm3 digest of the message
+func SM3Base64String(message string) string {
+	return base64.StdEncoding.EncodeToString(SM3Sum(common.StringToBytes(message)))
+}
+
+// HMACSM3 returns the hmac of the data with sm3 as the hash function
+func HMACSM3(key, data []byte) []byte {
+	h := hmac.New(sm3.New, key)
+	// hash.Hash never returns an error
+	_, _ = h.Write(data)
+
+	return h.Sum(nil)
+}
+
+// HMACSM3HexString returns the upper case hex string of the hmac-sm3 of the message
+func HMACSM3HexString(key, message string) string {
+	return strings.ToUpper(hex.EncodeToString(HMACSM3(common.StringToBytes(key), common.StringToBytes(message))))
+}
+
+// HMACSM3Base64String returns the base64 string of the hmac-sm3 of the message
+func HMACSM3Base64String(key, message string) string {
+	return base64.StdEncoding.EncodeToString(HMACSM3(common.StringToBytes(key), common.StringToBytes(message)))
+}
+
+// VerifyHMACSM3 checks if the mac is the hmac-sm3 of the data in constant time
+func VerifyHMACSM3(key, data, mac []byte) bool {
+	return hmac.Equal(HMACSM3(key, data), mac)
+}
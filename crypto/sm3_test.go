@@ -0,0 +1,36 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSM3_All(t *testing.T) {
+	TestSM3_SM3HexString(t)
+	TestSM3_HMACSM3(t)
+}
+
+func TestSM3_SM3HexString(t *testing.T) {
+	asst := assert.New(t)
+
+	// the example of GM/T 0004
+	asst.Equal("66C7F0F462EEEDD9D1F2D46BDC10E4E24167C4875CF2F7A2297DA02B8F4BA8E0", SM3HexString("abc"), "test SM3HexString() failed")
+	asst.Equal(44, len(SM3Base64String(defaultMessage)), "test SM3HexString() failed")
+}
+
+func TestSM3_HMACSM3(t *testing.T) {
+	asst := assert.New(t)
+
+	key := "secret"
+	mac := HMACSM3HexString(key, defaultMessage)
+	asst.Equal(64, len(mac), "test HMACSM3() failed")
+	asst.NotEqual(mac, HMACSM3HexString("other", defaultMessage), "test HMACSM3() failed")
+
+	macBytes, err := hex.DecodeString(mac)
+	asst.Nil(err, "test HMACSM3() failed")
+	asst.True(VerifyHMACSM3([]byte(key), []byte(defaultMessage), macBytes), "test HMACSM3() failed")
+	asst.False(VerifyHMACSM3([]byte(key), []byte("other"), macBytes), "test HMACSM3() failed")
+	t.Logf("hmac-sm3: %s", mac)
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/tjfoc/gmsm/sm4"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	SM4KeySize = 16
+	// sm4RandomKeySize is the size of the random bytes, the base64 string of which is exactly SM4KeySize long
+	sm4RandomKeySize = 12
+)
+
+type SM4 struct {
+	key string
+}
+
+// NewSM4 returns a new *SM4 with a random key
+func NewSM4() (*SM4, error) {
+	key := make([]byte, sm4RandomKeySize)
+
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSM4WithKey(base64.StdEncoding.EncodeToString(key)), nil
+}
+
+// NewSM4WithKey returns a new *SM4 with given key, the key will be padded or truncated to 16 bytes
+func NewSM4WithKey(key string) *SM4 {
+	return newSM4WithKey(key)
+}
+
+// newSM4WithKey returns a new *SM4 with given key
+func newSM4WithKey(key string) *SM4 {
+	return &SM4{
+		key: padSM4Key(key),
+	}
+}
+
+// GetKey returns the key
+func (s *SM4) GetKey() string {
+	return s.key
+}
+
+// Encrypt encrypts the message in cbc mode and returns the base64 string
+func (s *SM4) Encrypt(message string) (string, error) {
+	block, err := sm4.NewCipher(common.StringToBytes(s.key))
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return encryptCBC(block, message)
+}
+
+// Decrypt decrypts the base64 string in cbc mode
+func (s *SM4) Decrypt(ciphertextBase64 string) (string, error) {
+	block, err := sm4.NewCipher(common.StringToBytes(s.key))
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return decryptCBC(block, ciphertextBase64)
+}
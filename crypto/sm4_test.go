@@ -0,0 +1,38 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSM4_All(t *testing.T) {
+	TestSM4_Encrypt(t)
+	TestSM4_Decrypt(t)
+}
+
+func TestSM4_Encrypt(t *testing.T) {
+	asst := assert.New(t)
+
+	s, err := NewSM4()
+	asst.Nil(err, "test SM4.Encrypt() failed")
+	asst.Equal(SM4KeySize, len(s.GetKey()), "test SM4.Encrypt() failed")
+
+	cipherText, err := s.Encrypt(defaultMessage)
+	asst.Nil(err, "test SM4.Encrypt() failed")
+	message, err := s.Decrypt(cipherText)
+	asst.Nil(err, "test SM4.Encrypt() failed")
+	asst.Equal(defaultMessage, message, "test SM4.Encrypt() failed")
+}
+
+func TestSM4_Decrypt(t *testing.T) {
+	asst := assert.New(t)
+
+	key := "aaa"
+	cipherText, err := EncryptWithSM4Key(key, defaultMessage)
+	asst.Nil(err, "test SM4.Decrypt() failed")
+	message, err := DecryptWithSM4Key(key, cipherText)
+	asst.Nil(err, "test SM4.Decrypt() failed")
+	asst.Equal(defaultMessage, message, "test SM4.Decrypt() failed")
+	t.Logf("actual key: %s, cipher text: %s", NewSM4WithKey(key).GetKey(), cipherText)
+}
@@ -1,6 +1,10 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"math/big"
 	"strings"
@@ -8,6 +12,7 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/tjfoc/gmsm/sm2"
 
+	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 )
 
@@ -35,6 +40,14 @@ func padAESKey(key string) string {
 	return key
 }
 
+func padSM4Key(key string) string {
+	if len(key) >= SM4KeySize {
+		return key[:SM4KeySize]
+	}
+
+	return key + strings.Repeat(constant.EqualString, SM4KeySize-len(key))
+}
+
 func ConvertHexToSM2PrivateKey(hexPrivateKeyStr string) (*sm2.PrivateKey, error) {
 	keyBytes, err := hex.DecodeString(hexPrivateKeyStr)
 	if err != nil {
@@ -130,3 +143,77 @@ func padSM2Key(value *big.Int) []byte {
 
 	return buf
 }
+
+// encryptCBC encrypts the message with the block in cbc mode and pkcs5 padding,
+// the random initialization vector is prepended to the ciphertext, it returns the base64 string
+func encryptCBC(block cipher.Block, message string) (string, error) {
+	plaintext := common.StringToBytes(message)
+	// pad message
+	blockSize := block.BlockSize()
+	padText := applyPKCS5Padding(plaintext, blockSize)
+
+	// generate a random initialization vector
+	ciphertext := make([]byte, blockSize+len(padText))
+	iv := ciphertext[:blockSize]
+	if _, err := rand.Read(iv); err != nil {
+		return constant.EmptyString, err
+	}
+
+	// create a new CBC encryptor
+	stream := cipher.NewCBCEncrypter(block, iv)
+	stream.CryptBlocks(ciphertext[blockSize:], padText)
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decryptCBC decrypts the base64 string with the block in cbc mode and removes the pkcs5 padding
+func decryptCBC(block cipher.Block, ciphertextBase64 string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextBase64)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	// get IV and ciphertext
+	blockSize := block.BlockSize()
+	if len(ciphertext) < blockSize*constant.TwoInt || len(ciphertext)%blockSize != constant.ZeroInt {
+		return constant.EmptyString, errors.Errorf("invalid ciphertext length. length: %d", len(ciphertext))
+	}
+	iv := ciphertext[:blockSize]
+	ciphertext = ciphertext[blockSize:]
+
+	// create a new CBC decrypter
+	stream := cipher.NewCBCDecrypter(block, iv)
+	// decrypt cipher
+	stream.CryptBlocks(ciphertext, ciphertext)
+
+	// remove padding
+	plaintext, err := removePKCS5Padding(ciphertext, blockSize)
+	if err != nil {
+		return constant.EmptyString, err
+	}
+
+	return string(plaintext), nil
+}
+
+func applyPKCS5Padding(plaintext []byte, blockSize int) []byte {
+	padding := blockSize - len(plaintext)%blockSize
+	padText := bytes.Repeat([]byte{byte(padding)}, padding)
+
+	return append(plaintext, padText...)
+}
+
+// removePKCS5Padding removes the padding of the decrypted text, it returns error if the padding is invalid,
+// note that cbc mode does not check the integrity, use AEAD if the ciphertext may be tampered
+func removePKCS5Padding(padText []byte, blockSize int) ([]byte, error) {
+	padding := int(padText[len(padText)-1])
+	if padding == constant.ZeroInt || padding > blockSize {
+		return nil, errors.New("invalid padding of the decrypted text")
+	}
+	for _, b := range padText[len(padText)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("invalid padding of the decrypted text")
+		}
+	}
+
+	return padText[:len(padText)-padding], nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/romberli/log"
 
+	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 )
 
@@ -67,16 +68,31 @@ var (
 )
 
 type Client struct {
-	client        *http.Client
-	maxWaitTime   int
-	maxRetryCount int
-	delayTime     int
+	client         *http.Client
+	retryOption    *common.RetryOption
+	circuitBreaker *common.CircuitBreaker
 }
 
+// NewClient returns a new *Client, maxWaitTime is in seconds, delayTime is in milliseconds
 func NewClient(client *http.Client, maxWaitTime, maxRetryCount, delayTime int) (*Client, error) {
 	return newClient(client, maxWaitTime, maxRetryCount, delayTime)
 }
 
+// NewClientWithRetryOption returns a new *Client with given retry option, if the option is nil, the requests will not be retried
+func NewClientWithRetryOption(client *http.Client, option *common.RetryOption) (*Client, error) {
+	if option != nil {
+		err := option.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Client{
+		client:      client,
+		retryOption: option,
+	}, nil
+}
+
 func NewClientWithDefault() (*Client, error) {
 	client := &http.Client{
 		Transport: DefaultTransport,
@@ -96,32 +112,36 @@ func NewClientWithRetry() (*Client, error) {
 }
 
 func newClient(client *http.Client, maxWaitTime, maxRetryCount, delayTime int) (*Client, error) {
-	c := &Client{
-		client:        client,
-		maxWaitTime:   maxWaitTime,
-		maxRetryCount: maxRetryCount,
-		delayTime:     delayTime,
-	}
-
-	err := c.validate()
+	err := validateRetryArgs(maxWaitTime, maxRetryCount, delayTime)
 	if err != nil {
 		return nil, err
 	}
 
-	return c, nil
+	return NewClientWithRetryOption(client, newRetryOption(maxWaitTime, maxRetryCount, delayTime))
 }
 
-func (c *Client) validate() error {
+// newRetryOption returns a new *common.RetryOption with fixed delay time, maxWaitTime is in seconds, delayTime is in milliseconds
+func newRetryOption(maxWaitTime, maxRetryCount, delayTime int) *common.RetryOption {
+	maxWait := common.UnlimitedWaitTime
+	if maxWaitTime >= constant.ZeroInt {
+		maxWait = time.Duration(maxWaitTime) * time.Second
+	}
+
+	return common.NewRetryOption(maxRetryCount, maxWait, time.Duration(delayTime)*time.Millisecond, nil)
+}
+
+// validateRetryArgs validates the retry arguments
+func validateRetryArgs(maxWaitTime, maxRetryCount, delayTime int) error {
 	// validate maxWaitTime
-	if c.maxWaitTime < DefaultUnlimitedWaitTime {
+	if maxWaitTime < DefaultUnlimitedWaitTime {
 		return errors.New("maximum wait time argument should not be smaller than -1")
 	}
 	// validate maxRetryCount
-	if c.maxRetryCount < DefaultUnlimitedRetryCount {
+	if maxRetryCount < DefaultUnlimitedRetryCount {
 		return errors.New("maximum retry count argument should not be smaller than -1")
 	}
 	// validate delay
-	if c.delayTime < constant.ZeroInt {
+	if delayTime < constant.ZeroInt {
 		return errors.New("delay time argument should not be smaller than 0")
 	}
 
@@ -164,10 +184,53 @@ func (c *Client) SetMaxIdleConnsPerHost(maxIdleConnsPerHost int) {
 	c.client.Transport.(*http.Transport).MaxIdleConnsPerHost = maxIdleConnsPerHost
 }
 
+// SetRetryOption sets the retry option with fixed delay time, maxWaitTime is in seconds, delay is in milliseconds
 func (c *Client) SetRetryOption(maxWaitTime, maxRetryCount, delay int) {
-	c.maxWaitTime = maxWaitTime
-	c.maxRetryCount = maxRetryCount
-	c.delayTime = delay
+	c.retryOption = newRetryOption(maxWaitTime, maxRetryCount, delay)
+}
+
+// SetRetryPolicy sets the retry option, so that the backoff, the retryable errors and the hook could be configured,
+// if the option is nil, the requests will not be retried
+func (c *Client) SetRetryPolicy(option *common.RetryOption) {
+	c.retryOption = option
+}
+
+// GetRetryOption returns the retry option
+func (c *Client) GetRetryOption() *common.RetryOption {
+	return c.retryOption
+}
+
+// SetCircuitBreaker sets the circuit breaker, the host of the url is used as the target,
+// the transport errors and the 5xx responses are reported as failures,
+// if the circuit of the host is open, the requests fail fast without retrying
+func (c *Client) SetCircuitBreaker(cb *common.CircuitBreaker) {
+	c.circuitBreaker = cb
+}
+
+// allow checks if the request to the host of the url is allowed by the circuit breaker, it returns the target
+func (c *Client) allow(rawURL string) (string, error) {
+	if c.circuitBreaker == nil {
+		return constant.EmptyString, nil
+	}
+
+	u, err := url.Parse(PrepareURL(rawURL))
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	return u.Host, c.circuitBreaker.Allow(u.Host)
+}
+
+// report reports the result of the request to the circuit breaker
+func (c *Client) report(target string, resp *http.Response, err error) {
+	if c.circuitBreaker == nil {
+		return
+	}
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		err = errors.Errorf("server error. status code: %d", resp.StatusCode)
+	}
+
+	c.circuitBreaker.Report(target, err)
 }
 
 // PrepareURL prepares the url
@@ -188,70 +251,41 @@ func (c *Client) PrepareURL(scheme, addr, path string, params map[string]string)
 }
 
 func (c *Client) Get(url string) (*http.Response, error) {
-	maxWait := c.maxWaitTime
-	if maxWait < constant.ZeroInt {
-		maxWait = int(constant.Century.Seconds())
-	}
-
-	timer := time.NewTimer(time.Duration(maxWait) * time.Second)
-	defer timer.Stop()
-
-	var i int
-
-	for {
-		resp, err := c.client.Get(PrepareURL(url))
+	var resp *http.Response
+	err := common.Retry(func() error {
+		target, err := c.allow(url)
 		if err != nil {
-			// check retry count
-			if c.maxRetryCount >= constant.ZeroInt && i >= c.maxRetryCount {
-				return resp, errors.Trace(err)
-			}
-			// check wait time
-			select {
-			case <-timer.C:
-				return resp, errors.Trace(err)
-			default:
-				time.Sleep(time.Duration(c.delayTime) * time.Millisecond)
-			}
-
-			i++
-			continue
+			return err
 		}
+		resp, err = c.client.Get(PrepareURL(url))
+		c.report(target, resp, err)
 
-		return resp, nil
+		return err
+	}, c.retryOption)
+	if err != nil {
+		return resp, errors.Trace(err)
 	}
+
+	return resp, nil
 }
 
 func (c *Client) Post(url string, body []byte) (*http.Response, error) {
-	maxWait := c.maxWaitTime
-	if maxWait < constant.ZeroInt {
-		maxWait = int(constant.Century.Seconds())
-	}
-	timer := time.NewTimer(time.Duration(maxWait) * time.Second)
-	defer timer.Stop()
-
-	var i int
-
-	for {
-		resp, err := c.client.Post(PrepareURL(url), DefaultContentTypeValue, bytes.NewBuffer(body))
+	var resp *http.Response
+	err := common.Retry(func() error {
+		target, err := c.allow(url)
 		if err != nil {
-			// check retry count
-			if c.maxRetryCount >= constant.ZeroInt && i >= c.maxRetryCount {
-				return resp, errors.Trace(err)
-			}
-			// check wait timeout
-			select {
-			case <-timer.C:
-				return resp, errors.Trace(err)
-			default:
-				time.Sleep(time.Duration(c.delayTime) * time.Millisecond)
-			}
-
-			i++
-			continue
+			return err
 		}
+		resp, err = c.client.Post(PrepareURL(url), DefaultContentTypeValue, bytes.NewBuffer(body))
+		c.report(target, resp, err)
 
-		return resp, nil
+		return err
+	}, c.retryOption)
+	if err != nil {
+		return resp, errors.Trace(err)
 	}
+
+	return resp, nil
 }
 
 func (c *Client) PostDAS(url string, body []byte) ([]byte, error) {
@@ -287,70 +321,36 @@ func (c *Client) PostDAS(url string, body []byte) ([]byte, error) {
 }
 
 func (c *Client) SendRequestWithBasicAuth(method, url string, body []byte, user, pass string) ([]byte, error) {
-	maxWait := c.maxWaitTime
-	if maxWait < constant.ZeroInt {
-		maxWait = int(constant.Century.Seconds())
-	}
-	timer := time.NewTimer(time.Duration(maxWait) * time.Second)
-	defer timer.Stop()
-
-	var i int
-
-	for {
-		resp, err := c.sendRequestWithBasicAuth(method, url, body, user, pass)
+	var resp []byte
+	err := common.Retry(func() error {
+		var err error
+		resp, err = c.sendRequestWithBasicAuth(method, url, body, user, pass)
 		if err != nil {
 			log.Warnf("http.Client.SendRequestWithBasicAuth(): send request with basic auth failed. error: %s", err.Error())
-			// check retry count
-			if c.maxRetryCount >= constant.ZeroInt && i >= c.maxRetryCount {
-				return resp, errors.Trace(err)
-			}
-			// check wait timeout
-			select {
-			case <-timer.C:
-				return resp, errors.Trace(err)
-			default:
-				time.Sleep(time.Duration(c.delayTime) * time.Millisecond)
-			}
-
-			i++
-			continue
 		}
 
-		return resp, nil
+		return err
+	}, c.retryOption)
+	if err != nil {
+		return resp, errors.Trace(err)
 	}
+
+	return resp, nil
 }
 
 func (c *Client) SendRequestWithHeaderAndBody(method, url string, header map[string]string, body []byte) ([]byte, error) {
-	maxWait := c.maxWaitTime
-	if maxWait < constant.ZeroInt {
-		maxWait = int(constant.Century.Seconds())
-	}
-	timer := time.NewTimer(time.Duration(maxWait) * time.Second)
-	defer timer.Stop()
+	var resp []byte
+	err := common.Retry(func() error {
+		var err error
+		resp, err = c.sendRequestWithHeaderAndBody(method, url, header, body)
 
-	var i int
-
-	for {
-		resp, err := c.sendRequestWithHeaderAndBody(method, url, header, body)
-		if err != nil {
-			// check retry count
-			if c.maxRetryCount >= constant.ZeroInt && i >= c.maxRetryCount {
-				return resp, errors.Trace(err)
-			}
-			// check wait timeout
-			select {
-			case <-timer.C:
-				return resp, errors.Trace(err)
-			default:
-				time.Sleep(time.Duration(c.delayTime) * time.Millisecond)
-			}
-
-			i++
-			continue
-		}
-
-		return resp, nil
+		return err
+	}, c.retryOption)
+	if err != nil {
+		return resp, errors.Trace(err)
 	}
+
+	return resp, nil
 }
 
 func (c *Client) GetWithBasicAuth(url string, body []byte, user, pass string) ([]byte, error) {
@@ -370,7 +370,12 @@ func (c *Client) sendRequestWithBasicAuth(method, url string, body []byte, user,
 	req.Header.Set(DefaultContentTypeKey, DefaultContentTypeValue)
 	req.SetBasicAuth(user, pass)
 
+	target, err := c.allow(url)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := c.GetClient().Do(req)
+	c.report(target, resp, err)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -398,7 +403,12 @@ func (c *Client) sendRequestWithHeaderAndBody(method, url string, header map[str
 		req.Header.Set(k, v)
 	}
 
+	target, err := c.allow(url)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := c.GetClient().Do(req)
+	c.report(target, resp, err)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -3,12 +3,15 @@ package http
 import (
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/romberli/log"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 )
 
@@ -171,3 +174,50 @@ func TestClient_PostWithBasicAuth(t *testing.T) {
 	asst.Nil(err, "test PostWithBasicAuth() failed")
 	t.Log(string(respBody))
 }
+
+func TestClient_SetRetryPolicy(t *testing.T) {
+	asst := assert.New(t)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	c, err := NewClientWithDefault()
+	asst.Nil(err, "test SetRetryPolicy() failed")
+	var attempts int
+	option := common.NewRetryOptionWithBackoff(2, common.UnlimitedWaitTime, time.Millisecond, 10*time.Millisecond,
+		common.DefaultBackoffMultiplier, common.DefaultBackoffJitter, nil)
+	option.OnRetry = func(attempt int, delay time.Duration, err error) {
+		attempts++
+	}
+	c.SetRetryPolicy(option)
+
+	_, err = c.Get(url)
+	asst.NotNil(err, "test SetRetryPolicy() failed")
+	asst.Equal(2, attempts, "test SetRetryPolicy() failed")
+}
+
+func TestClient_SetCircuitBreaker(t *testing.T) {
+	asst := assert.New(t)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c, err := NewClientWithDefault()
+	asst.Nil(err, "test SetCircuitBreaker() failed")
+	cb, err := common.NewCircuitBreaker(common.NewCircuitBreakerOption(time.Minute, 10, 2, 0.5, time.Minute, 1, 0))
+	asst.Nil(err, "test SetCircuitBreaker() failed")
+	c.SetCircuitBreaker(cb)
+
+	for i := 0; i < 5; i++ {
+		_, err = c.SendRequestWithHeaderAndBody(MethodGet, server.URL, nil, nil)
+		asst.NotNil(err, "test SetCircuitBreaker() failed")
+	}
+	// the circuit opened after 2 failures, the following requests failed fast
+	asst.Equal(2, requests, "test SetCircuitBreaker() failed")
+	asst.True(common.IsCircuitBreakerError(err), "test SetCircuitBreaker() failed")
+}
@@ -0,0 +1,307 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pingcap/errors"
+	"github.com/romberli/log"
+
+	"github.com/romberli/go-util/auth"
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultAuthorizationHeader = "Authorization"
+	DefaultBearerScheme        = "Bearer"
+	// DefaultMaxLogBodySize is the default maximum size of the request and response bodies in the access log,
+	// the larger bodies are not logged, as the truncated json could not be masked
+	DefaultMaxLogBodySize = 4096
+
+	defaultOmittedBodyTemplate  = "<omitted, size: %d>"
+	defaultOversizeBodyTemplate = "<omitted, larger than %d bytes>"
+)
+
+type claimsContextKey struct{}
+
+// Middleware wraps the http handler
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps the handler with the middlewares, the first middleware is the outermost one
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - constant.OneInt; i >= constant.ZeroInt; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// ErrorHandler writes the response when the middleware fails
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// DefaultErrorHandler writes the status code and the status text, the error is logged but not written to the response,
+// so that the details of the token validation are not exposed to the clients
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, status int, err error) {
+	log.Warnf("http middleware: request failed. method: %s, path: %s, status: %d, error: %s", r.Method, r.URL.Path, status, err.Error())
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", DefaultBearerScheme)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// NewJWTMiddleware returns a new Middleware which extracts the bearer token from the authorization header,
+// verifies it with the auth and puts the claims into the request context, the claims could be got by GetClaimsFromContext()
+func NewJWTMiddleware(a *auth.Auth) Middleware {
+	return NewJWTMiddlewareWithErrorHandler(a, DefaultErrorHandler)
+}
+
+// NewJWTMiddlewareWithErrorHandler returns a new Middleware with given error handler
+func NewJWTMiddlewareWithErrorHandler(a *auth.Auth, errorHandler ErrorHandler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := GetBearerToken(r)
+			if err != nil {
+				errorHandler(w, r, http.StatusUnauthorized, err)
+				return
+			}
+
+			claims := make(jwt.MapClaims)
+			err = a.ParseWithContext(r.Context(), token, &claims)
+			if err != nil {
+				errorHandler(w, r, http.StatusUnauthorized, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims)))
+		})
+	}
+}
+
+// GetBearerToken returns the bearer token of the authorization header
+func GetBearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get(DefaultAuthorizationHeader)
+	if header == constant.EmptyString {
+		return constant.EmptyString, errors.New("authorization header is missing")
+	}
+
+	scheme, token, ok := strings.Cut(header, constant.SpaceString)
+	if !ok || !strings.EqualFold(scheme, DefaultBearerScheme) {
+		return constant.EmptyString, errors.New("authorization header is not a bearer token")
+	}
+	token = strings.TrimSpace(token)
+	if token == constant.EmptyString {
+		return constant.EmptyString, errors.New("bearer token is empty")
+	}
+
+	return token, nil
+}
+
+// GetClaimsFromContext returns the claims which are put into the context by the jwt middleware
+func GetClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(jwt.MapClaims)
+
+	return claims, ok
+}
+
+// AccessLogEntry is the entry of the access log, the bodies and the query are masked
+type AccessLogEntry struct {
+	Method       string
+	Path         string
+	Query        string
+	RemoteAddr   string
+	Status       int
+	Duration     time.Duration
+	RequestBody  string
+	ResponseBody string
+}
+
+// String returns the string of the entry
+func (ale *AccessLogEntry) String() string {
+	return fmt.Sprintf("method: %s, path: %s, query: %s, remote addr: %s, status: %d, duration: %s, request body: %s, response body: %s",
+		ale.Method, ale.Path, ale.Query, ale.RemoteAddr, ale.Status, ale.Duration, ale.RequestBody, ale.ResponseBody)
+}
+
+// AccessLogFunc writes the access log entry
+type AccessLogFunc func(entry *AccessLogEntry)
+
+// DefaultAccessLogFunc writes the access log entry with the global logger
+func DefaultAccessLogFunc(entry *AccessLogEntry) {
+	log.Infof("http access log: %s", entry.String())
+}
+
+// AccessLogMasker masks the sensitive fields of the request and response bodies before they are logged
+type AccessLogMasker struct {
+	sensitiveFields   []string
+	sensitivePatterns []*common.SensitivePattern
+	excludes          []string
+	maxBodySize       int
+}
+
+// NewAccessLogMasker returns a new *AccessLogMasker
+func NewAccessLogMasker(sensitiveFields []string, sensitivePatterns []*common.SensitivePattern, maxBodySize int, excludes ...string) *AccessLogMasker {
+	return &AccessLogMasker{
+		sensitiveFields:   sensitiveFields,
+		sensitivePatterns: sensitivePatterns,
+		excludes:          excludes,
+		maxBodySize:       maxBodySize,
+	}
+}
+
+// NewAccessLogMaskerWithDefault returns a new *AccessLogMasker with the default sensitive keywords and patterns
+func NewAccessLogMaskerWithDefault() *AccessLogMasker {
+	return NewAccessLogMasker(common.DefaultSensitiveKeywords, common.DefaultSensitivePatterns, DefaultMaxLogBodySize)
+}
+
+// MaskBody masks the json body, the body which is not json or is larger than the maximum size is omitted,
+// as the sensitive fields of it could not be found
+func (alm *AccessLogMasker) MaskBody(body []byte) string {
+	if len(body) == constant.ZeroInt {
+		return constant.EmptyString
+	}
+	if len(body) > alm.maxBodySize {
+		return fmt.Sprintf(defaultOversizeBodyTemplate, alm.maxBodySize)
+	}
+
+	masked, err := common.MaskJSONWithPatterns(body, alm.sensitiveFields, alm.sensitivePatterns, alm.excludes...)
+	if err != nil {
+		return fmt.Sprintf(defaultOmittedBodyTemplate, len(body))
+	}
+
+	return string(masked)
+}
+
+// MaskQuery masks the values of the sensitive query parameters
+func (alm *AccessLogMasker) MaskQuery(query url.Values) string {
+	if len(query) == constant.ZeroInt {
+		return constant.EmptyString
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		return fmt.Sprintf(defaultOmittedBodyTemplate, len(query))
+	}
+	masked, err := common.MaskJSONWithPatterns(data, alm.sensitiveFields, alm.sensitivePatterns, alm.excludes...)
+	if err != nil {
+		return fmt.Sprintf(defaultOmittedBodyTemplate, len(query))
+	}
+	// the values of the sensitive parameters are masked as a string instead of the string array
+	result := make(map[string]interface{})
+	err = json.Unmarshal(masked, &result)
+	if err != nil {
+		return fmt.Sprintf(defaultOmittedBodyTemplate, len(query))
+	}
+
+	maskedQuery := make(url.Values, len(result))
+	for key, value := range result {
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				maskedQuery.Add(key, fmt.Sprint(item))
+			}
+		default:
+			maskedQuery.Add(key, fmt.Sprint(v))
+		}
+	}
+
+	return maskedQuery.Encode()
+}
+
+// NewAccessLogMiddleware returns a new Middleware which writes the access log with masked request and response bodies,
+// so that the passwords and the secrets never reach the logs
+func NewAccessLogMiddleware(masker *AccessLogMasker, logFunc AccessLogFunc) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			var requestBody []byte
+			if r.Body != nil {
+				// read at most maxBodySize + 1 bytes, and the rest of the body is still readable by the next handler
+				var err error
+				requestBody, err = io.ReadAll(io.LimitReader(r.Body, int64(masker.maxBodySize)+constant.OneInt))
+				if err != nil {
+					DefaultErrorHandler(w, r, http.StatusBadRequest, errors.Trace(err))
+					return
+				}
+				r.Body = &readCloser{
+					Reader: io.MultiReader(bytes.NewReader(requestBody), r.Body),
+					Closer: r.Body,
+				}
+			}
+
+			rw := newAccessLogResponseWriter(w, masker.maxBodySize)
+			next.ServeHTTP(rw, r)
+
+			logFunc(&AccessLogEntry{
+				Method:       r.Method,
+				Path:         r.URL.Path,
+				Query:        masker.MaskQuery(r.URL.Query()),
+				RemoteAddr:   r.RemoteAddr,
+				Status:       rw.status,
+				Duration:     time.Since(start),
+				RequestBody:  masker.MaskBody(requestBody),
+				ResponseBody: rw.getMaskedBody(masker),
+			})
+		})
+	}
+}
+
+// NewAccessLogMiddlewareWithDefault returns a new Middleware with the default masker and log function
+func NewAccessLogMiddlewareWithDefault() Middleware {
+	return NewAccessLogMiddleware(NewAccessLogMaskerWithDefault(), DefaultAccessLogFunc)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// accessLogResponseWriter records the status code and the head of the response body
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	maxBodySize int
+}
+
+// newAccessLogResponseWriter returns a new *accessLogResponseWriter
+func newAccessLogResponseWriter(w http.ResponseWriter, maxBodySize int) *accessLogResponseWriter {
+	return &accessLogResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		maxBodySize:    maxBodySize,
+	}
+}
+
+// WriteHeader records the status code and writes it
+func (w *accessLogResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the head of the body and writes the body
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	if remain := w.maxBodySize + constant.OneInt - w.body.Len(); remain > constant.ZeroInt {
+		w.body.Write(b[:min(remain, len(b))])
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer, it is used by http.ResponseController
+func (w *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// getMaskedBody returns the masked response body
+func (w *accessLogResponseWriter) getMaskedBody(masker *AccessLogMasker) string {
+	return masker.MaskBody(w.body.Bytes())
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/auth"
+)
+
+const (
+	testMiddlewareReqBodyStr  = `{"user":"root","password":"aaAA11..","sql":"create user 'u1'@'%' identified by 'bbBB22..'"}`
+	testMiddlewareRespBodyStr = `{"code":0,"secret_key":"abcdef"}`
+)
+
+func testNewMiddlewareAuth(t *testing.T) *auth.Auth {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key failed. error: %s", err.Error())
+	}
+	a, err := auth.NewAuthWithKeyPair("kid-1", privateKey, nil)
+	if err != nil {
+		t.Fatalf("create auth failed. error: %s", err.Error())
+	}
+
+	return a
+}
+
+func TestMiddleware_JWT(t *testing.T) {
+	asst := assert.New(t)
+
+	a := testNewMiddlewareAuth(t)
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := GetClaimsFromContext(r.Context())
+		asst.True(ok, "test JWT() failed")
+		_, _ = w.Write([]byte(claims["username"].(string)))
+	}), NewJWTMiddleware(a))
+
+	token, err := a.SignWithClaims(jwt.MapClaims{"username": "test", "exp": float64(time.Now().Add(time.Minute).Unix())}, nil)
+	asst.Nil(err, "test JWT() failed")
+
+	// valid token
+	req := httptest.NewRequest(MethodGet, "/api/v1/test", nil)
+	req.Header.Set(DefaultAuthorizationHeader, DefaultBearerScheme+" "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	asst.Equal(StatusOK, rec.Code, "test JWT() failed")
+	asst.Equal("test", rec.Body.String(), "test JWT() failed")
+
+	// missing token
+	req = httptest.NewRequest(MethodGet, "/api/v1/test", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	asst.Equal(http.StatusUnauthorized, rec.Code, "test JWT() failed")
+	asst.Equal(DefaultBearerScheme, rec.Header().Get("WWW-Authenticate"), "test JWT() failed")
+
+	// token signed by another key
+	token, err = testNewMiddlewareAuth(t).SignWithClaims(jwt.MapClaims{"username": "test"}, nil)
+	asst.Nil(err, "test JWT() failed")
+	req = httptest.NewRequest(MethodGet, "/api/v1/test", nil)
+	req.Header.Set(DefaultAuthorizationHeader, DefaultBearerScheme+" "+token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	asst.Equal(http.StatusUnauthorized, rec.Code, "test JWT() failed")
+}
+
+func TestMiddleware_GetBearerToken(t *testing.T) {
+	asst := assert.New(t)
+
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	req.Header.Set(DefaultAuthorizationHeader, "bearer  abc ")
+	token, err := GetBearerToken(req)
+	asst.Nil(err, "test GetBearerToken() failed")
+	asst.Equal("abc", token, "test GetBearerToken() failed")
+
+	req.Header.Set(DefaultAuthorizationHeader, "Basic abc")
+	_, err = GetBearerToken(req)
+	asst.NotNil(err, "test GetBearerToken() failed")
+}
+
+func TestMiddleware_AccessLog(t *testing.T) {
+	asst := assert.New(t)
+
+	var entry *AccessLogEntry
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the next handler could still read the whole request body
+		body, err := io.ReadAll(r.Body)
+		asst.Nil(err, "test AccessLog() failed")
+		asst.Equal(testMiddlewareReqBodyStr, string(body), "test AccessLog() failed")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(testMiddlewareRespBodyStr))
+	}), NewAccessLogMiddleware(NewAccessLogMaskerWithDefault(), func(e *AccessLogEntry) { entry = e }))
+
+	req := httptest.NewRequest(MethodPost, "/api/v1/user?name=root&password=aaAA11..", strings.NewReader(testMiddlewareReqBodyStr))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	asst.Equal(http.StatusCreated, rec.Code, "test AccessLog() failed")
+	asst.Equal(testMiddlewareRespBodyStr, rec.Body.String(), "test AccessLog() failed")
+
+	asst.Equal(http.StatusCreated, entry.Status, "test AccessLog() failed")
+	asst.Equal("/api/v1/user", entry.Path, "test AccessLog() failed")
+	asst.NotContains(entry.String(), "aaAA11..", "test AccessLog() failed")
+	asst.NotContains(entry.String(), "bbBB22..", "test AccessLog() failed")
+	asst.NotContains(entry.String(), "abcdef", "test AccessLog() failed")
+	asst.Contains(entry.Query, "name=root", "test AccessLog() failed")
+	t.Log(entry.String())
+}
+
+func TestMiddleware_MaskBody(t *testing.T) {
+	asst := assert.New(t)
+
+	masker := NewAccessLogMasker([]string{"pass"}, nil, 32)
+	// not json
+	asst.Equal("<omitted, size: 13>", masker.MaskBody([]byte("password=abcd")), "test MaskBody() failed")
+	// too large
+	asst.Equal("<omitted, larger than 32 bytes>", masker.MaskBody([]byte(testMiddlewareReqBodyStr)), "test MaskBody() failed")
+	asst.Equal(`{"pass":"******"}`, masker.MaskBody([]byte(`{"pass":"abcd"}`)), "test MaskBody() failed")
+}
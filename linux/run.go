@@ -0,0 +1,294 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	defaultPTYTerm   = "xterm"
+	defaultPTYHeight = 40
+	defaultPTYWidth  = 80
+	defaultPTYSpeed  = 14400
+)
+
+// envKeyRegexp matches the valid names of the environment variables, they are exported by shell on the remote host
+var envKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CommandResult is the result of a command executed locally or remotely
+type CommandResult struct {
+	Command string
+	Stdout  string
+	Stderr  string
+	// ExitCode is UnknownExitCode if the command did not exit normally, for example, it was killed or failed to start
+	ExitCode  int
+	StartTime time.Time
+	Duration  time.Duration
+}
+
+// newCommandResult returns a new *CommandResult
+func newCommandResult(command string) *CommandResult {
+	return &CommandResult{
+		Command:   command,
+		ExitCode:  UnknownExitCode,
+		StartTime: time.Now(),
+	}
+}
+
+// IsSuccess returns if the command exited with 0
+func (cr *CommandResult) IsSuccess() bool {
+	return cr.ExitCode == DefaultSuccessReturnValue
+}
+
+// GetOutput returns the merged and trimmed stdout and stderr, it is the same as the output of ExecuteCommand()
+func (cr *CommandResult) GetOutput() string {
+	return strings.TrimSpace(cr.Stdout + cr.Stderr)
+}
+
+// String returns the string value of the result
+func (cr *CommandResult) String() string {
+	return fmt.Sprintf("command: %s, exit code: %d, duration: %s, stdout: %s, stderr: %s",
+		cr.Command, cr.ExitCode, cr.Duration, cr.Stdout, cr.Stderr)
+}
+
+// RunOption is the option of Run()
+type RunOption interface {
+	apply(*runOptions)
+}
+
+type runOptionFunc func(*runOptions)
+
+func (f runOptionFunc) apply(options *runOptions) {
+	f(options)
+}
+
+type runOptions struct {
+	envKeys    []string
+	envValues  []string
+	workDir    string
+	stdin      io.Reader
+	stdoutFunc func(line string)
+	stderrFunc func(line string)
+	timeout    time.Duration
+	pty        bool
+}
+
+// newRunOptions returns a new *runOptions with given options
+func newRunOptions(opts ...RunOption) *runOptions {
+	options := &runOptions{}
+	for _, opt := range opts {
+		opt.apply(options)
+	}
+
+	return options
+}
+
+// validate validates the options
+func (ro *runOptions) validate() error {
+	for _, key := range ro.envKeys {
+		if !envKeyRegexp.MatchString(key) {
+			return errors.Errorf("environment variable name must match %s, %s is not valid", envKeyRegexp.String(), key)
+		}
+	}
+
+	return nil
+}
+
+// withTimeout returns the context with the timeout of the options
+func (ro *runOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ro.timeout > constant.ZeroInt {
+		return context.WithTimeout(ctx, ro.timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// RunEnvOption sets an environment variable of the command, it could be specified multiple times
+func RunEnvOption(key, value string) RunOption {
+	return runOptionFunc(func(options *runOptions) {
+		options.envKeys = append(options.envKeys, key)
+		options.envValues = append(options.envValues, value)
+	})
+}
+
+// RunWorkDirOption sets the working directory of the command
+func RunWorkDirOption(workDir string) RunOption {
+	return runOptionFunc(func(options *runOptions) {
+		options.workDir = workDir
+	})
+}
+
+// RunStdinOption sets the stdin of the command
+func RunStdinOption(stdin io.Reader) RunOption {
+	return runOptionFunc(func(options *runOptions) {
+		options.stdin = stdin
+	})
+}
+
+// RunStdoutOption sets the callback which is called with each line of stdout as soon as it is output,
+// the line does not contain the trailing newline, stdout is still collected in the result
+func RunStdoutOption(fn func(line string)) RunOption {
+	return runOptionFunc(func(options *runOptions) {
+		options.stdoutFunc = fn
+	})
+}
+
+// RunStderrOption sets the callback which is called with each line of stderr as soon as it is output,
+// the line does not contain the trailing newline, stderr is still collected in the result
+func RunStderrOption(fn func(line string)) RunOption {
+	return runOptionFunc(func(options *runOptions) {
+		options.stderrFunc = fn
+	})
+}
+
+// RunTimeoutOption sets the timeout of the command, the command will be killed after the timeout
+func RunTimeoutOption(timeout time.Duration) RunOption {
+	return runOptionFunc(func(options *runOptions) {
+		options.timeout = timeout
+	})
+}
+
+// RunPTYOption allocates a pseudo terminal for the command, some commands, for example, sudo with requiretty, need it,
+// note that stderr is merged into stdout by the terminal, and it is only supported by the remote commands
+func RunPTYOption() RunOption {
+	return runOptionFunc(func(options *runOptions) {
+		options.pty = true
+	})
+}
+
+// lineWriter collects the output and calls the callback with each line
+type lineWriter struct {
+	mutex    sync.Mutex
+	buffer   bytes.Buffer
+	pending  []byte
+	callback func(line string)
+}
+
+// newLineWriter returns a new *lineWriter, the callback could be nil
+func newLineWriter(callback func(line string)) *lineWriter {
+	return &lineWriter{callback: callback}
+}
+
+// Write implements io.Writer interface
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	lw.buffer.Write(p)
+	if lw.callback == nil {
+		return len(p), nil
+	}
+
+	lw.pending = append(lw.pending, p...)
+	for {
+		index := bytes.IndexByte(lw.pending, '\n')
+		if index < constant.ZeroInt {
+			break
+		}
+		lw.callback(strings.TrimSuffix(string(lw.pending[:index]), "\r"))
+		lw.pending = lw.pending[index+constant.OneInt:]
+	}
+
+	return len(p), nil
+}
+
+// Flush calls the callback with the last line which does not end with a newline
+func (lw *lineWriter) Flush() {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	if lw.callback != nil && len(lw.pending) > constant.ZeroInt {
+		lw.callback(string(lw.pending))
+		lw.pending = nil
+	}
+}
+
+// String returns all the output
+func (lw *lineWriter) String() string {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	return lw.buffer.String()
+}
+
+// Run executes the command with shell on the local host and waits for it to complete,
+// if the context is done or the timeout exceeded, the command and its child processes will be killed,
+// the result is always returned, and the error is not nil if the command did not exit with 0
+func Run(ctx context.Context, command string, opts ...RunOption) (*CommandResult, error) {
+	options := newRunOptions(opts...)
+	result := newCommandResult(command)
+	defer func() { result.Duration = time.Since(result.StartTime) }()
+
+	if options.pty {
+		return result, errors.New("pty is only supported by the remote commands")
+	}
+	err := options.validate()
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := options.withTimeout(ctx)
+	defer cancel()
+
+	stdout := newLineWriter(options.stdoutFunc)
+	stderr := newLineWriter(options.stderrFunc)
+
+	cmd := exec.CommandContext(ctx, shPath, dashCArg, command)
+	cmd.Dir = options.workDir
+	if len(options.envKeys) > constant.ZeroInt {
+		cmd.Env = os.Environ()
+		for i, key := range options.envKeys {
+			cmd.Env = append(cmd.Env, key+constant.EqualString+options.envValues[i])
+		}
+	}
+	cmd.Stdin = options.stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// run the command in a new process group, so its child processes could be killed together
+	setProcessGroup(cmd)
+
+	err = cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, errors.Annotatef(ctx.Err(), "command was killed. command: %s", command)
+		}
+
+		return result, errors.Trace(err)
+	}
+
+	return result, nil
+}
+
+// shellQuote quotes the string with single quotes, so that it could be used as a single argument of shell
+func shellQuote(s string) string {
+	return constant.SingleQuoteString + strings.ReplaceAll(s, constant.SingleQuoteString, `'\''`) + constant.SingleQuoteString
+}
+
+// shellJoin quotes each argument and joins them with spaces, the result could be run by shell as argv
+func shellJoin(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+
+	return strings.Join(quoted, constant.SpaceString)
+}
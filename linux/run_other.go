@@ -0,0 +1,11 @@
+//go:build !unix
+
+package linux
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing as the process group is not supported,
+// only the command itself is killed when the context is done
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,126 @@
+package linux
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	testRunCommand       = "echo test_stdout; echo test_stderr >&2; exit 3"
+	testRunStreamCommand = "echo line1; sleep 0.2; echo line2; printf line3"
+	testRunEnvCommand    = `echo "$TEST_ENV"; pwd`
+	testRunEnvValue      = `it's "quoted" $HOME`
+	testRunStdinCommand  = "cat"
+	testRunSleepCommand  = "sleep 5 & sleep 5; echo done"
+)
+
+// testRunner is implemented by the local and remote runners
+type testRunner func(ctx context.Context, cmd string, opts ...RunOption) (*CommandResult, error)
+
+func testNewSSHRunner(t *testing.T) testRunner {
+	server := newTestSSHServer(t)
+	conn, err := NewSSHConnWithConfig(server.getConfig(testSSHServerUserPass))
+	if err != nil {
+		t.Fatalf("connect to test ssh server failed. error:\n%+v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn.Run
+}
+
+func testRunAll(t *testing.T, run testRunner) {
+	asst := assert.New(t)
+
+	// exit code
+	result, err := run(context.Background(), testRunCommand)
+	asst.NotNil(err, "test Run() failed")
+	asst.Equal(3, result.ExitCode, "test Run() failed")
+	asst.False(result.IsSuccess(), "test Run() failed")
+	asst.Equal("test_stdout\n", result.Stdout, "test Run() failed")
+	asst.Equal("test_stderr\n", result.Stderr, "test Run() failed")
+	asst.Equal(testRunCommand, result.Command, "test Run() failed")
+
+	// streaming
+	var (
+		mutex sync.Mutex
+		lines []string
+		times []time.Time
+	)
+	result, err = run(context.Background(), testRunStreamCommand, RunStdoutOption(func(line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		lines = append(lines, line)
+		times = append(times, time.Now())
+	}))
+	asst.Nil(err, "test Run() failed")
+	asst.Equal(constant.ZeroInt, result.ExitCode, "test Run() failed")
+	asst.Equal([]string{"line1", "line2", "line3"}, lines, "test Run() failed")
+	asst.Equal("line1\nline2\nline3", result.Stdout, "test Run() failed")
+	if asst.Equal(3, len(times), "test Run() failed") {
+		// the first line is received before the command exits
+		asst.True(times[constant.OneInt].Sub(times[constant.ZeroInt]) >= 100*time.Millisecond, "test Run() failed")
+	}
+
+	// env and working directory
+	workDir := t.TempDir()
+	result, err = run(context.Background(), testRunEnvCommand, RunEnvOption("TEST_ENV", testRunEnvValue), RunWorkDirOption(workDir))
+	asst.Nil(err, "test Run() failed")
+	asst.Equal(testRunEnvValue+constant.CRLFString+workDir+constant.CRLFString, result.Stdout, "test Run() failed")
+
+	// stdin
+	result, err = run(context.Background(), testRunStdinCommand, RunStdinOption(strings.NewReader(testContent)))
+	asst.Nil(err, "test Run() failed")
+	asst.Equal(testContent, result.Stdout, "test Run() failed")
+
+	// timeout kills the process and its child processes
+	start := time.Now()
+	result, err = run(context.Background(), testRunSleepCommand, RunTimeoutOption(300*time.Millisecond))
+	asst.Equal(context.DeadlineExceeded, errors.Cause(err), "test Run() failed")
+	asst.Equal(UnknownExitCode, result.ExitCode, "test Run() failed")
+	asst.True(time.Since(start) < 3*time.Second, "test Run() failed")
+	asst.True(result.Duration < 3*time.Second, "test Run() failed")
+
+	// context
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	_, err = run(ctx, testRunSleepCommand)
+	asst.Equal(context.Canceled, errors.Cause(err), "test Run() failed")
+}
+
+func TestRun_All(t *testing.T) {
+	TestRun_Local(t)
+	TestRun_SSH(t)
+	TestRun_SSHPTY(t)
+}
+
+func TestRun_Local(t *testing.T) {
+	asst := assert.New(t)
+
+	testRunAll(t, Run)
+
+	_, err := Run(context.Background(), testSSHServerCommand, RunPTYOption())
+	asst.NotNil(err, "test Run() failed")
+}
+
+func TestRun_SSH(t *testing.T) {
+	testRunAll(t, testNewSSHRunner(t))
+}
+
+func TestRun_SSHPTY(t *testing.T) {
+	asst := assert.New(t)
+
+	run := testNewSSHRunner(t)
+	// the in-process server does not allocate a real terminal, it only checks the request is accepted
+	result, err := run(context.Background(), testSSHServerCommand, RunPTYOption())
+	asst.Nil(err, "test Run() failed")
+	asst.Equal(testSSHServerOutput, result.GetOutput(), "test Run() failed")
+}
@@ -1,11 +1,12 @@
 package linux
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -26,26 +27,30 @@ const (
 	DefaultSplitStr           = constant.CRLFString
 	DefaultSuccessReturnValue = constant.ZeroInt
 	DefaultFailedReturnValue  = constant.OneInt
+	UnknownExitCode           = -1
 	DefaultSSHTimeout         = 10 * time.Second
 	DefaultSSHPortNum         = 22
 	DefaultSSHUserName        = "root"
 	DefaultSSHUserPass        = "root"
 	DefaultByteBufferSize     = 1024 * 1024 // 1MB
 
-	hostNameCommand   = "/usr/bin/hostname"
-	pathExistsCommand = "/usr/bin/test -e %s && echo 0 || echo 1"
-	isDirCommand      = "/usr/bin/test -d %s && echo 0 || echo 1"
-	lsCommand         = "/usr/bin/ls %s"
-	mkdirCommand      = "/usr/bin/mkdir -p %s"
-	rmCommand         = "/usr/bin/rm -rf %s"
-	catCommand        = "/usr/bin/cat %s"
-	touchCommand      = "/usr/bin/touch %s"
-	cpCommand         = "/usr/bin/cp -r %s %s"
-	mvCommand         = "/usr/bin/mv %s %s"
-	chownCommand      = "/usr/bin/chown -R %s:%s %s"
-	chmodCommand      = "/usr/bin/chmod -R %s %s"
-
-	sudoPrefix = `sudo su -c "%s"`
+	DefaultBinDir   = "/usr/bin"
+	DefaultSudoPath = "/usr/bin/sudo"
+
+	hostNameBinary  = "hostname"
+	testBinary      = "test"
+	lsBinary        = "ls"
+	mkdirBinary     = "mkdir"
+	rmBinary        = "rm"
+	catBinary       = "cat"
+	touchBinary     = "touch"
+	cpBinary        = "cp"
+	mvBinary        = "mv"
+	chownBinary     = "chown"
+	chmodBinary     = "chmod"
+	sha256sumBinary = "sha256sum"
+
+	endOfOptionsArg = "--"
 )
 
 type SSHConfig struct {
@@ -53,6 +58,29 @@ type SSHConfig struct {
 	PortNum  int
 	UserName string
 	UserPass string
+	// PrivateKey is the PEM encoded private key, Passphrase decrypts it if it is encrypted
+	PrivateKey []byte
+	Passphrase string
+	// AgentSocket is the unix socket of ssh-agent, if it is empty, ssh-agent will not be used
+	AgentSocket string
+	// KeyboardInteractive answers the keyboard-interactive questions, if it is nil, keyboard-interactive will not be used
+	KeyboardInteractive ssh.KeyboardInteractiveChallenge
+	// HostKeyPolicy specifies how to verify the host key with KnownHostsFile
+	HostKeyPolicy  HostKeyPolicy
+	KnownHostsFile string
+	// ProxyJumps are the jump hosts, the connection goes through them in order, like "ssh -J"
+	ProxyJumps []*SSHConfig
+	Timeout    time.Duration
+	// BinDir is the directory of the binaries used by the file helpers, for example, rm and chmod,
+	// if it is empty, the binaries will be searched in $PATH of the remote host
+	BinDir string
+	// SudoPath is the path of sudo binary, if it is empty, sudo will be searched in $PATH of the remote host
+	SudoPath string
+	// SudoUser is the user which the commands run as when using sudo, if it is empty, the commands run as root
+	SudoUser string
+	// SudoPass is sent to sudo via stdin only when sudo prompts for it, so the command never reads it,
+	// if it is empty, sudo runs in non-interactive mode and fails if a password is required
+	SudoPass string
 	useSudo  bool
 }
 
@@ -69,11 +97,15 @@ func NewSSHConfigWithDefault(hostIP string) *SSHConfig {
 // newSSHConfig return a new *SSHConfig
 func newSSHConfig(hostIP string, portNum int, userName string, userPass string, useSudo bool) *SSHConfig {
 	return &SSHConfig{
-		hostIP,
-		portNum,
-		userName,
-		userPass,
-		useSudo,
+		HostIp:        hostIP,
+		PortNum:       portNum,
+		UserName:      userName,
+		UserPass:      userPass,
+		HostKeyPolicy: HostKeyPolicyInsecure,
+		Timeout:       DefaultSSHTimeout,
+		BinDir:        DefaultBinDir,
+		SudoPath:      DefaultSudoPath,
+		useSudo:       useSudo,
 	}
 }
 
@@ -82,10 +114,35 @@ func (c *SSHConfig) SetUseSudo(useSudo bool) {
 	c.useSudo = useSudo
 }
 
+// SetSudo enables sudo and sets the user which the commands run as and the sudo password,
+// both of them could be empty, which means running as root and sudo does not require a password
+func (c *SSHConfig) SetSudo(sudoUser, sudoPass string) {
+	c.useSudo = true
+	c.SudoUser = sudoUser
+	c.SudoPass = sudoPass
+}
+
+// SetSudoPath sets the path of sudo binary
+func (c *SSHConfig) SetSudoPath(sudoPath string) {
+	c.SudoPath = sudoPath
+}
+
+// SetBinDir sets the directory of the binaries used by the file helpers
+func (c *SSHConfig) SetBinDir(binDir string) {
+	c.BinDir = binDir
+}
+
+// GetAddr returns the address of the host, the format is host:port
+func (c *SSHConfig) GetAddr() string {
+	return net.JoinHostPort(c.HostIp, strconv.Itoa(c.PortNum))
+}
+
 type SSHConn struct {
 	Config     *SSHConfig
 	SSHClient  *ssh.Client
 	SFTPClient *sftp.Client
+	// jumpClients are the clients of the jump hosts, they are closed after SSHClient
+	jumpClients []*ssh.Client
 }
 
 // NewSSHConn returns a new *SSHConn
@@ -93,47 +150,60 @@ func NewSSHConn(hostIP string, portNum int, userName, userPass string, useSudo b
 	return newSSHConnWithConfig(NewSSHConfig(hostIP, portNum, userName, userPass, useSudo))
 }
 
-// newSSHConnWithConfig returns *SSHConn with given config
-func newSSHConnWithConfig(config *SSHConfig) (*SSHConn, error) {
-	var (
-		auth         []ssh.AuthMethod
-		addr         string
-		clientConfig *ssh.ClientConfig
-		sshClient    *ssh.Client
-		sftpClient   *sftp.Client
-	)
-
-	// get auth method
-	auth = append(auth, ssh.Password(config.UserPass))
+// NewSSHConnWithConfig returns a new *SSHConn with given config
+func NewSSHConnWithConfig(config *SSHConfig) (*SSHConn, error) {
+	return newSSHConnWithConfig(config)
+}
 
-	hostKeyCallBack := func(host string, remote net.Addr, key ssh.PublicKey) error {
-		return nil
-	}
+// NewSSHConnWithContext returns a new *SSHConn with given config, it returns the error of the context if the context is done before connected
+func NewSSHConnWithContext(ctx context.Context, config *SSHConfig) (*SSHConn, error) {
+	type connResult struct {
+		conn *SSHConn
+		err  error
+	}
+
+	ch := make(chan *connResult, constant.OneInt)
+	go func() {
+		conn, err := newSSHConnWithConfig(config)
+		ch <- &connResult{conn: conn, err: err}
+	}()
+
+	select {
+	case result := <-ch:
+		return result.conn, result.err
+	case <-ctx.Done():
+		go func() {
+			// close the connection which is connected too late
+			result := <-ch
+			if result.conn != nil {
+				_ = result.conn.Close()
+			}
+		}()
 
-	clientConfig = &ssh.ClientConfig{
-		User:            config.UserName,
-		Auth:            auth,
-		Timeout:         DefaultSSHTimeout,
-		HostKeyCallback: hostKeyCallBack,
+		return nil, errors.Annotatef(ctx.Err(), "connect to ssh failed. addr: %s", config.GetAddr())
 	}
+}
 
-	// connect to ssh
-	addr = fmt.Sprintf("%s:%d", config.HostIp, config.PortNum)
-	sshClient, err := ssh.Dial(constant.TransportProtocolTCP, addr, clientConfig)
+// newSSHConnWithConfig returns *SSHConn with given config
+func newSSHConnWithConfig(config *SSHConfig) (*SSHConn, error) {
+	// connect to ssh, through the jump hosts if any
+	sshClient, jumpClients, err := dialSSH(config)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, err
 	}
 
 	// create sftp client
-	sftpClient, err = sftp.NewClient(sshClient)
+	sftpClient, err := sftp.NewClient(sshClient)
 	if err != nil {
+		closeSSHClients(sshClient, jumpClients)
 		return nil, errors.Trace(err)
 	}
 
 	return &SSHConn{
-		config,
-		sshClient,
-		sftpClient,
+		Config:      config,
+		SSHClient:   sshClient,
+		SFTPClient:  sftpClient,
+		jumpClients: jumpClients,
 	}, nil
 }
 
@@ -144,7 +214,19 @@ func (conn *SSHConn) Close() error {
 		return errors.Trace(err)
 	}
 
-	return errors.Trace(conn.SSHClient.Close())
+	err = conn.SSHClient.Close()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// close the jump hosts from the nearest one
+	for i := len(conn.jumpClients) - constant.OneInt; i >= constant.ZeroInt; i-- {
+		err = conn.jumpClients[i].Close()
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
 }
 
 // SetUseSudo sets if use sudo or not
@@ -165,70 +247,217 @@ func (conn *SSHConn) ExecuteCommandWithoutOutput(cmd string) error {
 }
 
 func (conn *SSHConn) executeCommand(cmd string) (string, error) {
-	var (
-		stdOutBuffer bytes.Buffer
-		stdErrBuffer bytes.Buffer
-	)
+	result, err := conn.Run(context.Background(), cmd)
+	if err != nil {
+		if result.Stderr != constant.EmptyString {
+			err = errors.Errorf("%s%+v", result.Stderr, errors.Trace(err))
+		}
+	}
+
+	return result.GetOutput(), errors.Trace(err)
+}
+
+// Run executes the command on the remote host and waits for it to complete,
+// if the context is done or the timeout exceeded, the remote process will be killed,
+// note that the ssh server may ignore the kill signal, it is more reliable with RunPTYOption(),
+// because the remote process will receive SIGHUP when the terminal is closed,
+// the result is always returned, and the error is not nil if the command did not exit with 0
+func (conn *SSHConn) Run(ctx context.Context, cmd string, opts ...RunOption) (*CommandResult, error) {
+	options := newRunOptions(opts...)
+	result := newCommandResult(cmd)
+	defer func() { result.Duration = time.Since(result.StartTime) }()
+
+	err := options.validate()
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := options.withTimeout(ctx)
+	defer cancel()
 
 	// create ssh session
 	sshSession, err := conn.SSHClient.NewSession()
 	if err != nil {
-		return constant.EmptyString, errors.Trace(err)
+		return result, errors.Trace(err)
 	}
 	defer func() { _ = sshSession.Close() }()
 
-	sshSession.Stdout = &stdOutBuffer
-	sshSession.Stderr = &stdErrBuffer
-
-	// prepare command
-	if conn.Config.useSudo && !strings.HasPrefix(cmd, sudoPrefix) {
-		cmd = fmt.Sprintf(sudoPrefix, cmd)
+	stdout := newLineWriter(options.stdoutFunc)
+	stderr := newLineWriter(options.stderrFunc)
+	sshSession.Stdout = stdout
+	sshSession.Stderr = stderr
+	sshSession.Stdin = options.stdin
+	var (
+		prompter     *sudoPrompter
+		stdoutFilter *sudoOutputFilter
+		stderrFilter *sudoOutputFilter
+	)
+	if conn.usePromptedSudo() {
+		// sudo reads the password from stdin only if it prompts for it
+		prompter = newSudoPrompter(conn.Config.SudoPass, options.stdin)
+		defer prompter.Close()
+		stdoutFilter = prompter.newOutputFilter(stdout)
+		stderrFilter = prompter.newOutputFilter(stderr)
+		sshSession.Stdin = prompter
+		sshSession.Stdout = stdoutFilter
+		sshSession.Stderr = stderrFilter
+	}
+
+	if options.pty {
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          constant.ZeroInt,
+			ssh.TTY_OP_ISPEED: defaultPTYSpeed,
+			ssh.TTY_OP_OSPEED: defaultPTYSpeed,
+		}
+		err = sshSession.RequestPty(defaultPTYTerm, defaultPTYHeight, defaultPTYWidth, modes)
+		if err != nil {
+			return result, errors.Trace(err)
+		}
 	}
+
 	// run command
-	err = sshSession.Run(cmd)
+	err = sshSession.Start(conn.getCommand(cmd, options))
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	waitChan := make(chan error, constant.OneInt)
+	go func() {
+		waitChan <- sshSession.Wait()
+	}()
+
+	select {
+	case err = <-waitChan:
+	case <-ctx.Done():
+		// kill the remote process
+		_ = sshSession.Signal(ssh.SIGKILL)
+		_ = sshSession.Close()
+		<-waitChan
+		err = errors.Annotatef(ctx.Err(), "command was killed. command: %s", cmd)
+	}
+
+	if prompter != nil {
+		prompter.Close()
+		_ = stdoutFilter.Flush()
+		_ = stderrFilter.Flush()
+	}
+	stdout.Flush()
+	stderr.Flush()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
 	if err != nil {
-		if stdErrBuffer.String() != constant.EmptyString {
-			err = errors.Errorf("%s%+v", stdErrBuffer.String(), errors.Trace(err))
+		if exitErr, ok := err.(*ssh.ExitError); ok {
+			result.ExitCode = exitErr.ExitStatus()
 		}
+
+		return result, errors.Trace(err)
 	}
+	result.ExitCode = DefaultSuccessReturnValue
 
-	output := stdOutBuffer.String() + stdErrBuffer.String()
-	return strings.TrimSpace(output), errors.Trace(err)
+	return result, nil
+}
+
+// getCommand returns the command with the environment variables, the working directory and sudo
+func (conn *SSHConn) getCommand(cmd string, options *runOptions) string {
+	if options.workDir != constant.EmptyString {
+		cmd = fmt.Sprintf("cd %s && %s", shellQuote(options.workDir), cmd)
+	}
+	if len(options.envKeys) > constant.ZeroInt {
+		// the ssh server usually rejects the environment variables sent by the client, so export them in the command
+		exports := make([]string, len(options.envKeys))
+		for i, key := range options.envKeys {
+			exports[i] = fmt.Sprintf("export %s=%s;", key, shellQuote(options.envValues[i]))
+		}
+		cmd = strings.Join(exports, constant.SpaceString) + constant.SpaceString + cmd
+	}
+	if conn.Config.useSudo {
+		cmd = conn.getSudoCommand(cmd)
+	}
+
+	return cmd
+}
+
+// getSudoCommand returns the command run by sudo, the command is passed to shell as a single argument,
+// so it will not be broken by the quotes in it
+func (conn *SSHConn) getSudoCommand(cmd string) string {
+	sudoPath := conn.Config.SudoPath
+	if sudoPath == constant.EmptyString {
+		sudoPath = "sudo"
+	}
+
+	args := []string{sudoPath}
+	if conn.Config.SudoPass == constant.EmptyString {
+		// fail instead of waiting for the password
+		args = append(args, "-n")
+	} else {
+		// read the password from stdin after the prompt,
+		// the shell prints the start marker, so that the stdin of the command is sent after sudo starts it
+		args = append(args, "-S", "-p", sudoPromptMarker)
+		cmd = fmt.Sprintf("printf '%%s' %s >&2\n%s", shellQuote(sudoStartMarker), cmd)
+	}
+	if conn.Config.SudoUser != constant.EmptyString {
+		args = append(args, "-u", conn.Config.SudoUser)
+	}
+	args = append(args, endOfOptionsArg, shPath, dashCArg, cmd)
+
+	return shellJoin(args...)
+}
+
+// usePromptedSudo returns if the command is run by sudo with password, the password is sent after sudo prompts for it
+func (conn *SSHConn) usePromptedSudo() bool {
+	return conn.Config.useSudo && conn.Config.SudoPass != constant.EmptyString
+}
+
+// getBinary returns the path of the binary on the remote host
+func (conn *SSHConn) getBinary(name string) string {
+	if conn.Config.BinDir == constant.EmptyString {
+		return name
+	}
+
+	return path.Join(conn.Config.BinDir, name)
+}
+
+// executeBinary executes the binary with the arguments, each argument is quoted, so it is safe to contain any character
+func (conn *SSHConn) executeBinary(name string, args ...string) (string, error) {
+	return conn.executeCommand(shellJoin(append([]string{conn.getBinary(name)}, args...)...))
+}
+
+// testPath runs the test binary with given flag on the path
+func (conn *SSHConn) testPath(flag, path string) (bool, error) {
+	cmd := shellJoin(conn.getBinary(testBinary), flag, path)
+	result, err := conn.Run(context.Background(), cmd)
+	if err == nil {
+		return true, nil
+	}
+	if result.ExitCode == DefaultFailedReturnValue && result.Stderr == constant.EmptyString {
+		return false, nil
+	}
+	if result.Stderr != constant.EmptyString {
+		err = errors.Errorf("%s%+v", result.Stderr, errors.Trace(err))
+	}
+
+	return false, errors.Trace(err)
 }
 
 // GetHostName returns hostname of remote host
 func (conn *SSHConn) GetHostName() (string, error) {
-	return conn.ExecuteCommand(hostNameCommand)
+	return conn.executeBinary(hostNameBinary)
 }
 
 // PathExists returns if given path exists
 func (conn *SSHConn) PathExists(path string) (bool, error) {
-	cmd := fmt.Sprintf(pathExistsCommand, strings.TrimSpace(path))
-	output, err := conn.ExecuteCommand(cmd)
-	if err != nil {
-		return false, err
-	}
-
-	return output == strconv.Itoa(constant.ZeroInt), nil
+	return conn.testPath("-e", strings.TrimSpace(path))
 }
 
 // IsDir returns if given path on the remote host is a directory or not
 func (conn *SSHConn) IsDir(path string) (bool, error) {
-	cmd := fmt.Sprintf(isDirCommand, strings.TrimSpace(path))
-	output, err := conn.ExecuteCommand(cmd)
-	if err != nil {
-		return false, err
-	}
-
-	return output == strconv.Itoa(constant.ZeroInt), nil
+	return conn.testPath("-d", strings.TrimSpace(path))
 }
 
 // ListPath returns subdirectories and files of given path on the remote host, it returns a slice of sub paths
 func (conn *SSHConn) ListPath(path string) ([]string, error) {
 	var subPathList []string
 
-	cmd := fmt.Sprintf(lsCommand, strings.TrimSpace(path))
-	subPathStr, err := conn.ExecuteCommand(cmd)
+	subPathStr, err := conn.executeBinary(lsBinary, endOfOptionsArg, strings.TrimSpace(path))
 	if err != nil {
 		return nil, err
 	}
@@ -277,42 +506,56 @@ func (conn *SSHConn) ReadDir(dirName string) ([]os.FileInfo, error) {
 
 // MkdirAll creates a directory named path, along with any necessary parents, on the remote host, it will act like shell command "mkdir -p $path"
 func (conn *SSHConn) MkdirAll(path string) error {
-	return conn.ExecuteCommandWithoutOutput(fmt.Sprintf(mkdirCommand, strings.TrimSpace(path)))
+	_, err := conn.executeBinary(mkdirBinary, "-p", endOfOptionsArg, strings.TrimSpace(path))
+
+	return err
 }
 
 // RemoveAll removes given path on the remote host, it will act like shell command "rm -rf $path",
 func (conn *SSHConn) RemoveAll(path string) error {
-	return conn.ExecuteCommandWithoutOutput(fmt.Sprintf(rmCommand, strings.TrimSpace(path)))
+	_, err := conn.executeBinary(rmBinary, "-rf", endOfOptionsArg, strings.TrimSpace(path))
+
+	return err
 }
 
 // Cat returns the content of the given file on the remote host, it will act like shell command "cat $path"
 func (conn *SSHConn) Cat(path string) (string, error) {
-	return conn.ExecuteCommand(fmt.Sprintf(catCommand, strings.TrimSpace(path)))
+	return conn.executeBinary(catBinary, endOfOptionsArg, strings.TrimSpace(path))
 }
 
 // Touch touches the given path on the remote host, it will act like shell command "touch $path"
 func (conn *SSHConn) Touch(path string) error {
-	return conn.ExecuteCommandWithoutOutput(fmt.Sprintf(touchCommand, strings.TrimSpace(path)))
+	_, err := conn.executeBinary(touchBinary, endOfOptionsArg, strings.TrimSpace(path))
+
+	return err
 }
 
 // Copy copies a file or directory on the remote host, it will act like shell command "copy -r $src $dest"
 func (conn *SSHConn) Copy(src, dest string) error {
-	return conn.ExecuteCommandWithoutOutput(fmt.Sprintf(cpCommand, strings.TrimSpace(src), strings.TrimSpace(dest)))
+	_, err := conn.executeBinary(cpBinary, "-r", endOfOptionsArg, strings.TrimSpace(src), strings.TrimSpace(dest))
+
+	return err
 }
 
 // Move moves a file or directory on the remote host, it will act like shell command "mv $src $dest"
 func (conn *SSHConn) Move(src, dest string) error {
-	return conn.ExecuteCommandWithoutOutput(fmt.Sprintf(mvCommand, strings.TrimSpace(src), strings.TrimSpace(dest)))
+	_, err := conn.executeBinary(mvBinary, endOfOptionsArg, strings.TrimSpace(src), strings.TrimSpace(dest))
+
+	return err
 }
 
 // Chown changes the owner and group of the given path on the remote host, it will act like shell command "chown -R $user:$group $path"
 func (conn *SSHConn) Chown(path, user, group string) error {
-	return conn.ExecuteCommandWithoutOutput(fmt.Sprintf(chownCommand, user, group, strings.TrimSpace(path)))
+	_, err := conn.executeBinary(chownBinary, "-R", endOfOptionsArg, user+constant.ColonString+group, strings.TrimSpace(path))
+
+	return err
 }
 
 // Chmod changes the mode of the given path on the remote host, it will act like shell command "chmod -R $mode $path"
 func (conn *SSHConn) Chmod(path string, mode string) error {
-	return conn.ExecuteCommandWithoutOutput(fmt.Sprintf(chmodCommand, mode, strings.TrimSpace(path)))
+	_, err := conn.executeBinary(chmodBinary, "-R", endOfOptionsArg, mode, strings.TrimSpace(path))
+
+	return err
 }
 
 // IsEmptyDir returns if  given directory is empty or not on the remote host
@@ -0,0 +1,377 @@
+package linux
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultSSHAgentSocketEnv = "SSH_AUTH_SOCK"
+	DefaultKnownHostsFile    = ".ssh/known_hosts"
+
+	defaultKnownHostsDirMode  os.FileMode = 0700
+	defaultKnownHostsFileMode os.FileMode = 0600
+)
+
+// HostKeyPolicy specifies how to verify the host key of the remote host
+type HostKeyPolicy int
+
+const (
+	// HostKeyPolicyInsecure accepts any host key, it is the default policy for compatibility
+	HostKeyPolicyInsecure HostKeyPolicy = iota
+	// HostKeyPolicyStrict only accepts the host keys in the known hosts file
+	HostKeyPolicyStrict
+	// HostKeyPolicyTOFU trusts the host key on first use, it adds the key of the unknown host to the known hosts file,
+	// and rejects the host whose key changed
+	HostKeyPolicyTOFU
+)
+
+// String returns the string value of the host key policy
+func (hkp HostKeyPolicy) String() string {
+	switch hkp {
+	case HostKeyPolicyInsecure:
+		return "insecure"
+	case HostKeyPolicyStrict:
+		return "strict"
+	case HostKeyPolicyTOFU:
+		return "tofu"
+	default:
+		return "unknown"
+	}
+}
+
+// knownHostsMutex protects the known hosts files from being appended concurrently
+var knownHostsMutex sync.Mutex
+
+// SetPrivateKey sets the PEM encoded private key and its passphrase, the passphrase could be empty if the key is not encrypted
+func (c *SSHConfig) SetPrivateKey(privateKey []byte, passphrase string) {
+	c.PrivateKey = privateKey
+	c.Passphrase = passphrase
+}
+
+// SetPrivateKeyFile reads the private key from given file and sets it with the passphrase
+func (c *SSHConfig) SetPrivateKeyFile(fileName, passphrase string) error {
+	privateKey, err := os.ReadFile(fileName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	c.SetPrivateKey(privateKey, passphrase)
+
+	return nil
+}
+
+// SetAgentSocket sets the unix socket of ssh-agent
+func (c *SSHConfig) SetAgentSocket(socket string) {
+	c.AgentSocket = socket
+}
+
+// SetAgentSocketWithDefault sets the unix socket of ssh-agent with the value of environment variable SSH_AUTH_SOCK
+func (c *SSHConfig) SetAgentSocketWithDefault() error {
+	socket := os.Getenv(DefaultSSHAgentSocketEnv)
+	if socket == constant.EmptyString {
+		return errors.Errorf("environment variable %s is empty, ssh-agent may not be running", DefaultSSHAgentSocketEnv)
+	}
+
+	c.SetAgentSocket(socket)
+
+	return nil
+}
+
+// SetKeyboardInteractive sets the function which answers the keyboard-interactive questions
+func (c *SSHConfig) SetKeyboardInteractive(challenge ssh.KeyboardInteractiveChallenge) {
+	c.KeyboardInteractive = challenge
+}
+
+// SetKeyboardInteractiveWithPassword answers all the keyboard-interactive questions with the password,
+// it is useful when the server only enables keyboard-interactive for password authentication
+func (c *SSHConfig) SetKeyboardInteractiveWithPassword() {
+	c.SetKeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range questions {
+			answers[i] = c.UserPass
+		}
+
+		return answers, nil
+	})
+}
+
+// SetKnownHosts sets the known hosts file and the host key policy, if the file name is empty, ~/.ssh/known_hosts will be used
+func (c *SSHConfig) SetKnownHosts(fileName string, policy HostKeyPolicy) {
+	c.KnownHostsFile = fileName
+	c.HostKeyPolicy = policy
+}
+
+// AddProxyJump appends the jump hosts, the connection goes through them in order,
+// the jump hosts of the jump host config are ignored
+func (c *SSHConfig) AddProxyJump(jumps ...*SSHConfig) {
+	c.ProxyJumps = append(c.ProxyJumps, jumps...)
+}
+
+// SetTimeout sets the timeout of establishing the connection
+func (c *SSHConfig) SetTimeout(timeout time.Duration) {
+	c.Timeout = timeout
+}
+
+// getClientConfig returns the client config of the host, the returned closer closes the connection to ssh-agent,
+// it should be called after the authentication is done
+func (c *SSHConfig) getClientConfig() (*ssh.ClientConfig, io.Closer, error) {
+	authMethods, closer, err := c.getAuthMethods()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostKeyCallback, err := c.getHostKeyCallback()
+	if err != nil {
+		if closer != nil {
+			_ = closer.Close()
+		}
+		return nil, nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout <= constant.ZeroInt {
+		timeout = DefaultSSHTimeout
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.UserName,
+		Auth:            authMethods,
+		Timeout:         timeout,
+		HostKeyCallback: hostKeyCallback,
+	}, closer, nil
+}
+
+// getAuthMethods returns the auth methods in the order of public key, keyboard-interactive and password
+func (c *SSHConfig) getAuthMethods() ([]ssh.AuthMethod, io.Closer, error) {
+	var (
+		authMethods []ssh.AuthMethod
+		agentConn   net.Conn
+	)
+
+	if len(c.PrivateKey) > constant.ZeroInt {
+		signer, err := parsePrivateKey(c.PrivateKey, c.Passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+
+	if c.AgentSocket != constant.EmptyString {
+		var err error
+		agentConn, err = net.Dial("unix", c.AgentSocket)
+		if err != nil {
+			return nil, nil, errors.Annotatef(err, "connect to ssh-agent failed. socket: %s", c.AgentSocket)
+		}
+		authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	if c.KeyboardInteractive != nil {
+		authMethods = append(authMethods, ssh.KeyboardInteractive(c.KeyboardInteractive))
+	}
+
+	if c.UserPass != constant.EmptyString {
+		authMethods = append(authMethods, ssh.Password(c.UserPass))
+	}
+
+	if len(authMethods) == constant.ZeroInt {
+		return nil, nil, errors.Errorf("no auth method is specified. host: %s", c.GetAddr())
+	}
+
+	if agentConn == nil {
+		return authMethods, nil, nil
+	}
+
+	return authMethods, agentConn, nil
+}
+
+// getHostKeyCallback returns the host key callback of the host key policy
+func (c *SSHConfig) getHostKeyCallback() (ssh.HostKeyCallback, error) {
+	if c.HostKeyPolicy == HostKeyPolicyInsecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	fileName := c.KnownHostsFile
+	if fileName == constant.EmptyString {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		fileName = filepath.Join(homeDir, DefaultKnownHostsFile)
+	}
+
+	switch c.HostKeyPolicy {
+	case HostKeyPolicyStrict:
+		callback, err := knownhosts.New(fileName)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		return callback, nil
+	case HostKeyPolicyTOFU:
+		return newTOFUHostKeyCallback(fileName)
+	default:
+		return nil, errors.Errorf("host key policy must be one of [%s, %s, %s], %d is not valid",
+			HostKeyPolicyInsecure, HostKeyPolicyStrict, HostKeyPolicyTOFU, c.HostKeyPolicy)
+	}
+}
+
+// newTOFUHostKeyCallback returns a host key callback which trusts the host key on first use,
+// the known hosts file will be created if it does not exist
+func newTOFUHostKeyCallback(fileName string) (ssh.HostKeyCallback, error) {
+	knownHostsMutex.Lock()
+	defer knownHostsMutex.Unlock()
+
+	err := os.MkdirAll(filepath.Dir(fileName), defaultKnownHostsDirMode)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDONLY, defaultKnownHostsFileMode)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	err = file.Close()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsMutex.Lock()
+		defer knownHostsMutex.Unlock()
+
+		// read the file every time, so the keys added by other connections are visible
+		callback, err := knownhosts.New(fileName)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		err = callback(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		keyErr, ok := err.(*knownhosts.KeyError)
+		if !ok || len(keyErr.Want) > constant.ZeroInt {
+			// the host key changed, it may be a man-in-the-middle attack
+			return errors.Trace(err)
+		}
+
+		// the host is unknown, trust it
+		file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, defaultKnownHostsFileMode)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer func() { _ = file.Close() }()
+
+		_, err = file.WriteString(knownhosts.Line([]string{hostname}, key) + constant.CRLFString)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		return nil
+	}, nil
+}
+
+// parsePrivateKey parses the PEM encoded private key, the passphrase could be empty if the key is not encrypted
+func parsePrivateKey(privateKey []byte, passphrase string) (ssh.Signer, error) {
+	if passphrase != constant.EmptyString {
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(privateKey, []byte(passphrase))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		return signer, nil
+	}
+
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			return nil, errors.Errorf("private key is encrypted, but passphrase is empty")
+		}
+		return nil, errors.Trace(err)
+	}
+
+	return signer, nil
+}
+
+// dialSSH connects to the host through the jump hosts, it returns the client of the host and the clients of the jump hosts
+func dialSSH(config *SSHConfig) (*ssh.Client, []*ssh.Client, error) {
+	var (
+		client      *ssh.Client
+		jumpClients []*ssh.Client
+	)
+
+	hops := make([]*SSHConfig, constant.ZeroInt, len(config.ProxyJumps)+constant.OneInt)
+	hops = append(hops, config.ProxyJumps...)
+	hops = append(hops, config)
+
+	for i, hop := range hops {
+		if i > constant.ZeroInt {
+			jumpClients = append(jumpClients, client)
+		}
+
+		nextClient, err := dialSSHHop(client, hop)
+		if err != nil {
+			closeSSHClients(nil, jumpClients)
+			return nil, nil, err
+		}
+		client = nextClient
+	}
+
+	return client, jumpClients, nil
+}
+
+// dialSSHHop connects to the host, if the jump client is not nil, the connection goes through it
+func dialSSHHop(jumpClient *ssh.Client, config *SSHConfig) (*ssh.Client, error) {
+	clientConfig, closer, err := config.getClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		// the agent is only used during the authentication
+		defer func() { _ = closer.Close() }()
+	}
+
+	addr := config.GetAddr()
+	if jumpClient == nil {
+		client, err := ssh.Dial(constant.TransportProtocolTCP, addr, clientConfig)
+		if err != nil {
+			return nil, errors.Annotatef(err, "connect to ssh failed. addr: %s", addr)
+		}
+
+		return client, nil
+	}
+
+	conn, err := jumpClient.Dial(constant.TransportProtocolTCP, addr)
+	if err != nil {
+		return nil, errors.Annotatef(err, "connect to ssh through jump host failed. jump host: %s, addr: %s",
+			jumpClient.RemoteAddr().String(), addr)
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Annotatef(err, "connect to ssh through jump host failed. jump host: %s, addr: %s",
+			jumpClient.RemoteAddr().String(), addr)
+	}
+
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// closeSSHClients closes the client and the jump clients from the nearest one
+func closeSSHClients(client *ssh.Client, jumpClients []*ssh.Client) {
+	if client != nil {
+		_ = client.Close()
+	}
+	for i := len(jumpClients) - constant.OneInt; i >= constant.ZeroInt; i-- {
+		_ = jumpClients[i].Close()
+	}
+}
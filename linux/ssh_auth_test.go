@@ -0,0 +1,493 @@
+package linux
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	testSSHServerUserName   = "test_user"
+	testSSHServerUserPass   = "test_pass"
+	testSSHServerPassphrase = "test_passphrase"
+	testSSHServerCommand    = "echo test_ssh_server"
+	testSSHServerOutput     = "test_ssh_server"
+)
+
+// testSSHServer is an in-process ssh server, it runs the commands with local shell,
+// serves sftp with local file system and forwards tcp connections, so it could be used as a jump host
+type testSSHServer struct {
+	listener       net.Listener
+	mutex          sync.Mutex
+	hostSigner     ssh.Signer
+	authorizedKeys []ssh.PublicKey
+	// keyboardInteractiveOnly disables the password authentication
+	keyboardInteractiveOnly bool
+	wg                      sync.WaitGroup
+}
+
+// newTestSSHServer starts a new *testSSHServer listening on a random local port
+func newTestSSHServer(t *testing.T, authorizedKeys ...ssh.PublicKey) *testSSHServer {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key failed. error:\n%+v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("create host signer failed. error:\n%+v", err)
+	}
+	listener, err := net.Listen(constant.TransportProtocolTCP, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed. error:\n%+v", err)
+	}
+
+	s := &testSSHServer{
+		listener:       listener,
+		hostSigner:     hostSigner,
+		authorizedKeys: authorizedKeys,
+	}
+	s.wg.Add(constant.OneInt)
+	go s.serve()
+	t.Cleanup(s.close)
+
+	return s
+}
+
+func (s *testSSHServer) getPortNum() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSSHServer) getConfig(userPass string) *SSHConfig {
+	return NewSSHConfig("127.0.0.1", s.getPortNum(), testSSHServerUserName, userPass, false)
+}
+
+func (s *testSSHServer) setHostSigner(hostSigner ssh.Signer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.hostSigner = hostSigner
+}
+
+func (s *testSSHServer) setKeyboardInteractiveOnly(keyboardInteractiveOnly bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.keyboardInteractiveOnly = keyboardInteractiveOnly
+}
+
+func (s *testSSHServer) close() {
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *testSSHServer) serverConfig() *ssh.ServerConfig {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, authorizedKey := range s.authorizedKeys {
+				if bytes.Equal(authorizedKey.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, io.EOF
+		},
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := client(conn.User(), "test", []string{"Password: "}, []bool{false})
+			if err != nil {
+				return nil, err
+			}
+			if len(answers) == constant.OneInt && answers[constant.ZeroInt] == testSSHServerUserPass {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.keyboardInteractiveOnly {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testSSHServerUserName && string(password) == testSSHServerUserPass {
+				return nil, nil
+			}
+			return nil, io.EOF
+		}
+	}
+	config.AddHostKey(s.hostSigner)
+
+	return config
+}
+
+func (s *testSSHServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *testSSHServer) handleConn(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.serverConfig())
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.handleSession(newChannel)
+		case "direct-tcpip":
+			go s.handleDirectTCPIP(newChannel)
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+		}
+	}
+}
+
+func (s *testSSHServer) handleSession(newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { _ = channel.Close() }()
+
+	var (
+		cmd  *exec.Cmd
+		done = make(chan struct{})
+	)
+	for {
+		select {
+		case <-done:
+			return
+		case req, ok := <-reqs:
+			if !ok {
+				return
+			}
+			switch req.Type {
+			case "exec":
+				var payload struct{ Command string }
+				_ = ssh.Unmarshal(req.Payload, &payload)
+				_ = req.Reply(true, nil)
+				cmd = exec.Command("sh", "-c", payload.Command)
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+				cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+				// like sshd, the exit status is sent as soon as the process exits, even if the client does not close stdin
+				stdin, startErr := cmd.StdinPipe()
+				if startErr == nil {
+					go func() {
+						_, _ = io.Copy(stdin, channel)
+						_ = stdin.Close()
+					}()
+					// start the process here, so the signal requests could be handled concurrently
+					startErr = cmd.Start()
+				}
+				go func() {
+					defer close(done)
+					exitStatus := constant.ZeroInt
+					err := startErr
+					if err == nil {
+						err = cmd.Wait()
+					}
+					if err != nil {
+						exitStatus = DefaultFailedReturnValue
+						if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= constant.ZeroInt {
+							exitStatus = exitErr.ExitCode()
+						}
+					}
+					_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(exitStatus)}))
+				}()
+			case "signal":
+				// the process may have not started, ignore the error
+				if cmd != nil && cmd.Process != nil {
+					_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+				}
+			case "subsystem":
+				var payload struct{ Name string }
+				_ = ssh.Unmarshal(req.Payload, &payload)
+				if payload.Name != "sftp" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				_ = req.Reply(true, nil)
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					return
+				}
+				_ = server.Serve()
+				return
+			default:
+				_ = req.Reply(req.WantReply, nil)
+			}
+		}
+	}
+}
+
+func (s *testSSHServer) handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	err := ssh.Unmarshal(newChannel.ExtraData(), &payload)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial(constant.TransportProtocolTCP, net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		_, _ = io.Copy(channel, conn)
+		_ = channel.CloseWrite()
+	}()
+	_, _ = io.Copy(conn, channel)
+	_ = conn.Close()
+	_ = channel.Close()
+}
+
+// testGenerateSSHKey generates a new ed25519 key pair, if passphrase is not empty, the private key is encrypted
+func testGenerateSSHKey(t *testing.T, passphrase string) ([]byte, ssh.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed. error:\n%+v", err)
+	}
+	var block *pem.Block
+	if passphrase == constant.EmptyString {
+		block, err = ssh.MarshalPrivateKey(privateKey, constant.EmptyString)
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, constant.EmptyString, []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("marshal private key failed. error:\n%+v", err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("create public key failed. error:\n%+v", err)
+	}
+
+	return pem.EncodeToMemory(block), sshPublicKey, privateKey
+}
+
+func testExecuteSSHCommand(asst *assert.Assertions, config *SSHConfig) {
+	conn, err := NewSSHConnWithConfig(config)
+	if !asst.Nil(err, "test NewSSHConnWithConfig() failed") {
+		return
+	}
+	defer func() { asst.Nil(conn.Close(), "test Close() failed") }()
+
+	output, err := conn.ExecuteCommand(testSSHServerCommand)
+	asst.Nil(err, "test ExecuteCommand() failed")
+	asst.Equal(testSSHServerOutput, output, "test ExecuteCommand() failed")
+}
+
+func TestSSHAuth_All(t *testing.T) {
+	TestSSHAuth_Password(t)
+	TestSSHAuth_PrivateKey(t)
+	TestSSHAuth_Agent(t)
+	TestSSHAuth_KeyboardInteractive(t)
+	TestSSHAuth_KnownHosts(t)
+	TestSSHAuth_ProxyJump(t)
+}
+
+func TestSSHAuth_Password(t *testing.T) {
+	asst := assert.New(t)
+
+	server := newTestSSHServer(t)
+	testExecuteSSHCommand(asst, server.getConfig(testSSHServerUserPass))
+
+	_, err := NewSSHConnWithConfig(server.getConfig("wrong_pass"))
+	asst.NotNil(err, "test Password() failed")
+}
+
+func TestSSHAuth_PrivateKey(t *testing.T) {
+	asst := assert.New(t)
+
+	privateKey, publicKey, _ := testGenerateSSHKey(t, constant.EmptyString)
+	encryptedKey, encryptedPublicKey, _ := testGenerateSSHKey(t, testSSHServerPassphrase)
+	server := newTestSSHServer(t, publicKey, encryptedPublicKey)
+
+	// plain key
+	config := server.getConfig(constant.EmptyString)
+	config.SetPrivateKey(privateKey, constant.EmptyString)
+	testExecuteSSHCommand(asst, config)
+	// key file with passphrase
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	err := os.WriteFile(keyFile, encryptedKey, defaultKnownHostsFileMode)
+	asst.Nil(err, "test PrivateKey() failed")
+	config = server.getConfig(constant.EmptyString)
+	err = config.SetPrivateKeyFile(keyFile, testSSHServerPassphrase)
+	asst.Nil(err, "test SetPrivateKeyFile() failed")
+	testExecuteSSHCommand(asst, config)
+	// missing passphrase
+	config.SetPrivateKey(encryptedKey, constant.EmptyString)
+	_, err = NewSSHConnWithConfig(config)
+	asst.NotNil(err, "test PrivateKey() failed")
+	// unauthorized key
+	unauthorizedKey, _, _ := testGenerateSSHKey(t, constant.EmptyString)
+	config.SetPrivateKey(unauthorizedKey, constant.EmptyString)
+	_, err = NewSSHConnWithConfig(config)
+	asst.NotNil(err, "test PrivateKey() failed")
+}
+
+func TestSSHAuth_Agent(t *testing.T) {
+	asst := assert.New(t)
+
+	_, publicKey, privateKey := testGenerateSSHKey(t, constant.EmptyString)
+	server := newTestSSHServer(t, publicKey)
+
+	keyring := agent.NewKeyring()
+	err := keyring.Add(agent.AddedKey{PrivateKey: privateKey})
+	asst.Nil(err, "test Agent() failed")
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if !asst.Nil(err, "test Agent() failed") {
+		return
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	config := server.getConfig(constant.EmptyString)
+	t.Setenv(DefaultSSHAgentSocketEnv, socket)
+	err = config.SetAgentSocketWithDefault()
+	asst.Nil(err, "test SetAgentSocketWithDefault() failed")
+	testExecuteSSHCommand(asst, config)
+}
+
+func TestSSHAuth_KeyboardInteractive(t *testing.T) {
+	asst := assert.New(t)
+
+	server := newTestSSHServer(t)
+	server.setKeyboardInteractiveOnly(true)
+
+	// password auth is disabled
+	_, err := NewSSHConnWithConfig(server.getConfig(testSSHServerUserPass))
+	asst.NotNil(err, "test KeyboardInteractive() failed")
+
+	config := server.getConfig(testSSHServerUserPass)
+	config.SetKeyboardInteractiveWithPassword()
+	testExecuteSSHCommand(asst, config)
+}
+
+func TestSSHAuth_KnownHosts(t *testing.T) {
+	asst := assert.New(t)
+
+	server := newTestSSHServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+
+	// strict mode without the known hosts file
+	config := server.getConfig(testSSHServerUserPass)
+	config.SetKnownHosts(knownHostsFile, HostKeyPolicyStrict)
+	_, err := NewSSHConnWithConfig(config)
+	asst.NotNil(err, "test KnownHosts() failed")
+	// trust on first use
+	config.SetKnownHosts(knownHostsFile, HostKeyPolicyTOFU)
+	testExecuteSSHCommand(asst, config)
+	content, err := os.ReadFile(knownHostsFile)
+	asst.Nil(err, "test KnownHosts() failed")
+	asst.Equal(constant.OneInt, strings.Count(string(content), constant.CRLFString), "test KnownHosts() failed")
+	asst.True(strings.Contains(string(content), strconv.Itoa(server.getPortNum())), "test KnownHosts() failed")
+	// the host is known now
+	testExecuteSSHCommand(asst, config)
+	config.SetKnownHosts(knownHostsFile, HostKeyPolicyStrict)
+	testExecuteSSHCommand(asst, config)
+	// the host key changed
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	asst.Nil(err, "test KnownHosts() failed")
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	asst.Nil(err, "test KnownHosts() failed")
+	server.setHostSigner(hostSigner)
+	config.SetKnownHosts(knownHostsFile, HostKeyPolicyTOFU)
+	_, err = NewSSHConnWithConfig(config)
+	asst.NotNil(err, "test KnownHosts() failed")
+	content, err = os.ReadFile(knownHostsFile)
+	asst.Nil(err, "test KnownHosts() failed")
+	asst.Equal(constant.OneInt, strings.Count(string(content), constant.CRLFString), "test KnownHosts() failed")
+}
+
+func TestSSHAuth_ProxyJump(t *testing.T) {
+	asst := assert.New(t)
+
+	privateKey, publicKey, _ := testGenerateSSHKey(t, constant.EmptyString)
+	bastion1 := newTestSSHServer(t, publicKey)
+	bastion2 := newTestSSHServer(t)
+	target := newTestSSHServer(t, publicKey)
+
+	jump1 := bastion1.getConfig(constant.EmptyString)
+	jump1.SetPrivateKey(privateKey, constant.EmptyString)
+	jump2 := bastion2.getConfig(testSSHServerUserPass)
+
+	// one hop
+	config := target.getConfig(constant.EmptyString)
+	config.SetPrivateKey(privateKey, constant.EmptyString)
+	config.AddProxyJump(jump1)
+	testExecuteSSHCommand(asst, config)
+	// two hops
+	config.ProxyJumps = nil
+	config.AddProxyJump(jump1, jump2)
+	conn, err := NewSSHConnWithConfig(config)
+	if asst.Nil(err, "test ProxyJump() failed") {
+		asst.Equal(2, len(conn.jumpClients), "test ProxyJump() failed")
+		asst.Nil(conn.Close(), "test ProxyJump() failed")
+	}
+	// the jump host failed to authenticate
+	jump2.UserPass = "wrong_pass"
+	_, err = NewSSHConnWithConfig(config)
+	asst.NotNil(err, "test ProxyJump() failed")
+	// the connection to the target host failed
+	jump2.UserPass = testSSHServerUserPass
+	listener, err := net.Listen(constant.TransportProtocolTCP, "127.0.0.1:0")
+	asst.Nil(err, "test ProxyJump() failed")
+	config.PortNum = listener.Addr().(*net.TCPAddr).Port
+	asst.Nil(listener.Close(), "test ProxyJump() failed")
+	_, err = NewSSHConnWithConfig(config)
+	asst.NotNil(err, "test ProxyJump() failed")
+}
@@ -0,0 +1,340 @@
+package linux
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultSSHGroupConcurrency     = 10
+	DefaultSSHGroupTimeout         = 60 * time.Second
+	DefaultSSHGroupContinueOnError = true
+)
+
+// ErrSSHGroupCanceled is the error of the hosts which are not executed because another host failed in fail-fast mode
+var ErrSSHGroupCanceled = errors.New("ssh group canceled because another host failed")
+
+// SSHGroupFunc is the function executed on each host of the group, the result is filled by the function,
+// the connection is closed when the context is done, so the running operations on it will be aborted
+type SSHGroupFunc func(ctx context.Context, conn *SSHConn, result *SSHHostResult) error
+
+// SSHHost is a host of the inventory
+type SSHHost struct {
+	// Name identifies the host in the results, if it is empty, the address of the config will be used
+	Name   string
+	Config *SSHConfig
+}
+
+// NewSSHHost returns a new *SSHHost
+func NewSSHHost(name string, config *SSHConfig) *SSHHost {
+	return &SSHHost{
+		Name:   name,
+		Config: config,
+	}
+}
+
+// GetName returns the name of the host
+func (h *SSHHost) GetName() string {
+	if h.Name == constant.EmptyString {
+		return h.Config.GetAddr()
+	}
+
+	return h.Name
+}
+
+// SSHHostResult is the result of a host, the start time and the duration include connecting to the host
+type SSHHostResult struct {
+	Host string
+	CommandResult
+	Err error
+}
+
+// newSSHHostResult returns a new *SSHHostResult
+func newSSHHostResult(host string) *SSHHostResult {
+	return &SSHHostResult{
+		Host:          host,
+		CommandResult: CommandResult{ExitCode: UnknownExitCode},
+	}
+}
+
+// IsSuccess returns if the execution on the host succeeded
+func (shr *SSHHostResult) IsSuccess() bool {
+	return shr.Err == nil
+}
+
+// String returns the string value of the result
+func (shr *SSHHostResult) String() string {
+	if shr.Err != nil {
+		return fmt.Sprintf("host: %s, exit code: %d, duration: %s, error: %s", shr.Host, shr.ExitCode, shr.Duration, shr.Err.Error())
+	}
+
+	return fmt.Sprintf("host: %s, exit code: %d, duration: %s", shr.Host, shr.ExitCode, shr.Duration)
+}
+
+// SSHGroupResult is the results of all the hosts of the group, the order is the same as the inventory
+type SSHGroupResult struct {
+	Results []*SSHHostResult
+}
+
+// GetResult returns the result of given host, it returns nil if the host is not in the group
+func (sgr *SSHGroupResult) GetResult(host string) *SSHHostResult {
+	for _, result := range sgr.Results {
+		if result.Host == host {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// GetSucceeded returns the results of the hosts which succeeded
+func (sgr *SSHGroupResult) GetSucceeded() []*SSHHostResult {
+	var results []*SSHHostResult
+	for _, result := range sgr.Results {
+		if result.IsSuccess() {
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// GetFailed returns the results of the hosts which failed, including the canceled hosts
+func (sgr *SSHGroupResult) GetFailed() []*SSHHostResult {
+	var results []*SSHHostResult
+	for _, result := range sgr.Results {
+		if !result.IsSuccess() {
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// Error returns the error summarizing the failed hosts, it returns nil if all the hosts succeeded
+func (sgr *SSHGroupResult) Error() error {
+	failed := sgr.GetFailed()
+	if len(failed) == constant.ZeroInt {
+		return nil
+	}
+
+	hosts := make([]string, len(failed))
+	for i, result := range failed {
+		hosts[i] = result.Host
+	}
+
+	return errors.Errorf("ssh group failed on %d of %d hosts. failed hosts: %s, first error:\n%+v",
+		len(failed), len(sgr.Results), strings.Join(hosts, constant.CommaString), failed[constant.ZeroInt].Err)
+}
+
+// SSHGroup executes the commands or copies the files on the hosts of the inventory in parallel
+type SSHGroup struct {
+	hosts           []*SSHHost
+	concurrency     int
+	timeout         time.Duration
+	continueOnError bool
+}
+
+// NewSSHGroup returns a new *SSHGroup,
+// concurrency is the maximum number of the hosts executed at the same time,
+// timeout is the timeout of each host, including connecting, if it is 0, there is no timeout,
+// if continueOnError is false, the group stops at the first failed host and the remaining hosts are canceled
+func NewSSHGroup(hosts []*SSHHost, concurrency int, timeout time.Duration, continueOnError bool) (*SSHGroup, error) {
+	sg := &SSHGroup{
+		hosts:           hosts,
+		concurrency:     concurrency,
+		timeout:         timeout,
+		continueOnError: continueOnError,
+	}
+
+	err := sg.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return sg, nil
+}
+
+// NewSSHGroupWithDefault returns a new *SSHGroup with default values
+func NewSSHGroupWithDefault(hosts []*SSHHost) (*SSHGroup, error) {
+	return NewSSHGroup(hosts, DefaultSSHGroupConcurrency, DefaultSSHGroupTimeout, DefaultSSHGroupContinueOnError)
+}
+
+// validate validates the group
+func (sg *SSHGroup) validate() error {
+	if len(sg.hosts) == constant.ZeroInt {
+		return errors.New("hosts of ssh group must not be empty")
+	}
+	if sg.concurrency <= constant.ZeroInt {
+		return errors.Errorf("concurrency must be larger than 0, %d is not valid", sg.concurrency)
+	}
+	if sg.timeout < constant.ZeroInt {
+		return errors.Errorf("timeout must not be smaller than 0, %s is not valid", sg.timeout)
+	}
+
+	names := make(map[string]struct{}, len(sg.hosts))
+	for _, host := range sg.hosts {
+		if host == nil || host.Config == nil {
+			return errors.New("host and its config of ssh group must not be nil")
+		}
+		name := host.GetName()
+		_, ok := names[name]
+		if ok {
+			return errors.Errorf("host name of ssh group must be unique, %s is duplicated", name)
+		}
+		names[name] = struct{}{}
+	}
+
+	return nil
+}
+
+// GetHosts returns the hosts of the group
+func (sg *SSHGroup) GetHosts() []*SSHHost {
+	return sg.hosts
+}
+
+// ExecuteCommand executes the command on all the hosts, the returned error summarizes the failed hosts,
+// the options are applied to the command of each host, note that the callbacks of stdout and stderr are called concurrently
+func (sg *SSHGroup) ExecuteCommand(ctx context.Context, cmd string, opts ...RunOption) (*SSHGroupResult, error) {
+	return sg.Execute(ctx, func(ctx context.Context, conn *SSHConn, result *SSHHostResult) error {
+		commandResult, err := conn.Run(ctx, cmd, opts...)
+		result.Command = commandResult.Command
+		result.Stdout = commandResult.Stdout
+		result.Stderr = commandResult.Stderr
+		result.ExitCode = commandResult.ExitCode
+
+		return err
+	})
+}
+
+// CopyToRemote copies the local file or directory to all the hosts, it acts like SSHConn.CopyToRemote()
+func (sg *SSHGroup) CopyToRemote(ctx context.Context, pathSource, pathDest string, tmpDir ...string) (*SSHGroupResult, error) {
+	return sg.Execute(ctx, func(ctx context.Context, conn *SSHConn, result *SSHHostResult) error {
+		return conn.CopyToRemote(pathSource, pathDest, tmpDir...)
+	})
+}
+
+// CopyFromRemote copies the file or directory of all the hosts to the local directory,
+// to avoid overwriting each other, the path of each host is copied to pathDest/$hostName
+func (sg *SSHGroup) CopyFromRemote(ctx context.Context, pathSource, pathDest string, tmpDir ...string) (*SSHGroupResult, error) {
+	return sg.Execute(ctx, func(ctx context.Context, conn *SSHConn, result *SSHHostResult) error {
+		hostDest := filepath.Join(strings.TrimSpace(pathDest), result.Host)
+		err := os.MkdirAll(hostDest, constant.DefaultExecFileMode)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		return conn.CopyFromRemote(pathSource, hostDest, tmpDir...)
+	})
+}
+
+// Execute executes the function on all the hosts, the returned error summarizes the failed hosts
+func (sg *SSHGroup) Execute(ctx context.Context, fn SSHGroupFunc) (*SSHGroupResult, error) {
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, sg.concurrency)
+		results   = make([]*SSHHostResult, len(sg.hosts))
+	)
+
+	for i, host := range sg.hosts {
+		result := newSSHHostResult(host.GetName())
+		results[i] = result
+
+		acquired := false
+		select {
+		case semaphore <- struct{}{}:
+			acquired = true
+		case <-groupCtx.Done():
+		}
+		if groupCtx.Err() != nil {
+			if acquired {
+				<-semaphore
+			}
+			result.Err = sg.getCanceledError(ctx)
+			continue
+		}
+
+		wg.Add(constant.OneInt)
+		go func(host *SSHHost, result *SSHHostResult) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			sg.executeHost(groupCtx, host, result, fn)
+			if result.Err != nil && !sg.continueOnError {
+				cancel()
+			}
+		}(host, result)
+	}
+
+	wg.Wait()
+
+	groupResult := &SSHGroupResult{Results: results}
+
+	return groupResult, groupResult.Error()
+}
+
+// getCanceledError returns the error of the hosts which are not executed
+func (sg *SSHGroup) getCanceledError(ctx context.Context) error {
+	if ctx.Err() != nil {
+		// the parent context is done
+		return errors.Trace(ctx.Err())
+	}
+
+	return ErrSSHGroupCanceled
+}
+
+// executeHost executes the function on the host and fills the result
+func (sg *SSHGroup) executeHost(ctx context.Context, host *SSHHost, result *SSHHostResult, fn SSHGroupFunc) {
+	startTime := time.Now()
+	defer func() {
+		result.StartTime = startTime
+		result.Duration = time.Since(startTime)
+	}()
+
+	if sg.timeout > constant.ZeroInt {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sg.timeout)
+		defer cancel()
+	}
+
+	conn, err := NewSSHConnWithContext(ctx, host.Config)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	done := make(chan struct{})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		select {
+		case <-ctx.Done():
+			// abort the running operations
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+
+	err = fn(ctx, conn, result)
+	close(done)
+	<-closed
+
+	if err != nil && ctx.Err() != nil {
+		err = errors.Annotatef(ctx.Err(), "execution on the host was aborted. host: %s, error: %s", result.Host, err.Error())
+	}
+	result.Err = err
+}
@@ -0,0 +1,162 @@
+package linux
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/constant"
+)
+
+// testNewSSHGroupHosts starts num in-process ssh servers and returns the inventory of them
+func testNewSSHGroupHosts(t *testing.T, num int) []*SSHHost {
+	hosts := make([]*SSHHost, num)
+	for i := constant.ZeroInt; i < num; i++ {
+		server := newTestSSHServer(t)
+		hosts[i] = NewSSHHost(fmt.Sprintf("host%d", i), server.getConfig(testSSHServerUserPass))
+	}
+
+	return hosts
+}
+
+func TestSSHGroup_All(t *testing.T) {
+	TestSSHGroup_Validate(t)
+	TestSSHGroup_ExecuteCommand(t)
+	TestSSHGroup_Concurrency(t)
+	TestSSHGroup_Timeout(t)
+	TestSSHGroup_FailFast(t)
+	TestSSHGroup_Copy(t)
+}
+
+func TestSSHGroup_Validate(t *testing.T) {
+	asst := assert.New(t)
+
+	hosts := testNewSSHGroupHosts(t, constant.TwoInt)
+	_, err := NewSSHGroupWithDefault(nil)
+	asst.NotNil(err, "test Validate() failed")
+	_, err = NewSSHGroup(hosts, constant.ZeroInt, DefaultSSHGroupTimeout, true)
+	asst.NotNil(err, "test Validate() failed")
+	hosts[constant.OneInt].Name = hosts[constant.ZeroInt].Name
+	_, err = NewSSHGroupWithDefault(hosts)
+	asst.NotNil(err, "test Validate() failed")
+	// the address is used as the name
+	hosts[constant.OneInt].Name = constant.EmptyString
+	sg, err := NewSSHGroupWithDefault(hosts)
+	asst.Nil(err, "test Validate() failed")
+	asst.Equal(hosts[constant.OneInt].Config.GetAddr(), sg.GetHosts()[constant.OneInt].GetName(), "test Validate() failed")
+}
+
+func TestSSHGroup_ExecuteCommand(t *testing.T) {
+	asst := assert.New(t)
+
+	hosts := testNewSSHGroupHosts(t, 3)
+	sg, err := NewSSHGroupWithDefault(hosts)
+	asst.Nil(err, "test ExecuteCommand() failed")
+
+	result, err := sg.ExecuteCommand(context.Background(), "echo test_stdout; echo test_stderr >&2")
+	asst.Nil(err, "test ExecuteCommand() failed")
+	asst.Equal(3, len(result.GetSucceeded()), "test ExecuteCommand() failed")
+	for i, hostResult := range result.Results {
+		asst.Equal(hosts[i].GetName(), hostResult.Host, "test ExecuteCommand() failed")
+		asst.Equal("test_stdout\n", hostResult.Stdout, "test ExecuteCommand() failed")
+		asst.Equal("test_stderr\n", hostResult.Stderr, "test ExecuteCommand() failed")
+		asst.Equal(constant.ZeroInt, hostResult.ExitCode, "test ExecuteCommand() failed")
+		asst.True(hostResult.Duration > constant.ZeroInt, "test ExecuteCommand() failed")
+	}
+
+	// exit code
+	result, err = sg.ExecuteCommand(context.Background(), "exit 3")
+	asst.NotNil(err, "test ExecuteCommand() failed")
+	asst.Equal(3, len(result.GetFailed()), "test ExecuteCommand() failed")
+	asst.Equal(3, result.GetResult("host1").ExitCode, "test ExecuteCommand() failed")
+	asst.Nil(result.GetResult("host3"), "test ExecuteCommand() failed")
+}
+
+func TestSSHGroup_Concurrency(t *testing.T) {
+	asst := assert.New(t)
+
+	hosts := testNewSSHGroupHosts(t, 4)
+	sg, err := NewSSHGroup(hosts, constant.TwoInt, DefaultSSHGroupTimeout, true)
+	asst.Nil(err, "test Concurrency() failed")
+
+	start := time.Now()
+	_, err = sg.ExecuteCommand(context.Background(), "sleep 0.3")
+	asst.Nil(err, "test Concurrency() failed")
+	asst.True(time.Since(start) >= 600*time.Millisecond, "test Concurrency() failed")
+}
+
+func TestSSHGroup_Timeout(t *testing.T) {
+	asst := assert.New(t)
+
+	hosts := testNewSSHGroupHosts(t, constant.TwoInt)
+	sg, err := NewSSHGroup(hosts, DefaultSSHGroupConcurrency, 500*time.Millisecond, true)
+	asst.Nil(err, "test Timeout() failed")
+
+	start := time.Now()
+	result, err := sg.ExecuteCommand(context.Background(), "sleep 5")
+	asst.NotNil(err, "test Timeout() failed")
+	asst.True(time.Since(start) < 3*time.Second, "test Timeout() failed")
+	for _, hostResult := range result.Results {
+		asst.Equal(context.DeadlineExceeded, errors.Cause(hostResult.Err), "test Timeout() failed")
+	}
+}
+
+func TestSSHGroup_FailFast(t *testing.T) {
+	asst := assert.New(t)
+
+	hosts := testNewSSHGroupHosts(t, 3)
+	hosts[constant.ZeroInt].Config.UserPass = "wrong_pass"
+	sg, err := NewSSHGroup(hosts, constant.OneInt, DefaultSSHGroupTimeout, false)
+	asst.Nil(err, "test FailFast() failed")
+
+	result, err := sg.ExecuteCommand(context.Background(), testSSHServerCommand)
+	asst.NotNil(err, "test FailFast() failed")
+	asst.Equal(3, len(result.GetFailed()), "test FailFast() failed")
+	asst.Equal(ErrSSHGroupCanceled, errors.Cause(result.GetResult("host2").Err), "test FailFast() failed")
+
+	// continue on error
+	sg, err = NewSSHGroup(hosts, constant.OneInt, DefaultSSHGroupTimeout, true)
+	asst.Nil(err, "test FailFast() failed")
+	result, err = sg.ExecuteCommand(context.Background(), testSSHServerCommand)
+	asst.NotNil(err, "test FailFast() failed")
+	asst.Equal(constant.TwoInt, len(result.GetSucceeded()), "test FailFast() failed")
+	asst.Equal(testSSHServerOutput+constant.CRLFString, result.GetResult("host2").Stdout, "test FailFast() failed")
+}
+
+func TestSSHGroup_Copy(t *testing.T) {
+	asst := assert.New(t)
+
+	hosts := testNewSSHGroupHosts(t, constant.TwoInt)
+	sg, err := NewSSHGroupWithDefault(hosts)
+	asst.Nil(err, "test Copy() failed")
+
+	// all the in-process servers share the local file system
+	tmpDir := t.TempDir()
+	fileNameSource := filepath.Join(tmpDir, testLocalFileName)
+	err = os.WriteFile(fileNameSource, []byte(testContent), constant.DefaultFileMode)
+	asst.Nil(err, "test Copy() failed")
+
+	dirNameDest := filepath.Join(tmpDir, "dest")
+	result, err := sg.CopyFromRemote(context.Background(), fileNameSource, dirNameDest)
+	asst.Nil(err, "test CopyFromRemote() failed")
+	for _, hostResult := range result.Results {
+		content, err := os.ReadFile(filepath.Join(dirNameDest, hostResult.Host, testLocalFileName))
+		asst.Nil(err, "test CopyFromRemote() failed")
+		asst.Equal(testContent, string(content), "test CopyFromRemote() failed")
+	}
+
+	sg, err = NewSSHGroupWithDefault(hosts[:constant.OneInt])
+	asst.Nil(err, "test Copy() failed")
+	fileNameDest := filepath.Join(tmpDir, testRemoteFileName)
+	_, err = sg.CopyToRemote(context.Background(), fileNameSource, fileNameDest)
+	asst.Nil(err, "test CopyToRemote() failed")
+	content, err := os.ReadFile(fileNameDest)
+	asst.Nil(err, "test CopyToRemote() failed")
+	asst.Equal(testContent, string(content), "test CopyToRemote() failed")
+}
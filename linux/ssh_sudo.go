@@ -0,0 +1,198 @@
+package linux
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	// sudoPromptMarker is the password prompt of sudo, the password is sent only after sudo prompts for it
+	sudoPromptMarker = "[go-util-sudo-prompt]"
+	// sudoStartMarker is printed by the shell which sudo runs, the stdin of the command is sent only after it is printed
+	sudoStartMarker = "[go-util-sudo-start]"
+)
+
+// sudoPrompter is the stdin of the command run by sudo with password,
+// it sends the password only when sudo prompts for it, and sends the stdin of the command only after sudo starts the command,
+// so the command never reads the password, even if sudo does not require it, for example, NOPASSWD or the cached credentials
+type sudoPrompter struct {
+	mutex    sync.Mutex
+	password string
+	stdin    io.Reader
+	pending  []byte
+	prompts  int
+	started  bool
+	notify   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// newSudoPrompter returns a new *sudoPrompter, stdin is the stdin of the command, it could be nil
+func newSudoPrompter(password string, stdin io.Reader) *sudoPrompter {
+	return &sudoPrompter{
+		password: password,
+		stdin:    stdin,
+		notify:   make(chan struct{}, constant.OneInt),
+		done:     make(chan struct{}),
+	}
+}
+
+// Read implements io.Reader interface, it blocks until sudo prompts for the password or starts the command
+func (sp *sudoPrompter) Read(p []byte) (int, error) {
+	for {
+		sp.mutex.Lock()
+		if len(sp.pending) > constant.ZeroInt {
+			n := copy(p, sp.pending)
+			sp.pending = sp.pending[n:]
+			sp.mutex.Unlock()
+			return n, nil
+		}
+		started, prompts := sp.started, sp.prompts
+		sp.mutex.Unlock()
+
+		if started {
+			if sp.stdin == nil {
+				return constant.ZeroInt, io.EOF
+			}
+			return sp.stdin.Read(p)
+		}
+		if prompts > constant.OneInt {
+			// sudo prompts again, the password is wrong, close stdin to let sudo fail
+			return constant.ZeroInt, io.EOF
+		}
+
+		select {
+		case <-sp.notify:
+		case <-sp.done:
+			return constant.ZeroInt, io.EOF
+		}
+	}
+}
+
+// Close stops waiting for sudo, it should be called after the command exits
+func (sp *sudoPrompter) Close() {
+	sp.once.Do(func() { close(sp.done) })
+}
+
+// isStarted returns if sudo has started the command
+func (sp *sudoPrompter) isStarted() bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	return sp.started
+}
+
+// handleMarker handles the marker found in the output
+func (sp *sudoPrompter) handleMarker(marker string) {
+	sp.mutex.Lock()
+	switch marker {
+	case sudoPromptMarker:
+		if sp.prompts == constant.ZeroInt {
+			sp.pending = []byte(sp.password + constant.CRLFString)
+		}
+		sp.prompts++
+	case sudoStartMarker:
+		sp.started = true
+	}
+	sp.mutex.Unlock()
+
+	select {
+	case sp.notify <- struct{}{}:
+	default:
+	}
+}
+
+// newOutputFilter returns a new *sudoOutputFilter which writes to given writer
+func (sp *sudoPrompter) newOutputFilter(writer io.Writer) *sudoOutputFilter {
+	return &sudoOutputFilter{
+		prompter: sp,
+		writer:   writer,
+	}
+}
+
+// sudoOutputFilter removes the markers from the output and passes them to the prompter,
+// both stdout and stderr are filtered, as the prompt is written to the terminal if pty is allocated
+type sudoOutputFilter struct {
+	prompter *sudoPrompter
+	writer   io.Writer
+	pending  []byte
+}
+
+// Write implements io.Writer interface
+func (sof *sudoOutputFilter) Write(p []byte) (int, error) {
+	if sof.prompter.isStarted() {
+		// no more markers after the command started
+		err := sof.Flush()
+		if err != nil {
+			return constant.ZeroInt, err
+		}
+		return sof.writer.Write(p)
+	}
+
+	sof.pending = append(sof.pending, p...)
+	for {
+		index, marker := indexSudoMarker(sof.pending)
+		if index < constant.ZeroInt {
+			break
+		}
+		_, err := sof.writer.Write(sof.pending[:index])
+		if err != nil {
+			return constant.ZeroInt, err
+		}
+		sof.pending = sof.pending[index+len(marker):]
+		sof.prompter.handleMarker(marker)
+	}
+
+	// keep the tail which may be the beginning of a marker
+	length := len(sof.pending) - getSudoMarkerPrefixLength(sof.pending)
+	_, err := sof.writer.Write(sof.pending[:length])
+	if err != nil {
+		return constant.ZeroInt, err
+	}
+	sof.pending = sof.pending[length:]
+
+	return len(p), nil
+}
+
+// Flush writes the kept tail, it should be called after the command exits
+func (sof *sudoOutputFilter) Flush() error {
+	if len(sof.pending) == constant.ZeroInt {
+		return nil
+	}
+
+	_, err := sof.writer.Write(sof.pending)
+	sof.pending = nil
+
+	return err
+}
+
+// indexSudoMarker returns the index of the first marker in the data and the marker, if there is no marker, it returns -1
+func indexSudoMarker(data []byte) (int, string) {
+	index, marker := -1, constant.EmptyString
+	for _, m := range []string{sudoPromptMarker, sudoStartMarker} {
+		i := bytes.Index(data, []byte(m))
+		if i >= constant.ZeroInt && (index < constant.ZeroInt || i < index) {
+			index, marker = i, m
+		}
+	}
+
+	return index, marker
+}
+
+// getSudoMarkerPrefixLength returns the length of the longest tail of the data which is the beginning of a marker
+func getSudoMarkerPrefixLength(data []byte) int {
+	length := constant.ZeroInt
+	for _, marker := range []string{sudoPromptMarker, sudoStartMarker} {
+		for l := len(marker) - constant.OneInt; l > length; l-- {
+			if l <= len(data) && bytes.HasSuffix(data, []byte(marker[:l])) {
+				length = l
+				break
+			}
+		}
+	}
+
+	return length
+}
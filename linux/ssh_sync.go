@@ -0,0 +1,769 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pkg/sftp"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultSyncAlwaysChecksum      = false
+	DefaultSyncResume              = true
+	DefaultSyncVerify              = true
+	DefaultSyncPreservePermissions = true
+	DefaultSyncPreserveOwnership   = false
+
+	// syncPartSuffix is the suffix of the partial file, the file is transferred to .$name.part and renamed after it completes
+	syncPartSuffix = ".part"
+	// syncResumeCheckSize is the size of the tail of the partial file which is compared with the source before resuming
+	syncResumeCheckSize = 1024 * 1024 // 1MB
+)
+
+// SyncProgress is the progress of the sync, the sizes are in bytes
+type SyncProgress struct {
+	// File is the path relative to the source root
+	File             string
+	FileSize         int64
+	FileTransferred  int64
+	FileIndex        int
+	FileCount        int
+	TotalSize        int64
+	TotalTransferred int64
+}
+
+// SyncProgressFunc is called after each chunk is transferred
+type SyncProgressFunc func(progress SyncProgress)
+
+// SyncOption is the option of SyncToRemote() and SyncFromRemote(),
+// by default, a file is transferred if its size differs, or its mtime differs and so does its checksum,
+// the checksum of the remote file is computed by sha256sum on the remote host
+type SyncOption struct {
+	// Includes are the glob patterns of the files to sync, if it is empty, all the files will be synced,
+	// a pattern matches either the path relative to the source root or the base name
+	Includes []string
+	// Excludes are the glob patterns of the files and directories not to sync, they take precedence over Includes
+	Excludes []string
+	// AlwaysChecksum compares the checksum even if the size and the mtime are the same
+	AlwaysChecksum bool
+	// Resume continues the partial file left by the interrupted transfer
+	Resume bool
+	// Verify compares the checksum of the transferred file with the source,
+	// the checksum of the remote file is computed by sha256sum on the remote host, so the file is not read back
+	Verify              bool
+	PreservePermissions bool
+	// PreserveOwnership preserves the uid and gid, it usually requires root privilege on the destination
+	PreserveOwnership bool
+	BufferSize        int
+	Progress          SyncProgressFunc
+}
+
+// NewSyncOption returns a new *SyncOption
+func NewSyncOption(includes, excludes []string, alwaysChecksum, resume, verify, preservePermissions, preserveOwnership bool,
+	bufferSize int, progress SyncProgressFunc) (*SyncOption, error) {
+	so := &SyncOption{
+		Includes:            includes,
+		Excludes:            excludes,
+		AlwaysChecksum:      alwaysChecksum,
+		Resume:              resume,
+		Verify:              verify,
+		PreservePermissions: preservePermissions,
+		PreserveOwnership:   preserveOwnership,
+		BufferSize:          bufferSize,
+		Progress:            progress,
+	}
+
+	err := so.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return so, nil
+}
+
+// NewSyncOptionWithDefault returns a new *SyncOption with default values
+func NewSyncOptionWithDefault() *SyncOption {
+	return &SyncOption{
+		AlwaysChecksum:      DefaultSyncAlwaysChecksum,
+		Resume:              DefaultSyncResume,
+		Verify:              DefaultSyncVerify,
+		PreservePermissions: DefaultSyncPreservePermissions,
+		PreserveOwnership:   DefaultSyncPreserveOwnership,
+		BufferSize:          DefaultByteBufferSize,
+	}
+}
+
+// Validate validates the option
+func (so *SyncOption) Validate() error {
+	for _, pattern := range append(append([]string{}, so.Includes...), so.Excludes...) {
+		_, err := path.Match(pattern, constant.EmptyString)
+		if err != nil {
+			return errors.Annotatef(err, "glob pattern is not valid. pattern: %s", pattern)
+		}
+	}
+	if so.BufferSize < constant.ZeroInt {
+		return errors.Errorf("buffer size must not be smaller than 0, %d is not valid", so.BufferSize)
+	}
+
+	return nil
+}
+
+// isExcluded returns if the relative path matches any exclude pattern
+func (so *SyncOption) isExcluded(rel string) bool {
+	return matchGlobs(so.Excludes, rel)
+}
+
+// isIncluded returns if the file of the relative path should be synced
+func (so *SyncOption) isIncluded(rel string) bool {
+	return len(so.Includes) == constant.ZeroInt || matchGlobs(so.Includes, rel)
+}
+
+// matchGlobs returns if the relative path or its base name matches any pattern
+func matchGlobs(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		matched, _ := path.Match(pattern, rel)
+		if matched {
+			return true
+		}
+		matched, _ = path.Match(pattern, path.Base(rel))
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SyncResult is the result of the sync, the files are the paths relative to the source root
+type SyncResult struct {
+	TransferredFiles []string
+	// ResumedFiles are the files which continued the partial files, they are also in TransferredFiles
+	ResumedFiles     []string
+	SkippedFiles     []string
+	TransferredBytes int64
+	Duration         time.Duration
+}
+
+// SyncToRemote syncs the local file or directory to the remote host, it acts like "rsync -r $pathSource/ $pathDest",
+// if the source is a directory, its content is synced into the destination directory,
+// if the source is a file and the destination is an existing directory, the file is synced into the directory,
+// only the changed files are transferred, and the symbolic links and the other special files are skipped
+func (conn *SSHConn) SyncToRemote(ctx context.Context, pathSource, pathDest string, option *SyncOption) (*SyncResult, error) {
+	return newSyncer(localSyncFS{}, newRemoteSyncFS(conn), option).sync(ctx, pathSource, pathDest)
+}
+
+// SyncFromRemote syncs the file or directory of the remote host to local, it acts like SyncToRemote() in reverse
+func (conn *SSHConn) SyncFromRemote(ctx context.Context, pathSource, pathDest string, option *SyncOption) (*SyncResult, error) {
+	return newSyncer(newRemoteSyncFS(conn), localSyncFS{}, option).sync(ctx, pathSource, pathDest)
+}
+
+// syncFile is the file opened by syncFS
+type syncFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+}
+
+// syncFS is the file system of one side of the sync
+type syncFS interface {
+	Lstat(name string) (os.FileInfo, error)
+	// Walk walks the file tree in lexical order, fn could return filepath.SkipDir to skip a directory
+	Walk(root string, fn func(name string, info os.FileInfo) error) error
+	OpenFile(name string, flag int) (syncFile, error)
+	MkdirAll(name string) error
+	// Rename renames the file and replaces the existing one
+	Rename(oldName, newName string) error
+	Remove(name string) error
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+	Chtimes(name string, atime, mtime time.Time) error
+	// GetOwner returns the uid and gid of the file info
+	GetOwner(info os.FileInfo) (int, int, bool)
+	// Checksum returns the hex encoded sha256 checksum of the file, it is computed where the file is
+	Checksum(ctx context.Context, name string) (string, error)
+}
+
+// localSyncFS is the local file system
+type localSyncFS struct{}
+
+func (localSyncFS) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (localSyncFS) Walk(root string, fn func(name string, info os.FileInfo) error) error {
+	return filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		return fn(name, info)
+	})
+}
+
+func (localSyncFS) OpenFile(name string, flag int) (syncFile, error) {
+	return os.OpenFile(name, flag, constant.DefaultFileMode)
+}
+
+func (localSyncFS) MkdirAll(name string) error {
+	return os.MkdirAll(name, constant.DefaultExecFileMode)
+}
+
+func (localSyncFS) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (localSyncFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (localSyncFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (localSyncFS) Chown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+func (localSyncFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (localSyncFS) GetOwner(info os.FileInfo) (int, int, bool) {
+	return getFileOwner(info)
+}
+
+func (localSyncFS) Checksum(ctx context.Context, name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return constant.EmptyString, errors.Trace(err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// remoteSyncFS is the file system of the remote host
+type remoteSyncFS struct {
+	conn   *SSHConn
+	client *sftp.Client
+}
+
+// newRemoteSyncFS returns a new remoteSyncFS of the connection
+func newRemoteSyncFS(conn *SSHConn) remoteSyncFS {
+	return remoteSyncFS{
+		conn:   conn,
+		client: conn.SFTPClient,
+	}
+}
+
+func (rfs remoteSyncFS) Lstat(name string) (os.FileInfo, error) {
+	return rfs.client.Lstat(name)
+}
+
+func (rfs remoteSyncFS) Walk(root string, fn func(name string, info os.FileInfo) error) error {
+	walker := rfs.client.Walk(root)
+	for walker.Step() {
+		err := walker.Err()
+		if err != nil {
+			return err
+		}
+		err = fn(walker.Path(), walker.Stat())
+		if err == filepath.SkipDir {
+			walker.SkipDir()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rfs remoteSyncFS) OpenFile(name string, flag int) (syncFile, error) {
+	return rfs.client.OpenFile(name, flag)
+}
+
+func (rfs remoteSyncFS) MkdirAll(name string) error {
+	return rfs.client.MkdirAll(name)
+}
+
+func (rfs remoteSyncFS) Rename(oldName, newName string) error {
+	err := rfs.client.PosixRename(oldName, newName)
+	if err == nil {
+		return nil
+	}
+	// the server does not support posix-rename@openssh.com extension, the plain rename fails if the new file exists
+	_ = rfs.client.Remove(newName)
+
+	return rfs.client.Rename(oldName, newName)
+}
+
+func (rfs remoteSyncFS) Remove(name string) error {
+	return rfs.client.Remove(name)
+}
+
+func (rfs remoteSyncFS) Chmod(name string, mode os.FileMode) error {
+	return rfs.client.Chmod(name, mode)
+}
+
+func (rfs remoteSyncFS) Chown(name string, uid, gid int) error {
+	return rfs.client.Chown(name, uid, gid)
+}
+
+func (rfs remoteSyncFS) Chtimes(name string, atime, mtime time.Time) error {
+	return rfs.client.Chtimes(name, atime, mtime)
+}
+
+func (rfs remoteSyncFS) GetOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*sftp.FileStat)
+	if !ok {
+		return constant.ZeroInt, constant.ZeroInt, false
+	}
+
+	return int(stat.UID), int(stat.GID), true
+}
+
+// Checksum runs sha256sum on the remote host, so the file is not transferred to compute the checksum
+func (rfs remoteSyncFS) Checksum(ctx context.Context, name string) (string, error) {
+	result, err := rfs.conn.Run(ctx, shellJoin(rfs.conn.getBinary(sha256sumBinary), endOfOptionsArg, name))
+	if err != nil {
+		return constant.EmptyString, errors.Annotatef(err, "get checksum of the remote file failed. path: %s, stderr: %s", name, result.Stderr)
+	}
+
+	return parseSHA256Sum(result.Stdout)
+}
+
+// syncEntry is a file or directory to sync
+type syncEntry struct {
+	rel     string
+	srcPath string
+	dstPath string
+	info    os.FileInfo
+}
+
+// syncer syncs the files from src to dst
+type syncer struct {
+	src      syncFS
+	dst      syncFS
+	option   *SyncOption
+	result   *SyncResult
+	progress SyncProgress
+}
+
+// newSyncer returns a new *syncer, if option is nil, the default option will be used
+func newSyncer(src, dst syncFS, option *SyncOption) *syncer {
+	if option == nil {
+		option = NewSyncOptionWithDefault()
+	}
+
+	return &syncer{
+		src:    src,
+		dst:    dst,
+		option: option,
+		result: &SyncResult{},
+	}
+}
+
+// sync syncs the source path to the destination path
+func (s *syncer) sync(ctx context.Context, pathSource, pathDest string) (*SyncResult, error) {
+	startTime := time.Now()
+	defer func() { s.result.Duration = time.Since(startTime) }()
+
+	err := s.option.Validate()
+	if err != nil {
+		return s.result, err
+	}
+
+	pathSource = strings.TrimSpace(pathSource)
+	pathDest = strings.TrimSpace(pathDest)
+
+	dirs, files, err := s.getEntries(pathSource, pathDest)
+	if err != nil {
+		return s.result, err
+	}
+
+	// create the directories first
+	for _, dir := range dirs {
+		err = s.dst.MkdirAll(dir.dstPath)
+		if err != nil {
+			return s.result, errors.Trace(err)
+		}
+	}
+
+	// find the changed files
+	var changed []*syncEntry
+	for _, file := range files {
+		needTransfer, err := s.needTransfer(ctx, file)
+		if err != nil {
+			return s.result, err
+		}
+		if !needTransfer {
+			s.result.SkippedFiles = append(s.result.SkippedFiles, file.rel)
+			continue
+		}
+		changed = append(changed, file)
+		s.progress.TotalSize += file.info.Size()
+	}
+
+	s.progress.FileCount = len(changed)
+	for i, file := range changed {
+		if ctx.Err() != nil {
+			return s.result, errors.Trace(ctx.Err())
+		}
+		s.progress.FileIndex = i + constant.OneInt
+		err = s.transferFile(ctx, file)
+		if err != nil {
+			return s.result, err
+		}
+		s.result.TransferredFiles = append(s.result.TransferredFiles, file.rel)
+	}
+
+	// set the metadata of the directories after their files are synced, the deepest one first
+	for i := len(dirs) - constant.OneInt; i >= constant.ZeroInt; i-- {
+		err = s.setMetadata(dirs[i])
+		if err != nil {
+			return s.result, err
+		}
+	}
+
+	return s.result, nil
+}
+
+// getEntries returns the directories and the regular files to sync, the directories are in lexical order
+func (s *syncer) getEntries(pathSource, pathDest string) ([]*syncEntry, []*syncEntry, error) {
+	srcInfo, err := s.src.Lstat(pathSource)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	if !srcInfo.IsDir() {
+		if !srcInfo.Mode().IsRegular() {
+			return nil, nil, errors.Errorf("source must be a regular file or a directory. path: %s", pathSource)
+		}
+		dstInfo, err := s.dst.Lstat(pathDest)
+		if err == nil && dstInfo.IsDir() {
+			pathDest = filepath.Join(pathDest, filepath.Base(pathSource))
+		}
+
+		return nil, []*syncEntry{{rel: filepath.Base(pathSource), srcPath: pathSource, dstPath: pathDest, info: srcInfo}}, nil
+	}
+
+	var dirs, files []*syncEntry
+	err = s.src.Walk(pathSource, func(name string, info os.FileInfo) error {
+		rel, err := filepath.Rel(pathSource, name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		entry := &syncEntry{rel: rel, srcPath: name, dstPath: filepath.Join(pathDest, rel), info: info}
+
+		if info.IsDir() {
+			if rel != constant.DotString && s.option.isExcluded(rel) {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, entry)
+			return nil
+		}
+		if !info.Mode().IsRegular() || isPartFile(name) {
+			// skip the symbolic links, the special files and the partial files
+			return nil
+		}
+		if s.option.isExcluded(rel) || !s.option.isIncluded(rel) {
+			return nil
+		}
+		files = append(files, entry)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	return dirs, files, nil
+}
+
+// needTransfer returns if the file should be transferred,
+// if the file is not transferred because it has the same checksum, its metadata will be updated
+func (s *syncer) needTransfer(ctx context.Context, entry *syncEntry) (bool, error) {
+	dstInfo, err := s.dst.Lstat(entry.dstPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, errors.Trace(err)
+	}
+	if dstInfo.IsDir() {
+		return false, errors.Errorf("destination is a directory, but source is a file. path: %s", entry.dstPath)
+	}
+	if dstInfo.Size() != entry.info.Size() {
+		return true, nil
+	}
+	// sftp only keeps the mtime in seconds
+	sameMTime := dstInfo.ModTime().Unix() == entry.info.ModTime().Unix()
+	if sameMTime && !s.option.AlwaysChecksum {
+		return false, nil
+	}
+
+	sameChecksum, err := s.compareChecksum(ctx, entry.srcPath, entry.dstPath)
+	if err != nil {
+		return false, err
+	}
+	if !sameChecksum {
+		return true, nil
+	}
+	if !sameMTime {
+		return false, s.setMetadata(entry)
+	}
+
+	return false, nil
+}
+
+// transferFile transfers the file to the partial file, and renames it to the destination after it completes
+func (s *syncer) transferFile(ctx context.Context, entry *syncEntry) error {
+	partPath := getPartPath(entry.dstPath)
+
+	var offset int64
+	if s.option.Resume {
+		var err error
+		offset, err = s.getResumeOffset(entry, partPath)
+		if err != nil {
+			return err
+		}
+		if offset > constant.ZeroInt {
+			s.result.ResumedFiles = append(s.result.ResumedFiles, entry.rel)
+		}
+	}
+
+	err := s.copyFile(ctx, entry, partPath, offset)
+	if err != nil {
+		if !s.option.Resume {
+			_ = s.dst.Remove(partPath)
+		}
+		return err
+	}
+
+	if s.option.Verify {
+		sameChecksum, err := s.compareChecksum(ctx, entry.srcPath, partPath)
+		if err != nil {
+			return err
+		}
+		if !sameChecksum {
+			_ = s.dst.Remove(partPath)
+			return errors.Errorf("checksum of the transferred file does not match the source. path: %s", entry.srcPath)
+		}
+	}
+
+	err = s.dst.Rename(partPath, entry.dstPath)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return s.setMetadata(entry)
+}
+
+// copyFile copies the source file to the partial file from the offset
+func (s *syncer) copyFile(ctx context.Context, entry *syncEntry, partPath string, offset int64) error {
+	srcFile, err := s.src.OpenFile(entry.srcPath, os.O_RDONLY)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	flag := os.O_WRONLY | os.O_CREATE
+	if offset == constant.ZeroInt {
+		flag |= os.O_TRUNC
+	}
+	dstFile, err := s.dst.OpenFile(partPath, flag)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = dstFile.Close() }()
+
+	if offset > constant.ZeroInt {
+		_, err = srcFile.Seek(offset, io.SeekStart)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		_, err = dstFile.Seek(offset, io.SeekStart)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	s.progress.File = entry.rel
+	s.progress.FileSize = entry.info.Size()
+	s.progress.FileTransferred = offset
+	s.progress.TotalTransferred += offset
+
+	bufferSize := s.option.BufferSize
+	if bufferSize <= constant.ZeroInt {
+		bufferSize = DefaultByteBufferSize
+	}
+	buf := make([]byte, bufferSize)
+	for {
+		if ctx.Err() != nil {
+			return errors.Trace(ctx.Err())
+		}
+
+		n, err := srcFile.Read(buf)
+		if n > constant.ZeroInt {
+			_, writeErr := dstFile.Write(buf[:n])
+			if writeErr != nil {
+				return errors.Trace(writeErr)
+			}
+			s.result.TransferredBytes += int64(n)
+			s.progress.FileTransferred += int64(n)
+			s.progress.TotalTransferred += int64(n)
+			if s.option.Progress != nil {
+				s.option.Progress(s.progress)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return errors.Trace(dstFile.Close())
+}
+
+// getResumeOffset returns the offset to resume from, it compares the tail of the partial file with the source,
+// if they are different or the partial file does not exist, it returns 0
+func (s *syncer) getResumeOffset(entry *syncEntry, partPath string) (int64, error) {
+	partInfo, err := s.dst.Lstat(partPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return constant.ZeroInt, nil
+		}
+		return constant.ZeroInt, errors.Trace(err)
+	}
+	size := partInfo.Size()
+	if size <= constant.ZeroInt || size > entry.info.Size() || !partInfo.Mode().IsRegular() {
+		return constant.ZeroInt, nil
+	}
+
+	checkSize := size
+	if checkSize > syncResumeCheckSize {
+		checkSize = syncResumeCheckSize
+	}
+	srcTail, err := readAt(s.src, entry.srcPath, size-checkSize, checkSize)
+	if err != nil {
+		return constant.ZeroInt, err
+	}
+	partTail, err := readAt(s.dst, partPath, size-checkSize, checkSize)
+	if err != nil {
+		return constant.ZeroInt, err
+	}
+	if !bytes.Equal(srcTail, partTail) {
+		return constant.ZeroInt, nil
+	}
+
+	return size, nil
+}
+
+// compareChecksum returns if the source file and the destination file have the same checksum
+func (s *syncer) compareChecksum(ctx context.Context, srcPath, dstPath string) (bool, error) {
+	srcChecksum, err := s.src.Checksum(ctx, srcPath)
+	if err != nil {
+		return false, err
+	}
+	dstChecksum, err := s.dst.Checksum(ctx, dstPath)
+	if err != nil {
+		return false, err
+	}
+
+	return srcChecksum == dstChecksum, nil
+}
+
+// setMetadata sets the ownership, the permissions and the mtime of the destination as the source
+func (s *syncer) setMetadata(entry *syncEntry) error {
+	if s.option.PreserveOwnership {
+		uid, gid, ok := s.src.GetOwner(entry.info)
+		if ok {
+			// chown may clear the setuid bits, so it must be done before chmod
+			err := s.dst.Chown(entry.dstPath, uid, gid)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	if s.option.PreservePermissions {
+		err := s.dst.Chmod(entry.dstPath, entry.info.Mode().Perm())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if entry.info.IsDir() {
+		return nil
+	}
+
+	// the mtime is always synced, so the unchanged files could be skipped next time without comparing the checksum
+	return errors.Trace(s.dst.Chtimes(entry.dstPath, entry.info.ModTime(), entry.info.ModTime()))
+}
+
+// getPartPath returns the path of the partial file of given file
+func getPartPath(name string) string {
+	return filepath.Join(filepath.Dir(name), constant.DotString+filepath.Base(name)+syncPartSuffix)
+}
+
+// isPartFile returns if given file is a partial file left by the interrupted transfer
+func isPartFile(name string) bool {
+	base := filepath.Base(name)
+
+	return strings.HasPrefix(base, constant.DotString) && strings.HasSuffix(base, syncPartSuffix)
+}
+
+// parseSHA256Sum returns the checksum of the output of sha256sum,
+// the output starts with a backslash if the file name contains the special characters
+func parseSHA256Sum(output string) (string, error) {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(output), constant.BackSlashString))
+	if len(fields) == constant.ZeroInt || len(fields[constant.ZeroInt]) != sha256.Size*constant.TwoInt {
+		return constant.EmptyString, errors.Errorf("output of sha256sum is not valid. output: %s", output)
+	}
+	_, err := hex.DecodeString(fields[constant.ZeroInt])
+	if err != nil {
+		return constant.EmptyString, errors.Annotatef(err, "output of sha256sum is not valid. output: %s", output)
+	}
+
+	return fields[constant.ZeroInt], nil
+}
+
+// readAt reads size bytes of the file from the offset
+func readAt(fs syncFS, name string, offset, size int64) ([]byte, error) {
+	file, err := fs.OpenFile(name, os.O_RDONLY)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	buf := make([]byte, size)
+	_, err = io.ReadFull(file, buf)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return buf, nil
+}
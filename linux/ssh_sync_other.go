@@ -0,0 +1,14 @@
+//go:build !unix
+
+package linux
+
+import (
+	"os"
+
+	"github.com/romberli/go-util/constant"
+)
+
+// getFileOwner returns false as the uid and gid of the local file are not supported
+func getFileOwner(info os.FileInfo) (int, int, bool) {
+	return constant.ZeroInt, constant.ZeroInt, false
+}
@@ -0,0 +1,268 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	testSyncBigFileName  = "big.bin"
+	testSyncBigFileSize  = 3 * 1024 * 1024
+	testSyncExecFileName = "bin/run.sh"
+)
+
+var testSyncFiles = map[string]string{
+	"a.txt":              "test_a",
+	"sub/b.log":          "test_b",
+	"sub/deep/c.txt":     "test_c",
+	"skip/d.txt":         "test_d",
+	"e.tmp":              "test_e",
+	testSyncExecFileName: "#!/bin/sh\necho test",
+}
+
+// testNewSyncConn starts an in-process ssh server and returns the connection, the local and the remote directories,
+// note that the in-process server shares the local file system
+func testNewSyncConn(t *testing.T) (*SSHConn, string, string) {
+	server := newTestSSHServer(t)
+	conn, err := NewSSHConnWithConfig(server.getConfig(testSSHServerUserPass))
+	if err != nil {
+		t.Fatalf("connect to test ssh server failed. error:\n%+v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	tmpDir := t.TempDir()
+	localDir := filepath.Join(tmpDir, "local dir")
+	for name, content := range testSyncFiles {
+		fileName := filepath.Join(localDir, name)
+		err = os.MkdirAll(filepath.Dir(fileName), constant.DefaultExecFileMode)
+		if err != nil {
+			t.Fatalf("create dir failed. error:\n%+v", err)
+		}
+		err = os.WriteFile(fileName, []byte(content), constant.DefaultFileMode)
+		if err != nil {
+			t.Fatalf("write file failed. error:\n%+v", err)
+		}
+	}
+	err = os.Chmod(filepath.Join(localDir, testSyncExecFileName), 0750)
+	if err != nil {
+		t.Fatalf("chmod failed. error:\n%+v", err)
+	}
+
+	return conn, localDir, filepath.Join(tmpDir, "remote dir")
+}
+
+func testSortedStrings(s []string) []string {
+	sorted := append([]string{}, s...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func TestSSHSync_All(t *testing.T) {
+	TestSSHSync_SyncToRemote(t)
+	TestSSHSync_Checksum(t)
+	TestSSHSync_Resume(t)
+	TestSSHSync_SyncFromRemote(t)
+	TestSSHSync_Option(t)
+}
+
+func TestSSHSync_SyncToRemote(t *testing.T) {
+	asst := assert.New(t)
+
+	conn, localDir, remoteDir := testNewSyncConn(t)
+	option := NewSyncOptionWithDefault()
+	option.Excludes = []string{"skip", "*.tmp"}
+	option.PreserveOwnership = true
+	var progresses []SyncProgress
+	option.Progress = func(progress SyncProgress) {
+		progresses = append(progresses, progress)
+	}
+
+	result, err := conn.SyncToRemote(context.Background(), localDir, remoteDir, option)
+	asst.Nil(err, "test SyncToRemote() failed")
+	asst.Equal([]string{"a.txt", testSyncExecFileName, "sub/b.log", "sub/deep/c.txt"},
+		testSortedStrings(result.TransferredFiles), "test SyncToRemote() failed")
+	for _, name := range result.TransferredFiles {
+		content, err := os.ReadFile(filepath.Join(remoteDir, name))
+		asst.Nil(err, "test SyncToRemote() failed")
+		asst.Equal(testSyncFiles[name], string(content), "test SyncToRemote() failed")
+		localInfo, err := os.Stat(filepath.Join(localDir, name))
+		asst.Nil(err, "test SyncToRemote() failed")
+		remoteInfo, err := os.Stat(filepath.Join(remoteDir, name))
+		asst.Nil(err, "test SyncToRemote() failed")
+		asst.Equal(localInfo.Mode(), remoteInfo.Mode(), "test SyncToRemote() failed")
+		asst.Equal(localInfo.ModTime().Unix(), remoteInfo.ModTime().Unix(), "test SyncToRemote() failed")
+	}
+	exists, err := PathExists(filepath.Join(remoteDir, "skip"))
+	asst.Nil(err, "test SyncToRemote() failed")
+	asst.False(exists, "test SyncToRemote() failed")
+	exists, err = PathExists(filepath.Join(remoteDir, "e.tmp"))
+	asst.Nil(err, "test SyncToRemote() failed")
+	asst.False(exists, "test SyncToRemote() failed")
+
+	// progress
+	if asst.NotEmpty(progresses, "test SyncToRemote() failed") {
+		last := progresses[len(progresses)-constant.OneInt]
+		asst.Equal(4, last.FileCount, "test SyncToRemote() failed")
+		asst.Equal(last.FileCount, last.FileIndex, "test SyncToRemote() failed")
+		asst.Equal(last.TotalSize, last.TotalTransferred, "test SyncToRemote() failed")
+		asst.Equal(result.TransferredBytes, last.TotalTransferred, "test SyncToRemote() failed")
+	}
+
+	// nothing changed
+	result, err = conn.SyncToRemote(context.Background(), localDir, remoteDir, option)
+	asst.Nil(err, "test SyncToRemote() failed")
+	asst.Empty(result.TransferredFiles, "test SyncToRemote() failed")
+	asst.Equal(4, len(result.SkippedFiles), "test SyncToRemote() failed")
+	asst.Equal(int64(constant.ZeroInt), result.TransferredBytes, "test SyncToRemote() failed")
+
+	// the canceled context
+	err = os.WriteFile(filepath.Join(localDir, "a.txt"), []byte("changed"), constant.DefaultFileMode)
+	asst.Nil(err, "test SyncToRemote() failed")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = conn.SyncToRemote(ctx, localDir, remoteDir, option)
+	asst.Equal(context.Canceled, errors.Cause(err), "test SyncToRemote() failed")
+}
+
+func TestSSHSync_Checksum(t *testing.T) {
+	asst := assert.New(t)
+
+	conn, localDir, remoteDir := testNewSyncConn(t)
+	_, err := conn.SyncToRemote(context.Background(), localDir, remoteDir, nil)
+	asst.Nil(err, "test Checksum() failed")
+
+	// the content changed, but the size and the mtime are the same
+	fileName := filepath.Join(localDir, "a.txt")
+	info, err := os.Stat(fileName)
+	asst.Nil(err, "test Checksum() failed")
+	err = os.WriteFile(fileName, []byte("test_x"), constant.DefaultFileMode)
+	asst.Nil(err, "test Checksum() failed")
+	err = os.Chtimes(fileName, info.ModTime(), info.ModTime())
+	asst.Nil(err, "test Checksum() failed")
+	result, err := conn.SyncToRemote(context.Background(), localDir, remoteDir, nil)
+	asst.Nil(err, "test Checksum() failed")
+	asst.Empty(result.TransferredFiles, "test Checksum() failed")
+	option := NewSyncOptionWithDefault()
+	option.AlwaysChecksum = true
+	result, err = conn.SyncToRemote(context.Background(), localDir, remoteDir, option)
+	asst.Nil(err, "test Checksum() failed")
+	asst.Equal([]string{"a.txt"}, result.TransferredFiles, "test Checksum() failed")
+
+	// the mtime changed, but the content is the same
+	mtime := time.Now().Add(-time.Hour)
+	err = os.Chtimes(fileName, mtime, mtime)
+	asst.Nil(err, "test Checksum() failed")
+	result, err = conn.SyncToRemote(context.Background(), localDir, remoteDir, nil)
+	asst.Nil(err, "test Checksum() failed")
+	asst.Empty(result.TransferredFiles, "test Checksum() failed")
+	remoteInfo, err := os.Stat(filepath.Join(remoteDir, "a.txt"))
+	asst.Nil(err, "test Checksum() failed")
+	asst.Equal(mtime.Unix(), remoteInfo.ModTime().Unix(), "test Checksum() failed")
+
+	// the checksum of the remote file is computed on the remote host
+	remoteFileName := filepath.Join(remoteDir, "it's a.txt")
+	err = os.WriteFile(remoteFileName, []byte("test_x"), constant.DefaultFileMode)
+	asst.Nil(err, "test Checksum() failed")
+	localChecksum, err := localSyncFS{}.Checksum(context.Background(), fileName)
+	asst.Nil(err, "test Checksum() failed")
+	remoteChecksum, err := newRemoteSyncFS(conn).Checksum(context.Background(), remoteFileName)
+	asst.Nil(err, "test Checksum() failed")
+	asst.Equal(localChecksum, remoteChecksum, "test Checksum() failed")
+	_, err = newRemoteSyncFS(conn).Checksum(context.Background(), filepath.Join(remoteDir, "not_exists"))
+	asst.NotNil(err, "test Checksum() failed")
+	checksum, err := parseSHA256Sum(constant.BackSlashString + localChecksum + "  a\\nb\n")
+	asst.Nil(err, "test Checksum() failed")
+	asst.Equal(localChecksum, checksum, "test Checksum() failed")
+	_, err = parseSHA256Sum("sha256sum: not found")
+	asst.NotNil(err, "test Checksum() failed")
+}
+
+func TestSSHSync_Resume(t *testing.T) {
+	asst := assert.New(t)
+
+	conn, localDir, remoteDir := testNewSyncConn(t)
+	content := bytes.Repeat([]byte("0123456789abcdef"), testSyncBigFileSize/16)
+	err := os.WriteFile(filepath.Join(localDir, testSyncBigFileName), content, constant.DefaultFileMode)
+	asst.Nil(err, "test Resume() failed")
+	err = os.MkdirAll(remoteDir, constant.DefaultExecFileMode)
+	asst.Nil(err, "test Resume() failed")
+
+	// the partial file left by the interrupted transfer
+	half := int64(len(content) / constant.TwoInt)
+	partPath := getPartPath(filepath.Join(remoteDir, testSyncBigFileName))
+	err = os.WriteFile(partPath, content[:half], constant.DefaultFileMode)
+	asst.Nil(err, "test Resume() failed")
+	option := NewSyncOptionWithDefault()
+	option.Includes = []string{testSyncBigFileName}
+	result, err := conn.SyncToRemote(context.Background(), localDir, remoteDir, option)
+	asst.Nil(err, "test Resume() failed")
+	asst.Equal([]string{testSyncBigFileName}, result.ResumedFiles, "test Resume() failed")
+	asst.Equal(int64(len(content))-half, result.TransferredBytes, "test Resume() failed")
+	remoteContent, err := os.ReadFile(filepath.Join(remoteDir, testSyncBigFileName))
+	asst.Nil(err, "test Resume() failed")
+	asst.True(bytes.Equal(content, remoteContent), "test Resume() failed")
+	exists, err := PathExists(partPath)
+	asst.Nil(err, "test Resume() failed")
+	asst.False(exists, "test Resume() failed")
+
+	// the partial file does not match the source
+	err = os.Remove(filepath.Join(remoteDir, testSyncBigFileName))
+	asst.Nil(err, "test Resume() failed")
+	err = os.WriteFile(partPath, bytes.Repeat([]byte("x"), int(half)), constant.DefaultFileMode)
+	asst.Nil(err, "test Resume() failed")
+	result, err = conn.SyncToRemote(context.Background(), localDir, remoteDir, option)
+	asst.Nil(err, "test Resume() failed")
+	asst.Empty(result.ResumedFiles, "test Resume() failed")
+	asst.Equal(int64(len(content)), result.TransferredBytes, "test Resume() failed")
+	remoteContent, err = os.ReadFile(filepath.Join(remoteDir, testSyncBigFileName))
+	asst.Nil(err, "test Resume() failed")
+	asst.True(bytes.Equal(content, remoteContent), "test Resume() failed")
+}
+
+func TestSSHSync_SyncFromRemote(t *testing.T) {
+	asst := assert.New(t)
+
+	// the local directory of the test is the remote source here
+	conn, remoteDir, localDir := testNewSyncConn(t)
+	option := NewSyncOptionWithDefault()
+	option.Includes = []string{"*.txt"}
+	option.Excludes = []string{"skip"}
+	result, err := conn.SyncFromRemote(context.Background(), remoteDir, localDir, option)
+	asst.Nil(err, "test SyncFromRemote() failed")
+	asst.Equal([]string{"a.txt", "sub/deep/c.txt"}, testSortedStrings(result.TransferredFiles), "test SyncFromRemote() failed")
+
+	// sync a single file into the existing directory
+	result, err = conn.SyncFromRemote(context.Background(), filepath.Join(remoteDir, "sub/b.log"), localDir, nil)
+	asst.Nil(err, "test SyncFromRemote() failed")
+	asst.Equal([]string{"b.log"}, result.TransferredFiles, "test SyncFromRemote() failed")
+	content, err := os.ReadFile(filepath.Join(localDir, "b.log"))
+	asst.Nil(err, "test SyncFromRemote() failed")
+	asst.Equal(testSyncFiles["sub/b.log"], string(content), "test SyncFromRemote() failed")
+}
+
+func TestSSHSync_Option(t *testing.T) {
+	asst := assert.New(t)
+
+	_, err := NewSyncOption([]string{"[a-"}, nil, false, true, true, true, false, DefaultByteBufferSize, nil)
+	asst.NotNil(err, "test NewSyncOption() failed")
+	_, err = NewSyncOption(nil, nil, false, true, true, true, false, -1, nil)
+	asst.NotNil(err, "test NewSyncOption() failed")
+	option, err := NewSyncOption([]string{"*.txt"}, []string{"skip"}, false, true, true, true, false, constant.ZeroInt, nil)
+	asst.Nil(err, "test NewSyncOption() failed")
+	asst.True(option.isIncluded("sub/deep/c.txt"), "test NewSyncOption() failed")
+	asst.False(option.isIncluded("sub/b.log"), "test NewSyncOption() failed")
+	asst.True(option.isExcluded("sub/skip"), "test NewSyncOption() failed")
+	asst.True(isPartFile(getPartPath("/tmp/a.txt")), "test NewSyncOption() failed")
+	asst.False(isPartFile("/tmp/a.part"), "test NewSyncOption() failed")
+}
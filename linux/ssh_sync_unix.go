@@ -0,0 +1,20 @@
+//go:build unix
+
+package linux
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/romberli/go-util/constant"
+)
+
+// getFileOwner returns the uid and gid of the local file info
+func getFileOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return constant.ZeroInt, constant.ZeroInt, false
+	}
+
+	return int(stat.Uid), int(stat.Gid), true
+}
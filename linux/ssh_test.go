@@ -1,9 +1,11 @@
 package linux
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -302,3 +304,160 @@ func TestSSHConn_Common(t *testing.T) {
 	output, err = testSSHConn.ExecuteCommand("whoami")
 	t.Log(output)
 }
+
+func TestSSHConn_QuotedFileHelpers(t *testing.T) {
+	asst := assert.New(t)
+
+	server := newTestSSHServer(t)
+	conn, err := NewSSHConnWithConfig(server.getConfig(testSSHServerUserPass))
+	if !asst.Nil(err, "test QuotedFileHelpers() failed") {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	tmpDir := t.TempDir()
+	injectedFile := filepath.Join(tmpDir, "injected")
+	// the path contains spaces, quotes and command substitutions
+	dirName := filepath.Join(tmpDir, fmt.Sprintf(`-dir with 'single' "double" $(touch %s) ; touch %s`, injectedFile, injectedFile))
+	fileName := filepath.Join(dirName, "file name.txt")
+
+	err = conn.MkdirAll(dirName)
+	asst.Nil(err, "test MkdirAll() failed")
+	isDir, err := conn.IsDir(dirName)
+	asst.Nil(err, "test IsDir() failed")
+	asst.True(isDir, "test IsDir() failed")
+	err = conn.Touch(fileName)
+	asst.Nil(err, "test Touch() failed")
+	exists, err := conn.PathExists(fileName)
+	asst.Nil(err, "test PathExists() failed")
+	asst.True(exists, "test PathExists() failed")
+	isDir, err = conn.IsDir(fileName)
+	asst.Nil(err, "test IsDir() failed")
+	asst.False(isDir, "test IsDir() failed")
+	err = os.WriteFile(fileName, []byte(testContent), constant.DefaultFileMode)
+	asst.Nil(err, "test QuotedFileHelpers() failed")
+	content, err := conn.Cat(fileName)
+	asst.Nil(err, "test Cat() failed")
+	asst.Equal(testContent, content, "test Cat() failed")
+	subPaths, err := conn.ListPath(dirName)
+	asst.Nil(err, "test ListPath() failed")
+	asst.Equal([]string{"file name.txt"}, subPaths, "test ListPath() failed")
+
+	copyFileName := filepath.Join(dirName, "copy 'file'.txt")
+	err = conn.Copy(fileName, copyFileName)
+	asst.Nil(err, "test Copy() failed")
+	moveFileName := filepath.Join(dirName, `move "file".txt`)
+	err = conn.Move(copyFileName, moveFileName)
+	asst.Nil(err, "test Move() failed")
+	exists, err = conn.PathExists(copyFileName)
+	asst.Nil(err, "test Move() failed")
+	asst.False(exists, "test Move() failed")
+	err = conn.Chmod(moveFileName, "0600")
+	asst.Nil(err, "test Chmod() failed")
+	fileInfo, err := os.Stat(moveFileName)
+	asst.Nil(err, "test Chmod() failed")
+	asst.Equal(os.FileMode(0600), fileInfo.Mode().Perm(), "test Chmod() failed")
+	err = conn.Chown(moveFileName, "root", "root")
+	asst.Nil(err, "test Chown() failed")
+
+	err = conn.RemoveAll(dirName)
+	asst.Nil(err, "test RemoveAll() failed")
+	exists, err = conn.PathExists(dirName)
+	asst.Nil(err, "test RemoveAll() failed")
+	asst.False(exists, "test RemoveAll() failed")
+	exists, err = PathExists(injectedFile)
+	asst.Nil(err, "test QuotedFileHelpers() failed")
+	asst.False(exists, "test QuotedFileHelpers() failed")
+
+	// the binaries are searched in $PATH
+	conn.Config.SetBinDir(constant.EmptyString)
+	exists, err = conn.PathExists(tmpDir)
+	asst.Nil(err, "test SetBinDir() failed")
+	asst.True(exists, "test SetBinDir() failed")
+	conn.Config.SetBinDir(filepath.Join(tmpDir, "not_exists"))
+	_, err = conn.PathExists(tmpDir)
+	asst.NotNil(err, "test SetBinDir() failed")
+}
+
+func TestSSHConn_Sudo(t *testing.T) {
+	asst := assert.New(t)
+
+	server := newTestSSHServer(t)
+	conn, err := NewSSHConnWithConfig(server.getConfig(testSSHServerUserPass))
+	if !asst.Nil(err, "test Sudo() failed") {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	// the fake sudo records its arguments, prompts and checks the password from stdin and runs the command after "--",
+	// if the nopasswd file exists, it does not prompt for the password
+	tmpDir := t.TempDir()
+	argsFile := filepath.Join(tmpDir, "args")
+	noPasswdFile := filepath.Join(tmpDir, "nopasswd")
+	sudoPath := filepath.Join(tmpDir, "fake sudo")
+	sudoScript := fmt.Sprintf(`#!/bin/sh
+printf '%%s\n' "$@" > '%s'
+if [ "$1" = "-S" ] && [ ! -e '%s' ]; then
+    printf '%%s' "$3" >&2
+    read -r pass
+    [ "$pass" = "test_sudo_pass" ] || { echo "wrong password" >&2; exit 1; }
+fi
+while [ "$1" != "--" ]; do shift; done
+shift
+exec "$@"
+`, argsFile, noPasswdFile)
+	err = os.WriteFile(sudoPath, []byte(sudoScript), constant.DefaultExecFileMode)
+	asst.Nil(err, "test Sudo() failed")
+	conn.Config.SetSudoPath(sudoPath)
+
+	// without password
+	conn.SetUseSudo(true)
+	output, err := conn.ExecuteCommand(`echo "it's quoted"`)
+	asst.Nil(err, "test Sudo() failed")
+	asst.Equal("it's quoted", output, "test Sudo() failed")
+	args, err := os.ReadFile(argsFile)
+	asst.Nil(err, "test Sudo() failed")
+	asst.Equal("-n\n--\n/bin/sh\n-c\necho \"it's quoted\"\n", string(args), "test Sudo() failed")
+
+	// with user and password, the stdin of the command follows the password
+	conn.Config.SetSudo("test_sudo_user", "test_sudo_pass")
+	result, err := conn.Run(context.Background(), "cat", RunStdinOption(strings.NewReader(testContent)))
+	asst.Nil(err, "test Sudo() failed")
+	asst.Equal(testContent, result.Stdout, "test Sudo() failed")
+	args, err = os.ReadFile(argsFile)
+	asst.Nil(err, "test Sudo() failed")
+	asst.Equal("-S\n-p\n[go-util-sudo-prompt]\n-u\ntest_sudo_user\n--\n/bin/sh\n-c\n"+
+		"printf '%s' '[go-util-sudo-start]' >&2\ncat\n", string(args), "test Sudo() failed")
+	asst.Equal(constant.EmptyString, result.Stderr, "test Sudo() failed")
+	exists, err := conn.PathExists(tmpDir)
+	asst.Nil(err, "test Sudo() failed")
+	asst.True(exists, "test Sudo() failed")
+
+	// sudo does not prompt, the password must not be sent to the command
+	err = os.WriteFile(noPasswdFile, nil, constant.DefaultFileMode)
+	asst.Nil(err, "test Sudo() failed")
+	result, err = conn.Run(context.Background(), "cat", RunStdinOption(strings.NewReader(testContent)))
+	asst.Nil(err, "test Sudo() failed")
+	asst.Equal(testContent, result.Stdout, "test Sudo() failed")
+	result, err = conn.Run(context.Background(), "cat; echo done >&2")
+	asst.Nil(err, "test Sudo() failed")
+	asst.Equal(constant.EmptyString, result.Stdout, "test Sudo() failed")
+	asst.Equal("done\n", result.Stderr, "test Sudo() failed")
+	result, err = conn.Run(context.Background(), "cat", RunPTYOption())
+	asst.Nil(err, "test Sudo() failed")
+	asst.NotContains(result.Stdout, "test_sudo_pass", "test Sudo() failed")
+	err = os.Remove(noPasswdFile)
+	asst.Nil(err, "test Sudo() failed")
+
+	// wrong password
+	conn.Config.SetSudo(constant.EmptyString, "wrong_pass")
+	_, err = conn.ExecuteCommand(testSSHServerCommand)
+	asst.NotNil(err, "test Sudo() failed")
+	_, err = conn.PathExists(tmpDir)
+	asst.NotNil(err, "test Sudo() failed")
+
+	// invalid environment variable name
+	conn.SetUseSudo(false)
+	_, err = conn.Run(context.Background(), testSSHServerCommand, RunEnvOption("A;B", "C"))
+	asst.NotNil(err, "test Sudo() failed")
+}
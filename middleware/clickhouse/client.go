@@ -11,6 +11,7 @@ import (
 	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 	"github.com/romberli/go-util/middleware"
+	"github.com/romberli/go-util/middleware/result"
 	"github.com/romberli/go-util/middleware/sql/statement"
 )
 
@@ -256,6 +257,25 @@ func (conn *Conn) executeContext(ctx context.Context, command string, args ...in
 	return NewEmptyResult(), nil
 }
 
+// ExecuteStream executes given sql and placeholders with context, and calls the handler for each row of the result,
+// rows are read from the server incrementally, so it is suitable for reading a large result which does not fit in memory,
+// it stops when all the rows are read, or the handler returns an error, or the context is done
+func (conn *Conn) ExecuteStream(ctx context.Context, command string, args []interface{}, handler result.RowHandler) error {
+	// set random value to nil
+	err := common.SetRandomValueToNil(args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := conn.Conn.QueryContext(ctx, command, args...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	return result.StreamSQLRows(ctx, rows, handler)
+}
+
 // CheckInstanceStatus returns if instance is ok
 func (conn *Conn) CheckInstanceStatus() bool {
 	result, err := conn.Execute(DefaultCheckInstanceStatusSQL)
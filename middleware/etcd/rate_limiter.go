@@ -0,0 +1,184 @@
+package etcd
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultRateLimiterPrefix = "/rate_limiter/"
+
+	// defaultRateLimiterMaxConflicts is the maximum number of the conflicts of the compare-and-swap of a request
+	defaultRateLimiterMaxConflicts = 10
+)
+
+var _ common.RateLimiter = (*RateLimiter)(nil)
+
+// RateLimiter is the rate limiter shared by all the processes, the TAT of each key of the generic cell rate algorithm
+// is stored in etcd and updated by compare-and-swap, so the limit is enforced across the cluster,
+// note that the clocks of the processes should be synchronized
+type RateLimiter struct {
+	conn   *Conn
+	prefix string
+	limit  *common.RateLimit
+
+	mutex     sync.RWMutex
+	keyLimits map[string]*common.RateLimit
+
+	leaseMutex sync.Mutex
+	leases     map[int64]*rateLimiterLease
+}
+
+type rateLimiterLease struct {
+	id        clientv3.LeaseID
+	grantedAt time.Time
+}
+
+// NewRateLimiter returns a new *RateLimiter with the default limit of all the keys
+func NewRateLimiter(conn *Conn, prefix string, limit *common.RateLimit) (*RateLimiter, error) {
+	err := limit.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RateLimiter{
+		conn:      conn,
+		prefix:    prefix,
+		limit:     limit,
+		keyLimits: make(map[string]*common.RateLimit),
+		leases:    make(map[int64]*rateLimiterLease),
+	}, nil
+}
+
+// NewRateLimiterWithDefault returns a new *RateLimiter with default prefix
+func NewRateLimiterWithDefault(conn *Conn, limit *common.RateLimit) (*RateLimiter, error) {
+	return NewRateLimiter(conn, DefaultRateLimiterPrefix, limit)
+}
+
+// SetKeyLimit sets the limit of the key, it overrides the default limit,
+// all the processes sharing the limiter should set the same limit of the key
+func (rl *RateLimiter) SetKeyLimit(key string, limit *common.RateLimit) error {
+	err := limit.Validate()
+	if err != nil {
+		return err
+	}
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rl.keyLimits[key] = limit
+
+	return nil
+}
+
+// Allow returns if a request of the key could happen now, it implements common.RateLimiter interface
+func (rl *RateLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	allowed, _, err := rl.reserve(ctx, key)
+
+	return allowed, err
+}
+
+// Wait blocks until a request of the key could happen or the context is done, it implements common.RateLimiter interface
+func (rl *RateLimiter) Wait(ctx context.Context, key string) error {
+	return common.WaitRateLimit(ctx, func() (bool, time.Duration, error) {
+		return rl.reserve(ctx, key)
+	})
+}
+
+// reserve reserves a request of the key, it retries if the TAT was updated by others concurrently
+func (rl *RateLimiter) reserve(ctx context.Context, key string) (bool, time.Duration, error) {
+	limit := rl.getLimit(key)
+	etcdKey := rl.prefix + key
+
+	for i := constant.ZeroInt; i < defaultRateLimiterMaxConflicts; i++ {
+		resp, err := rl.conn.Client.Get(ctx, etcdKey)
+		if err != nil {
+			return false, constant.ZeroInt, errors.Trace(err)
+		}
+
+		var (
+			tat time.Time
+			cmp = clientv3.Compare(clientv3.CreateRevision(etcdKey), "=", ZeroRevision)
+		)
+		if len(resp.Kvs) > constant.ZeroInt {
+			nanos, err := strconv.ParseInt(string(resp.Kvs[constant.ZeroInt].Value), 10, 64)
+			if err != nil {
+				return false, constant.ZeroInt, errors.Trace(err)
+			}
+			tat = time.Unix(0, nanos)
+			cmp = clientv3.Compare(clientv3.ModRevision(etcdKey), "=", resp.Kvs[constant.ZeroInt].ModRevision)
+		}
+
+		newTAT, retryAfter, allowed := limit.Reserve(tat, time.Now())
+		if !allowed {
+			return false, retryAfter, nil
+		}
+
+		leaseID, err := rl.getLease(ctx, limit)
+		if err != nil {
+			return false, constant.ZeroInt, err
+		}
+		txnResp, err := rl.conn.Client.Txn(ctx).
+			If(cmp).
+			Then(clientv3.OpPut(etcdKey, strconv.FormatInt(newTAT.UnixNano(), 10), clientv3.WithLease(leaseID))).
+			Commit()
+		if err != nil {
+			return false, constant.ZeroInt, errors.Trace(err)
+		}
+		if txnResp.Succeeded {
+			return true, constant.ZeroInt, nil
+		}
+		// the TAT was updated by others, try again
+	}
+
+	return false, constant.ZeroInt, errors.Errorf("too many conflicts when updating the rate limit. key: %s", key)
+}
+
+// getLimit returns the limit of the key
+func (rl *RateLimiter) getLimit(key string) *common.RateLimit {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+
+	limit, ok := rl.keyLimits[key]
+	if !ok {
+		return rl.limit
+	}
+
+	return limit
+}
+
+// getLease returns the lease of the keys of given limit, the keys expire after their TAT passes,
+// to avoid granting a lease for each request, the lease is shared and rotated every half of its ttl,
+// so the keys attached to it live at least as long as the tolerance of the limit
+func (rl *RateLimiter) getLease(ctx context.Context, limit *common.RateLimit) (clientv3.LeaseID, error) {
+	rotation := int64(math.Ceil(limit.GetTolerance().Seconds())) + MinimumTTL
+	ttl := rotation * constant.TwoInt
+
+	rl.leaseMutex.Lock()
+	defer rl.leaseMutex.Unlock()
+
+	lease, ok := rl.leases[ttl]
+	if ok && time.Since(lease.grantedAt) < time.Duration(rotation)*time.Second {
+		return lease.id, nil
+	}
+
+	resp, err := rl.conn.Grant(ctx, ttl)
+	if err != nil {
+		return clientv3.NoLease, errors.Trace(err)
+	}
+	rl.leases[ttl] = &rateLimiterLease{
+		id:        resp.ID,
+		grantedAt: time.Now(),
+	}
+
+	return resp.ID, nil
+}
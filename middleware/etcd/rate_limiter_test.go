@@ -0,0 +1,50 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/common"
+)
+
+const (
+	testRateLimiterPrefix = "/test/rate_limiter/"
+	testRateLimiterUser   = "user01"
+)
+
+func TestRateLimiter_All(t *testing.T) {
+	asst := assert.New(t)
+
+	conn, err := NewEtcdConnWithConnectTimeout([]string{"192.168.137.11:2379"}, 3*time.Second)
+	asst.Nil(err, "test RateLimiter failed")
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = conn.DeleteWithPrefix(ctx, testRateLimiterPrefix)
+	asst.Nil(err, "test RateLimiter failed")
+
+	limit, err := common.NewRateLimit(5, time.Second, 2)
+	asst.Nil(err, "test RateLimiter failed")
+	// the limiters of different processes share the same limit
+	limiter1, err := NewRateLimiter(conn, testRateLimiterPrefix, limit)
+	asst.Nil(err, "test RateLimiter failed")
+	limiter2, err := NewRateLimiter(conn, testRateLimiterPrefix, limit)
+	asst.Nil(err, "test RateLimiter failed")
+
+	allowed, err := limiter1.Allow(ctx, testRateLimiterUser)
+	asst.Nil(err, "test RateLimiter failed")
+	asst.True(allowed, "test RateLimiter failed")
+	allowed, err = limiter2.Allow(ctx, testRateLimiterUser)
+	asst.Nil(err, "test RateLimiter failed")
+	asst.True(allowed, "test RateLimiter failed")
+	allowed, err = limiter1.Allow(ctx, testRateLimiterUser)
+	asst.Nil(err, "test RateLimiter failed")
+	asst.False(allowed, "test RateLimiter failed")
+
+	err = limiter2.Wait(ctx, testRateLimiterUser)
+	asst.Nil(err, "test RateLimiter failed")
+}
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware"
+)
+
+const (
+	DefaultNamespace = "go_util"
+	DefaultSubsystem = "pool"
+
+	poolLabel = "pool"
+)
+
+var _ prometheus.Collector = (*PoolCollector)(nil)
+
+// StatsProvider is the interface which provides the statistics of a pool,
+// all the middleware.Pool and the rabbitmq producer and consumer pools implement it
+type StatsProvider interface {
+	// PoolStats returns the statistics of the pool
+	PoolStats() middleware.PoolStats
+}
+
+type PoolCollector struct {
+	sync.RWMutex
+	pools map[string]StatsProvider
+
+	maxConnections       *prometheus.Desc
+	usedConnections      *prometheus.Desc
+	idleConnections      *prometheus.Desc
+	waitingCount         *prometheus.Desc
+	getCount             *prometheus.Desc
+	getFailedCount       *prometheus.Desc
+	waitCount            *prometheus.Desc
+	waitDuration         *prometheus.Desc
+	createdCount         *prometheus.Desc
+	invalidCount         *prometheus.Desc
+	keepAliveFailedCount *prometheus.Desc
+	reconnectCount       *prometheus.Desc
+}
+
+// NewPoolCollector returns a new *PoolCollector with given namespace and subsystem of the metrics
+func NewPoolCollector(namespace, subsystem string) *PoolCollector {
+	newDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, []string{poolLabel}, nil)
+	}
+
+	return &PoolCollector{
+		pools:                make(map[string]StatsProvider),
+		maxConnections:       newDesc("max_connections", "Maximum number of connections of the pool."),
+		usedConnections:      newDesc("used_connections", "Number of connections which are in use."),
+		idleConnections:      newDesc("idle_connections", "Number of connections which are in the pool and not in use."),
+		waitingCount:         newDesc("waiting_count", "Number of callers which are waiting for a connection."),
+		getCount:             newDesc("get_total", "Total number of getting connection from the pool."),
+		getFailedCount:       newDesc("get_failed_total", "Total number of failures of getting connection from the pool."),
+		waitCount:            newDesc("wait_total", "Total number of getting connection which had to wait."),
+		waitDuration:         newDesc("get_wait_duration_seconds", "Time spent on getting connection from the pool."),
+		createdCount:         newDesc("created_total", "Total number of connections which were created."),
+		invalidCount:         newDesc("invalid_total", "Total number of connections which were found invalid and disconnected."),
+		keepAliveFailedCount: newDesc("keep_alive_failed_total", "Total number of connections which were found invalid when keeping alive."),
+		reconnectCount:       newDesc("reconnect_total", "Total number of connections which were created to replace the invalid connections."),
+	}
+}
+
+// NewPoolCollectorWithDefault returns a new *PoolCollector with default namespace and subsystem
+func NewPoolCollectorWithDefault() *PoolCollector {
+	return NewPoolCollector(DefaultNamespace, DefaultSubsystem)
+}
+
+// Register adds a pool to the collector, name will be used as the value of the pool label
+func (pc *PoolCollector) Register(name string, pool StatsProvider) error {
+	if name == constant.EmptyString {
+		return errors.New("pool name should not be empty")
+	}
+	if pool == nil {
+		return errors.New("pool should not be nil")
+	}
+
+	pc.Lock()
+	defer pc.Unlock()
+
+	_, ok := pc.pools[name]
+	if ok {
+		return errors.Errorf("pool %s had already been registered", name)
+	}
+	pc.pools[name] = pool
+
+	return nil
+}
+
+// Unregister removes the pool of given name from the collector
+func (pc *PoolCollector) Unregister(name string) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	delete(pc.pools, name)
+}
+
+// Describe implements prometheus.Collector interface
+func (pc *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pc.maxConnections
+	ch <- pc.usedConnections
+	ch <- pc.idleConnections
+	ch <- pc.waitingCount
+	ch <- pc.getCount
+	ch <- pc.getFailedCount
+	ch <- pc.waitCount
+	ch <- pc.waitDuration
+	ch <- pc.createdCount
+	ch <- pc.invalidCount
+	ch <- pc.keepAliveFailedCount
+	ch <- pc.reconnectCount
+}
+
+// Collect implements prometheus.Collector interface
+func (pc *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	pc.RLock()
+	defer pc.RUnlock()
+
+	for name, pool := range pc.pools {
+		stats := pool.PoolStats()
+
+		ch <- prometheus.MustNewConstMetric(pc.maxConnections, prometheus.GaugeValue, float64(stats.MaxConnections), name)
+		ch <- prometheus.MustNewConstMetric(pc.usedConnections, prometheus.GaugeValue, float64(stats.UsedConnections), name)
+		ch <- prometheus.MustNewConstMetric(pc.idleConnections, prometheus.GaugeValue, float64(stats.IdleConnections), name)
+		ch <- prometheus.MustNewConstMetric(pc.waitingCount, prometheus.GaugeValue, float64(stats.WaitingCount), name)
+		ch <- prometheus.MustNewConstMetric(pc.getCount, prometheus.CounterValue, float64(stats.GetCount), name)
+		ch <- prometheus.MustNewConstMetric(pc.getFailedCount, prometheus.CounterValue, float64(stats.GetFailedCount), name)
+		ch <- prometheus.MustNewConstMetric(pc.waitCount, prometheus.CounterValue, float64(stats.WaitCount), name)
+		ch <- prometheus.MustNewConstHistogram(pc.waitDuration, stats.GetCount, stats.WaitDuration.Seconds(),
+			stats.WaitDurationBuckets, name)
+		ch <- prometheus.MustNewConstMetric(pc.createdCount, prometheus.CounterValue, float64(stats.CreatedCount), name)
+		ch <- prometheus.MustNewConstMetric(pc.invalidCount, prometheus.CounterValue, float64(stats.InvalidCount), name)
+		ch <- prometheus.MustNewConstMetric(pc.keepAliveFailedCount, prometheus.CounterValue, float64(stats.KeepAliveFailedCount), name)
+		ch <- prometheus.MustNewConstMetric(pc.reconnectCount, prometheus.CounterValue, float64(stats.ReconnectCount), name)
+	}
+}
+
+// Names returns the sorted names of the registered pools
+func (pc *PoolCollector) Names() []string {
+	pc.RLock()
+	defer pc.RUnlock()
+
+	names := make([]string, constant.ZeroInt, len(pc.pools))
+	for name := range pc.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
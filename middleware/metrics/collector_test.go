@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/middleware"
+)
+
+type testPool struct {
+	recorder *middleware.PoolStatsRecorder
+}
+
+func (tp *testPool) PoolStats() middleware.PoolStats {
+	return tp.recorder.Stats(20, 3, 5)
+}
+
+func TestPoolCollector(t *testing.T) {
+	asst := assert.New(t)
+
+	tp := &testPool{recorder: middleware.NewPoolStatsRecorder()}
+	tp.recorder.ObserveGet(2*time.Millisecond, nil)
+	tp.recorder.StartWaiting()
+	tp.recorder.ObserveGet(2*time.Second, errors.New("test error"))
+	tp.recorder.StopWaiting()
+	tp.recorder.IncInvalid(true)
+	tp.recorder.IncCreated()
+
+	pc := NewPoolCollectorWithDefault()
+	err := pc.Register("mysql", tp)
+	asst.Nil(err, "test Register() failed")
+	err = pc.Register("mysql", tp)
+	asst.NotNil(err, "test Register() failed")
+	asst.Equal([]string{"mysql"}, pc.Names(), "test Names() failed")
+
+	registry := prometheus.NewRegistry()
+	err = registry.Register(pc)
+	asst.Nil(err, "test Register() failed")
+
+	expected := `
+		# HELP go_util_pool_get_failed_total Total number of failures of getting connection from the pool.
+		# TYPE go_util_pool_get_failed_total counter
+		go_util_pool_get_failed_total{pool="mysql"} 1
+		# HELP go_util_pool_reconnect_total Total number of connections which were created to replace the invalid connections.
+		# TYPE go_util_pool_reconnect_total counter
+		go_util_pool_reconnect_total{pool="mysql"} 1
+		# HELP go_util_pool_used_connections Number of connections which are in use.
+		# TYPE go_util_pool_used_connections gauge
+		go_util_pool_used_connections{pool="mysql"} 3
+	`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"go_util_pool_get_failed_total", "go_util_pool_reconnect_total", "go_util_pool_used_connections")
+	asst.Nil(err, "test Collect() failed")
+
+	count, err := testutil.GatherAndCount(registry, "go_util_pool_get_wait_duration_seconds")
+	asst.Nil(err, "test Collect() failed")
+	asst.Equal(1, count, "test Collect() failed")
+
+	pc.Unregister("mysql")
+	asst.Empty(pc.Names(), "test Unregister() failed")
+}
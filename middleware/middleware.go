@@ -48,6 +48,9 @@ type PoolConn interface {
 	Execute(command string, args ...interface{}) (Result, error)
 	// ExecuteContext executes given command and placeholders with context on the middleware
 	ExecuteContext(ctx context.Context, command string, args ...interface{}) (Result, error)
+	// ExecuteStream executes given command and placeholders with context on the middleware,
+	// and calls the handler for each row of the result
+	ExecuteStream(ctx context.Context, command string, args []interface{}, handler result.RowHandler) error
 }
 
 type Transaction interface {
@@ -67,10 +70,15 @@ type Pool interface {
 	IsClosed() bool
 	// Get gets a connection from the pool
 	Get() (PoolConn, error)
+	// GetContext gets a connection from the pool with context,
+	// if there is no available connection, it waits until a connection is returned to the pool or the context is done
+	GetContext(ctx context.Context) (PoolConn, error)
 	// Transaction returns a connection that could run multiple statements in the same transaction
 	Transaction() (Transaction, error)
 	// Supply creates given number of connections and add them to the pool
 	Supply(num int) error
 	// Release releases given number of connections, each connection will disconnect with the middleware
 	Release(num int) error
+	// PoolStats returns the statistics of the pool
+	PoolStats() PoolStats
 }
@@ -0,0 +1,145 @@
+package binlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const tempFileSuffix = ".tmp"
+
+// Checkpoint is the replication position which had been handled successfully,
+// if the gtid set is not empty, it will be used to resume the replication, otherwise, the file and position will be used
+type Checkpoint struct {
+	File      string `json:"file"`
+	Position  uint32 `json:"position"`
+	GTIDSet   string `json:"gtid_set"`
+	Timestamp uint32 `json:"timestamp"`
+}
+
+// NewCheckpoint returns a new *Checkpoint
+func NewCheckpoint(file string, position uint32, gtidSet string) *Checkpoint {
+	return &Checkpoint{
+		File:     file,
+		Position: position,
+		GTIDSet:  gtidSet,
+	}
+}
+
+// IsEmpty returns if the checkpoint has neither position nor gtid set
+func (c *Checkpoint) IsEmpty() bool {
+	return c == nil || (c.File == constant.EmptyString && c.GTIDSet == constant.EmptyString)
+}
+
+// Clone returns a copy of the checkpoint
+func (c *Checkpoint) Clone() *Checkpoint {
+	cp := *c
+
+	return &cp
+}
+
+type CheckpointStore interface {
+	// Load loads the checkpoint, if there is no saved checkpoint, it returns nil, nil
+	Load() (*Checkpoint, error)
+	// Save saves the checkpoint
+	Save(checkpoint *Checkpoint) error
+}
+
+var _ CheckpointStore = (*MemoryCheckpointStore)(nil)
+var _ CheckpointStore = (*FileCheckpointStore)(nil)
+
+// MemoryCheckpointStore keeps the checkpoint in memory, it's useful for testing or the cases that resuming is not needed
+type MemoryCheckpointStore struct {
+	sync.Mutex
+	checkpoint *Checkpoint
+}
+
+// NewMemoryCheckpointStore returns a new *MemoryCheckpointStore with given initial checkpoint, it could be nil
+func NewMemoryCheckpointStore(checkpoint *Checkpoint) *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoint: checkpoint}
+}
+
+// Load loads the checkpoint
+func (mcs *MemoryCheckpointStore) Load() (*Checkpoint, error) {
+	mcs.Lock()
+	defer mcs.Unlock()
+
+	if mcs.checkpoint == nil {
+		return nil, nil
+	}
+
+	return mcs.checkpoint.Clone(), nil
+}
+
+// Save saves the checkpoint
+func (mcs *MemoryCheckpointStore) Save(checkpoint *Checkpoint) error {
+	mcs.Lock()
+	defer mcs.Unlock()
+
+	mcs.checkpoint = checkpoint.Clone()
+
+	return nil
+}
+
+// FileCheckpointStore saves the checkpoint to a json file,
+// it writes a temporary file and renames it to make sure the checkpoint file is always complete
+type FileCheckpointStore struct {
+	sync.Mutex
+	path string
+}
+
+// NewFileCheckpointStore returns a new *FileCheckpointStore
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// Load loads the checkpoint from the file
+func (fcs *FileCheckpointStore) Load() (*Checkpoint, error) {
+	fcs.Lock()
+	defer fcs.Unlock()
+
+	data, err := os.ReadFile(fcs.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Trace(err)
+	}
+
+	checkpoint := &Checkpoint{}
+	err = json.Unmarshal(data, checkpoint)
+	if err != nil {
+		return nil, errors.Annotatef(err, "unmarshal checkpoint file failed. path: %s", fcs.path)
+	}
+
+	return checkpoint, nil
+}
+
+// Save saves the checkpoint to the file
+func (fcs *FileCheckpointStore) Save(checkpoint *Checkpoint) error {
+	fcs.Lock()
+	defer fcs.Unlock()
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(fcs.path), constant.DefaultExecFileMode)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	tempPath := fcs.path + tempFileSuffix
+	err = os.WriteFile(tempPath, data, constant.DefaultFileMode)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(os.Rename(tempPath, fcs.path))
+}
@@ -0,0 +1,40 @@
+package binlog
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCheckpointStore(t *testing.T) {
+	asst := assert.New(t)
+
+	store := NewMemoryCheckpointStore(nil)
+	checkpoint, err := store.Load()
+	asst.Nil(err, "test Load() failed")
+	asst.True(checkpoint.IsEmpty(), "test Load() failed")
+
+	err = store.Save(NewCheckpoint("mysql-bin.000001", 4, ""))
+	asst.Nil(err, "test Save() failed")
+	checkpoint, err = store.Load()
+	asst.Nil(err, "test Load() failed")
+	asst.Equal("mysql-bin.000001", checkpoint.File, "test Load() failed")
+	asst.Equal(uint32(4), checkpoint.Position, "test Load() failed")
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	asst := assert.New(t)
+
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "binlog", "checkpoint.json"))
+	checkpoint, err := store.Load()
+	asst.Nil(err, "test Load() failed")
+	asst.True(checkpoint.IsEmpty(), "test Load() failed")
+
+	expect := NewCheckpoint("mysql-bin.000002", 1024, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	err = store.Save(expect)
+	asst.Nil(err, "test Save() failed")
+	checkpoint, err = store.Load()
+	asst.Nil(err, "test Load() failed")
+	asst.Equal(expect, checkpoint, "test Load() failed")
+}
@@ -0,0 +1,102 @@
+package binlog
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultFlavor  = mysql.MySQLFlavor
+	DefaultCharset = "utf8mb4"
+)
+
+type Config struct {
+	// Addr is the address of the source, formatted as host:port
+	Addr string
+	User string
+	Pass string
+	// ServerID is the server id of the replica, it must be unique in the replication cluster
+	ServerID uint32
+	// Flavor is either mysql or mariadb
+	Flavor string
+	// Schemas is the schemas to be captured, empty means all the schemas
+	Schemas []string
+	// Tables is the tables to be captured, formatted as db.table, empty means all the tables of the schemas
+	Tables []string
+}
+
+// NewConfig returns a new Config
+func NewConfig(addr, user, pass string, serverID uint32, schemas, tables []string) Config {
+	return Config{
+		Addr:     addr,
+		User:     user,
+		Pass:     pass,
+		ServerID: serverID,
+		Flavor:   DefaultFlavor,
+		Schemas:  schemas,
+		Tables:   tables,
+	}
+}
+
+// NewConfigWithDefault returns a new Config which captures all the schemas
+func NewConfigWithDefault(addr, user, pass string, serverID uint32) Config {
+	return NewConfig(addr, user, pass, serverID, nil, nil)
+}
+
+// Validate validates the config
+func (c Config) Validate() error {
+	_, _, err := c.getHostAndPort()
+	if err != nil {
+		return err
+	}
+	if c.User == constant.EmptyString {
+		return errors.New("user should not be empty")
+	}
+	if c.ServerID == constant.ZeroInt {
+		return errors.New("server id should not be 0")
+	}
+	if c.Flavor != mysql.MySQLFlavor && c.Flavor != mysql.MariaDBFlavor {
+		return errors.Errorf("flavor should be either %s or %s. flavor: %s", mysql.MySQLFlavor, mysql.MariaDBFlavor, c.Flavor)
+	}
+	for _, table := range c.Tables {
+		if len(strings.Split(table, constant.DotString)) != 2 {
+			return errors.Errorf("table should be formatted as db.table. table: %s", table)
+		}
+	}
+
+	return nil
+}
+
+// IsIncluded checks if the table should be captured
+func (c Config) IsIncluded(dbName, tableName string) bool {
+	if len(c.Schemas) > constant.ZeroInt && !common.ElementInSlice(c.Schemas, dbName) {
+		return false
+	}
+	if len(c.Tables) > constant.ZeroInt && !common.ElementInSlice(c.Tables, dbName+constant.DotString+tableName) {
+		return false
+	}
+
+	return true
+}
+
+// getHostAndPort returns the host and port of the address
+func (c Config) getHostAndPort() (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return constant.EmptyString, constant.ZeroInt, errors.Annotatef(err, "address must be formatted as host:port. addr: %s", c.Addr)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return constant.EmptyString, constant.ZeroInt, errors.Annotatef(err, "port must be a valid number. addr: %s", c.Addr)
+	}
+
+	return host, uint16(port), nil
+}
@@ -0,0 +1,29 @@
+package binlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	asst := assert.New(t)
+
+	asst.Nil(NewConfigWithDefault("192.168.137.11:3306", "root", "root", 1001).Validate(), "test Validate() failed")
+	asst.NotNil(NewConfigWithDefault("192.168.137.11", "root", "root", 1001).Validate(), "test Validate() failed")
+	asst.NotNil(NewConfigWithDefault("192.168.137.11:3306", "", "root", 1001).Validate(), "test Validate() failed")
+	asst.NotNil(NewConfigWithDefault("192.168.137.11:3306", "root", "root", 0).Validate(), "test Validate() failed")
+	asst.NotNil(NewConfig("192.168.137.11:3306", "root", "root", 1001, nil, []string{"t01"}).Validate(), "test Validate() failed")
+}
+
+func TestConfig_IsIncluded(t *testing.T) {
+	asst := assert.New(t)
+
+	config := NewConfigWithDefault("192.168.137.11:3306", "root", "root", 1001)
+	asst.True(config.IsIncluded("test", "t01"), "test IsIncluded() failed")
+
+	config = NewConfig("192.168.137.11:3306", "root", "root", 1001, []string{"test"}, []string{"test.t01"})
+	asst.True(config.IsIncluded("test", "t01"), "test IsIncluded() failed")
+	asst.False(config.IsIncluded("test", "t02"), "test IsIncluded() failed")
+	asst.False(config.IsIncluded("mysql", "t01"), "test IsIncluded() failed")
+}
@@ -0,0 +1,211 @@
+package binlog
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware/rabbitmq"
+	"github.com/romberli/go-util/middleware/sql/statement"
+)
+
+const (
+	unsignedString  = "unsigned"
+	mediumIntString = "mediumint"
+	mediumIntMask   = 0xFFFFFF
+	renamePrefix    = "rename"
+	truncatePrefix  = "truncate"
+)
+
+// TableMeta is the column information of a table, it's used to decode the row events
+type TableMeta struct {
+	DBName      string
+	TableName   string
+	ColumnNames []string
+	ColumnTypes []string
+	PKNames     []string
+	Unsigned    []bool
+}
+
+// NewTableMeta returns a new *TableMeta
+func NewTableMeta(dbName, tableName string, columnNames, columnTypes, pkNames []string) *TableMeta {
+	unsigned := make([]bool, len(columnTypes))
+	for i, columnType := range columnTypes {
+		unsigned[i] = strings.Contains(strings.ToLower(columnType), unsignedString)
+	}
+
+	return &TableMeta{
+		DBName:      dbName,
+		TableName:   tableName,
+		ColumnNames: columnNames,
+		ColumnTypes: columnTypes,
+		PKNames:     pkNames,
+		Unsigned:    unsigned,
+	}
+}
+
+// GetColumns returns the column types map of the table, key is the column name, value is the column type
+func (tm *TableMeta) GetColumns() map[string]string {
+	columns := make(map[string]string, len(tm.ColumnNames))
+	for i, columnName := range tm.ColumnNames {
+		columns[columnName] = tm.ColumnTypes[i]
+	}
+
+	return columns
+}
+
+// getSQLType returns the sql type of the rows event
+func getSQLType(eventType replication.EventType) (string, error) {
+	switch eventType {
+	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2,
+		replication.MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1:
+		return rabbitmq.SQLTypeInsert, nil
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2,
+		replication.PARTIAL_UPDATE_ROWS_EVENT, replication.MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1:
+		return rabbitmq.SQLTypeUpdate, nil
+	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2,
+		replication.MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1:
+		return rabbitmq.SQLTypeDelete, nil
+	default:
+		return constant.EmptyString, errors.Errorf("not a rows event. event type: %s", eventType.String())
+	}
+}
+
+// ConvertRowsEvent converts the rows event to a *rabbitmq.Message,
+// the message is canal-style, for update event, Old only contains the changed columns
+func ConvertRowsEvent(header *replication.EventHeader, event *replication.RowsEvent, meta *TableMeta) (*rabbitmq.Message, error) {
+	sqlType, err := getSQLType(header.EventType)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(meta.ColumnNames)) != event.ColumnCount {
+		return nil, errors.Errorf("column number of the table does not match the rows event, maybe the table structure had changed. "+
+			"table: %s.%s, columns: %d, event columns: %d", meta.DBName, meta.TableName, len(meta.ColumnNames), event.ColumnCount)
+	}
+
+	var (
+		data []map[string]interface{}
+		old  []map[string]interface{}
+	)
+
+	step := constant.OneInt
+	if sqlType == rabbitmq.SQLTypeUpdate {
+		// update rows event contains before image and after image in pairs
+		step = 2
+	}
+
+	for i := constant.ZeroInt; i+step <= len(event.Rows); i += step {
+		row := convertRow(meta, event.Rows[i+step-1], getSkippedColumns(event, i+step-1))
+		data = append(data, row)
+
+		if sqlType == rabbitmq.SQLTypeUpdate {
+			before := convertRow(meta, event.Rows[i], getSkippedColumns(event, i))
+			changed := make(map[string]interface{})
+			for columnName, value := range before {
+				afterValue, ok := row[columnName]
+				if !ok || !reflect.DeepEqual(value, afterValue) {
+					changed[columnName] = value
+				}
+			}
+			old = append(old, changed)
+		}
+	}
+
+	message := rabbitmq.NewMessage(constant.ZeroInt, sqlType, false, meta.DBName, meta.TableName, meta.PKNames,
+		meta.GetColumns(), data, old)
+	message.ES = int64(header.Timestamp) * 1000
+
+	return message, nil
+}
+
+// ConvertQueryEvent converts the ddl query event to a *rabbitmq.Message,
+// if the query is not a ddl statement, it returns nil
+func ConvertQueryEvent(header *replication.EventHeader, event *replication.QueryEvent, tableName string) *rabbitmq.Message {
+	sql := string(event.Query)
+	sqlType := GetDDLType(sql)
+	if sqlType == constant.EmptyString {
+		return nil
+	}
+
+	message := rabbitmq.NewMessage(constant.ZeroInt, sqlType, true, string(event.Schema), tableName, nil, nil, nil, nil)
+	message.SQL = sql
+	message.ES = int64(header.Timestamp) * 1000
+
+	return message
+}
+
+// GetDDLType returns the upper case ddl type of the sql, for example: CREATE, ALTER, DROP, RENAME and TRUNCATE,
+// if the sql is not a ddl statement, it returns empty string
+func GetDDLType(sql string) string {
+	switch statement.GetType(sql) {
+	case statement.Create, statement.Alter, statement.Drop:
+		return strings.ToUpper(strings.Fields(sql)[constant.ZeroInt])
+	}
+
+	fields := strings.Fields(strings.ToLower(sql))
+	if len(fields) > constant.ZeroInt && (fields[constant.ZeroInt] == renamePrefix || fields[constant.ZeroInt] == truncatePrefix) {
+		return strings.ToUpper(fields[constant.ZeroInt])
+	}
+
+	return constant.EmptyString
+}
+
+// getSkippedColumns returns the skipped columns of given row, they are not logged when binlog_row_image is not full
+func getSkippedColumns(event *replication.RowsEvent, row int) []int {
+	if row < len(event.SkippedColumns) {
+		return event.SkippedColumns[row]
+	}
+
+	return nil
+}
+
+// convertRow converts the row values to a map, key is the column name
+func convertRow(meta *TableMeta, values []interface{}, skippedColumns []int) map[string]interface{} {
+	skipped := make(map[int]bool, len(skippedColumns))
+	for _, column := range skippedColumns {
+		skipped[column] = true
+	}
+
+	row := make(map[string]interface{}, len(meta.ColumnNames))
+	for i, columnName := range meta.ColumnNames {
+		if skipped[i] || i >= len(values) {
+			continue
+		}
+		row[columnName] = convertValue(values[i], meta.ColumnTypes[i], meta.Unsigned[i])
+	}
+
+	return row
+}
+
+// convertValue converts the value decoded from the binlog,
+// []byte will be converted to string, and the signed integers of the unsigned columns will be converted to unsigned integers
+func convertValue(value interface{}, columnType string, unsigned bool) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case int8:
+		if unsigned {
+			return uint8(v)
+		}
+	case int16:
+		if unsigned {
+			return uint16(v)
+		}
+	case int32:
+		if unsigned {
+			if strings.HasPrefix(strings.ToLower(columnType), mediumIntString) {
+				return uint32(v) & mediumIntMask
+			}
+			return uint32(v)
+		}
+	case int64:
+		if unsigned {
+			return uint64(v)
+		}
+	}
+
+	return value
+}
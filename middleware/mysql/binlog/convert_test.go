@@ -0,0 +1,92 @@
+package binlog
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/middleware/rabbitmq"
+)
+
+func newTestTableMeta() *TableMeta {
+	return NewTableMeta("test", "t01", []string{"id", "name", "age"},
+		[]string{"int(10) unsigned", "varchar(100)", "mediumint(8) unsigned"}, []string{"id"})
+}
+
+func TestConvertRowsEvent(t *testing.T) {
+	asst := assert.New(t)
+
+	meta := newTestTableMeta()
+	header := &replication.EventHeader{Timestamp: 1700000000, EventType: replication.WRITE_ROWS_EVENTv2}
+	event := &replication.RowsEvent{
+		ColumnCount: 3,
+		Rows:        [][]interface{}{{int32(-1), []byte("aa"), int32(-1)}},
+	}
+	message, err := ConvertRowsEvent(header, event, meta)
+	asst.Nil(err, "test ConvertRowsEvent() failed")
+	asst.Equal(rabbitmq.SQLTypeInsert, message.GetSQLType(), "test ConvertRowsEvent() failed")
+	asst.Equal(int64(1700000000000), message.GetES(), "test ConvertRowsEvent() failed")
+	asst.Equal(uint32(4294967295), message.GetData()[0]["id"], "test ConvertRowsEvent() failed")
+	asst.Equal("aa", message.GetData()[0]["name"], "test ConvertRowsEvent() failed")
+	asst.Equal(uint32(16777215), message.GetData()[0]["age"], "test ConvertRowsEvent() failed")
+
+	header.EventType = replication.UPDATE_ROWS_EVENTv2
+	event.Rows = [][]interface{}{
+		{int32(1), []byte("aa"), int32(10)},
+		{int32(1), []byte("bb"), int32(10)},
+	}
+	message, err = ConvertRowsEvent(header, event, meta)
+	asst.Nil(err, "test ConvertRowsEvent() failed")
+	asst.Equal(rabbitmq.SQLTypeUpdate, message.GetSQLType(), "test ConvertRowsEvent() failed")
+	asst.Equal(1, len(message.GetData()), "test ConvertRowsEvent() failed")
+	asst.Equal("bb", message.GetData()[0]["name"], "test ConvertRowsEvent() failed")
+	asst.Equal(map[string]interface{}{"name": "aa"}, message.GetOld()[0], "test ConvertRowsEvent() failed")
+
+	header.EventType = replication.DELETE_ROWS_EVENTv2
+	event.Rows = [][]interface{}{{int32(1), []byte("bb"), int32(10)}}
+	message, err = ConvertRowsEvent(header, event, meta)
+	asst.Nil(err, "test ConvertRowsEvent() failed")
+	asst.Equal(rabbitmq.SQLTypeDelete, message.GetSQLType(), "test ConvertRowsEvent() failed")
+
+	// column count mismatch
+	event.ColumnCount = 4
+	_, err = ConvertRowsEvent(header, event, meta)
+	asst.NotNil(err, "test ConvertRowsEvent() failed")
+}
+
+func TestConvertQueryEvent(t *testing.T) {
+	asst := assert.New(t)
+
+	header := &replication.EventHeader{Timestamp: 1700000000}
+	event := &replication.QueryEvent{Schema: []byte("test"), Query: []byte("alter table t01 add column c1 int")}
+	message := ConvertQueryEvent(header, event, "t01")
+	asst.NotNil(message, "test ConvertQueryEvent() failed")
+	asst.True(message.GetIsDDL(), "test ConvertQueryEvent() failed")
+	asst.Equal("ALTER", message.GetSQLType(), "test ConvertQueryEvent() failed")
+	asst.Equal("alter table t01 add column c1 int", message.GetSQL(), "test ConvertQueryEvent() failed")
+
+	event.Query = []byte("insert into t01 values(1)")
+	asst.Nil(ConvertQueryEvent(header, event, "t01"), "test ConvertQueryEvent() failed")
+}
+
+func TestGetDDLType(t *testing.T) {
+	asst := assert.New(t)
+
+	asst.Equal("CREATE", GetDDLType("create table t01(id int)"), "test GetDDLType() failed")
+	asst.Equal("DROP", GetDDLType("DROP TABLE t01"), "test GetDDLType() failed")
+	asst.Equal("RENAME", GetDDLType("rename table t01 to t02"), "test GetDDLType() failed")
+	asst.Equal("TRUNCATE", GetDDLType("truncate table t01"), "test GetDDLType() failed")
+	asst.Equal("", GetDDLType("select 1"), "test GetDDLType() failed")
+}
+
+func TestGetDDLTableName(t *testing.T) {
+	asst := assert.New(t)
+
+	dbName, tableName := getDDLTableName("test", "alter table db01.t01 add column c1 int")
+	asst.Equal("db01", dbName, "test getDDLTableName() failed")
+	asst.Equal("t01", tableName, "test getDDLTableName() failed")
+	dbName, tableName = getDDLTableName("test", "create table t02(id int)")
+	asst.Equal("test", dbName, "test getDDLTableName() failed")
+	asst.Equal("t02", tableName, "test getDDLTableName() failed")
+}
@@ -0,0 +1,460 @@
+package binlog
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pingcap/errors"
+	"github.com/romberli/log"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware/mysql"
+	"github.com/romberli/go-util/middleware/rabbitmq"
+	"github.com/romberli/go-util/middleware/sql/parser"
+)
+
+const (
+	DefaultCheckpointInterval = time.Second
+
+	informationSchemaDBName = "information_schema"
+	beginSQL                = "BEGIN"
+	commitSQL               = "COMMIT"
+	primaryKeyString        = "PRI"
+	fileString              = "File"
+	positionString          = "Position"
+	executedGTIDSetString   = "Executed_Gtid_Set"
+	showBinaryLogStatusSQL  = "SHOW BINARY LOG STATUS"
+	showMasterStatusSQL     = "SHOW MASTER STATUS"
+	selectColumnsSQL        = `
+		SELECT column_name, column_type, column_key
+		FROM information_schema.columns
+		WHERE table_schema = ? AND table_name = ?
+		ORDER BY ordinal_position ;
+	`
+)
+
+// Handler handles the messages of a transaction,
+// checkpoint is the position right after the transaction, it will be saved only if the handler returns nil,
+// so the messages may be handled more than once after restarting
+type Handler func(ctx context.Context, messages []*rabbitmq.Message, checkpoint *Checkpoint) error
+
+// Streamer connects to the source as a replica, decodes the row events and the ddl statements into *rabbitmq.Message,
+// and calls the handler when each transaction commits
+type Streamer struct {
+	sync.Mutex
+	config          Config
+	store           CheckpointStore
+	handler         Handler
+	syncerConfig    replication.BinlogSyncerConfig
+	syncer          *replication.BinlogSyncer
+	conn            *mysql.Conn
+	tables          map[string]*TableMeta
+	file            string
+	messages        []*rabbitmq.Message
+	checkpoint      *Checkpoint
+	lastSaveTime    time.Time
+	isRunning       bool
+	isClosed        bool
+	checkpointDirty bool
+}
+
+// NewStreamer returns a new *Streamer,
+// it also connects to the source to get the table information which is used to decode the row events
+func NewStreamer(config Config, store CheckpointStore, handler Handler) (*Streamer, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("checkpoint store should not be nil")
+	}
+	if handler == nil {
+		return nil, errors.New("handler should not be nil")
+	}
+
+	host, port, err := config.getHostAndPort()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := mysql.NewConn(config.Addr, informationSchemaDBName, config.User, config.Pass)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Streamer{
+		config:  config,
+		store:   store,
+		handler: handler,
+		syncerConfig: replication.BinlogSyncerConfig{
+			ServerID: config.ServerID,
+			Flavor:   config.Flavor,
+			Host:     host,
+			Port:     port,
+			User:     config.User,
+			Password: config.Pass,
+			Charset:  DefaultCharset,
+		},
+		conn:   conn,
+		tables: make(map[string]*TableMeta),
+	}, nil
+}
+
+// Checkpoint returns the last checkpoint which had been handled successfully
+func (s *Streamer) Checkpoint() *Checkpoint {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.checkpoint == nil {
+		return nil
+	}
+
+	return s.checkpoint.Clone()
+}
+
+// Close stops the replication and closes the connection
+func (s *Streamer) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.isClosed {
+		return nil
+	}
+	s.isClosed = true
+
+	if s.syncer != nil {
+		// the running replication will stop with an error
+		s.syncer.Close()
+	}
+
+	return errors.Trace(s.conn.Close())
+}
+
+// Run starts the replication from the saved checkpoint, if there is no saved checkpoint,
+// it starts from current position of the source, it blocks until the context is done or an error occurs
+func (s *Streamer) Run(ctx context.Context) error {
+	s.Lock()
+	if s.isClosed {
+		s.Unlock()
+		return errors.New("streamer had been closed")
+	}
+	if s.isRunning {
+		s.Unlock()
+		return errors.New("streamer is already running")
+	}
+	s.isRunning = true
+	// the messages of the unfinished transaction will be received again after restarting from the checkpoint
+	s.messages = nil
+	// the binlog syncer could not be restarted after it is closed, so create a new one for each run
+	syncer := replication.NewBinlogSyncer(s.syncerConfig)
+	s.syncer = syncer
+	s.Unlock()
+	defer func() {
+		// stop receiving the events
+		syncer.Close()
+
+		s.Lock()
+		s.syncer = nil
+		s.isRunning = false
+		s.Unlock()
+	}()
+
+	checkpoint, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	if checkpoint.IsEmpty() {
+		checkpoint, err = s.getCurrentCheckpoint()
+		if err != nil {
+			return err
+		}
+	}
+
+	streamer, err := s.startSync(syncer, checkpoint)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.checkpoint = checkpoint
+	s.file = checkpoint.File
+	s.Unlock()
+
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		err = s.handleEvent(ctx, ev)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// startSync starts the replication of given syncer from given checkpoint
+func (s *Streamer) startSync(syncer *replication.BinlogSyncer, checkpoint *Checkpoint) (*replication.BinlogStreamer, error) {
+	if checkpoint.GTIDSet != constant.EmptyString {
+		gtidSet, err := gomysql.ParseGTIDSet(s.config.Flavor, checkpoint.GTIDSet)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		log.Infof("start replication from gtid set. gtid_set: %s", checkpoint.GTIDSet)
+
+		streamer, err := syncer.StartSyncGTID(gtidSet)
+
+		return streamer, errors.Trace(err)
+	}
+
+	log.Infof("start replication from position. file: %s, position: %d", checkpoint.File, checkpoint.Position)
+	streamer, err := syncer.StartSync(gomysql.Position{Name: checkpoint.File, Pos: checkpoint.Position})
+
+	return streamer, errors.Trace(err)
+}
+
+// getCurrentCheckpoint returns current position and executed gtid set of the source
+func (s *Streamer) getCurrentCheckpoint() (*Checkpoint, error) {
+	result, err := s.conn.Execute(showBinaryLogStatusSQL)
+	if err != nil {
+		// for the versions which do not support "show binary log status"
+		result, err = s.conn.Execute(showMasterStatusSQL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result.RowNumber() == constant.ZeroInt {
+		return nil, errors.New("binary log is not enabled on the source")
+	}
+
+	file, err := result.GetStringByName(constant.ZeroInt, fileString)
+	if err != nil {
+		return nil, err
+	}
+	position, err := result.GetUint64ByName(constant.ZeroInt, positionString)
+	if err != nil {
+		return nil, err
+	}
+
+	var gtidSet string
+	if s.config.Flavor == gomysql.MySQLFlavor && result.ColumnExists(executedGTIDSetString) {
+		gtidSet, err = result.GetStringByName(constant.ZeroInt, executedGTIDSetString)
+		if err != nil {
+			return nil, err
+		}
+		gtidSet = strings.ReplaceAll(gtidSet, constant.CRLFString, constant.EmptyString)
+	}
+
+	return NewCheckpoint(file, uint32(position), gtidSet), nil
+}
+
+// handleEvent handles a binlog event
+func (s *Streamer) handleEvent(ctx context.Context, ev *replication.BinlogEvent) error {
+	switch e := ev.Event.(type) {
+	case *replication.RotateEvent:
+		s.Lock()
+		s.file = string(e.NextLogName)
+		if len(s.messages) == constant.ZeroInt {
+			s.checkpoint.File = s.file
+			s.checkpoint.Position = uint32(e.Position)
+		}
+		s.Unlock()
+	case *replication.RowsEvent:
+		dbName, tableName := string(e.Table.Schema), string(e.Table.Table)
+		if !s.config.IsIncluded(dbName, tableName) {
+			return nil
+		}
+
+		meta, err := s.getTableMeta(e.Table)
+		if err != nil {
+			return err
+		}
+		message, err := ConvertRowsEvent(ev.Header, e, meta)
+		if err != nil {
+			return err
+		}
+
+		s.Lock()
+		s.messages = append(s.messages, message)
+		s.Unlock()
+	case *replication.QueryEvent:
+		query := strings.ToUpper(strings.TrimSpace(string(e.Query)))
+		switch query {
+		case beginSQL:
+			return nil
+		case commitSQL:
+			return s.commit(ctx, ev.Header, e.GSet)
+		}
+
+		dbName, tableName := getDDLTableName(string(e.Schema), string(e.Query))
+		message := ConvertQueryEvent(ev.Header, e, tableName)
+		if message == nil {
+			// the other statements, for example, savepoint, xa and the statement-format dml,
+			// are in the middle of the transaction, they do not end the transaction
+			return nil
+		}
+
+		// table structure may have changed, clear the cached table information
+		s.Lock()
+		s.tables = make(map[string]*TableMeta)
+		if s.config.IsIncluded(dbName, tableName) {
+			message.DBName = dbName
+			s.messages = append(s.messages, message)
+		}
+		s.Unlock()
+		// ddl statements commit implicitly
+		return s.commit(ctx, ev.Header, e.GSet)
+	case *replication.XIDEvent:
+		return s.commit(ctx, ev.Header, e.GSet)
+	}
+
+	return nil
+}
+
+// commit calls the handler with the messages of current transaction, and saves the checkpoint
+func (s *Streamer) commit(ctx context.Context, header *replication.EventHeader, gtidSet gomysql.GTIDSet) error {
+	s.Lock()
+	checkpoint := NewCheckpoint(s.file, header.LogPos, s.checkpoint.GTIDSet)
+	if gtidSet != nil {
+		checkpoint.GTIDSet = gtidSet.String()
+	}
+	checkpoint.Timestamp = header.Timestamp
+	messages := s.messages
+	s.messages = nil
+	s.Unlock()
+
+	if len(messages) > constant.ZeroInt {
+		err := s.handler(ctx, messages, checkpoint.Clone())
+		if err != nil {
+			return err
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.checkpoint = checkpoint
+	// the transactions without any captured message advance the checkpoint,
+	// save them at intervals to avoid saving too frequently
+	if len(messages) == constant.ZeroInt && time.Since(s.lastSaveTime) < DefaultCheckpointInterval {
+		return nil
+	}
+	s.lastSaveTime = time.Now()
+
+	return s.store.Save(checkpoint)
+}
+
+// getTableMeta returns the table information of the table map event,
+// it prefers the cached table information, then information_schema, then the metadata of the table map event
+func (s *Streamer) getTableMeta(e *replication.TableMapEvent) (*TableMeta, error) {
+	dbName, tableName := string(e.Schema), string(e.Table)
+	key := dbName + constant.DotString + tableName
+
+	s.Lock()
+	meta, ok := s.tables[key]
+	s.Unlock()
+	if ok && uint64(len(meta.ColumnNames)) == e.ColumnCount {
+		return meta, nil
+	}
+
+	meta, err := s.getTableMetaFromSource(dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(meta.ColumnNames)) != e.ColumnCount {
+		// the table structure had changed after the event, try to use the metadata of the event,
+		// it is only available when binlog_row_metadata is FULL
+		columnNames := e.ColumnNameString()
+		if uint64(len(columnNames)) != e.ColumnCount {
+			return nil, errors.Errorf("could not get the column names of the table, "+
+				"maybe the table structure had changed, consider to set binlog_row_metadata to FULL. table: %s", key)
+		}
+		meta = getTableMetaFromEvent(e, meta)
+	}
+
+	s.Lock()
+	s.tables[key] = meta
+	s.Unlock()
+
+	return meta, nil
+}
+
+// getTableMetaFromSource gets the table information from information_schema of the source
+func (s *Streamer) getTableMetaFromSource(dbName, tableName string) (*TableMeta, error) {
+	result, err := s.conn.Execute(selectColumnsSQL, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var columnNames, columnTypes, pkNames []string
+	for i := constant.ZeroInt; i < result.RowNumber(); i++ {
+		columnName, err := result.GetString(i, constant.ZeroInt)
+		if err != nil {
+			return nil, err
+		}
+		columnType, err := result.GetString(i, constant.OneInt)
+		if err != nil {
+			return nil, err
+		}
+		columnKey, err := result.GetString(i, constant.TwoInt)
+		if err != nil {
+			return nil, err
+		}
+
+		columnNames = append(columnNames, columnName)
+		columnTypes = append(columnTypes, columnType)
+		if columnKey == primaryKeyString {
+			pkNames = append(pkNames, columnName)
+		}
+	}
+
+	return NewTableMeta(dbName, tableName, columnNames, columnTypes, pkNames), nil
+}
+
+// getTableMetaFromEvent gets the table information from the metadata of the table map event,
+// the column types which could not be found in the table information of the source will be empty
+func getTableMetaFromEvent(e *replication.TableMapEvent, sourceMeta *TableMeta) *TableMeta {
+	sourceTypes := make(map[string]string, len(sourceMeta.ColumnNames))
+	for i, columnName := range sourceMeta.ColumnNames {
+		sourceTypes[columnName] = sourceMeta.ColumnTypes[i]
+	}
+
+	columnNames := e.ColumnNameString()
+	columnTypes := make([]string, len(columnNames))
+	for i, columnName := range columnNames {
+		columnTypes[i] = sourceTypes[columnName]
+	}
+
+	pkNames := make([]string, len(e.PrimaryKey))
+	for i, index := range e.PrimaryKey {
+		pkNames[i] = columnNames[index]
+	}
+
+	meta := NewTableMeta(string(e.Schema), string(e.Table), columnNames, columnTypes, pkNames)
+	for index, unsigned := range e.UnsignedMap() {
+		meta.Unsigned[index] = unsigned
+	}
+
+	return meta
+}
+
+// getDDLTableName returns the db name and table name of the ddl statement,
+// if they could not be parsed, it returns the default db name and empty table name
+func getDDLTableName(defaultDBName, sql string) (string, string) {
+	result, err := parser.NewParserWithDefault().Parse(sql)
+	if err != nil || len(result.TableNames) == constant.ZeroInt {
+		return defaultDBName, constant.EmptyString
+	}
+
+	tableName := result.TableNames[constant.ZeroInt]
+	dbNames := result.TableDBListMap[tableName]
+	if len(dbNames) > constant.ZeroInt && dbNames[constant.ZeroInt] != constant.EmptyString {
+		return dbNames[constant.ZeroInt], tableName
+	}
+
+	return defaultDBName, tableName
+}
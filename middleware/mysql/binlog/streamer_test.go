@@ -0,0 +1,220 @@
+package binlog
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/server"
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/middleware/rabbitmq"
+)
+
+const testBinlogFile = "mysql-bin.000001"
+
+type testErrorCheckpointStore struct{}
+
+func (testErrorCheckpointStore) Load() (*Checkpoint, error) {
+	return nil, errors.New("test load error")
+}
+
+func (testErrorCheckpointStore) Save(checkpoint *Checkpoint) error {
+	return nil
+}
+
+// testReplicationHandler is a fake replication source which never sends any event
+type testReplicationHandler struct {
+	server.EmptyReplicationHandler
+	sync.Mutex
+	streamers []*replication.BinlogStreamer
+}
+
+func (h *testReplicationHandler) HandleQuery(query string) (*gomysql.Result, error) {
+	return nil, nil
+}
+
+func (h *testReplicationHandler) HandleRegisterSlave(data []byte) error {
+	return nil
+}
+
+func (h *testReplicationHandler) HandleBinlogDump(pos gomysql.Position) (*replication.BinlogStreamer, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	streamer := replication.NewBinlogStreamer()
+	h.streamers = append(h.streamers, streamer)
+
+	return streamer, nil
+}
+
+// close stops sending the events of all the dumps
+func (h *testReplicationHandler) close() {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, streamer := range h.streamers {
+		streamer.AddErrorToStreamer(errors.New("test replication source closed"))
+	}
+}
+
+// newTestReplicationSource starts a fake replication source and returns the listener and the handler
+func newTestReplicationSource(t *testing.T) (net.Listener, *testReplicationHandler) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed. error:\n%+v", err)
+	}
+	handler := &testReplicationHandler{}
+
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn, err := server.NewConn(c, "root", "root", handler)
+				if err != nil {
+					_ = c.Close()
+					return
+				}
+				for conn.HandleCommand() == nil {
+				}
+			}()
+		}
+	}()
+
+	return listener, handler
+}
+
+// newTestStreamer returns a *Streamer which does not connect to the source, it could only handle the events
+func newTestStreamer(store CheckpointStore, handler Handler) *Streamer {
+	return &Streamer{
+		config:  NewConfigWithDefault("127.0.0.1:3306", "root", "root", 1001),
+		store:   store,
+		handler: handler,
+		syncerConfig: replication.BinlogSyncerConfig{
+			ServerID: 1001,
+			Flavor:   gomysql.MySQLFlavor,
+			Host:     "127.0.0.1",
+			Port:     3306,
+			User:     "root",
+			Password: "root",
+		},
+		tables:     map[string]*TableMeta{"test.t01": newTestTableMeta()},
+		file:       testBinlogFile,
+		checkpoint: NewCheckpoint(testBinlogFile, 4, ""),
+	}
+}
+
+func newTestQueryEvent(logPos uint32, query string) *replication.BinlogEvent {
+	return &replication.BinlogEvent{
+		Header: &replication.EventHeader{Timestamp: 1700000000, EventType: replication.QUERY_EVENT, LogPos: logPos},
+		Event:  &replication.QueryEvent{Schema: []byte("test"), Query: []byte(query)},
+	}
+}
+
+func newTestRowsEvent(logPos uint32, id int32) *replication.BinlogEvent {
+	return &replication.BinlogEvent{
+		Header: &replication.EventHeader{Timestamp: 1700000000, EventType: replication.WRITE_ROWS_EVENTv2, LogPos: logPos},
+		Event: &replication.RowsEvent{
+			Table:       &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("t01"), ColumnCount: 3},
+			ColumnCount: 3,
+			Rows:        [][]interface{}{{id, []byte("aa"), int32(10)}},
+		},
+	}
+}
+
+func newTestXIDEvent(logPos uint32) *replication.BinlogEvent {
+	return &replication.BinlogEvent{
+		Header: &replication.EventHeader{Timestamp: 1700000000, EventType: replication.XID_EVENT, LogPos: logPos},
+		Event:  &replication.XIDEvent{},
+	}
+}
+
+func TestStreamer_HandleEvent(t *testing.T) {
+	asst := assert.New(t)
+
+	var handled [][]*rabbitmq.Message
+	store := NewMemoryCheckpointStore(nil)
+	s := newTestStreamer(store, func(ctx context.Context, messages []*rabbitmq.Message, checkpoint *Checkpoint) error {
+		handled = append(handled, messages)
+		return nil
+	})
+
+	ctx := context.Background()
+	// the savepoint is in the middle of the transaction, it must not commit the transaction
+	for _, ev := range []*replication.BinlogEvent{
+		newTestQueryEvent(100, "BEGIN"),
+		newTestRowsEvent(200, 1),
+		newTestQueryEvent(300, "SAVEPOINT sp1"),
+		newTestRowsEvent(400, 2),
+	} {
+		err := s.handleEvent(ctx, ev)
+		asst.Nil(err, "test handleEvent() failed")
+	}
+	asst.Equal(0, len(handled), "test handleEvent() failed")
+	checkpoint, err := store.Load()
+	asst.Nil(err, "test handleEvent() failed")
+	asst.Nil(checkpoint, "test handleEvent() failed")
+
+	err = s.handleEvent(ctx, newTestXIDEvent(500))
+	asst.Nil(err, "test handleEvent() failed")
+	asst.Equal(1, len(handled), "test handleEvent() failed")
+	asst.Equal(2, len(handled[0]), "test handleEvent() failed")
+	checkpoint, err = store.Load()
+	asst.Nil(err, "test handleEvent() failed")
+	asst.Equal(uint32(500), checkpoint.Position, "test handleEvent() failed")
+
+	// ddl statements commit implicitly
+	err = s.handleEvent(ctx, newTestQueryEvent(600, "ALTER TABLE t01 ADD COLUMN c1 INT"))
+	asst.Nil(err, "test handleEvent() failed")
+	asst.Equal(2, len(handled), "test handleEvent() failed")
+	asst.True(handled[1][0].GetIsDDL(), "test handleEvent() failed")
+	asst.Equal(uint32(600), s.Checkpoint().Position, "test handleEvent() failed")
+}
+
+func TestStreamer_Run(t *testing.T) {
+	asst := assert.New(t)
+
+	s := newTestStreamer(testErrorCheckpointStore{}, func(ctx context.Context, messages []*rabbitmq.Message, checkpoint *Checkpoint) error {
+		return nil
+	})
+
+	// the streamer could be restarted after it stopped with an error
+	for i := 0; i < 2; i++ {
+		err := s.Run(context.Background())
+		asst.NotNil(err, "test Run() failed")
+		asst.Contains(err.Error(), "test load error", "test Run() failed")
+	}
+}
+
+func TestStreamer_RunTwice(t *testing.T) {
+	asst := assert.New(t)
+
+	listener, handler := newTestReplicationSource(t)
+	defer func() {
+		_ = listener.Close()
+		handler.close()
+	}()
+
+	s := newTestStreamer(NewMemoryCheckpointStore(NewCheckpoint(testBinlogFile, 4, "")), func(ctx context.Context, messages []*rabbitmq.Message, checkpoint *Checkpoint) error {
+		return nil
+	})
+	s.syncerConfig.Port = uint16(listener.Addr().(*net.TCPAddr).Port)
+
+	// each run uses a new syncer, and the syncer is closed after the run returns
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		err := s.Run(ctx)
+		cancel()
+		asst.NotNil(err, "test Run() failed")
+		asst.ErrorIs(err, context.DeadlineExceeded, "test Run() failed")
+		asst.Nil(s.syncer, "test Run() failed")
+	}
+}
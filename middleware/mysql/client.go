@@ -2,16 +2,20 @@ package mysql
 
 import (
 	"context"
+	"database/sql/driver"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/pingcap/errors"
 	"github.com/romberli/log"
 
 	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 	"github.com/romberli/go-util/middleware"
+	"github.com/romberli/go-util/middleware/result"
 )
 
 type ReplicationRole string
@@ -192,6 +196,111 @@ func (conn *Conn) executeContext(ctx context.Context, command string, args ...in
 	return NewResult(result), nil
 }
 
+// ExecuteStream executes given sql and placeholders with context, and calls the handler for each row of the result,
+// rows are read from the server incrementally, so it is suitable for reading a large result which does not fit in memory,
+// it stops when all the rows are read, or the handler returns an error, or the context is done,
+// note that if it stops before all the rows are read, the remaining rows are still left on the connection,
+// so the connection will be closed and could not be used anymore,
+// when using connection pool, the closed connection will not be put back to the pool
+func (conn *Conn) ExecuteStream(ctx context.Context, command string, args []interface{}, handler result.RowHandler) error {
+	err := common.SetRandomValueToNil(args...)
+	if err != nil {
+		return err
+	}
+
+	// interrupt the blocking read when the context is done
+	deadlineSet := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(deadlineSet)
+		_ = conn.Conn.SetReadDeadline(time.Now())
+	})
+	defer func() {
+		if !stop() {
+			// the read deadline had been set, reset it in case the connection is still in use
+			<-deadlineSet
+			_ = conn.Conn.SetReadDeadline(time.Time{})
+		}
+	}()
+
+	var (
+		rv          *result.RowView
+		values      []driver.Value
+		index       int
+		interrupted bool
+	)
+
+	perResultCallback := func(r *mysql.Result) error {
+		columns := make([]string, len(r.Fields))
+		for i, field := range r.Fields {
+			columns[i] = string(field.Name)
+		}
+		rv = result.NewRowViewWithColumns(columns)
+		values = make([]driver.Value, len(columns))
+
+		return nil
+	}
+	perRowCallback := func(row []mysql.FieldValue) error {
+		err := ctx.Err()
+		if err != nil {
+			interrupted = true
+			return err
+		}
+
+		for i := range row {
+			value := row[i].Value()
+			b, ok := value.([]byte)
+			if ok {
+				// the underlying buffer will be reused when reading the next row
+				value = append([]byte(nil), b...)
+			}
+			values[i] = value
+		}
+
+		rv.Set(index, values)
+		err = handler(rv)
+		if err != nil {
+			interrupted = true
+			return err
+		}
+		index++
+
+		return nil
+	}
+
+	var r mysql.Result
+	if len(args) == constant.ZeroInt {
+		err = conn.Conn.ExecuteSelectStreaming(command, &r, perRowCallback, perResultCallback)
+	} else {
+		var stmt *client.Stmt
+		stmt, err = conn.Conn.Prepare(command)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer func() { _ = stmt.Close() }()
+
+		err = stmt.ExecuteSelectStreaming(&r, perRowCallback, perResultCallback, args...)
+	}
+	if err != nil {
+		_, isServerErr := errors.Cause(err).(*mysql.MyError)
+		if interrupted || !isServerErr {
+			// the remaining packets of the result may be left on the connection, it could not be used anymore
+			closeErr := conn.Conn.Close()
+			if closeErr != nil {
+				log.Errorf("close connection failed, addr: %s, user: %s, error:\n%+v", conn.Addr, conn.DBUser, closeErr)
+			}
+		}
+
+		ctxErr := ctx.Err()
+		if ctxErr != nil {
+			return errors.Trace(ctxErr)
+		}
+
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
 // GetVersion returns mysql version
 func (conn *Conn) GetVersion() (Version, error) {
 	result, err := conn.Execute(SelectVersionSQL)
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -12,6 +13,7 @@ import (
 	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 	"github.com/romberli/go-util/middleware"
+	"github.com/romberli/go-util/middleware/result"
 )
 
 const (
@@ -86,6 +88,7 @@ func TestConn_All(t *testing.T) {
 	TestMySQLConnection(t)
 	TestConn_ExecuteInBatch(t)
 	TestConn_Execute(t)
+	TestConn_ExecuteStream(t)
 	TestConn_IsReplicationSlave(t)
 	TestConn_IsMater(t)
 	TestConn_IsMGR(t)
@@ -198,6 +201,44 @@ func TestConn_Execute(t *testing.T) {
 	asst.Nil(err, "execute drop table sql failed")
 }
 
+func TestConn_ExecuteStream(t *testing.T) {
+	asst := assert.New(t)
+	// create table
+	err := createTable()
+	asst.Nil(err, "test ExecuteStream() failed")
+	// insert data
+	sql := `INSERT INTO t05(name, col1, col2) VALUES(?, ?, ?), (?, ?, ?);`
+	_, err = conn.Execute(sql, "aa", 1, 3.14, "bb", 2, 6.28)
+	asst.Nil(err, "test ExecuteStream() failed")
+	// select data
+	var names []string
+	sql = "SELECT id, name, col1 FROM t05 WHERE col1 >= ? ORDER BY id;"
+	err = conn.ExecuteStream(context.Background(), sql, []interface{}{1}, func(row *result.RowView) error {
+		name, err := row.GetStringByName("name")
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+
+		return nil
+	})
+	asst.Nil(err, "test ExecuteStream() failed")
+	asst.Equal([]string{"aa", "bb"}, names, "test ExecuteStream() failed")
+	// stop early
+	ctx, cancel := context.WithCancel(context.Background())
+	err = conn.ExecuteStream(ctx, "SELECT id FROM t05 ORDER BY id;", nil, func(row *result.RowView) error {
+		cancel()
+		return nil
+	})
+	asst.NotNil(err, "test ExecuteStream() failed")
+	// the connection is closed as the remaining rows are left on it
+	asst.False(conn.CheckInstanceStatus(), "test ExecuteStream() failed")
+	// drop table
+	conn = initConn()
+	err = dropTable()
+	asst.Nil(err, "test ExecuteStream() failed")
+}
+
 func TestConn_IsReplicationSlave(t *testing.T) {
 	asst := assert.New(t)
 
@@ -0,0 +1,735 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/romberli/go-multierror"
+	"github.com/romberli/log"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware"
+	"github.com/romberli/go-util/middleware/result"
+	"github.com/romberli/go-util/middleware/sql/parser"
+	"github.com/romberli/go-util/middleware/sql/statement"
+)
+
+const (
+	DefaultTopologyCheckInterval = 5 // seconds
+
+	forUpdateString       = "for update"
+	forShareString        = "for share"
+	lockInShareModeString = "lock in share mode"
+	intoString            = " into "
+)
+
+var _ middleware.Pool = (*ClusterPool)(nil)
+
+// StatementRouter decides if given sql is a read statement which could be executed on the replicas
+type StatementRouter func(sql string) bool
+
+// IsReadStatement checks if given sql is a read statement by statement.GetType(),
+// select statements with locking clause or into clause are considered as write statements
+func IsReadStatement(sql string) bool {
+	if statement.GetType(sql) != statement.Select {
+		return false
+	}
+
+	sql = strings.ToLower(sql)
+	for _, s := range []string{forUpdateString, forShareString, lockInShareModeString, intoString} {
+		if strings.Contains(sql, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewParserRouter returns a StatementRouter which uses the sql parser to check the sql type,
+// it is more accurate than IsReadStatement() but costs more,
+// the sql which could not be parsed is considered as a write statement
+func NewParserRouter() StatementRouter {
+	var mutex sync.Mutex
+	p := parser.NewParserWithDefault()
+
+	return func(sql string) bool {
+		mutex.Lock()
+		stmtNodes, err := p.GetStatementNodes(sql)
+		mutex.Unlock()
+		if err != nil || len(stmtNodes) == constant.ZeroInt {
+			return false
+		}
+
+		for _, stmtNode := range stmtNodes {
+			selectStmt, ok := stmtNode.(*ast.SelectStmt)
+			if !ok {
+				return false
+			}
+			if selectStmt.LockInfo != nil && selectStmt.LockInfo.LockType != ast.SelectLockNone {
+				return false
+			}
+			if selectStmt.SelectIntoOpt != nil {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+type ClusterPoolConfig struct {
+	PoolConfig
+	Replicas              []string
+	TopologyCheckInterval int
+	LagPolicy             LagPolicy
+}
+
+// NewClusterPoolConfig returns a new ClusterPoolConfig,
+// the address of the pool config is the address of the source
+func NewClusterPoolConfig(config PoolConfig, replicas []string, topologyCheckInterval int) ClusterPoolConfig {
+	return ClusterPoolConfig{
+		PoolConfig:            config,
+		Replicas:              replicas,
+		TopologyCheckInterval: topologyCheckInterval,
+	}
+}
+
+// NewClusterPoolConfigWithLagPolicy returns a new ClusterPoolConfig with given lag policy,
+// the replicas whose replication lag exceeds the threshold will be taken out of read rotation
+func NewClusterPoolConfigWithLagPolicy(config PoolConfig, replicas []string, topologyCheckInterval int, lagPolicy LagPolicy) ClusterPoolConfig {
+	return ClusterPoolConfig{
+		PoolConfig:            config,
+		Replicas:              replicas,
+		TopologyCheckInterval: topologyCheckInterval,
+		LagPolicy:             lagPolicy,
+	}
+}
+
+// Validate validates cluster pool config
+func (cfg *ClusterPoolConfig) Validate() error {
+	err := cfg.PoolConfig.Validate()
+	if err != nil {
+		return err
+	}
+
+	addrs := map[string]bool{cfg.Addr: true}
+	for _, replica := range cfg.Replicas {
+		if replica == constant.EmptyString {
+			return errors.New("replica address should not be empty")
+		}
+		if addrs[replica] {
+			return errors.Errorf("duplicate address found in the cluster. addr: %s", replica)
+		}
+		addrs[replica] = true
+	}
+
+	if cfg.TopologyCheckInterval <= constant.ZeroInt {
+		return errors.New("topology check interval argument should be larger than 0")
+	}
+
+	return cfg.LagPolicy.Validate()
+}
+
+// ClusterNode is the status of a node of the cluster
+type ClusterNode struct {
+	Addr          string          `json:"addr"`
+	Role          ReplicationRole `json:"role"`
+	ReadOnly      bool            `json:"read_only"`
+	Available     bool            `json:"available"`
+	LastCheckTime time.Time       `json:"last_check_time"`
+	LastError     string          `json:"last_error"`
+}
+
+// IsWritable returns if the node could be used as the source
+func (cn ClusterNode) IsWritable() bool {
+	return cn.Available && !cn.ReadOnly && cn.Role == ReplicationSource
+}
+
+type clusterNode struct {
+	ClusterNode
+	health ReplicaHealth
+	pool   *Pool
+}
+
+// ClusterPool is a pool of a mysql replication cluster,
+// it routes write statements to the source and load-balances read statements across the replicas,
+// it checks the topology periodically and fails over to the new source automatically
+type ClusterPool struct {
+	sync.RWMutex
+	ClusterPoolConfig
+	checkMutex sync.Mutex
+	router     StatementRouter
+	nodes      []*clusterNode
+	source     *clusterNode
+	replicas   []*clusterNode
+	counter    uint64
+	isClosed   bool
+	closed     chan struct{}
+}
+
+// NewClusterPool returns a new *ClusterPool with default pool configuration
+func NewClusterPool(source string, replicas []string, dbName, dbUser, dbPass string) (*ClusterPool, error) {
+	cfg := NewPoolConfig(source, dbName, dbUser, dbPass,
+		DefaultMaxConnections, DefaultInitConnections, DefaultMaxIdleConnections,
+		DefaultMaxIdleTime, DefaultMaxWaitTime, DefaultMaxRetryCount, DefaultKeepAliveInterval)
+
+	return NewClusterPoolWithConfig(NewClusterPoolConfig(cfg, replicas, DefaultTopologyCheckInterval))
+}
+
+// NewClusterPoolWithConfig returns a new *ClusterPool with given config,
+// each node of the cluster has its own *Pool which uses the same pool config except the address
+func NewClusterPoolWithConfig(config ClusterPoolConfig) (*ClusterPool, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &ClusterPool{
+		ClusterPoolConfig: config,
+		router:            IsReadStatement,
+		closed:            make(chan struct{}),
+	}
+
+	merr := &multierror.Error{}
+	for _, addr := range append([]string{config.Addr}, config.Replicas...) {
+		cn := &clusterNode{ClusterNode: ClusterNode{Addr: addr}}
+		cfg := config.PoolConfig
+		cfg.Addr = addr
+		cn.pool, err = NewPoolWithPoolConfig(cfg)
+		if err != nil {
+			merr = multierror.Append(merr, errors.Annotatef(err, "creating pool failed. addr: %s", addr))
+			cn.LastError = err.Error()
+		}
+		cp.nodes = append(cp.nodes, cn)
+	}
+	if merr.Len() == len(cp.nodes) {
+		return nil, errors.Trace(merr.ErrorOrNil())
+	}
+	if merr.ErrorOrNil() != nil {
+		log.Warnf("some nodes of the cluster are not available. error:\n%+v", merr.ErrorOrNil())
+	}
+
+	// the configured source is used until the topology check finds another one
+	cp.source = cp.nodes[constant.ZeroInt]
+	err = cp.CheckTopology()
+	if err != nil {
+		log.Warnf("checking topology of the cluster failed. error:\n%+v", err)
+	}
+
+	go cp.maintainTopology()
+
+	return cp, nil
+}
+
+// SetRouter sets the statement router of the cluster pool
+func (cp *ClusterPool) SetRouter(router StatementRouter) {
+	cp.Lock()
+	defer cp.Unlock()
+
+	cp.router = router
+}
+
+// IsRead checks if given sql should be routed to the replicas
+func (cp *ClusterPool) IsRead(sql string) bool {
+	cp.RLock()
+	router := cp.router
+	cp.RUnlock()
+
+	return router(sql)
+}
+
+// SourceAddr returns the address of current source
+func (cp *ClusterPool) SourceAddr() string {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	return cp.source.Addr
+}
+
+// ReplicaAddrs returns the addresses of the replicas which are in read rotation
+func (cp *ClusterPool) ReplicaAddrs() []string {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	replicas := make([]string, len(cp.replicas))
+	for i, replica := range cp.replicas {
+		replicas[i] = replica.Addr
+	}
+
+	return replicas
+}
+
+// Nodes returns the status of all the nodes of the cluster
+func (cp *ClusterPool) Nodes() []ClusterNode {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	nodes := make([]ClusterNode, len(cp.nodes))
+	for i, node := range cp.nodes {
+		nodes[i] = node.ClusterNode
+	}
+
+	return nodes
+}
+
+// IsClosed returns if the cluster pool had been closed
+func (cp *ClusterPool) IsClosed() bool {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	return cp.isClosed
+}
+
+// Close closes the pools of all the nodes
+func (cp *ClusterPool) Close() error {
+	cp.Lock()
+	defer cp.Unlock()
+
+	if cp.isClosed {
+		return nil
+	}
+	cp.isClosed = true
+	close(cp.closed)
+
+	merr := &multierror.Error{}
+	for _, node := range cp.nodes {
+		if node.pool == nil {
+			continue
+		}
+		err := node.pool.Close()
+		if err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+
+	return errors.Trace(merr.ErrorOrNil())
+}
+
+// Get gets a connection of the source, it's the same as GetSource()
+func (cp *ClusterPool) Get() (middleware.PoolConn, error) {
+	return cp.GetSource()
+}
+
+// GetContext gets a connection of the source with context, it's the same as GetSourceContext(),
+// it always uses the source, use GetByStatement() or GetReplicaContext() to read from the replicas
+func (cp *ClusterPool) GetContext(ctx context.Context) (middleware.PoolConn, error) {
+	return cp.GetSourceContext(ctx)
+}
+
+// Transaction returns a connection of the source that could run multiple statements in the same transaction
+func (cp *ClusterPool) Transaction() (middleware.Transaction, error) {
+	ctx, cancel := middleware.GetWaitContext(cp.MaxWaitTime)
+	defer cancel()
+
+	pc, err := cp.getSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pc, nil
+}
+
+// GetSource gets a connection of the source, it waits at most MaxWaitTime seconds
+func (cp *ClusterPool) GetSource() (middleware.PoolConn, error) {
+	ctx, cancel := middleware.GetWaitContext(cp.MaxWaitTime)
+	defer cancel()
+
+	return cp.GetSourceContext(ctx)
+}
+
+// GetSourceContext gets a connection of the source with context,
+// if the source is not available, it checks the topology and tries again
+func (cp *ClusterPool) GetSourceContext(ctx context.Context) (middleware.PoolConn, error) {
+	pc, err := cp.getSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pc, nil
+}
+
+// GetReplica gets a connection of a replica, it waits at most MaxWaitTime seconds
+func (cp *ClusterPool) GetReplica() (middleware.PoolConn, error) {
+	ctx, cancel := middleware.GetWaitContext(cp.MaxWaitTime)
+	defer cancel()
+
+	return cp.GetReplicaContext(ctx)
+}
+
+// GetReplicaContext gets a connection of a replica with context,
+// replicas are chosen in round-robin order, if there is no available replica, it returns a connection of the source
+func (cp *ClusterPool) GetReplicaContext(ctx context.Context) (middleware.PoolConn, error) {
+	pc, err := cp.getReplica(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pc, nil
+}
+
+// GetByStatement gets a connection of the source or a replica according to given sql
+func (cp *ClusterPool) GetByStatement(ctx context.Context, sql string) (middleware.PoolConn, error) {
+	if cp.IsRead(sql) {
+		return cp.GetReplicaContext(ctx)
+	}
+
+	return cp.GetSourceContext(ctx)
+}
+
+// Execute routes given sql to the source or a replica and executes it
+func (cp *ClusterPool) Execute(command string, args ...interface{}) (middleware.Result, error) {
+	return cp.ExecuteContext(context.Background(), command, args...)
+}
+
+// ExecuteContext routes given sql to the source or a replica and executes it with context
+func (cp *ClusterPool) ExecuteContext(ctx context.Context, command string, args ...interface{}) (middleware.Result, error) {
+	pc, err := cp.GetByStatement(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeErr := pc.Close()
+		if closeErr != nil {
+			log.Errorf("closing connection failed. error:\n%+v", closeErr)
+		}
+	}()
+
+	return pc.ExecuteContext(ctx, command, args...)
+}
+
+// ExecuteStream routes given sql to the source or a replica, executes it and calls the handler for each row of the result
+func (cp *ClusterPool) ExecuteStream(ctx context.Context, command string, args []interface{}, handler result.RowHandler) error {
+	pc, err := cp.GetByStatement(ctx, command)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := pc.Close()
+		if closeErr != nil {
+			log.Errorf("closing connection failed. error:\n%+v", closeErr)
+		}
+	}()
+
+	return pc.ExecuteStream(ctx, command, args, handler)
+}
+
+// Supply creates given number of connections and add them to the pool of each node
+func (cp *ClusterPool) Supply(num int) error {
+	return cp.eachPool(func(p *Pool) error {
+		return p.Supply(num)
+	})
+}
+
+// Release releases given number of connections of the pool of each node
+func (cp *ClusterPool) Release(num int) error {
+	return cp.eachPool(func(p *Pool) error {
+		return p.Release(num)
+	})
+}
+
+// PoolStats returns the sum of the statistics of the pools of all the nodes
+func (cp *ClusterPool) PoolStats() middleware.PoolStats {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	stats := middleware.PoolStats{WaitDurationBuckets: make(map[float64]uint64)}
+	for _, node := range cp.nodes {
+		if node.pool == nil {
+			continue
+		}
+		s := node.pool.PoolStats()
+		stats.MaxConnections += s.MaxConnections
+		stats.UsedConnections += s.UsedConnections
+		stats.IdleConnections += s.IdleConnections
+		stats.WaitingCount += s.WaitingCount
+		stats.GetCount += s.GetCount
+		stats.GetFailedCount += s.GetFailedCount
+		stats.WaitCount += s.WaitCount
+		stats.WaitDuration += s.WaitDuration
+		for bucket, count := range s.WaitDurationBuckets {
+			stats.WaitDurationBuckets[bucket] += count
+		}
+		stats.CreatedCount += s.CreatedCount
+		stats.InvalidCount += s.InvalidCount
+		stats.KeepAliveFailedCount += s.KeepAliveFailedCount
+		stats.ReconnectCount += s.ReconnectCount
+	}
+
+	return stats
+}
+
+// ReplicaHealth returns the health status of all the nodes except the source,
+// it could be used by dashboards and alerting
+func (cp *ClusterPool) ReplicaHealth() []ReplicaHealth {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	healths := make([]ReplicaHealth, constant.ZeroInt, len(cp.nodes))
+	for _, node := range cp.nodes {
+		if node != cp.source {
+			healths = append(healths, node.health)
+		}
+	}
+
+	return healths
+}
+
+// NodePoolStats returns the statistics of the pool of given node
+func (cp *ClusterPool) NodePoolStats(addr string) (middleware.PoolStats, error) {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	for _, node := range cp.nodes {
+		if node.Addr == addr && node.pool != nil {
+			return node.pool.PoolStats(), nil
+		}
+	}
+
+	return middleware.PoolStats{}, errors.Errorf("pool of the node does not exist. addr: %s", addr)
+}
+
+// CheckTopology checks the role and read only status of each node,
+// if current source is not writable anymore and another node is writable, the new source will be used,
+// the available nodes other than the source are put into read rotation
+func (cp *ClusterPool) CheckTopology() error {
+	// only one routine checks the topology at the same time
+	cp.checkMutex.Lock()
+	defer cp.checkMutex.Unlock()
+
+	cp.RLock()
+	nodes := make([]*clusterNode, len(cp.nodes))
+	pools := make([]*Pool, len(cp.nodes))
+	for i, node := range cp.nodes {
+		nodes[i] = node
+		pools[i] = node.pool
+	}
+	cp.RUnlock()
+
+	merr := &multierror.Error{}
+	statuses := make([]ClusterNode, len(nodes))
+	healths := make([]ReplicaHealth, len(nodes))
+	for i, node := range nodes {
+		statuses[i], healths[i], pools[i] = cp.checkNode(node.Addr, pools[i])
+		if statuses[i].LastError != constant.EmptyString {
+			merr = multierror.Append(merr, errors.Errorf("checking node failed. addr: %s, error: %s",
+				node.Addr, statuses[i].LastError))
+		}
+	}
+
+	cp.Lock()
+	defer cp.Unlock()
+
+	if cp.isClosed {
+		for i, p := range pools {
+			if p != nil && nodes[i].pool == nil {
+				_ = p.Close()
+			}
+		}
+		return nil
+	}
+
+	for i, node := range nodes {
+		node.ClusterNode = statuses[i]
+		healths[i].InRotation = node.health.InRotation
+		node.health = healths[i]
+		node.pool = pools[i]
+	}
+
+	// choose the source
+	if !cp.source.IsWritable() {
+		var writable []*clusterNode
+		for _, node := range nodes {
+			if node.IsWritable() {
+				writable = append(writable, node)
+			}
+		}
+		if len(writable) > constant.ZeroInt {
+			if len(writable) > constant.OneInt {
+				log.Warnf("more than one writable node found in the cluster, %s will be used as the source", writable[constant.ZeroInt].Addr)
+			}
+			log.Infof("source of the cluster changed. old: %s, new: %s", cp.source.Addr, writable[constant.ZeroInt].Addr)
+			cp.source = writable[constant.ZeroInt]
+		} else if cp.source.Available {
+			log.Warnf("source of the cluster is not writable and no other writable node found. source: %s", cp.source.Addr)
+		}
+	}
+
+	// put the available and healthy nodes into read rotation
+	replicas := make([]*clusterNode, constant.ZeroInt, len(nodes))
+	for _, node := range nodes {
+		isInRotation := node != cp.source && node.health.checkRotation(cp.LagPolicy, node.health.InRotation)
+		if node != cp.source && isInRotation != node.health.InRotation {
+			if isInRotation {
+				log.Infof("replica is put back to read rotation. addr: %s, lag: %s", node.Addr, node.health.Lag)
+			} else {
+				log.Warnf("replica is taken out of read rotation. addr: %s, available: %t, is_lag_known: %t, lag: %s",
+					node.Addr, node.health.Available, node.health.IsLagKnown, node.health.Lag)
+			}
+		}
+		node.health.InRotation = isInRotation
+		if isInRotation {
+			replicas = append(replicas, node)
+		}
+	}
+	cp.replicas = replicas
+
+	return errors.Trace(merr.ErrorOrNil())
+}
+
+// checkNode checks the status and the replication lag of the node,
+// if the pool of the node had not been created, it will try to create it
+func (cp *ClusterPool) checkNode(addr string, p *Pool) (ClusterNode, ReplicaHealth, *Pool) {
+	status := ClusterNode{Addr: addr, LastCheckTime: time.Now()}
+	health := ReplicaHealth{Addr: addr, LastCheckTime: status.LastCheckTime}
+
+	var err error
+	if p == nil {
+		cfg := cp.PoolConfig
+		cfg.Addr = addr
+		p, err = NewPoolWithPoolConfig(cfg)
+		if err != nil {
+			status.LastError = err.Error()
+			health.LastError = status.LastError
+			return status, health, nil
+		}
+	}
+
+	pc, err := p.getFromPool()
+	if err != nil {
+		status.LastError = err.Error()
+		health.LastError = status.LastError
+		return status, health, p
+	}
+	defer func() { _ = pc.Close() }()
+
+	status.ReadOnly, err = pc.IsReadOnly()
+	if err != nil {
+		status.LastError = err.Error()
+		health.LastError = status.LastError
+		return status, health, p
+	}
+	status.Role, err = pc.GetReplicationRole()
+	if err != nil {
+		status.LastError = err.Error()
+		health.LastError = status.LastError
+		return status, health, p
+	}
+	status.Available = true
+	health.Available = true
+
+	if status.Role != ReplicationSource {
+		err = sampleReplicaHealth(pc, cp.LagPolicy, &health)
+		if err != nil {
+			health.LastError = err.Error()
+		}
+	}
+
+	return status, health, p
+}
+
+// maintainTopology checks the topology of the cluster periodically until the cluster pool is closed
+func (cp *ClusterPool) maintainTopology() {
+	ticker := time.NewTicker(time.Duration(cp.TopologyCheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cp.closed:
+			return
+		case <-ticker.C:
+			err := cp.CheckTopology()
+			if err != nil {
+				log.Debugf("got error when checking topology of the cluster. error:\n%+v", err)
+			}
+		}
+	}
+}
+
+// getSource gets a connection of the source, if it failed, it checks the topology and tries again
+func (cp *ClusterPool) getSource(ctx context.Context) (*PoolConn, error) {
+	pc, err := cp.getFromNode(ctx, cp.currentSource())
+	if err == nil {
+		return pc, nil
+	}
+	if ctx.Err() != nil || cp.IsClosed() {
+		return nil, err
+	}
+
+	// the source may have changed, check the topology and try again
+	checkErr := cp.CheckTopology()
+	if checkErr != nil {
+		log.Debugf("got error when checking topology of the cluster. error:\n%+v", checkErr)
+	}
+
+	return cp.getFromNode(ctx, cp.currentSource())
+}
+
+// getReplica gets a connection of a replica in round-robin order,
+// if all the replicas are unavailable, it gets a connection of the source
+func (cp *ClusterPool) getReplica(ctx context.Context) (*PoolConn, error) {
+	cp.RLock()
+	replicas := cp.replicas
+	cp.RUnlock()
+
+	num := len(replicas)
+	if num > constant.ZeroInt {
+		start := int(atomic.AddUint64(&cp.counter, constant.OneInt) % uint64(num))
+		for i := constant.ZeroInt; i < num; i++ {
+			pc, err := cp.getFromNode(ctx, replicas[(start+i)%num])
+			if err == nil {
+				return pc, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Debugf("getting connection from replica failed, try next one. error:\n%+v", err)
+		}
+	}
+
+	return cp.getSource(ctx)
+}
+
+// currentSource returns current source node
+func (cp *ClusterPool) currentSource() *clusterNode {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	return cp.source
+}
+
+// getFromNode gets a connection from the pool of given node
+func (cp *ClusterPool) getFromNode(ctx context.Context, node *clusterNode) (*PoolConn, error) {
+	cp.RLock()
+	p := node.pool
+	cp.RUnlock()
+
+	if p == nil {
+		return nil, errors.Errorf("pool of the node is not available. addr: %s", node.Addr)
+	}
+
+	return p.getFromPoolContext(ctx)
+}
+
+// eachPool calls given function with the pool of each node
+func (cp *ClusterPool) eachPool(f func(p *Pool) error) error {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	merr := &multierror.Error{}
+	for _, node := range cp.nodes {
+		if node.pool == nil {
+			continue
+		}
+		err := f(node.pool)
+		if err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+
+	return errors.Trace(merr.ErrorOrNil())
+}
@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReadStatement(t *testing.T) {
+	asst := assert.New(t)
+
+	parserRouter := NewParserRouter()
+
+	testCases := []struct {
+		sql    string
+		isRead bool
+	}{
+		{"select * from t01 where id = 1", true},
+		{"  SELECT id, col1 FROM t01", true},
+		{"select * from t01 where id = 1 for update", false},
+		{"select * from t01 where id = 1 lock in share mode", false},
+		{"select * from t01 into outfile '/tmp/t01.txt'", false},
+		{"insert into t01(id, col1) values(1, 'a')", false},
+		{"update t01 set col1 = 'b' where id = 1", false},
+		{"delete from t01 where id = 1", false},
+		{"create table t02(id int primary key)", false},
+	}
+
+	for _, tc := range testCases {
+		asst.Equal(tc.isRead, IsReadStatement(tc.sql), "test IsReadStatement() failed. sql: %s", tc.sql)
+		asst.Equal(tc.isRead, parserRouter(tc.sql), "test NewParserRouter() failed. sql: %s", tc.sql)
+	}
+	// invalid sql is considered as a write statement
+	asst.False(parserRouter("select from where"), "test NewParserRouter() failed")
+}
+
+func TestClusterPool(t *testing.T) {
+	asst := assert.New(t)
+
+	source := "192.168.137.11:3306"
+	replicas := []string{"192.168.137.12:3306", "192.168.137.13:3306"}
+	dbName := "test"
+	dbUser := "root"
+	dbPass := "root"
+
+	cp, err := NewClusterPool(source, replicas, dbName, dbUser, dbPass)
+	asst.Nil(err, "create cluster pool failed")
+	defer func() {
+		err = cp.Close()
+		asst.Nil(err, "close cluster pool failed")
+	}()
+	t.Logf("source: %s, replicas: %v", cp.SourceAddr(), cp.ReplicaAddrs())
+
+	err = cp.CheckTopology()
+	asst.Nil(err, "check topology failed")
+	for _, node := range cp.Nodes() {
+		t.Logf("node: %+v", node)
+	}
+	for _, health := range cp.ReplicaHealth() {
+		t.Logf("replica health: %+v", health)
+	}
+
+	// write statements go to the source
+	_, err = cp.Execute("create table if not exists t_cluster(id int primary key, col1 varchar(100))")
+	asst.Nil(err, "execute create table sql failed")
+	_, err = cp.Execute("insert into t_cluster(id, col1) values(?, ?) on duplicate key update col1 = values(col1)", 1, "a")
+	asst.Nil(err, "execute insert sql failed")
+
+	// read statements go to the replicas
+	conn, err := cp.GetReplica()
+	asst.Nil(err, "get replica connection failed")
+	readOnly, err := conn.(*PoolConn).IsReadOnly()
+	asst.Nil(err, "check read only failed")
+	asst.True(readOnly, "replica should be read only")
+	err = conn.Close()
+	asst.Nil(err, "close connection failed")
+
+	result, err := cp.Execute("select count(*) from t_cluster")
+	asst.Nil(err, "execute select sql failed")
+	count, err := result.GetInt(0, 0)
+	asst.Nil(err, "get count failed")
+	t.Logf("count: %d", count)
+
+	_, err = cp.Execute("drop table if exists t_cluster")
+	asst.Nil(err, "execute drop table sql failed")
+}
@@ -0,0 +1,233 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	SecondsBehindSourceString = "Seconds_Behind_Source"
+	SecondsBehindMasterString = "Seconds_Behind_Master"
+	ReplicaIORunningString    = "Replica_IO_Running"
+	SlaveIORunningString      = "Slave_IO_Running"
+	ReplicaSQLRunningString   = "Replica_SQL_Running"
+	SlaveSQLRunningString     = "Slave_SQL_Running"
+	ThreadRunningYes          = "Yes"
+)
+
+// ReplicationLag is the replication lag of a replica
+type ReplicationLag struct {
+	// IsReplica means if the server has replication channels
+	IsReplica bool `json:"is_replica"`
+	// IOThreadRunning means if the io threads of all the channels are running
+	IOThreadRunning bool `json:"io_thread_running"`
+	// SQLThreadRunning means if the sql threads of all the channels are running
+	SQLThreadRunning bool `json:"sql_thread_running"`
+	// IsLagKnown means if the lag could be determined, for example,
+	// Seconds_Behind_Source is NULL when the sql thread is not running
+	IsLagKnown bool `json:"is_lag_known"`
+	// Lag is the maximum lag of all the channels
+	Lag time.Duration `json:"lag"`
+}
+
+// GetReplicationLag returns the replication lag of this server by "show replica status",
+// for the old versions which do not support "show replica status", it uses "show slave status" instead
+func (conn *Conn) GetReplicationLag() (*ReplicationLag, error) {
+	result, err := conn.Execute(ShowReplicaStatusSQL)
+	if err != nil {
+		result, err = conn.Execute(ShowSlaveStatusSQL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rl := &ReplicationLag{}
+	if result.RowNumber() == constant.ZeroInt {
+		return rl, nil
+	}
+
+	lagColumn := chooseColumn(result, SecondsBehindSourceString, SecondsBehindMasterString)
+	ioColumn := chooseColumn(result, ReplicaIORunningString, SlaveIORunningString)
+	sqlColumn := chooseColumn(result, ReplicaSQLRunningString, SlaveSQLRunningString)
+
+	rl.IsReplica = true
+	rl.IOThreadRunning = true
+	rl.SQLThreadRunning = true
+	rl.IsLagKnown = true
+	for i := constant.ZeroInt; i < result.RowNumber(); i++ {
+		ioRunning, err := result.GetStringByName(i, ioColumn)
+		if err != nil {
+			return nil, err
+		}
+		sqlRunning, err := result.GetStringByName(i, sqlColumn)
+		if err != nil {
+			return nil, err
+		}
+		rl.IOThreadRunning = rl.IOThreadRunning && ioRunning == ThreadRunningYes
+		rl.SQLThreadRunning = rl.SQLThreadRunning && sqlRunning == ThreadRunningYes
+
+		isNull, err := result.IsNullByName(i, lagColumn)
+		if err != nil {
+			return nil, err
+		}
+		if isNull {
+			rl.IsLagKnown = false
+			continue
+		}
+		seconds, err := result.GetIntByName(i, lagColumn)
+		if err != nil {
+			return nil, err
+		}
+		lag := time.Duration(seconds) * time.Second
+		if lag > rl.Lag {
+			rl.Lag = lag
+		}
+	}
+
+	return rl, nil
+}
+
+// GetHeartbeatLag returns the replication lag by given heartbeat sql,
+// the sql should return the lag in seconds as the first column of the first row, for example:
+// "SELECT TIMESTAMPDIFF(MICROSECOND, MAX(ts), UTC_TIMESTAMP(6)) / 1000000 FROM heartbeat.heartbeat"
+func (conn *Conn) GetHeartbeatLag(sql string) (time.Duration, error) {
+	result, err := conn.Execute(sql)
+	if err != nil {
+		return constant.ZeroInt, err
+	}
+	if result.RowNumber() == constant.ZeroInt {
+		return constant.ZeroInt, errors.Errorf("heartbeat sql returns empty result. sql: %s", sql)
+	}
+
+	seconds, err := result.GetFloat(constant.ZeroInt, constant.ZeroInt)
+	if err != nil {
+		return constant.ZeroInt, err
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// chooseColumn returns the first column name which exists in the result
+func chooseColumn(result *Result, names ...string) string {
+	for _, name := range names {
+		if result.ColumnExists(name) {
+			return name
+		}
+	}
+
+	return names[constant.ZeroInt]
+}
+
+type LagPolicy struct {
+	// MaxLag is the threshold in seconds, the replica will be taken out of read rotation when its lag exceeds it,
+	// 0 means the lag will not be checked
+	MaxLag int
+	// RecoverLag is the threshold in seconds, the replica will be put back to read rotation when its lag is not larger than it,
+	// it should not be larger than MaxLag, 0 means using MaxLag
+	RecoverLag int
+	// HeartbeatSQL is the sql to get the lag from a heartbeat table, see Conn.GetHeartbeatLag(),
+	// if it's empty, Seconds_Behind_Source of "show replica status" will be used
+	HeartbeatSQL string
+}
+
+// NewLagPolicy returns a new LagPolicy
+func NewLagPolicy(maxLag, recoverLag int, heartbeatSQL string) LagPolicy {
+	return LagPolicy{
+		MaxLag:       maxLag,
+		RecoverLag:   recoverLag,
+		HeartbeatSQL: heartbeatSQL,
+	}
+}
+
+// Validate validates lag policy
+func (lp LagPolicy) Validate() error {
+	if lp.MaxLag < constant.ZeroInt {
+		return errors.New("maximum lag argument should not be smaller than 0")
+	}
+	if lp.RecoverLag < constant.ZeroInt {
+		return errors.New("recover lag argument should not be smaller than 0")
+	}
+	if lp.RecoverLag > lp.MaxLag {
+		return errors.Errorf("recover lag should be less or equal than maximum lag. recover_lag: %d, max_lag: %d",
+			lp.RecoverLag, lp.MaxLag)
+	}
+
+	return nil
+}
+
+// IsEnabled returns if the lag should be checked
+func (lp LagPolicy) IsEnabled() bool {
+	return lp.MaxLag > constant.ZeroInt
+}
+
+// getRecoverLag returns the recover lag threshold
+func (lp LagPolicy) getRecoverLag() time.Duration {
+	if lp.RecoverLag == constant.ZeroInt {
+		return time.Duration(lp.MaxLag) * time.Second
+	}
+
+	return time.Duration(lp.RecoverLag) * time.Second
+}
+
+// ReplicaHealth is the health status of a replica of the cluster
+type ReplicaHealth struct {
+	Addr             string        `json:"addr"`
+	Available        bool          `json:"available"`
+	IsReplica        bool          `json:"is_replica"`
+	IOThreadRunning  bool          `json:"io_thread_running"`
+	SQLThreadRunning bool          `json:"sql_thread_running"`
+	IsLagKnown       bool          `json:"is_lag_known"`
+	Lag              time.Duration `json:"lag"`
+	InRotation       bool          `json:"in_rotation"`
+	LastCheckTime    time.Time     `json:"last_check_time"`
+	LastError        string        `json:"last_error"`
+}
+
+// checkRotation returns if the replica should be in read rotation,
+// isInRotation is the rotation status of last check, it's used to avoid flapping between the two thresholds
+func (rh ReplicaHealth) checkRotation(lp LagPolicy, isInRotation bool) bool {
+	if !rh.Available {
+		return false
+	}
+	if !lp.IsEnabled() || !rh.IsReplica {
+		// lag is not checked or the node is not a replica at all
+		return true
+	}
+	if !rh.IsLagKnown || (lp.HeartbeatSQL == constant.EmptyString && !rh.SQLThreadRunning) {
+		return false
+	}
+
+	if isInRotation {
+		return rh.Lag <= time.Duration(lp.MaxLag)*time.Second
+	}
+
+	return rh.Lag <= lp.getRecoverLag()
+}
+
+// sampleReplicaHealth samples the replication lag of the replica by given connection
+func sampleReplicaHealth(pc *PoolConn, lp LagPolicy, health *ReplicaHealth) error {
+	rl, err := pc.GetReplicationLag()
+	if err != nil {
+		return err
+	}
+
+	health.IsReplica = rl.IsReplica
+	health.IOThreadRunning = rl.IOThreadRunning
+	health.SQLThreadRunning = rl.SQLThreadRunning
+	health.IsLagKnown = rl.IsLagKnown
+	health.Lag = rl.Lag
+
+	if lp.HeartbeatSQL != constant.EmptyString && rl.IsReplica {
+		health.Lag, err = pc.GetHeartbeatLag(lp.HeartbeatSQL)
+		if err != nil {
+			health.IsLagKnown = false
+			return err
+		}
+		health.IsLagKnown = true
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLagPolicy_Validate(t *testing.T) {
+	asst := assert.New(t)
+
+	asst.Nil(NewLagPolicy(0, 0, "").Validate(), "test Validate() failed")
+	asst.Nil(NewLagPolicy(10, 5, "").Validate(), "test Validate() failed")
+	asst.NotNil(NewLagPolicy(-1, 0, "").Validate(), "test Validate() failed")
+	asst.NotNil(NewLagPolicy(5, 10, "").Validate(), "test Validate() failed")
+}
+
+func TestReplicaHealth_checkRotation(t *testing.T) {
+	asst := assert.New(t)
+
+	lp := NewLagPolicy(10, 5, "")
+	health := ReplicaHealth{
+		Available:        true,
+		IsReplica:        true,
+		IOThreadRunning:  true,
+		SQLThreadRunning: true,
+		IsLagKnown:       true,
+	}
+
+	// lag is between the two thresholds, the rotation status keeps unchanged
+	health.Lag = 8 * time.Second
+	asst.True(health.checkRotation(lp, true), "test checkRotation() failed")
+	asst.False(health.checkRotation(lp, false), "test checkRotation() failed")
+	// lag exceeds the maximum lag
+	health.Lag = 11 * time.Second
+	asst.False(health.checkRotation(lp, true), "test checkRotation() failed")
+	// lag recovers
+	health.Lag = 5 * time.Second
+	asst.True(health.checkRotation(lp, false), "test checkRotation() failed")
+	// replication stopped
+	health.IsLagKnown = false
+	asst.False(health.checkRotation(lp, true), "test checkRotation() failed")
+	// lag is not checked
+	asst.True(health.checkRotation(NewLagPolicy(0, 0, ""), true), "test checkRotation() failed")
+	// node is not available
+	health.Available = false
+	asst.False(health.checkRotation(NewLagPolicy(0, 0, ""), true), "test checkRotation() failed")
+}
+
+func TestConn_GetReplicationLag(t *testing.T) {
+	asst := assert.New(t)
+
+	rl, err := conn.GetReplicationLag()
+	asst.Nil(err, "test GetReplicationLag() failed")
+	asst.False(rl.IsReplica, "test GetReplicationLag() failed")
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -67,6 +68,43 @@ func TestPool(t *testing.T) {
 	asst.Nil(err, "close pool failed")
 }
 
+func TestPool_GetContext(t *testing.T) {
+	asst := assert.New(t)
+
+	addr := "192.168.137.11:3306"
+	dbName := "test"
+	dbUser := "root"
+	dbPass := "root"
+
+	// create pool with only one connection
+	pool, err := NewPool(addr, dbName, dbUser, dbPass, 1, 1, 1, 10000, 1, -1, 1000)
+	asst.Nil(err, "create pool failed. addr: %s, dbName: %s, dbUser: %s, dbPass: %s", addr, dbName, dbUser, dbPass)
+	defer func() {
+		err = pool.Close()
+		asst.Nil(err, "close pool failed")
+	}()
+
+	conn, err := pool.GetContext(context.Background())
+	asst.Nil(err, "get connection from pool failed")
+
+	// the pool is exhausted, waiting should stop when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = pool.GetContext(ctx)
+	asst.ErrorIs(err, context.DeadlineExceeded, "get connection from pool should fail")
+
+	// the connection should be handed over to the waiter when it is returned to the pool
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = conn.Close()
+	}()
+	conn, err = pool.GetContext(context.Background())
+	asst.Nil(err, "get connection from pool failed")
+	asst.Equal(1, pool.UsedConnections(), "used connections should be 1")
+	err = conn.Close()
+	asst.Nil(err, "close connection failed")
+}
+
 func TestPool_Transaction(t *testing.T) {
 	var (
 		err  error
@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware"
+	"github.com/romberli/go-util/middleware/result"
+)
+
+// Query gets a connection from given pool, executes given sql and placeholders with context,
+// then maps each row of the result to a new value of type T,
+// T must be a struct or a pointer to struct, fields are mapped by the "middleware" tag,
+// it works with any middleware.Pool, if the pool is a *ClusterPool, the read statements will be executed on the replicas
+func Query[T any](ctx context.Context, pool middleware.Pool, sql string, args ...interface{}) ([]T, error) {
+	return QueryWithTag[T](ctx, pool, constant.DefaultMiddlewareTag, sql, args...)
+}
+
+// QueryWithTag is same as Query(), but fields are mapped by given tag
+func QueryWithTag[T any](ctx context.Context, pool middleware.Pool, tag, sql string, args ...interface{}) ([]T, error) {
+	r, err := query(ctx, pool, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Unmarshal[T](r, tag)
+}
+
+// QueryOne is same as Query(), but the result must have exactly one row, otherwise, it returns error
+func QueryOne[T any](ctx context.Context, pool middleware.Pool, sql string, args ...interface{}) (T, error) {
+	return QueryOneWithTag[T](ctx, pool, constant.DefaultMiddlewareTag, sql, args...)
+}
+
+// QueryOneWithTag is same as QueryOne(), but fields are mapped by given tag
+func QueryOneWithTag[T any](ctx context.Context, pool middleware.Pool, tag, sql string, args ...interface{}) (T, error) {
+	var t T
+
+	r, err := query(ctx, pool, sql, args...)
+	if err != nil {
+		return t, err
+	}
+
+	rowNum := r.RowNumber()
+	if rowNum != constant.OneInt {
+		return t, errors.Errorf("number of rows must be exactly 1, %d is not valid. sql: %s", rowNum, sql)
+	}
+
+	return result.UnmarshalRow[T](r, constant.ZeroInt, tag)
+}
+
+// statementPool is the pool which gets the connection according to the sql, like *ClusterPool
+type statementPool interface {
+	GetByStatement(ctx context.Context, sql string) (middleware.PoolConn, error)
+}
+
+var _ statementPool = (*ClusterPool)(nil)
+
+// query gets a connection from the pool and executes given sql and placeholders with context,
+// if the pool could route the sql, like *ClusterPool, the read statements will be executed on the replicas
+func query(ctx context.Context, pool middleware.Pool, sql string, args ...interface{}) (middleware.Result, error) {
+	if pool == nil {
+		return nil, errors.New("pool should not be nil")
+	}
+
+	var (
+		pc  middleware.PoolConn
+		err error
+	)
+	sp, ok := pool.(statementPool)
+	if ok {
+		pc, err = sp.GetByStatement(ctx, sql)
+	} else {
+		pc, err = pool.GetContext(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = pc.Close() }()
+
+	return pc.ExecuteContext(ctx, sql, args...)
+}
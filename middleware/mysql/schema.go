@@ -0,0 +1,169 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware/sql/parser"
+)
+
+const (
+	SelectBaseTableNamesSQL = `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = ? AND table_type = 'BASE TABLE'
+		ORDER BY table_name ;
+	`
+	SelectColumnNamesSQL = `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = ? AND table_name = ?
+		ORDER BY ordinal_position ;
+	`
+	SelectIndexNamesSQL = `
+		SELECT DISTINCT index_name
+		FROM information_schema.statistics
+		WHERE table_schema = ? AND table_name = ? ;
+	`
+	ShowCreateTableSQL = "SHOW CREATE TABLE %s.%s"
+)
+
+// GetTableNames returns the names of the base tables of given database, views are not included
+func (conn *Conn) GetTableNames(dbName string) ([]string, error) {
+	result, err := conn.Execute(SelectBaseTableNamesSQL, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	tableNames := make([]string, result.RowNumber())
+	for i := constant.ZeroInt; i < result.RowNumber(); i++ {
+		tableNames[i], err = result.GetString(i, constant.ZeroInt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tableNames, nil
+}
+
+// GetCreateTableSQL returns the create table statement of given table by "show create table"
+func (conn *Conn) GetCreateTableSQL(dbName, tableName string) (string, error) {
+	result, err := conn.Execute(fmt.Sprintf(ShowCreateTableSQL, quoteIdentifier(dbName), quoteIdentifier(tableName)))
+	if err != nil {
+		return constant.EmptyString, err
+	}
+	if result.RowNumber() == constant.ZeroInt {
+		return constant.EmptyString, errors.Errorf("could not find the table. db: %s, table: %s", dbName, tableName)
+	}
+
+	return result.GetString(constant.ZeroInt, constant.OneInt)
+}
+
+// GetTableDefinition returns the definition of given table,
+// the column and index definitions are parsed from the output of "show create table",
+// so that they are comparable with the definitions parsed from the ddl statements,
+// and they are verified with the columns and indexes of information_schema,
+// note that the table schema of the definition is empty, as the output of "show create table" does not contain it
+func (conn *Conn) GetTableDefinition(dbName, tableName string) (*parser.TableFullDefinition, error) {
+	sql, err := conn.GetCreateTableSQL(dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	td, err := parser.NewParserWithDefault().ParseTableDefinition(sql)
+	if err != nil {
+		return nil, errors.Annotatef(err, "parse table definition failed. db: %s, table: %s", dbName, tableName)
+	}
+
+	err = conn.checkTableDefinition(dbName, tableName, td)
+	if err != nil {
+		return nil, err
+	}
+
+	return td, nil
+}
+
+// GetSchemaDefinition returns the definitions of all the base tables of given database
+func (conn *Conn) GetSchemaDefinition(dbName string) (*parser.SchemaDefinition, error) {
+	tableNames, err := conn.GetTableNames(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := parser.NewEmptySchemaDefinition()
+	for _, tableName := range tableNames {
+		td, err := conn.GetTableDefinition(dbName, tableName)
+		if err != nil {
+			return nil, err
+		}
+		sd.AddTable(td)
+	}
+
+	return sd, nil
+}
+
+// DiffSchema returns the difference between given database and the desired schema,
+// targetSQL is the create table statements of the desired schema, for example, the output of "mysqldump --no-data",
+// the migration sql of the result migrates the database to the desired schema
+func (conn *Conn) DiffSchema(dbName, targetSQL string) (*parser.SchemaDiff, error) {
+	source, err := conn.GetSchemaDefinition(dbName)
+	if err != nil {
+		return nil, err
+	}
+	target, err := parser.NewParserWithDefault().ParseSchemaDefinition(targetSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	return target.Diff(source), nil
+}
+
+// checkTableDefinition checks if the parsed table definition matches the columns and indexes of information_schema
+func (conn *Conn) checkTableDefinition(dbName, tableName string, td *parser.TableFullDefinition) error {
+	result, err := conn.Execute(SelectColumnNamesSQL, dbName, tableName)
+	if err != nil {
+		return err
+	}
+	if result.RowNumber() != len(td.Columns) {
+		return errors.Errorf("column number of the table definition does not match information_schema. "+
+			"db: %s, table: %s, definition: %d, information_schema: %d", dbName, tableName, len(td.Columns), result.RowNumber())
+	}
+	for i := constant.ZeroInt; i < result.RowNumber(); i++ {
+		columnName, err := result.GetString(i, constant.ZeroInt)
+		if err != nil {
+			return err
+		}
+		// the column names of the parsed table definition are in lower case
+		cd := td.GetColumnDefinition(strings.ToLower(columnName))
+		if cd == nil || cd.OrdinalPosition != i+constant.OneInt {
+			return errors.Errorf("column of the table definition does not match information_schema. "+
+				"db: %s, table: %s, column: %s, ordinal position: %d", dbName, tableName, columnName, i+constant.OneInt)
+		}
+	}
+
+	result, err = conn.Execute(SelectIndexNamesSQL, dbName, tableName)
+	if err != nil {
+		return err
+	}
+	for i := constant.ZeroInt; i < result.RowNumber(); i++ {
+		indexName, err := result.GetString(i, constant.ZeroInt)
+		if err != nil {
+			return err
+		}
+		_, ok := td.Indexes[indexName]
+		if !ok {
+			return errors.Errorf("index of the table definition does not match information_schema. db: %s, table: %s, index: %s",
+				dbName, tableName, indexName)
+		}
+	}
+
+	return nil
+}
+
+// quoteIdentifier quotes the identifier with back ticks, the back ticks inside the identifier will be doubled
+func quoteIdentifier(identifier string) string {
+	return constant.BackTickString + strings.ReplaceAll(identifier, constant.BackTickString, constant.BackTickString+constant.BackTickString) + constant.BackTickString
+}
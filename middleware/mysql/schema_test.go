@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSchemaDBName    = "test"
+	testSchemaTableName = "t_schema_diff"
+	testSchemaCreateSQL = `
+		CREATE TABLE t_schema_diff (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  name varchar(64) NOT NULL,
+		  PRIMARY KEY (id),
+		  KEY idx01_name (name)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin
+	`
+	testSchemaTargetSQL = `
+		CREATE TABLE t_schema_diff (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  name varchar(64) NOT NULL,
+		  email varchar(128) NOT NULL,
+		  PRIMARY KEY (id),
+		  KEY idx01_name (name)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin
+	`
+)
+
+func TestConn_GetTableDefinition(t *testing.T) {
+	asst := assert.New(t)
+
+	_, err := conn.Execute("DROP TABLE IF EXISTS " + testSchemaTableName)
+	asst.Nil(err, "test GetTableDefinition() failed")
+	_, err = conn.Execute(testSchemaCreateSQL)
+	asst.Nil(err, "test GetTableDefinition() failed")
+
+	td, err := conn.GetTableDefinition(testSchemaDBName, testSchemaTableName)
+	asst.Nil(err, "test GetTableDefinition() failed")
+	asst.Equal(2, len(td.Columns), "test GetTableDefinition() failed")
+	asst.Equal(2, len(td.Indexes), "test GetTableDefinition() failed")
+
+	diff, err := conn.DiffSchema(testSchemaDBName, testSchemaTargetSQL)
+	asst.Nil(err, "test DiffSchema() failed")
+	asst.Equal(1, len(diff.Altered), "test DiffSchema() failed")
+	for _, sql := range diff.GetMigrationSQL() {
+		t.Log(sql)
+	}
+
+	_, err = conn.Execute("DROP TABLE IF EXISTS " + testSchemaTableName)
+	asst.Nil(err, "test GetTableDefinition() failed")
+}
+
+func TestSchema_quoteIdentifier(t *testing.T) {
+	asst := assert.New(t)
+
+	asst.Equal("`t01`", quoteIdentifier("t01"), "test quoteIdentifier() failed")
+	asst.Equal("`t``01`", quoteIdentifier("t`01"), "test quoteIdentifier() failed")
+	asst.Equal("SHOW CREATE TABLE `db``01`.`t01`",
+		fmt.Sprintf(ShowCreateTableSQL, quoteIdentifier("db`01"), quoteIdentifier("t01")), "test quoteIdentifier() failed")
+}
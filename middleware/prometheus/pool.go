@@ -10,8 +10,10 @@ import (
 	"github.com/romberli/go-multierror"
 	"github.com/romberli/log"
 
+	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 	"github.com/romberli/go-util/middleware"
+	"github.com/romberli/go-util/middleware/result"
 )
 
 const (
@@ -42,6 +44,12 @@ type PoolConfig struct {
 	MaxWaitTime        int
 	MaxRetryCount      int
 	KeepAliveInterval  int
+	// RetryOption is the retry policy when creating new connections failed, if it is nil,
+	// the connections are retried at most MaxRetryCount times with fixed delay time
+	RetryOption *common.RetryOption
+	// CircuitBreaker makes creating new connections fail fast when the address is unavailable, it could be nil,
+	// the circuit breaker could be shared by multiple pools, as the circuits are kept by the addresses
+	CircuitBreaker *common.CircuitBreaker
 }
 
 // NewPoolConfig returns a new PoolConfig
@@ -106,6 +114,13 @@ func (cfg *PoolConfig) Validate() error {
 	if cfg.MaxRetryCount < DefaultUnlimitedRetryCount {
 		return errors.New("maximum retry count argument should not be smaller than -1")
 	}
+	// validate RetryOption
+	if cfg.RetryOption != nil {
+		err := cfg.RetryOption.Validate()
+		if err != nil {
+			return err
+		}
+	}
 	// validate KeepAliveInterval
 	if cfg.KeepAliveInterval <= constant.ZeroInt {
 		return errors.New("keep alive interval argument should be larger than 0")
@@ -114,6 +129,16 @@ func (cfg *PoolConfig) Validate() error {
 	return nil
 }
 
+// GetRetryOption returns the retry policy when creating new connections failed
+func (cfg *PoolConfig) GetRetryOption() *common.RetryOption {
+	if cfg.RetryOption != nil {
+		return cfg.RetryOption
+	}
+
+	// the wait time is controlled by the context of getting connections
+	return common.NewRetryOption(cfg.MaxRetryCount, common.UnlimitedWaitTime, time.Duration(DefaultDelayTime)*time.Millisecond, nil)
+}
+
 type PoolConn struct {
 	*Conn
 	Pool *Pool
@@ -228,6 +253,17 @@ func (pc *PoolConn) executeContext(ctx context.Context, command string, args ...
 	return pc.Conn.executeContext(ctx, command, args...)
 }
 
+// ExecuteStream executes given command and placeholders with context, and calls the handler for each row of the result,
+// note that prometheus returns the whole result at once, so the rows are not read incrementally
+func (pc *PoolConn) ExecuteStream(ctx context.Context, command string, args []interface{}, handler result.RowHandler) error {
+	r, err := pc.Conn.executeContext(ctx, command, args...)
+	if err != nil {
+		return err
+	}
+
+	return result.StreamRows(ctx, r.Rows, handler)
+}
+
 type Pool struct {
 	sync.Mutex
 	PoolConfig
@@ -236,6 +272,8 @@ type Pool struct {
 	expireTime      time.Time
 	keepAliveTime   time.Time
 	isClosed        bool
+	stats           *middleware.PoolStatsRecorder
+	waitQueue       *middleware.WaitQueue[*PoolConn]
 }
 
 // NewPool returns a new *Pool
@@ -277,6 +315,8 @@ func NewPoolWithPoolConfig(config PoolConfig) (*Pool, error) {
 		expireTime:      time.Now().Add(time.Duration(config.MaxIdleTime) * time.Second),
 		keepAliveTime:   time.Now().Add(time.Duration(config.KeepAliveInterval) * time.Second),
 		isClosed:        false,
+		stats:           middleware.NewPoolStatsRecorder(),
+		waitQueue:       middleware.NewWaitQueue[*PoolConn](),
 	}
 
 	err = p.init()
@@ -311,6 +351,14 @@ func (p *Pool) IsClosed() bool {
 	return p.isClosed
 }
 
+// PoolStats returns the statistics of the pool
+func (p *Pool) PoolStats() middleware.PoolStats {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.stats.Stats(p.MaxConnections, p.usedConnections, len(p.freeConnChan))
+}
+
 // Supply is an exported alias of supply() function with routine safe
 func (p *Pool) Supply(num int) error {
 	p.Lock()
@@ -329,12 +377,17 @@ func (p *Pool) supply(num int) error {
 
 	for i := 0; i < num; i++ {
 		if len(p.freeConnChan)+p.usedConnections < p.MaxConnections {
-			pc, err := NewPoolConnWithPool(p, p.Address, p.RoundTripper)
+			pc, err := p.newPoolConn()
 			if err != nil {
 				merr = multierror.Append(merr, err)
 				continue
 			}
+			p.stats.IncCreated()
 
+			if p.waitQueue.Serve(pc) {
+				p.usedConnections++
+				continue
+			}
 			p.addToFreeChan(pc)
 		}
 	}
@@ -348,15 +401,33 @@ func (p *Pool) Close() error {
 	defer p.Unlock()
 
 	p.isClosed = true
+	p.waitQueue.Close()
+
 	return p.release(len(p.freeConnChan))
 }
 
-// put puts given PoolConn back to the pool
+// put puts given PoolConn back to the pool,
+// if there are callers waiting for connection, it will be handed over to the first waiter directly
 func (p *Pool) put(pc *PoolConn) {
+	if p.waitQueue.Serve(pc) {
+		return
+	}
+
 	p.addToFreeChan(pc)
 	p.usedConnections--
 }
 
+// releaseSlot releases a slot of used connections which was occupied by a disconnected connection,
+// if there are callers waiting for connection, the slot will be handed over to the first waiter,
+// and the waiter will create a new connection by itself
+func (p *Pool) releaseSlot() {
+	if p.waitQueue.Serve(nil) {
+		return
+	}
+
+	p.usedConnections--
+}
+
 // getFromFreeChan gets a *PoolConn from free connection channel
 func (p *Pool) getFromFreeChan() (*PoolConn, bool) {
 	pc, ok := <-p.freeConnChan
@@ -368,54 +439,175 @@ func (p *Pool) addToFreeChan(pc *PoolConn) {
 	p.freeConnChan <- pc
 }
 
-// Get is an exported alias of get() function with routine safe
+// Get gets a connection from the pool, it waits at most MaxWaitTime seconds
 func (p *Pool) Get() (middleware.PoolConn, error) {
 	return p.getFromPool()
 }
 
+// GetContext gets a connection from the pool with context,
+// if used connections had reached maximum connections, it waits until a connection is returned to the pool or the context is done,
+// the waiting callers are served in first-in first-out order
+func (p *Pool) GetContext(ctx context.Context) (middleware.PoolConn, error) {
+	return p.getFromPoolContext(ctx)
+}
+
 // Transaction is used to implement the interface, but it is not supported in prometheus, never call this function
 func (p *Pool) Transaction() (middleware.Transaction, error) {
 	return nil, errors.New("prometheus does not support transaction, never call this function")
 }
 
-// getFromPool gets a connection from the pool
+// getFromPool gets a connection from the pool, it waits at most MaxWaitTime seconds
 func (p *Pool) getFromPool() (*PoolConn, error) {
-	maxWaitTime := p.MaxWaitTime
-	if maxWaitTime < constant.ZeroInt {
-		maxWaitTime = int(constant.Century.Seconds())
-	}
+	ctx, cancel := middleware.GetWaitContext(p.MaxWaitTime)
+	defer cancel()
 
-	timer := time.NewTimer(time.Duration(maxWaitTime) * time.Second)
-	defer timer.Stop()
+	return p.getFromPoolContext(ctx)
+}
 
-	var i int
+// getFromPoolContext gets a connection from the pool with context,
+// if used connections had reached maximum connections, it joins the wait queue,
+// if creating new connection failed, it retries at most MaxRetryCount times
+func (p *Pool) getFromPoolContext(ctx context.Context) (pc *PoolConn, err error) {
+	startTime := time.Now()
+	defer func() {
+		p.stats.ObserveGet(time.Since(startTime), err)
+	}()
+
+	var (
+		i           int
+		isWaiting   bool
+		retryOption = p.GetRetryOption()
+	)
 
 	for {
 		p.Lock()
+		if p.IsClosed() {
+			p.Unlock()
+			return nil, errors.New("pool had been closed")
+		}
 
-		pc, err := p.get()
-		p.Unlock()
+		if p.waitQueue.Len() == constant.ZeroInt && p.usedConnections < p.MaxConnections {
+			pc, err = p.get()
+			p.Unlock()
+			if err == nil {
+				return pc, nil
+			}
 
-		if err != nil {
+			if !isWaiting {
+				// could not get a connection immediately, start waiting
+				isWaiting = true
+				p.stats.StartWaiting()
+				defer p.stats.StopWaiting()
+			}
 			// check retry count
-			if p.MaxRetryCount >= constant.ZeroInt && i >= p.MaxRetryCount {
+			if !retryOption.ShouldRetry(i, err) {
 				return nil, err
 			}
+			delay := retryOption.GetDelay(i)
+			retryOption.Notify(i, delay, err)
+			i++
 
-			// check wait time
 			select {
-			case <-timer.C:
-				return nil, err
-			default:
-				time.Sleep(time.Duration(DefaultDelayTime) * time.Millisecond)
+			case <-ctx.Done():
+				return nil, errors.Annotatef(ctx.Err(), "getting connection from the pool failed. last error: %s", err.Error())
+			case <-time.After(delay):
 			}
 
-			i++
 			continue
 		}
 
-		return pc, nil
+		// used connections had reached maximum connections, or there are other callers waiting before, join the wait queue
+		w := p.waitQueue.Push()
+		p.Unlock()
+
+		if !isWaiting {
+			isWaiting = true
+			p.stats.StartWaiting()
+			defer p.stats.StopWaiting()
+		}
+
+		select {
+		case pc, ok := <-w.C():
+			if !ok {
+				return nil, errors.New("pool had been closed")
+			}
+			if pc != nil {
+				// check if connection is still valid
+				if pc.IsValid() {
+					return pc, nil
+				}
+
+				p.stats.IncInvalid(false)
+				disconnectErr := pc.Disconnect()
+				if disconnectErr != nil {
+					log.Warnf("disconnecting invalid connection failed when getting connection from the pool. error:\n%+v", disconnectErr)
+				}
+			}
+			// the waiter holds a free slot now, create a new connection
+			pc, err = p.newPoolConn()
+			if err == nil {
+				p.stats.IncCreated()
+				return pc, nil
+			}
+
+			p.Lock()
+			p.releaseSlot()
+			p.Unlock()
+
+			// check retry count
+			if !retryOption.ShouldRetry(i, err) {
+				return nil, err
+			}
+			retryOption.Notify(i, constant.ZeroInt, err)
+			i++
+		case <-ctx.Done():
+			p.Lock()
+			removed := p.waitQueue.Remove(w)
+			used := p.usedConnections
+			p.Unlock()
+
+			if !removed {
+				// the connection was handed over before removing the waiter, return it back to the pool
+				pc, ok := <-w.C()
+				if ok {
+					if pc == nil {
+						p.Lock()
+						p.releaseSlot()
+						p.Unlock()
+					} else {
+						closeErr := pc.Close()
+						if closeErr != nil {
+							log.Warnf("returning connection to the pool failed. error:\n%+v", closeErr)
+						}
+					}
+				}
+			}
+
+			return nil, errors.Annotatef(ctx.Err(), "waiting for connection failed. used_connections: %d, max_connections: %d",
+				used, p.MaxConnections)
+		}
+	}
+}
+
+// newPoolConn creates a new connection through the circuit breaker if it is set,
+// so that it fails fast when the circuit of the address is open
+func (p *Pool) newPoolConn() (*PoolConn, error) {
+	if p.CircuitBreaker == nil {
+		return NewPoolConnWithPool(p, p.Address, p.RoundTripper)
+	}
+
+	var pc *PoolConn
+	err := p.CircuitBreaker.Execute(p.Address, func() error {
+		var err error
+		pc, err = NewPoolConnWithPool(p, p.Address, p.RoundTripper)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return pc, nil
 }
 
 // get gets a connection from the pool and validate it,
@@ -444,6 +636,7 @@ func (p *Pool) get() (*PoolConn, error) {
 				return pc, nil
 			}
 
+			p.stats.IncInvalid(false)
 			err := pc.Disconnect()
 			if err != nil {
 				log.Warnf("disconnecting invalid connection failed when getting connection from the pool. error:\n%+v", err)
@@ -452,10 +645,11 @@ func (p *Pool) get() (*PoolConn, error) {
 	}
 
 	// there is no valid connection in the free connection channel, therefore create a new one
-	pc, err := NewPoolConnWithPool(p, p.Address, p.RoundTripper)
+	pc, err := p.newPoolConn()
 	if err != nil {
 		return nil, err
 	}
+	p.stats.IncCreated()
 
 	p.usedConnections++
 	return pc, nil
@@ -529,6 +723,7 @@ func (p *Pool) keepAlive(num int) error {
 					continue
 				}
 
+				p.stats.IncInvalid(true)
 				err := pc.Disconnect()
 				if err != nil {
 					merr = multierror.Append(merr, err)
@@ -2,15 +2,18 @@ package rabbitmq
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pingcap/errors"
 
+	"github.com/romberli/go-util/common"
 	"github.com/romberli/go-util/constant"
 )
 
 const (
 	DefaultUnlimitedWaitTime   = -1 // seconds
 	DefaultUnlimitedRetryCount = -1
+	DefaultDelayTime           = 5 // milliseconds
 )
 
 type Config struct {
@@ -68,6 +71,12 @@ type PoolConfig struct {
 	MaxWaitTime        int
 	MaxRetryCount      int
 	KeepAliveInterval  int
+	// RetryOption is the retry policy when creating new connections failed, if it is nil,
+	// the connections are retried at most MaxRetryCount times with fixed delay time
+	RetryOption *common.RetryOption
+	// CircuitBreaker makes creating new connections fail fast when the address is unavailable, it could be nil,
+	// the circuit breaker could be shared by multiple pools, as the circuits are kept by the addresses
+	CircuitBreaker *common.CircuitBreaker
 }
 
 // NewPoolConfig returns a new PoolConfig
@@ -135,6 +144,13 @@ func (pc *PoolConfig) Validate() error {
 	if pc.MaxRetryCount < DefaultUnlimitedRetryCount {
 		return errors.New("maximum retry count argument should not be smaller than -1")
 	}
+	// validate RetryOption
+	if pc.RetryOption != nil {
+		err := pc.RetryOption.Validate()
+		if err != nil {
+			return err
+		}
+	}
 	// validate KeepAliveInterval
 	if pc.KeepAliveInterval <= constant.ZeroInt {
 		return errors.New("keep alive interval argument should be larger than 0")
@@ -143,6 +159,16 @@ func (pc *PoolConfig) Validate() error {
 	return nil
 }
 
+// GetRetryOption returns the retry policy when creating new connections failed
+func (pc *PoolConfig) GetRetryOption() *common.RetryOption {
+	if pc.RetryOption != nil {
+		return pc.RetryOption
+	}
+
+	// the wait time is controlled by the context of getting connections
+	return common.NewRetryOption(pc.MaxRetryCount, common.UnlimitedWaitTime, time.Duration(DefaultDelayTime)*time.Millisecond, nil)
+}
+
 // Clone returns a new PoolConfig with same values
 func (pc *PoolConfig) Clone() *PoolConfig {
 	return &PoolConfig{
@@ -154,5 +180,7 @@ func (pc *PoolConfig) Clone() *PoolConfig {
 		MaxWaitTime:        pc.MaxWaitTime,
 		MaxRetryCount:      pc.MaxRetryCount,
 		KeepAliveInterval:  pc.KeepAliveInterval,
+		RetryOption:        pc.RetryOption,
+		CircuitBreaker:     pc.CircuitBreaker,
 	}
 }
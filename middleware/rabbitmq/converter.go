@@ -0,0 +1,394 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	DefaultBatchSize = 1000
+
+	batchKindReplace = "replace"
+	batchKindUpsert  = "upsert"
+	batchKindDelete  = "delete"
+)
+
+// SQLConverter converts the messages to the sql statements of the dialect,
+// the rows of the consecutive messages which belong to the same table and have the same sql type will be batched
+type SQLConverter struct {
+	Dialect Dialect
+	// IgnoreDDL means if the ddl messages should be ignored, if it's false, the ddl statements will be passed through the dialect
+	IgnoreDDL bool
+	// UseReplace means if the insert and update messages should be converted to the statements which replace the whole rows,
+	// otherwise, the insert messages will be converted to upsert statements, and the update messages will be converted to update statements
+	UseReplace bool
+	// BatchSize is the maximum number of the rows in a statement
+	BatchSize int
+}
+
+// NewSQLConverter returns a new *SQLConverter
+func NewSQLConverter(dialect Dialect, ignoreDDL, useReplace bool, batchSize int) *SQLConverter {
+	return &SQLConverter{
+		Dialect:    dialect,
+		IgnoreDDL:  ignoreDDL,
+		UseReplace: useReplace,
+		BatchSize:  batchSize,
+	}
+}
+
+// NewSQLConverterWithDefault returns a new *SQLConverter with mysql dialect and default batch size
+func NewSQLConverterWithDefault(ignoreDDL, useReplace bool) *SQLConverter {
+	return NewSQLConverter(NewMySQLDialect(), ignoreDDL, useReplace, DefaultBatchSize)
+}
+
+// Convert converts the messages to the sql statements, the order of the statements follows the order of the messages,
+// each element of the result is a map, key is the sql statement, value is the arguments of the statement
+func (sc *SQLConverter) Convert(messages ...*Message) ([]map[string][]interface{}, error) {
+	if sc.Dialect == nil {
+		return nil, errors.New("dialect should not be nil")
+	}
+
+	var (
+		statements []map[string][]interface{}
+		batch      *sqlBatch
+	)
+
+	flush := func() error {
+		if batch == nil {
+			return nil
+		}
+		batchStatements, err := batch.build(sc.Dialect, sc.getBatchSize())
+		if err != nil {
+			return err
+		}
+		statements = append(statements, batchStatements...)
+		batch = nil
+
+		return nil
+	}
+	add := func(m *Message, kind string, columnNames []string, row map[string]interface{}) error {
+		if batch != nil && !batch.accept(m, kind, columnNames) {
+			err := flush()
+			if err != nil {
+				return err
+			}
+		}
+		if batch == nil {
+			batch = newSQLBatch(m, kind, columnNames)
+		}
+		batch.add(row)
+
+		return nil
+	}
+
+	for _, m := range messages {
+		if m.GetIsDDL() {
+			err := flush()
+			if err != nil {
+				return nil, err
+			}
+			if sc.IgnoreDDL {
+				continue
+			}
+			sqlList, err := sc.Dialect.DDL(m.GetDBName(), m.GetSQL())
+			if err != nil {
+				return nil, err
+			}
+			for _, sql := range sqlList {
+				statements = append(statements, map[string][]interface{}{sql: nil})
+			}
+			continue
+		}
+
+		kind, rows, err := sc.getBatchKind(m)
+		if err != nil {
+			return nil, err
+		}
+		if kind == constant.EmptyString {
+			// convert the update message to update statements row by row
+			err = flush()
+			if err != nil {
+				return nil, err
+			}
+			updateStatements, err := sc.convertToUpdateSQL(m)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, updateStatements...)
+			continue
+		}
+
+		if m.GetSQLType() == SQLTypeUpdate {
+			// the rows will be inserted with the new primary keys, so the rows of the old primary keys must be deleted first
+			for _, row := range getPKChangedOldRows(m) {
+				err = add(m, batchKindDelete, nil, row)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, row := range rows {
+			var columnNames []string
+			if kind != batchKindDelete {
+				columnNames = getRowColumnNames(row)
+			}
+			err = add(m, kind, columnNames, row)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return statements, nil
+}
+
+// getBatchSize returns the batch size, if it is not larger than 0, the default batch size will be used
+func (sc *SQLConverter) getBatchSize() int {
+	if sc.BatchSize <= constant.ZeroInt {
+		return DefaultBatchSize
+	}
+
+	return sc.BatchSize
+}
+
+// getBatchKind returns the batch kind and the rows of the message,
+// if the message should not be batched, it returns empty string
+func (sc *SQLConverter) getBatchKind(m *Message) (string, []map[string]interface{}, error) {
+	rows := m.GetData()
+	if m.GetSQLType() == SQLTypeDelete && len(rows) == constant.ZeroInt {
+		// some producers put the deleted rows in the old
+		rows = m.GetOld()
+	}
+	if len(rows) == constant.ZeroInt {
+		return constant.EmptyString, nil, errors.Errorf("data should not be empty. table: %s.%s", m.GetDBName(), m.GetTableName())
+	}
+
+	switch m.GetSQLType() {
+	case SQLTypeInsert:
+		if sc.UseReplace {
+			return batchKindReplace, rows, nil
+		}
+		return batchKindUpsert, rows, nil
+	case SQLTypeUpdate:
+		if sc.UseReplace {
+			return batchKindReplace, rows, nil
+		}
+		if !sc.Dialect.SupportsUpdate() {
+			// the rows are complete, upsert them as the insert messages
+			return batchKindUpsert, rows, nil
+		}
+		return constant.EmptyString, rows, nil
+	case SQLTypeDelete:
+		if len(m.GetPKNames()) == constant.ZeroInt {
+			return constant.EmptyString, nil, errors.Errorf("table does not have a primary key. table: %s.%s", m.GetDBName(), m.GetTableName())
+		}
+		return batchKindDelete, rows, nil
+	default:
+		return constant.EmptyString, nil, errors.Errorf("sql type must be one of [INSERT, UPDATE, DELETE], %s is not supported", m.GetSQLType())
+	}
+}
+
+// convertToUpdateSQL converts the update message to update statements, each row will be converted to a statement,
+// only the changed columns will be updated
+func (sc *SQLConverter) convertToUpdateSQL(m *Message) ([]map[string][]interface{}, error) {
+	lenData := len(m.GetData())
+	lenOld := len(m.GetOld())
+	if lenData != lenOld {
+		return nil, errors.Errorf("the lengths of the data and old are not the same. table: %s.%s, data: %d, old: %d",
+			m.GetDBName(), m.GetTableName(), lenData, lenOld)
+	}
+	if len(m.GetPKNames()) == constant.ZeroInt {
+		return nil, errors.Errorf("table does not have a primary key. table: %s.%s", m.GetDBName(), m.GetTableName())
+	}
+
+	var statements []map[string][]interface{}
+	for i, old := range m.GetOld() {
+		if len(old) == constant.ZeroInt {
+			// nothing changed
+			continue
+		}
+
+		columnNames := make([]string, constant.ZeroInt, len(old))
+		for columnName := range old {
+			columnNames = append(columnNames, columnName)
+		}
+		sort.Strings(columnNames)
+
+		sql, err := sc.Dialect.Update(m.GetDBName(), m.GetTableName(), columnNames, m.GetPKNames())
+		if err != nil {
+			return nil, err
+		}
+
+		data := m.GetData()[i]
+		values := make([]interface{}, constant.ZeroInt, len(columnNames)+len(m.GetPKNames()))
+		for _, columnName := range columnNames {
+			values = append(values, data[columnName])
+		}
+		for _, pkName := range m.GetPKNames() {
+			// if the primary key had been changed, use the old value to locate the row
+			pkValue, ok := old[pkName]
+			if !ok {
+				pkValue = data[pkName]
+			}
+			values = append(values, pkValue)
+		}
+
+		statements = append(statements, map[string][]interface{}{sql: values})
+	}
+
+	return statements, nil
+}
+
+// getRowColumnNames returns the sorted column names of the row, only the columns present in the row are returned,
+// as the row may not contain all the columns of the table if the binlog row image is minimal
+func getRowColumnNames(row map[string]interface{}) []string {
+	columnNames := make([]string, constant.ZeroInt, len(row))
+	for columnName := range row {
+		columnNames = append(columnNames, columnName)
+	}
+	sort.Strings(columnNames)
+
+	return columnNames
+}
+
+// getPKChangedOldRows returns the primary keys of the old rows of the update message whose primary keys had been changed
+func getPKChangedOldRows(m *Message) []map[string]interface{} {
+	pkNames := m.GetPKNames()
+	if len(pkNames) == constant.ZeroInt {
+		return nil
+	}
+
+	var rows []map[string]interface{}
+	for i, old := range m.GetOld() {
+		if i >= len(m.GetData()) {
+			break
+		}
+
+		data := m.GetData()[i]
+		row := make(map[string]interface{}, len(pkNames))
+		isChanged := false
+		for _, pkName := range pkNames {
+			pkValue, ok := old[pkName]
+			if ok && fmt.Sprintf("%v", pkValue) != fmt.Sprintf("%v", data[pkName]) {
+				isChanged = true
+			} else {
+				pkValue = data[pkName]
+			}
+			row[pkName] = pkValue
+		}
+		if isChanged {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// sqlBatch is the rows of the consecutive messages which could be converted to the same statement
+type sqlBatch struct {
+	dbName      string
+	tableName   string
+	kind        string
+	columnNames []string
+	pkNames     []string
+	rows        []map[string]interface{}
+	keys        map[string]int
+}
+
+// newSQLBatch returns a new *sqlBatch
+func newSQLBatch(m *Message, kind string, columnNames []string) *sqlBatch {
+	return &sqlBatch{
+		dbName:      m.GetDBName(),
+		tableName:   m.GetTableName(),
+		kind:        kind,
+		columnNames: columnNames,
+		pkNames:     m.GetPKNames(),
+		keys:        make(map[string]int),
+	}
+}
+
+// accept returns if the rows of the message could be added to the batch
+func (sb *sqlBatch) accept(m *Message, kind string, columnNames []string) bool {
+	return sb.dbName == m.GetDBName() && sb.tableName == m.GetTableName() && sb.kind == kind &&
+		strings.Join(sb.columnNames, constant.CommaString) == strings.Join(columnNames, constant.CommaString) &&
+		strings.Join(sb.pkNames, constant.CommaString) == strings.Join(m.GetPKNames(), constant.CommaString)
+}
+
+// add adds the row to the batch, if the row has the same primary key with a row in the batch,
+// it overwrites the existing one, as some databases do not allow a statement to affect a row twice
+func (sb *sqlBatch) add(row map[string]interface{}) {
+	if len(sb.pkNames) == constant.ZeroInt {
+		sb.rows = append(sb.rows, row)
+		return
+	}
+
+	key := sb.getKey(row)
+	index, ok := sb.keys[key]
+	if ok {
+		sb.rows[index] = row
+		return
+	}
+	sb.keys[key] = len(sb.rows)
+	sb.rows = append(sb.rows, row)
+}
+
+// getKey returns the primary key of the row as a string
+func (sb *sqlBatch) getKey(row map[string]interface{}) string {
+	values := make([]interface{}, len(sb.pkNames))
+	for i, pkName := range sb.pkNames {
+		values[i] = row[pkName]
+	}
+
+	return fmt.Sprintf("%v", values)
+}
+
+// build builds the statements of the batch, each statement contains at most batchSize rows
+func (sb *sqlBatch) build(dialect Dialect, batchSize int) ([]map[string][]interface{}, error) {
+	var statements []map[string][]interface{}
+	for start := constant.ZeroInt; start < len(sb.rows); start += batchSize {
+		end := start + batchSize
+		if end > len(sb.rows) {
+			end = len(sb.rows)
+		}
+		rows := sb.rows[start:end]
+
+		var (
+			sql         string
+			err         error
+			columnNames = sb.columnNames
+		)
+		switch sb.kind {
+		case batchKindReplace:
+			sql, err = dialect.Replace(sb.dbName, sb.tableName, columnNames, sb.pkNames, len(rows))
+		case batchKindUpsert:
+			sql, err = dialect.Upsert(sb.dbName, sb.tableName, columnNames, sb.pkNames, len(rows))
+		case batchKindDelete:
+			columnNames = sb.pkNames
+			sql, err = dialect.Delete(sb.dbName, sb.tableName, columnNames, len(rows))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]interface{}, constant.ZeroInt, len(rows)*len(columnNames))
+		for _, row := range rows {
+			for _, columnName := range columnNames {
+				values = append(values, row[columnName])
+			}
+		}
+
+		statements = append(statements, map[string][]interface{}{sql: values})
+	}
+
+	return statements, nil
+}
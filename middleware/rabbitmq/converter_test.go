@@ -0,0 +1,117 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/common"
+)
+
+func newTestConverterMessages() []*Message {
+	columns := map[string]string{"id": "int", "name": "varchar(100)"}
+	pkNames := []string{"id"}
+
+	return []*Message{
+		NewMessage(1, SQLTypeInsert, false, "test_db", "t01", pkNames, columns,
+			[]map[string]interface{}{{"id": 1, "name": "a"}, {"id": 2, "name": "b"}}, nil),
+		NewMessage(2, SQLTypeInsert, false, "test_db", "t01", pkNames, columns,
+			[]map[string]interface{}{{"id": 2, "name": "bb"}, {"id": 3, "name": "c"}}, nil),
+		NewMessage(3, SQLTypeUpdate, false, "test_db", "t01", pkNames, columns,
+			[]map[string]interface{}{{"id": 3, "name": "cc"}}, []map[string]interface{}{{"name": "c"}}),
+		NewMessage(4, SQLTypeDelete, false, "test_db", "t01", pkNames, columns,
+			[]map[string]interface{}{{"id": 1, "name": "a"}}, nil),
+		NewMessage(5, SQLTypeDelete, false, "test_db", "t01", pkNames, columns,
+			[]map[string]interface{}{{"id": 2, "name": "bb"}}, nil),
+	}
+}
+
+func TestSQLConverter_Convert(t *testing.T) {
+	asst := assert.New(t)
+
+	messages := newTestConverterMessages()
+
+	// mysql
+	statements, err := NewSQLConverterWithDefault(false, false).Convert(messages...)
+	asst.Nil(err, common.CombineMessageWithError("test Convert() failed", err))
+	asst.Equal(3, len(statements), "test Convert() failed")
+	asst.Equal([]interface{}{1, "a", 2, "bb", 3, "c"},
+		statements[0]["INSERT INTO `test_db`.`t01`(`id`,`name`) VALUES (?,?),(?,?),(?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`) ;"],
+		"test Convert() failed")
+	asst.Equal([]interface{}{"cc", 3}, statements[1]["UPDATE `test_db`.`t01` SET `name`=? WHERE `id`=? ;"], "test Convert() failed")
+	asst.Equal([]interface{}{1, 2}, statements[2]["DELETE FROM `test_db`.`t01` WHERE `id` IN (?,?) ;"], "test Convert() failed")
+
+	// mysql with replace, the insert and update messages are batched together
+	statements, err = NewSQLConverterWithDefault(false, true).Convert(messages...)
+	asst.Nil(err, common.CombineMessageWithError("test Convert() failed", err))
+	asst.Equal(2, len(statements), "test Convert() failed")
+	asst.Equal([]interface{}{1, "a", 2, "bb", 3, "cc"},
+		statements[0]["REPLACE INTO `test_db`.`t01`(`id`,`name`) VALUES (?,?),(?,?),(?,?) ;"], "test Convert() failed")
+
+	// batch size
+	statements, err = NewSQLConverter(NewMySQLDialect(), false, true, 2).Convert(messages...)
+	asst.Nil(err, common.CombineMessageWithError("test Convert() failed", err))
+	asst.Equal(3, len(statements), "test Convert() failed")
+
+	// postgresql
+	statements, err = NewSQLConverter(NewPostgreSQLDialect(), false, false, DefaultBatchSize).Convert(messages...)
+	asst.Nil(err, common.CombineMessageWithError("test Convert() failed", err))
+	asst.Equal(3, len(statements), "test Convert() failed")
+	asst.Contains(statements[0], `INSERT INTO "test_db"."t01"("id","name") VALUES ($1,$2),($3,$4),($5,$6) ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name"`,
+		"test Convert() failed")
+	asst.Contains(statements[1], `UPDATE "test_db"."t01" SET "name"=$1 WHERE "id"=$2`, "test Convert() failed")
+	asst.Contains(statements[2], `DELETE FROM "test_db"."t01" WHERE "id" IN ($1,$2)`, "test Convert() failed")
+
+	// clickhouse, the update messages are converted to inserts
+	statements, err = NewSQLConverter(NewClickHouseDialect(), false, false, DefaultBatchSize).Convert(messages...)
+	asst.Nil(err, common.CombineMessageWithError("test Convert() failed", err))
+	asst.Equal(2, len(statements), "test Convert() failed")
+	asst.Contains(statements[0], "INSERT INTO `test_db`.`t01`(`id`,`name`) VALUES (?,?),(?,?),(?,?)", "test Convert() failed")
+	asst.Contains(statements[1], "ALTER TABLE `test_db`.`t01` DELETE WHERE `id` IN (?,?)", "test Convert() failed")
+
+	// clickhouse, the row of the old primary key is deleted before inserting the row of the new primary key,
+	// and only the columns present in the rows are inserted
+	columns := map[string]string{"id": "int", "name": "varchar(100)", "col1": "int"}
+	messages = []*Message{
+		NewMessage(6, SQLTypeUpdate, false, "test_db", "t01", []string{"id"}, columns,
+			[]map[string]interface{}{{"id": 4, "name": "d"}}, []map[string]interface{}{{"id": 3}}),
+	}
+	statements, err = NewSQLConverter(NewClickHouseDialect(), false, false, DefaultBatchSize).Convert(messages...)
+	asst.Nil(err, common.CombineMessageWithError("test Convert() failed", err))
+	asst.Equal(2, len(statements), "test Convert() failed")
+	asst.Equal([]interface{}{3}, statements[0]["ALTER TABLE `test_db`.`t01` DELETE WHERE `id` IN (?)"], "test Convert() failed")
+	asst.Equal([]interface{}{4, "d"}, statements[1]["INSERT INTO `test_db`.`t01`(`id`,`name`) VALUES (?,?)"], "test Convert() failed")
+}
+
+func TestDialect_QuoteIdentifier(t *testing.T) {
+	asst := assert.New(t)
+
+	asst.Equal("`a``b`", NewMySQLDialect().QuoteIdentifier("a`b"), "test QuoteIdentifier() failed")
+	asst.Equal(`"a""b"`, NewPostgreSQLDialect().QuoteIdentifier(`a"b`), "test QuoteIdentifier() failed")
+
+	_, err := NewDialect("oracle")
+	asst.NotNil(err, "test NewDialect() failed")
+}
+
+func TestDialect_DDL(t *testing.T) {
+	asst := assert.New(t)
+
+	sql := "alter table t01 add column col3 int"
+	// mysql
+	sqlList, err := NewMySQLDialect().DDL("test_db", sql)
+	asst.Nil(err, common.CombineMessageWithError("test DDL() failed", err))
+	asst.Equal([]string{"USE `test_db` ;", sql}, sqlList, "test DDL() failed")
+	sqlList, err = NewMySQLDialect().DDL("", sql)
+	asst.Nil(err, common.CombineMessageWithError("test DDL() failed", err))
+	asst.Equal([]string{sql}, sqlList, "test DDL() failed")
+	// postgresql
+	sqlList, err = NewPostgreSQLDialect().DDL("test_db", sql)
+	asst.Nil(err, common.CombineMessageWithError("test DDL() failed", err))
+	asst.Equal([]string{`SET search_path TO "test_db"`, sql}, sqlList, "test DDL() failed")
+	// clickhouse, only the table names without database name are qualified
+	sqlList, err = NewClickHouseDialect().DDL("test_db", "rename table t01 to t02, other_db.t03 to t04")
+	asst.Nil(err, common.CombineMessageWithError("test DDL() failed", err))
+	asst.Equal([]string{"RENAME TABLE `test_db`.`t01` TO `test_db`.`t02`, `other_db`.`t03` TO `test_db`.`t04`"}, sqlList, "test DDL() failed")
+	_, err = NewClickHouseDialect().DDL("test_db", "not a ddl statement")
+	asst.NotNil(err, "test DDL() failed")
+}
@@ -0,0 +1,364 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware/sql/parser"
+)
+
+const (
+	DialectMySQL      = "mysql"
+	DialectPostgreSQL = "postgresql"
+	DialectClickHouse = "clickhouse"
+)
+
+// Dialect is the sql dialect of the target database which the messages will be replayed into
+type Dialect interface {
+	// Name returns the name of the dialect
+	Name() string
+	// QuoteIdentifier quotes the identifier, such as the database name, table name and column name
+	QuoteIdentifier(identifier string) string
+	// Placeholder returns the placeholder of the n-th argument of the statement, n starts from 1
+	Placeholder(n int) string
+	// Replace returns the statement which writes the rows and overwrites the existing rows with the same primary key,
+	// the arguments are the values of the columns row by row
+	Replace(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error)
+	// Upsert returns the statement which inserts the rows and updates the non-primary key columns of the existing rows,
+	// the arguments are the values of the columns row by row
+	Upsert(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error)
+	// SupportsUpdate returns if the dialect supports updating the rows in place,
+	// if it returns false, the updated rows will be upserted instead
+	SupportsUpdate() bool
+	// Update returns the statement which updates the columns of a row by the primary key,
+	// the arguments are the values of the columns, then the values of the primary key columns
+	Update(dbName, tableName string, columnNames, pkNames []string) (string, error)
+	// Delete returns the statement which deletes the rows by the primary key,
+	// the arguments are the values of the primary key columns row by row
+	Delete(dbName, tableName string, pkNames []string, rowNum int) (string, error)
+	// DDL returns the statements which replay the ddl statement in the given database in order,
+	// the unqualified table names in the ddl statement belong to the given database,
+	// if it returns no statement, the ddl will be ignored
+	DDL(dbName, sql string) ([]string, error)
+}
+
+// NewDialect returns the dialect of given name, it supports mysql, postgresql and clickhouse
+func NewDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case DialectMySQL:
+		return NewMySQLDialect(), nil
+	case DialectPostgreSQL:
+		return NewPostgreSQLDialect(), nil
+	case DialectClickHouse:
+		return NewClickHouseDialect(), nil
+	default:
+		return nil, errors.Errorf("dialect must be one of [%s, %s, %s], %s is not supported",
+			DialectMySQL, DialectPostgreSQL, DialectClickHouse, name)
+	}
+}
+
+// MySQLDialect is the dialect of mysql
+type MySQLDialect struct{}
+
+// NewMySQLDialect returns a new *MySQLDialect
+func NewMySQLDialect() *MySQLDialect {
+	return &MySQLDialect{}
+}
+
+// Name returns the name of the dialect
+func (md *MySQLDialect) Name() string {
+	return DialectMySQL
+}
+
+// QuoteIdentifier quotes the identifier with back ticks
+func (md *MySQLDialect) QuoteIdentifier(identifier string) string {
+	return quoteIdentifier(identifier, constant.BackTickString)
+}
+
+// Placeholder returns the placeholder of the n-th argument of the statement
+func (md *MySQLDialect) Placeholder(n int) string {
+	return constant.QuestionMarkString
+}
+
+// Replace returns the statement like "REPLACE INTO ... VALUES ..."
+func (md *MySQLDialect) Replace(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error) {
+	return fmt.Sprintf("REPLACE INTO %s(%s) VALUES %s ;", getQualifiedTableName(md, dbName, tableName),
+		joinIdentifiers(md, columnNames), getValuesPlaceholders(md, len(columnNames), rowNum)), nil
+}
+
+// Upsert returns the statement like "INSERT INTO ... VALUES ... ON DUPLICATE KEY UPDATE ...",
+// if all the columns are primary key columns, it returns the statement like "INSERT IGNORE INTO ... VALUES ..."
+func (md *MySQLDialect) Upsert(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error) {
+	var assignments []string
+	for _, columnName := range columnNames {
+		if !common.ElementInSlice(pkNames, columnName) {
+			quoted := md.QuoteIdentifier(columnName)
+			assignments = append(assignments, fmt.Sprintf("%s=VALUES(%s)", quoted, quoted))
+		}
+	}
+
+	tableStr := getQualifiedTableName(md, dbName, tableName)
+	columnsStr := joinIdentifiers(md, columnNames)
+	valuesStr := getValuesPlaceholders(md, len(columnNames), rowNum)
+	if len(assignments) == constant.ZeroInt {
+		return fmt.Sprintf("INSERT IGNORE INTO %s(%s) VALUES %s ;", tableStr, columnsStr, valuesStr), nil
+	}
+
+	return fmt.Sprintf("INSERT INTO %s(%s) VALUES %s ON DUPLICATE KEY UPDATE %s ;",
+		tableStr, columnsStr, valuesStr, strings.Join(assignments, constant.CommaString)), nil
+}
+
+// SupportsUpdate returns true
+func (md *MySQLDialect) SupportsUpdate() bool {
+	return true
+}
+
+// Update returns the statement like "UPDATE ... SET ... WHERE ..."
+func (md *MySQLDialect) Update(dbName, tableName string, columnNames, pkNames []string) (string, error) {
+	setStr, whereStr := getUpdateClauses(md, columnNames, pkNames)
+
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s ;", getQualifiedTableName(md, dbName, tableName), setStr, whereStr), nil
+}
+
+// Delete returns the statement like "DELETE FROM ... WHERE ... IN (...)"
+func (md *MySQLDialect) Delete(dbName, tableName string, pkNames []string, rowNum int) (string, error) {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s ;", getQualifiedTableName(md, dbName, tableName),
+		getPKInCondition(md, pkNames, rowNum)), nil
+}
+
+// DDL returns the statement like "USE ..." and the original ddl statement
+func (md *MySQLDialect) DDL(dbName, sql string) ([]string, error) {
+	if dbName == constant.EmptyString {
+		return []string{sql}, nil
+	}
+
+	return []string{fmt.Sprintf("USE %s ;", md.QuoteIdentifier(dbName)), sql}, nil
+}
+
+// PostgreSQLDialect is the dialect of postgresql and the compatible databases
+type PostgreSQLDialect struct{}
+
+// NewPostgreSQLDialect returns a new *PostgreSQLDialect
+func NewPostgreSQLDialect() *PostgreSQLDialect {
+	return &PostgreSQLDialect{}
+}
+
+// Name returns the name of the dialect
+func (pd *PostgreSQLDialect) Name() string {
+	return DialectPostgreSQL
+}
+
+// QuoteIdentifier quotes the identifier with double quotes
+func (pd *PostgreSQLDialect) QuoteIdentifier(identifier string) string {
+	return quoteIdentifier(identifier, constant.DoubleQuoteString)
+}
+
+// Placeholder returns the placeholder of the n-th argument of the statement, for example: $1
+func (pd *PostgreSQLDialect) Placeholder(n int) string {
+	return constant.DollarString + strconv.Itoa(n)
+}
+
+// Replace returns the statement like "INSERT INTO ... VALUES ... ON CONFLICT (...) DO UPDATE SET ...",
+// as the rows are complete, it is the same as Upsert()
+func (pd *PostgreSQLDialect) Replace(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error) {
+	return pd.Upsert(dbName, tableName, columnNames, pkNames, rowNum)
+}
+
+// Upsert returns the statement like "INSERT INTO ... VALUES ... ON CONFLICT (...) DO UPDATE SET ...",
+// if all the columns are primary key columns, it returns the statement like "INSERT INTO ... VALUES ... ON CONFLICT (...) DO NOTHING"
+func (pd *PostgreSQLDialect) Upsert(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error) {
+	if len(pkNames) == constant.ZeroInt {
+		return constant.EmptyString, errors.Errorf("table does not have a primary key, could not do upsert. table: %s", tableName)
+	}
+
+	var assignments []string
+	for _, columnName := range columnNames {
+		if !common.ElementInSlice(pkNames, columnName) {
+			quoted := pd.QuoteIdentifier(columnName)
+			assignments = append(assignments, fmt.Sprintf("%s=EXCLUDED.%s", quoted, quoted))
+		}
+	}
+
+	action := "DO NOTHING"
+	if len(assignments) > constant.ZeroInt {
+		action = "DO UPDATE SET " + strings.Join(assignments, constant.CommaString)
+	}
+
+	return fmt.Sprintf("INSERT INTO %s(%s) VALUES %s ON CONFLICT (%s) %s", getQualifiedTableName(pd, dbName, tableName),
+		joinIdentifiers(pd, columnNames), getValuesPlaceholders(pd, len(columnNames), rowNum),
+		joinIdentifiers(pd, pkNames), action), nil
+}
+
+// SupportsUpdate returns true
+func (pd *PostgreSQLDialect) SupportsUpdate() bool {
+	return true
+}
+
+// Update returns the statement like "UPDATE ... SET ... WHERE ..."
+func (pd *PostgreSQLDialect) Update(dbName, tableName string, columnNames, pkNames []string) (string, error) {
+	setStr, whereStr := getUpdateClauses(pd, columnNames, pkNames)
+
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", getQualifiedTableName(pd, dbName, tableName), setStr, whereStr), nil
+}
+
+// Delete returns the statement like "DELETE FROM ... WHERE ... IN (...)"
+func (pd *PostgreSQLDialect) Delete(dbName, tableName string, pkNames []string, rowNum int) (string, error) {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", getQualifiedTableName(pd, dbName, tableName),
+		getPKInCondition(pd, pkNames, rowNum)), nil
+}
+
+// DDL returns the statement like "SET search_path TO ..." and the original ddl statement,
+// the database name of mysql is mapped to the schema of postgresql, as getQualifiedTableName() does
+func (pd *PostgreSQLDialect) DDL(dbName, sql string) ([]string, error) {
+	if dbName == constant.EmptyString {
+		return []string{sql}, nil
+	}
+
+	return []string{fmt.Sprintf("SET search_path TO %s", pd.QuoteIdentifier(dbName)), sql}, nil
+}
+
+// ClickHouseDialect is the dialect of clickhouse, the target tables are expected to be ReplacingMergeTree tables
+// ordered by the primary key, so that the rows with the same primary key will be deduplicated by the latest version
+type ClickHouseDialect struct{}
+
+// NewClickHouseDialect returns a new *ClickHouseDialect
+func NewClickHouseDialect() *ClickHouseDialect {
+	return &ClickHouseDialect{}
+}
+
+// Name returns the name of the dialect
+func (cd *ClickHouseDialect) Name() string {
+	return DialectClickHouse
+}
+
+// QuoteIdentifier quotes the identifier with back ticks
+func (cd *ClickHouseDialect) QuoteIdentifier(identifier string) string {
+	return quoteIdentifier(identifier, constant.BackTickString)
+}
+
+// Placeholder returns the placeholder of the n-th argument of the statement
+func (cd *ClickHouseDialect) Placeholder(n int) string {
+	return constant.QuestionMarkString
+}
+
+// Replace returns the statement like "INSERT INTO ... VALUES ...",
+// the ReplacingMergeTree engine will keep the latest version of the rows
+func (cd *ClickHouseDialect) Replace(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error) {
+	return fmt.Sprintf("INSERT INTO %s(%s) VALUES %s", getQualifiedTableName(cd, dbName, tableName),
+		joinIdentifiers(cd, columnNames), getValuesPlaceholders(cd, len(columnNames), rowNum)), nil
+}
+
+// Upsert returns the statement like "INSERT INTO ... VALUES ...", it is the same as Replace()
+func (cd *ClickHouseDialect) Upsert(dbName, tableName string, columnNames, pkNames []string, rowNum int) (string, error) {
+	return cd.Replace(dbName, tableName, columnNames, pkNames, rowNum)
+}
+
+// SupportsUpdate returns false, the updated rows will be inserted as new versions
+func (cd *ClickHouseDialect) SupportsUpdate() bool {
+	return false
+}
+
+// Update returns an error, as the mutations are too heavy to replay the updates row by row
+func (cd *ClickHouseDialect) Update(dbName, tableName string, columnNames, pkNames []string) (string, error) {
+	return constant.EmptyString, errors.New("clickhouse dialect does not support update statement, use Replace() instead")
+}
+
+// Delete returns the statement like "ALTER TABLE ... DELETE WHERE ... IN (...)"
+func (cd *ClickHouseDialect) Delete(dbName, tableName string, pkNames []string, rowNum int) (string, error) {
+	return fmt.Sprintf("ALTER TABLE %s DELETE WHERE %s", getQualifiedTableName(cd, dbName, tableName),
+		getPKInCondition(cd, pkNames, rowNum)), nil
+}
+
+// DDL returns the ddl statement in which the table names without database name are prefixed with given database name,
+// as the session state like "USE ..." is not kept between the statements by the clickhouse drivers
+func (cd *ClickHouseDialect) DDL(dbName, sql string) ([]string, error) {
+	if dbName == constant.EmptyString {
+		return []string{sql}, nil
+	}
+
+	sqlList, err := parser.NewParserWithDefault().QualifyTableNames(sql, dbName)
+	if err != nil {
+		return nil, errors.Annotatef(err, "qualify table names of ddl statement failed. db: %s, sql: %s", dbName, sql)
+	}
+
+	return sqlList, nil
+}
+
+// quoteIdentifier quotes the identifier with given quote, the quotes inside the identifier will be doubled
+func quoteIdentifier(identifier, quote string) string {
+	return quote + strings.ReplaceAll(identifier, quote, quote+quote) + quote
+}
+
+// getQualifiedTableName returns the quoted table name with the database name
+func getQualifiedTableName(d Dialect, dbName, tableName string) string {
+	if dbName == constant.EmptyString {
+		return d.QuoteIdentifier(tableName)
+	}
+
+	return d.QuoteIdentifier(dbName) + constant.DotString + d.QuoteIdentifier(tableName)
+}
+
+// joinIdentifiers quotes the identifiers and joins them with comma
+func joinIdentifiers(d Dialect, identifiers []string) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = d.QuoteIdentifier(identifier)
+	}
+
+	return strings.Join(quoted, constant.CommaString)
+}
+
+// getValuesPlaceholders returns the placeholders of the rows, for example: (?,?),(?,?)
+func getValuesPlaceholders(d Dialect, columnNum, rowNum int) string {
+	rows := make([]string, rowNum)
+	for i := constant.ZeroInt; i < rowNum; i++ {
+		rows[i] = getTuplePlaceholders(d, i*columnNum+constant.OneInt, columnNum)
+	}
+
+	return strings.Join(rows, constant.CommaString)
+}
+
+// getTuplePlaceholders returns the placeholders of a tuple which starts from the n-th argument, for example: (?,?)
+func getTuplePlaceholders(d Dialect, start, num int) string {
+	placeholders := make([]string, num)
+	for i := constant.ZeroInt; i < num; i++ {
+		placeholders[i] = d.Placeholder(start + i)
+	}
+
+	return constant.LeftParenthesisString + strings.Join(placeholders, constant.CommaString) + constant.RightParenthesisString
+}
+
+// getUpdateClauses returns the set clause and the where clause of the update statement
+func getUpdateClauses(d Dialect, columnNames, pkNames []string) (string, string) {
+	setList := make([]string, len(columnNames))
+	for i, columnName := range columnNames {
+		setList[i] = d.QuoteIdentifier(columnName) + constant.EqualString + d.Placeholder(i+constant.OneInt)
+	}
+
+	whereList := make([]string, len(pkNames))
+	for i, pkName := range pkNames {
+		whereList[i] = d.QuoteIdentifier(pkName) + constant.EqualString + d.Placeholder(len(columnNames)+i+constant.OneInt)
+	}
+
+	return strings.Join(setList, constant.CommaString), strings.Join(whereList, constant.SpaceString+andString+constant.SpaceString)
+}
+
+// getPKInCondition returns the condition which matches the rows by the primary key,
+// for example: `id` IN (?,?) or (`id1`,`id2`) IN ((?,?),(?,?))
+func getPKInCondition(d Dialect, pkNames []string, rowNum int) string {
+	if len(pkNames) == constant.OneInt {
+		placeholders := make([]string, rowNum)
+		for i := constant.ZeroInt; i < rowNum; i++ {
+			placeholders[i] = d.Placeholder(i + constant.OneInt)
+		}
+
+		return fmt.Sprintf("%s IN (%s)", d.QuoteIdentifier(pkNames[constant.ZeroInt]), strings.Join(placeholders, constant.CommaString))
+	}
+
+	return fmt.Sprintf("(%s) IN (%s)", joinIdentifiers(d, pkNames), getValuesPlaceholders(d, len(pkNames), rowNum))
+}
@@ -2,19 +2,17 @@ package rabbitmq
 
 import (
 	"encoding/json"
-	"fmt"
+	"sort"
 	"strings"
 
-	"github.com/pingcap/errors"
-
 	"github.com/romberli/go-util/common"
-	"github.com/romberli/go-util/constant"
 )
 
 const (
 	SQLTypeInsert = "INSERT"
 	SQLTypeUpdate = "UPDATE"
 	SQLTypeDelete = "DELETE"
+	SQLTypeQuery  = "QUERY"
 
 	andString = "AND"
 )
@@ -29,6 +27,8 @@ type Message struct {
 	Columns   map[string]string        `json:"mysqlType"`
 	Data      []map[string]interface{} `json:"data"`
 	Old       []map[string]interface{} `json:"old"`
+	SQL       string                   `json:"sql,omitempty"`
+	ES        int64                    `json:"es,omitempty"`
 }
 
 // NewMessage returns a new *Message
@@ -111,12 +111,23 @@ func (m *Message) GetOld() []map[string]interface{} {
 	return m.Old
 }
 
-// GetColumnNames gets the column names
+// GetSQL returns the SQL, it's the original statement of the ddl message
+func (m *Message) GetSQL() string {
+	return m.SQL
+}
+
+// GetES returns the ES, it's the execution timestamp of the event
+func (m *Message) GetES() int64 {
+	return m.ES
+}
+
+// GetColumnNames gets the column names, the column names are sorted to keep the column order stable
 func (m *Message) GetColumnNames() []string {
 	var columnNames []string
 	for name := range m.GetColumns() {
 		columnNames = append(columnNames, name)
 	}
+	sort.Strings(columnNames)
 
 	return columnNames
 }
@@ -137,170 +148,20 @@ func (m *Message) Split() []*Message {
 	return messages
 }
 
-// ConvertToSQL returns a map of the sql statement and the values
-// if ignoreDDL is true, and sql type is ddl, it will only return nil, nil,
-// if ignoreDDL is false, and sql type is ddl, it will return nil, error
-// if useReplace is true, message with insert or update type will be converted to statements like "replace into ... values ..."
-// if useReplace is false, message with insert type will be converted to statements like "insert into ... values ... on duplicate key update ..."
-// if useReplace is false, message with update type will be converted to statements like "update ... set ... where ..."
+// ConvertToSQL returns the sql statements of mysql dialect and the values, see ConvertToSQLWithDialect()
 func (m *Message) ConvertToSQL(ignoreDDL bool, useReplace bool) ([]map[string][]interface{}, error) {
-	if len(m.GetData()) == constant.ZeroInt {
-		return nil, errors.New("data should not be empty")
-	}
-
-	if m.GetIsDDL() {
-		if ignoreDDL {
-			return nil, nil
-		}
-
-		return nil, errors.New("does not support ddl statement, either ignore this or avoid to convert ddl statement")
-	}
-
-	switch m.GetSQLType() {
-	case SQLTypeInsert:
-		if useReplace {
-			return m.convertToReplaceSQL()
-		}
-		return m.convertToInsertSQL()
-	case SQLTypeUpdate:
-		if useReplace {
-			return m.convertToReplaceSQL()
-		}
-		return m.convertToUpdateSQL()
-	case SQLTypeDelete:
-		return m.convertToDeleteSQL()
-	default:
-		return nil, errors.Errorf("sql type must be one of [INSERT, UPDATE, DELETE], %s is not supported", m.GetSQLType())
-	}
+	return m.ConvertToSQLWithDialect(NewMySQLDialect(), ignoreDDL, useReplace)
 }
 
-// convertToReplaceSQL converts the message to replace sql statements
-func (m *Message) convertToReplaceSQL() ([]map[string][]interface{}, error) {
-	var (
-		columnNamesStr string
-		valuesStr      string
-		values         []interface{}
-	)
-
-	// use column slice to determine the column order
-	columnNames := m.GetColumnNames()
-
-	for _, columnName := range columnNames {
-		columnNamesStr += columnName + constant.CommaString
-	}
-
-	columnNamesStr = strings.TrimSuffix(columnNamesStr, constant.CommaString)
-
-	value := constant.LeftParenthesisString
-	value += strings.Repeat(constant.QuestionMarkString+constant.CommaString, len(columnNames))
-	value = strings.TrimSuffix(value, constant.CommaString)
-	value += constant.RightParenthesisString + constant.CommaString
-	valuesStr = strings.Repeat(value, len(m.GetData()))
-	valuesStr = strings.TrimSuffix(valuesStr, constant.CommaString)
-
-	sql := `REPLACE INTO %s.%s(%s) VALUES %s ;`
-	sql = fmt.Sprintf(sql, m.GetDBName(), m.GetTableName(), columnNamesStr, valuesStr)
-
-	for _, data := range m.GetData() {
-		for _, columnName := range columnNames {
-			values = append(values, data[columnName])
-		}
-	}
-
-	return []map[string][]interface{}{{sql: values}}, nil
-}
-
-// convertToInsertSQL converts the message to insert sql statements
-func (m *Message) convertToInsertSQL() ([]map[string][]interface{}, error) {
-	var (
-		columnNamesStr string
-		valuesStr      string
-		duplicateStr   string
-		values         []interface{}
-	)
-
-	// use column slice to determine the column order
-	columnNames := m.GetColumnNames()
-
-	for _, columnName := range columnNames {
-		columnNamesStr += columnName + constant.CommaString
-		if !common.ElementInSlice(m.GetPKNames(), columnName) {
-			duplicateStr += fmt.Sprintf("%s=VALUES(%s),", columnName, columnName)
-		}
-	}
-
-	columnNamesStr = strings.TrimSuffix(columnNamesStr, constant.CommaString)
-	duplicateStr = strings.TrimSuffix(duplicateStr, constant.CommaString)
-
-	value := constant.LeftParenthesisString
-	value += strings.Repeat(constant.QuestionMarkString+constant.CommaString, len(columnNames))
-	value = strings.TrimSuffix(value, constant.CommaString)
-	value += constant.RightParenthesisString + constant.CommaString
-	valuesStr = strings.Repeat(value, len(m.GetData()))
-	valuesStr = strings.TrimSuffix(valuesStr, constant.CommaString)
-
-	sql := `INSERT INTO %s.%s(%s) VALUES %s ON DUPLICATE KEY UPDATE %s ;`
-	sql = fmt.Sprintf(sql, m.GetDBName(), m.GetTableName(), columnNamesStr, valuesStr, duplicateStr)
-
-	for _, data := range m.GetData() {
-		for _, columnName := range columnNames {
-			values = append(values, data[columnName])
-		}
-	}
-
-	return []map[string][]interface{}{{sql: values}}, nil
-}
-
-// convertToUpdateSQL converts the message to update sql statements
-func (m *Message) convertToUpdateSQL() ([]map[string][]interface{}, error) {
-	lenData := len(m.GetData())
-	lenOld := len(m.GetOld())
-	if len(m.GetData()) != len(m.GetOld()) {
-		return nil, errors.Errorf("the lengths of the data and old are not the same. table: %s, data: %d, old: %d", m.GetTableName(), lenData, lenOld)
-	}
-
-	if len(m.GetPKNames()) == constant.ZeroInt {
-		return nil, errors.Errorf("table does not have a primary key. table: %s", m.GetTableName())
-	}
-
-	statements := make([]map[string][]interface{}, lenData)
-
-	for i, old := range m.GetOld() {
-		var values []interface{}
-
-		sql := `UPDATE %s.%s SET %s WHERE%s;`
-		setStr := constant.EmptyString
-		whereStr := constant.EmptyString
-
-		data := m.GetData()[i]
-		for columnName := range old {
-			values = append(values, data[columnName])
-			setStr += columnName + constant.EqualString + constant.QuestionMarkString + constant.CommaString
-		}
-
-		setStr = strings.TrimSuffix(setStr, constant.CommaString)
-
-		for _, pkName := range m.GetPKNames() {
-			pkValue, ok := old[pkName]
-			if !ok {
-				pkValue = data[pkName]
-			}
-
-			values = append(values, pkValue)
-			whereStr += fmt.Sprintf(" %s=? AND", pkName)
-		}
-
-		whereStr = strings.TrimSuffix(whereStr, andString)
-		sql = fmt.Sprintf(sql, m.GetDBName(), m.GetTableName(), setStr, whereStr)
-
-		statements[i] = map[string][]interface{}{sql: values}
-	}
-
-	return statements, nil
-}
-
-// convertToDeleteSQL converts the message to delete sql statements
-func (m *Message) convertToDeleteSQL() ([]map[string][]interface{}, error) {
-	// todo: implement
-	return nil, errors.New("does not support delete statement")
+// ConvertToSQLWithDialect returns a map of the sql statement and the values of given dialect,
+// if ignoreDDL is true, and sql type is ddl, it will only return nil, nil,
+// if ignoreDDL is false, and sql type is ddl, the original ddl statement will be passed through the dialect,
+// if useReplace is true, message with insert or update type will be converted to statements which replace the whole rows,
+// for example: "replace into ... values ..." of mysql,
+// if useReplace is false, message with insert type will be converted to upsert statements,
+// for example: "insert into ... values ... on duplicate key update ..." of mysql,
+// if useReplace is false, message with update type will be converted to statements like "update ... set ... where ...",
+// message with delete type will be converted to statements like "delete from ... where ... in (...)"
+func (m *Message) ConvertToSQLWithDialect(dialect Dialect, ignoreDDL bool, useReplace bool) ([]map[string][]interface{}, error) {
+	return NewSQLConverter(dialect, ignoreDDL, useReplace, DefaultBatchSize).Convert(m)
 }
@@ -149,9 +149,28 @@ const (
             "type": "DELETE"
         }
     `
-	testMessageReplaceSQL = `REPLACE INTO test_db.test_table(pk1,pk2,col1,col2) VALUES (?,?,?,?),(?,?,?,?) ;`
-	testMessageInsertSQL  = `INSERT INTO test_db.test_table(pk1,pk2,col1,col2) VALUES (?,?,?,?),(?,?,?,?) ON DUPLICATE KEY UPDATE col1=VALUES(col1),col2=VALUES(col2) ;`
-	testMessageUpdateSQL  = `UPDATE test_db.test_table SET col1=? WHERE pk1=? AND pk2=? ;`
+	testMessageDDLJSONString = `
+        {
+            "data": null,
+            "database": "test_db",
+            "es": 1656315780000,
+            "id": 1383,
+            "isDdl": true,
+            "mysqlType": null,
+            "old": null,
+            "pkNames": null,
+            "sql": "alter table test_table add column col3 int",
+            "sqlType": null,
+            "table": "test_table",
+            "ts": 1656315780000,
+            "type": "ALTER"
+        }
+    `
+	testMessageReplaceSQL = "REPLACE INTO `test_db`.`test_table`(`col1`,`col2`,`pk1`,`pk2`) VALUES (?,?,?,?),(?,?,?,?) ;"
+	testMessageInsertSQL  = "INSERT INTO `test_db`.`test_table`(`col1`,`col2`,`pk1`,`pk2`) VALUES (?,?,?,?),(?,?,?,?) ON DUPLICATE KEY UPDATE `col1`=VALUES(`col1`),`col2`=VALUES(`col2`) ;"
+	testMessageUpdateSQL  = "UPDATE `test_db`.`test_table` SET `col1`=? WHERE `pk1`=? AND `pk2`=? ;"
+	testMessageDeleteSQL  = "DELETE FROM `test_db`.`test_table` WHERE (`pk1`,`pk2`) IN ((?,?),(?,?)) ;"
+	testMessageDDLSQL     = "alter table test_table add column col3 int"
 )
 
 var (
@@ -212,6 +231,7 @@ func TestMessage_ConvertToSQL(t *testing.T) {
 	TestMessage_convertToInsertSQL(t)
 	TestMessage_convertToUpdateSQL(t)
 	TestMessage_convertToDeleteSQL(t)
+	TestMessage_convertDDL(t)
 }
 
 func TestMessage_convertToInsertSQL(t *testing.T) {
@@ -233,6 +253,7 @@ func TestMessage_convertToInsertSQL(t *testing.T) {
 			asst.Equal(8, len(values), "test convertToInsertSQL() failed")
 		}
 	}
+	asst.Contains(statements[0], testMessageReplaceSQL, "test convertToInsertSQL() failed")
 	t.Logf("expected:\t%s", testMessageReplaceSQL)
 	for _, statement := range statements {
 		for sql, values := range statement {
@@ -250,6 +271,7 @@ func TestMessage_convertToInsertSQL(t *testing.T) {
 			asst.Equal(8, len(values), "test convertToInsertSQL() failed")
 		}
 	}
+	asst.Contains(statements[0], testMessageInsertSQL, "test convertToInsertSQL() failed")
 	t.Logf("expected:\t%s", testMessageInsertSQL)
 	for _, statement := range statements {
 		for sql, values := range statement {
@@ -295,6 +317,7 @@ func TestMessage_convertToUpdateSQL(t *testing.T) {
 			asst.Equal(3, len(values), "test convertToUpdateSQL() failed")
 		}
 	}
+	asst.Contains(statements[0], testMessageUpdateSQL, "test convertToUpdateSQL() failed")
 	t.Logf("expected:\t%s", testMessageUpdateSQL)
 	for _, statement := range statements {
 		for sql, values := range statement {
@@ -305,5 +328,34 @@ func TestMessage_convertToUpdateSQL(t *testing.T) {
 }
 
 func TestMessage_convertToDeleteSQL(t *testing.T) {
+	asst := assert.New(t)
+
+	testMessage := NewEmptyMessage()
+	err := json.Unmarshal([]byte(testMessageDeleteJSONString), &testMessage)
+	asst.Nil(err, common.CombineMessageWithError("test convertToDeleteSQL() failed", err))
+
+	statements, err := testMessage.ConvertToSQL(true, false)
+	asst.Nil(err, common.CombineMessageWithError("test convertToDeleteSQL() failed", err))
+	asst.Equal(1, len(statements), "test convertToDeleteSQL() failed")
+	asst.Contains(statements[0], testMessageDeleteSQL, "test convertToDeleteSQL() failed")
+	asst.Equal(4, len(statements[0][testMessageDeleteSQL]), "test convertToDeleteSQL() failed")
+}
+
+func TestMessage_convertDDL(t *testing.T) {
+	asst := assert.New(t)
+
+	testMessage := NewEmptyMessage()
+	err := json.Unmarshal([]byte(testMessageDDLJSONString), &testMessage)
+	asst.Nil(err, common.CombineMessageWithError("test convertDDL() failed", err))
 
+	// ignoreDDL is true
+	statements, err := testMessage.ConvertToSQL(true, false)
+	asst.Nil(err, common.CombineMessageWithError("test convertDDL() failed", err))
+	asst.Equal(0, len(statements), "test convertDDL() failed")
+	// ignoreDDL is false
+	statements, err = testMessage.ConvertToSQL(false, false)
+	asst.Nil(err, common.CombineMessageWithError("test convertDDL() failed", err))
+	asst.Equal(2, len(statements), "test convertDDL() failed")
+	asst.Contains(statements[0], "USE `test_db` ;", "test convertDDL() failed")
+	asst.Contains(statements[1], testMessageDDLSQL, "test convertDDL() failed")
 }
@@ -1,6 +1,7 @@
 package producer
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/romberli/log"
 
 	"github.com/romberli/go-util/constant"
+	"github.com/romberli/go-util/middleware"
 	"github.com/romberli/go-util/middleware/rabbitmq"
 	"github.com/romberli/go-util/uid"
 )
@@ -116,6 +118,8 @@ type Pool struct {
 	expireTime       time.Time
 	keepAliveTime    time.Time
 	isClosed         bool
+	stats            *middleware.PoolStatsRecorder
+	waitQueue        *middleware.WaitQueue[*PoolProducer]
 }
 
 // NewPool returns a new *Pool
@@ -168,6 +172,8 @@ func NewPoolWithPoolConfig(config *rabbitmq.PoolConfig) (*Pool, error) {
 		expireTime:       time.Now().Add(time.Duration(config.MaxIdleTime) * time.Second),
 		keepAliveTime:    time.Now().Add(time.Duration(config.KeepAliveInterval) * time.Second),
 		isClosed:         false,
+		stats:            middleware.NewPoolStatsRecorder(),
+		waitQueue:        middleware.NewWaitQueue[*PoolProducer](),
 	}
 
 	err = p.init()
@@ -202,6 +208,14 @@ func (p *Pool) IsClosed() bool {
 	return p.isClosed
 }
 
+// PoolStats returns the statistics of the pool
+func (p *Pool) PoolStats() middleware.PoolStats {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.stats.Stats(p.MaxConnections, p.usedConnections, len(p.freeProducerChan))
+}
+
 // GetFullTag gets the full tag
 func (p *Pool) GetFullTag() string {
 	var ft string
@@ -235,12 +249,17 @@ func (p *Pool) supply(num int) error {
 
 	for i := constant.ZeroInt; i < num; i++ {
 		if len(p.freeProducerChan)+p.usedConnections < p.MaxConnections {
-			pc, err := NewPoolProducer(p)
+			pc, err := p.newPoolConn()
 			if err != nil {
 				merr = multierror.Append(merr, err)
 				continue
 			}
+			p.stats.IncCreated()
 
+			if p.waitQueue.Serve(pc) {
+				p.usedConnections++
+				continue
+			}
 			p.addToFreeChan(pc)
 		}
 	}
@@ -254,15 +273,33 @@ func (p *Pool) Close() error {
 	defer p.Unlock()
 
 	p.isClosed = true
+	p.waitQueue.Close()
+
 	return p.release(len(p.freeProducerChan))
 }
 
-// put puts given PoolProducer back to the pool
+// put puts given PoolProducer back to the pool,
+// if there are callers waiting for connection, it will be handed over to the first waiter directly
 func (p *Pool) put(pc *PoolProducer) {
+	if p.waitQueue.Serve(pc) {
+		return
+	}
+
 	p.addToFreeChan(pc)
 	p.usedConnections--
 }
 
+// releaseSlot releases a slot of used connections which was occupied by a disconnected connection,
+// if there are callers waiting for connection, the slot will be handed over to the first waiter,
+// and the waiter will create a new connection by itself
+func (p *Pool) releaseSlot() {
+	if p.waitQueue.Serve(nil) {
+		return
+	}
+
+	p.usedConnections--
+}
+
 // getFromFreeChan gets a *PoolProducer from free connection channel
 func (p *Pool) getFromFreeChan() (*PoolProducer, bool) {
 	pc, ok := <-p.freeProducerChan
@@ -275,49 +312,170 @@ func (p *Pool) addToFreeChan(pc *PoolProducer) {
 	p.freeProducerChan <- pc
 }
 
-// Get is an exported alias of get() function with routine safe
+// Get gets a connection from the pool, it waits at most MaxWaitTime seconds
 func (p *Pool) Get() (*PoolProducer, error) {
 	return p.getFromPool()
 }
 
-// getFromPool gets a connection from the pool
+// GetContext gets a connection from the pool with context,
+// if used connections had reached maximum connections, it waits until a connection is returned to the pool or the context is done,
+// the waiting callers are served in first-in first-out order
+func (p *Pool) GetContext(ctx context.Context) (*PoolProducer, error) {
+	return p.getFromPoolContext(ctx)
+}
+
+// getFromPool gets a connection from the pool, it waits at most MaxWaitTime seconds
 func (p *Pool) getFromPool() (*PoolProducer, error) {
-	maxWaitTime := p.MaxWaitTime
-	if maxWaitTime < constant.ZeroInt {
-		maxWaitTime = int(constant.Century.Seconds())
-	}
+	ctx, cancel := middleware.GetWaitContext(p.MaxWaitTime)
+	defer cancel()
 
-	timer := time.NewTimer(time.Duration(maxWaitTime) * time.Second)
-	defer timer.Stop()
+	return p.getFromPoolContext(ctx)
+}
 
-	var i int
+// getFromPoolContext gets a connection from the pool with context,
+// if used connections had reached maximum connections, it joins the wait queue,
+// if creating new connection failed, it retries at most MaxRetryCount times
+func (p *Pool) getFromPoolContext(ctx context.Context) (pc *PoolProducer, err error) {
+	startTime := time.Now()
+	defer func() {
+		p.stats.ObserveGet(time.Since(startTime), err)
+	}()
+
+	var (
+		i           int
+		isWaiting   bool
+		retryOption = p.GetRetryOption()
+	)
 
 	for {
 		p.Lock()
+		if p.IsClosed() {
+			p.Unlock()
+			return nil, errors.New("pool had been closed")
+		}
 
-		pc, err := p.get()
-		p.Unlock()
+		if p.waitQueue.Len() == constant.ZeroInt && p.usedConnections < p.MaxConnections {
+			pc, err = p.get()
+			p.Unlock()
+			if err == nil {
+				return pc, nil
+			}
 
-		if err != nil {
+			if !isWaiting {
+				// could not get a connection immediately, start waiting
+				isWaiting = true
+				p.stats.StartWaiting()
+				defer p.stats.StopWaiting()
+			}
 			// check retry count
-			if p.MaxRetryCount >= constant.ZeroInt && i >= p.MaxRetryCount {
+			if !retryOption.ShouldRetry(i, err) {
 				return nil, err
 			}
+			delay := retryOption.GetDelay(i)
+			retryOption.Notify(i, delay, err)
+			i++
 
-			// check wait time
 			select {
-			case <-timer.C:
-				return nil, err
-			default:
-				time.Sleep(time.Duration(DefaultDelayTime) * time.Millisecond)
+			case <-ctx.Done():
+				return nil, errors.Annotatef(ctx.Err(), "getting connection from the pool failed. last error: %s", err.Error())
+			case <-time.After(delay):
 			}
 
-			i++
 			continue
 		}
 
-		return pc, nil
+		// used connections had reached maximum connections, or there are other callers waiting before, join the wait queue
+		w := p.waitQueue.Push()
+		p.Unlock()
+
+		if !isWaiting {
+			isWaiting = true
+			p.stats.StartWaiting()
+			defer p.stats.StopWaiting()
+		}
+
+		select {
+		case pc, ok := <-w.C():
+			if !ok {
+				return nil, errors.New("pool had been closed")
+			}
+			if pc != nil {
+				// check if connection is still valid
+				if pc.IsValid() {
+					return pc, nil
+				}
+
+				p.stats.IncInvalid(false)
+				disconnectErr := pc.Disconnect()
+				if disconnectErr != nil {
+					log.Warnf("disconnecting invalid connection failed when getting connection from the pool. error:\n%+v", disconnectErr)
+				}
+			}
+			// the waiter holds a free slot now, create a new connection
+			pc, err = p.newPoolConn()
+			if err == nil {
+				p.stats.IncCreated()
+				return pc, nil
+			}
+
+			p.Lock()
+			p.releaseSlot()
+			p.Unlock()
+
+			// check retry count
+			if !retryOption.ShouldRetry(i, err) {
+				return nil, err
+			}
+			retryOption.Notify(i, constant.ZeroInt, err)
+			i++
+		case <-ctx.Done():
+			p.Lock()
+			removed := p.waitQueue.Remove(w)
+			used := p.usedConnections
+			p.Unlock()
+
+			if !removed {
+				// the connection was handed over before removing the waiter, return it back to the pool
+				pc, ok := <-w.C()
+				if ok {
+					if pc == nil {
+						p.Lock()
+						p.releaseSlot()
+						p.Unlock()
+					} else {
+						closeErr := pc.Close()
+						if closeErr != nil {
+							log.Warnf("returning connection to the pool failed. error:\n%+v", closeErr)
+						}
+					}
+				}
+			}
+
+			return nil, errors.Annotatef(ctx.Err(), "waiting for connection failed. used_connections: %d, max_connections: %d",
+				used, p.MaxConnections)
+		}
+	}
+}
+
+// newPoolConn creates a new producer through the circuit breaker if it is set,
+// so that it fails fast when the circuit of the address is open
+func (p *Pool) newPoolConn() (*PoolProducer, error) {
+	if p.CircuitBreaker == nil {
+		return NewPoolProducer(p)
 	}
+
+	var pc *PoolProducer
+	err := p.CircuitBreaker.Execute(p.Addr, func() error {
+		var err error
+		pc, err = NewPoolProducer(p)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pc, nil
 }
 
 // get gets a connection from the pool and validate it,
@@ -346,6 +504,7 @@ func (p *Pool) get() (*PoolProducer, error) {
 				return pc, nil
 			}
 
+			p.stats.IncInvalid(false)
 			err := pc.Disconnect()
 			if err != nil {
 				log.Warnf("disconnecting invalid connection failed when getting connection from the pool. error:\n%+v", err)
@@ -356,10 +515,11 @@ func (p *Pool) get() (*PoolProducer, error) {
 	}
 
 	// there is no valid connection in the free connection channel, therefore create a new one
-	pc, err := NewPoolProducer(p)
+	pc, err := p.newPoolConn()
 	if err != nil {
 		return nil, err
 	}
+	p.stats.IncCreated()
 
 	p.usedConnections++
 
@@ -434,6 +594,7 @@ func (p *Pool) keepAlive(num int) error {
 					continue
 				}
 
+				p.stats.IncInvalid(true)
 				err := pc.Disconnect()
 				if err != nil {
 					merr = multierror.Append(merr, err)
@@ -630,50 +630,25 @@ func (r *Rows) MapToStructByRowIndex(in interface{}, row int, tag string) error
 // each column in the row maps to a field of the struct,
 // tag argument is the tag of the field, it represents the column name,
 // if there is no such tag in the field, this field will be ignored,
-// so set tag to each field that need to be mapped,
-// using "middleware" as the tag is recommended.
+// the mapping of the struct type is compiled only once and cached afterwards,
+// if the value of a column could not be converted to the data type of the field, it returns a *ColumnTypeError
 func (r *Rows) mapToStructByRowIndex(in interface{}, row int, tag string) error {
-	if reflect.TypeOf(in).Kind() != reflect.Ptr {
+	inType := reflect.TypeOf(in)
+	if inType == nil || inType.Kind() != reflect.Ptr || inType.Elem().Kind() != reflect.Struct {
 		return errors.New("first argument must be a pointer to struct")
 	}
 
-	inVal := reflect.ValueOf(in).Elem()
-	inType := inVal.Type()
-
-	for i := 0; i < inVal.NumField(); i++ {
-		fieldType := inType.Field(i)
-		fieldName := fieldType.Name
-		columnName := fieldType.Tag.Get(tag)
-		if columnName == constant.EmptyString {
-			// no such tag, ignore this field
-			continue
-		}
-
-		// get value with row number and column name
-		fieldKind := fieldType.Type.Kind()
-		switch fieldKind {
-		case reflect.Bool,
-			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-			reflect.Float32, reflect.Float64,
-			reflect.String,
-			reflect.Struct,
-			reflect.Slice:
-			value, err := r.GetValueByName(row, columnName)
-			if err != nil {
-				return err
-			}
+	sm, err := GetStructMapping(inType.Elem(), tag)
+	if err != nil {
+		return err
+	}
 
-			err = common.SetValueOfStructByKind(in, fieldName, value, fieldKind)
-			if err != nil {
-				return err
-			}
-		default:
-			return errors.Errorf("got unsupported reflect.Kind of data type: %s", fieldKind.String())
-		}
+	columns, err := sm.columnIndexes(r)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return sm.mapRow(r, row, columns, reflect.ValueOf(in).Elem())
 }
 
 // MapToMapStringInterface maps rows to map[string]interface{},
@@ -0,0 +1,264 @@
+package result
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/constant"
+)
+
+// RowHandler handles a single row of a streaming result,
+// if it returns an error, the streaming will stop and the error will be returned
+type RowHandler func(row *RowView) error
+
+// RowView is a view of a single row of a streaming result,
+// it reuses the getters of *Rows, so the usage is the same as *Rows except that the row number is not needed,
+// note that the view is only valid in the RowHandler, it will be reused when reading the next row,
+// so do not keep the view itself after the handler returns, copy the values if needed
+type RowView struct {
+	rows  *Rows
+	index int
+}
+
+// NewRowView returns a new *RowView with given columns
+func NewRowView(fieldSlice []string, fieldMap map[string]int) *RowView {
+	return &RowView{
+		rows:  NewRows(fieldSlice, fieldMap, [][]driver.Value{nil}),
+		index: constant.ZeroInt,
+	}
+}
+
+// NewRowViewWithColumns returns a new *RowView with given column names
+func NewRowViewWithColumns(columns []string) *RowView {
+	fieldMap := make(map[string]int, len(columns))
+	for i, column := range columns {
+		fieldMap[column] = i
+	}
+
+	return NewRowView(columns, fieldMap)
+}
+
+// Set sets the index and the values of current row
+func (rv *RowView) Set(index int, values []driver.Value) {
+	rv.index = index
+	rv.rows.Values[constant.ZeroInt] = values
+}
+
+// Index returns the index of current row in the whole result, it starts from 0
+func (rv *RowView) Index() int {
+	return rv.index
+}
+
+// Rows returns a *Rows which contains only current row,
+// it could be used with the functions which accept *Rows, for example: Unmarshal() and UnmarshalRow()
+func (rv *RowView) Rows() *Rows {
+	return rv.rows
+}
+
+// Values returns a copy of the values of current row
+func (rv *RowView) Values() []driver.Value {
+	values := make([]driver.Value, len(rv.rows.Values[constant.ZeroInt]))
+	copy(values, rv.rows.Values[constant.ZeroInt])
+
+	return values
+}
+
+// ColumnNumber returns how many columns in the row
+func (rv *RowView) ColumnNumber() int {
+	return rv.rows.ColumnNumber()
+}
+
+// Columns returns the column names of the row
+func (rv *RowView) Columns() []string {
+	return rv.rows.FieldSlice
+}
+
+// ColumnExists check if column exists in the row
+func (rv *RowView) ColumnExists(name string) bool {
+	return rv.rows.ColumnExists(name)
+}
+
+// NameIndex returns index of given column
+func (rv *RowView) NameIndex(name string) (int, error) {
+	return rv.rows.NameIndex(name)
+}
+
+// GetValue returns interface{} type value of given column number
+func (rv *RowView) GetValue(column int) (interface{}, error) {
+	return rv.rows.GetValue(constant.ZeroInt, column)
+}
+
+// GetValueByName returns interface{} type value of given column name
+func (rv *RowView) GetValueByName(name string) (interface{}, error) {
+	return rv.rows.GetValueByName(constant.ZeroInt, name)
+}
+
+// IsNull checks if value of given column number is nil
+func (rv *RowView) IsNull(column int) (bool, error) {
+	return rv.rows.IsNull(constant.ZeroInt, column)
+}
+
+// IsNullByName checks if value of given column name is nil
+func (rv *RowView) IsNullByName(name string) (bool, error) {
+	return rv.rows.IsNullByName(constant.ZeroInt, name)
+}
+
+// GetBool returns bool type value of given column number
+func (rv *RowView) GetBool(column int) (bool, error) {
+	return rv.rows.GetBool(constant.ZeroInt, column)
+}
+
+// GetBoolByName returns bool type value of given column name
+func (rv *RowView) GetBoolByName(name string) (bool, error) {
+	return rv.rows.GetBoolByName(constant.ZeroInt, name)
+}
+
+// GetUint returns uint type value of given column number
+func (rv *RowView) GetUint(column int) (uint, error) {
+	return rv.rows.GetUint(constant.ZeroInt, column)
+}
+
+// GetUintByName returns uint type value of given column name
+func (rv *RowView) GetUintByName(name string) (uint, error) {
+	return rv.rows.GetUintByName(constant.ZeroInt, name)
+}
+
+// GetUint64 returns uint64 type value of given column number
+func (rv *RowView) GetUint64(column int) (uint64, error) {
+	return rv.rows.GetUint64(constant.ZeroInt, column)
+}
+
+// GetUint64ByName returns uint64 type value of given column name
+func (rv *RowView) GetUint64ByName(name string) (uint64, error) {
+	return rv.rows.GetUint64ByName(constant.ZeroInt, name)
+}
+
+// GetInt returns int type value of given column number
+func (rv *RowView) GetInt(column int) (int, error) {
+	return rv.rows.GetInt(constant.ZeroInt, column)
+}
+
+// GetIntByName returns int type value of given column name
+func (rv *RowView) GetIntByName(name string) (int, error) {
+	return rv.rows.GetIntByName(constant.ZeroInt, name)
+}
+
+// GetInt64 returns int64 type value of given column number
+func (rv *RowView) GetInt64(column int) (int64, error) {
+	return rv.rows.GetInt64(constant.ZeroInt, column)
+}
+
+// GetInt64ByName returns int64 type value of given column name
+func (rv *RowView) GetInt64ByName(name string) (int64, error) {
+	return rv.rows.GetInt64ByName(constant.ZeroInt, name)
+}
+
+// GetFloat returns float64 type value of given column number
+func (rv *RowView) GetFloat(column int) (float64, error) {
+	return rv.rows.GetFloat(constant.ZeroInt, column)
+}
+
+// GetFloatByName returns float64 type value of given column name
+func (rv *RowView) GetFloatByName(name string) (float64, error) {
+	return rv.rows.GetFloatByName(constant.ZeroInt, name)
+}
+
+// GetString returns string type value of given column number
+func (rv *RowView) GetString(column int) (string, error) {
+	return rv.rows.GetString(constant.ZeroInt, column)
+}
+
+// GetStringByName returns string type value of given column name
+func (rv *RowView) GetStringByName(name string) (string, error) {
+	return rv.rows.GetStringByName(constant.ZeroInt, name)
+}
+
+// GetStringSlice returns []string type value of given column number
+func (rv *RowView) GetStringSlice(column int) ([]string, error) {
+	return rv.rows.GetStringSlice(constant.ZeroInt, column)
+}
+
+// GetStringSliceByName returns []string type value of given column name
+func (rv *RowView) GetStringSliceByName(name string) ([]string, error) {
+	return rv.rows.GetStringSliceByName(constant.ZeroInt, name)
+}
+
+// MapToStruct maps current row to the struct,
+// first argument must be a pointer to struct,
+// tag argument is the tag of the field, it represents the column name
+func (rv *RowView) MapToStruct(in interface{}, tag string) error {
+	return rv.rows.MapToStructByRowIndex(in, constant.ZeroInt, tag)
+}
+
+// StreamRows calls the handler for each row of given *Rows,
+// it's used by the middleware which could only return the whole result at once,
+// it stops when all the rows are handled, or the handler returns an error, or the context is done
+func StreamRows(ctx context.Context, rows *Rows, handler RowHandler) error {
+	if rows == nil {
+		return nil
+	}
+
+	rv := NewRowView(rows.FieldSlice, rows.FieldMap)
+	for i, values := range rows.Values {
+		err := ctx.Err()
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		rv.Set(i, values)
+		err = handler(rv)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StreamSQLRows reads rows from given *sql.Rows incrementally and calls the handler for each row,
+// it stops when all the rows are read, or the handler returns an error, or the context is done,
+// it does not close the rows, caller should close it
+func StreamSQLRows(ctx context.Context, rows *sql.Rows, handler RowHandler) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	rv := NewRowViewWithColumns(columns)
+
+	scanArgs := make([]interface{}, len(columns))
+	vals := make([]Val, len(columns))
+	for i := range vals {
+		scanArgs[i] = &vals[i]
+	}
+	values := make([]driver.Value, len(columns))
+
+	var index int
+	for rows.Next() {
+		err = ctx.Err()
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		for i := range vals {
+			values[i] = vals[i].Value
+		}
+
+		rv.Set(index, values)
+		err = handler(rv)
+		if err != nil {
+			return err
+		}
+		index++
+	}
+
+	return errors.Trace(rows.Err())
+}
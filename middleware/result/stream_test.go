@@ -0,0 +1,57 @@
+package result
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/constant"
+)
+
+func TestStreamRows(t *testing.T) {
+	asst := assert.New(t)
+
+	r := testNewTypedRows()
+
+	var (
+		ids   []int
+		names []string
+	)
+	err := StreamRows(context.Background(), r, func(row *RowView) error {
+		id, err := row.GetInt(constant.ZeroInt)
+		if err != nil {
+			return err
+		}
+		name, err := row.GetStringByName("name")
+		if err != nil {
+			return err
+		}
+		ts, err := UnmarshalRow[testTypedStruct](row.Rows(), constant.ZeroInt, constant.DefaultMiddlewareTag)
+		if err != nil {
+			return err
+		}
+		asst.Equal(id, ts.ID, "test StreamRows() failed")
+		asst.Equal(len(ids), row.Index(), "test StreamRows() failed")
+
+		ids = append(ids, id)
+		names = append(names, name)
+
+		return nil
+	})
+	asst.Nil(err, "test StreamRows() failed")
+	asst.Equal([]int{1, 2}, ids, "test StreamRows() failed")
+	asst.Equal([]string{"a", "b"}, names, "test StreamRows() failed")
+
+	// stop early
+	ctx, cancel := context.WithCancel(context.Background())
+	var count int
+	err = StreamRows(ctx, r, func(row *RowView) error {
+		count++
+		cancel()
+
+		return nil
+	})
+	asst.ErrorIs(err, context.Canceled, "test StreamRows() failed")
+	asst.Equal(constant.OneInt, count, "test StreamRows() failed")
+}
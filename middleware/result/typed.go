@@ -0,0 +1,412 @@
+package result
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/romberli/go-util/common"
+	"github.com/romberli/go-util/constant"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+
+	structMappingCache sync.Map
+)
+
+type structMappingKey struct {
+	typ reflect.Type
+	tag string
+}
+
+// ColumnTypeError represents a failure of converting a column value to the data type of the mapped field
+type ColumnTypeError struct {
+	Row       int
+	Column    string
+	Field     string
+	FieldType reflect.Type
+	Value     interface{}
+	Err       error
+}
+
+// Error implements the error interface
+func (e *ColumnTypeError) Error() string {
+	return fmt.Sprintf("can not convert value of column %s(row: %d, value type: %T) to field %s(type: %s). error: %s",
+		e.Column, e.Row, e.Value, e.Field, e.FieldType.String(), e.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (e *ColumnTypeError) Unwrap() error {
+	return e.Err
+}
+
+// FieldMapping is the precompiled mapping between a column and a struct field
+type FieldMapping struct {
+	Name   string
+	Column string
+	Index  []int
+	Type   reflect.Type
+	setter func(field reflect.Value, value interface{}) error
+}
+
+// StructMapping is the precompiled mapping between columns and fields of a struct type
+type StructMapping struct {
+	Type   reflect.Type
+	Tag    string
+	Fields []*FieldMapping
+}
+
+// GetStructMapping returns the *StructMapping of given struct type and tag,
+// the mapping is compiled only once for each type and tag, and will be cached afterwards
+func GetStructMapping(typ reflect.Type, tag string) (*StructMapping, error) {
+	if tag == constant.EmptyString {
+		return nil, errors.New("tag argument could not be empty")
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, errors.Errorf("data type must be a struct, %s is not valid", typ.String())
+	}
+
+	key := structMappingKey{typ: typ, tag: tag}
+	sm, ok := structMappingCache.Load(key)
+	if ok {
+		return sm.(*StructMapping), nil
+	}
+
+	mapping, err := newStructMapping(typ, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	sm, _ = structMappingCache.LoadOrStore(key, mapping)
+
+	return sm.(*StructMapping), nil
+}
+
+// newStructMapping compiles the mapping of given struct type and tag
+func newStructMapping(typ reflect.Type, tag string) (*StructMapping, error) {
+	sm := &StructMapping{
+		Type: typ,
+		Tag:  tag,
+	}
+
+	for i := constant.ZeroInt; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		column := field.Tag.Get(tag)
+		if column == constant.EmptyString || column == constant.DashString || !field.IsExported() {
+			// no such tag, ignore this field
+			continue
+		}
+
+		setter, err := getSetter(field.Type)
+		if err != nil {
+			return nil, errors.Errorf("field %s of struct %s is not supported. error: %s", field.Name, typ.String(), err.Error())
+		}
+
+		sm.Fields = append(sm.Fields, &FieldMapping{
+			Name:   field.Name,
+			Column: column,
+			Index:  field.Index,
+			Type:   field.Type,
+			setter: setter,
+		})
+	}
+
+	return sm, nil
+}
+
+// getSetter returns the function which converts the column value and sets it to the field of given type
+func getSetter(typ reflect.Type) (func(field reflect.Value, value interface{}) error, error) {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return func(field reflect.Value, value interface{}) error {
+			v, err := common.ConvertToBool(value)
+			if err != nil {
+				return err
+			}
+			field.SetBool(v)
+
+			return nil
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(field reflect.Value, value interface{}) error {
+			v, err := common.ConvertToInt64(value)
+			if err != nil {
+				return err
+			}
+			if field.OverflowInt(v) {
+				return errors.Errorf("value %d overflows %s", v, field.Type().String())
+			}
+			field.SetInt(v)
+
+			return nil
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(field reflect.Value, value interface{}) error {
+			v, err := common.ConvertToUint64(value)
+			if err != nil {
+				return err
+			}
+			if field.OverflowUint(v) {
+				return errors.Errorf("value %d overflows %s", v, field.Type().String())
+			}
+			field.SetUint(v)
+
+			return nil
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return func(field reflect.Value, value interface{}) error {
+			v, err := common.ConvertToFloat(value)
+			if err != nil {
+				return err
+			}
+			field.SetFloat(v)
+
+			return nil
+		}, nil
+	case reflect.String:
+		return func(field reflect.Value, value interface{}) error {
+			v, err := common.ConvertToString(value)
+			if err != nil {
+				return err
+			}
+			field.SetString(v)
+
+			return nil
+		}, nil
+	case reflect.Slice:
+		elemKind := typ.Elem().Kind()
+		if elemKind == reflect.Uint8 {
+			return func(field reflect.Value, value interface{}) error {
+				switch v := value.(type) {
+				case nil:
+					field.SetBytes(nil)
+				case []byte:
+					field.SetBytes(append([]byte(nil), v...))
+				case string:
+					field.SetBytes([]byte(v))
+				default:
+					return errors.Errorf("unsupported data type: %T", v)
+				}
+
+				return nil
+			}, nil
+		}
+
+		return func(field reflect.Value, value interface{}) error {
+			var (
+				v   interface{}
+				err error
+			)
+
+			b, ok := value.([]byte)
+			if ok && json.Valid(b) {
+				v, err = common.ConvertBytesToSlice(b, elemKind)
+			} else {
+				v, err = common.ConvertToSlice(value, elemKind)
+			}
+			if err != nil {
+				return err
+			}
+
+			return setSlice(field, v)
+		}, nil
+	case reflect.Struct:
+		if typ != timeType {
+			return nil, errors.Errorf("unsupported struct type: %s", typ.String())
+		}
+
+		return func(field reflect.Value, value interface{}) error {
+			t, ok := value.(time.Time)
+			if ok {
+				field.Set(reflect.ValueOf(t))
+				return nil
+			}
+
+			s, err := common.ConvertToString(value)
+			if err != nil {
+				return err
+			}
+			t, err = common.ConvertStringToTime(s)
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(t))
+
+			return nil
+		}, nil
+	case reflect.Ptr:
+		elemSetter, err := getSetter(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return func(field reflect.Value, value interface{}) error {
+			if value == nil {
+				// null value maps to nil pointer
+				field.Set(reflect.Zero(typ))
+				return nil
+			}
+
+			elem := reflect.New(typ.Elem())
+			err := elemSetter(elem.Elem(), value)
+			if err != nil {
+				return err
+			}
+			field.Set(elem)
+
+			return nil
+		}, nil
+	default:
+		return nil, errors.Errorf("unsupported reflect.Kind of data type: %s", typ.Kind().String())
+	}
+}
+
+// setSlice sets each element of given slice to the slice field,
+// elements will be converted to the element type of the field
+func setSlice(field reflect.Value, slice interface{}) error {
+	if slice == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	sliceVal := reflect.ValueOf(slice)
+	elemType := field.Type().Elem()
+	val := reflect.MakeSlice(field.Type(), sliceVal.Len(), sliceVal.Len())
+	for i := constant.ZeroInt; i < sliceVal.Len(); i++ {
+		elem := sliceVal.Index(i)
+		if !elem.CanConvert(elemType) {
+			return errors.Errorf("can not convert element of type %s to %s", elem.Type().String(), elemType.String())
+		}
+		val.Index(i).Set(elem.Convert(elemType))
+	}
+	field.Set(val)
+
+	return nil
+}
+
+// columnIndexes returns the column index of each field in the result
+func (sm *StructMapping) columnIndexes(r Value) ([]int, error) {
+	indexes := make([]int, len(sm.Fields))
+	for i, fm := range sm.Fields {
+		column, err := r.NameIndex(fm.Column)
+		if err != nil {
+			return nil, errors.Errorf("column %s of field %s does not exist in the result", fm.Column, fm.Name)
+		}
+		indexes[i] = column
+	}
+
+	return indexes, nil
+}
+
+// mapRow maps the row of given index to the struct value
+func (sm *StructMapping) mapRow(r Value, row int, columns []int, val reflect.Value) error {
+	for i, fm := range sm.Fields {
+		value, err := r.GetValue(row, columns[i])
+		if err != nil {
+			return err
+		}
+
+		err = fm.setter(val.FieldByIndex(fm.Index), value)
+		if err != nil {
+			return &ColumnTypeError{
+				Row:       row,
+				Column:    fm.Column,
+				Field:     fm.Name,
+				FieldType: fm.Type,
+				Value:     value,
+				Err:       err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// TypedValue is the result which could be unmarshalled to the typed values, middleware.Result satisfies it
+type TypedValue interface {
+	Value
+	Unmarshaler
+}
+
+// Unmarshal maps each row of the result to a new value of type T,
+// T must be a struct or a pointer to struct,
+// each row is mapped by MapToStructByRowIndex() of the result,
+// each column in the row maps to a field of the struct,
+// tag argument is the tag of the field, it represents the column name,
+// if there is no such tag in the field, this field will be ignored,
+// if the value of a column could not be converted to the data type of the field,
+// it returns a *ColumnTypeError
+func Unmarshal[T any](r TypedValue, tag string) ([]T, error) {
+	sm, isPtr, err := getStructMappingOfType[T](tag)
+	if err != nil {
+		return nil, err
+	}
+
+	rowNum := r.RowNumber()
+	list := make([]T, rowNum)
+	for i := constant.ZeroInt; i < rowNum; i++ {
+		err = r.MapToStructByRowIndex(newStructPointer(sm, isPtr, &list[i]), i, tag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// UnmarshalRow maps the row of given index of the result to a new value of type T,
+// T must be a struct or a pointer to struct
+func UnmarshalRow[T any](r TypedValue, row int, tag string) (T, error) {
+	var t T
+
+	sm, isPtr, err := getStructMappingOfType[T](tag)
+	if err != nil {
+		return t, err
+	}
+
+	if row >= r.RowNumber() || row < constant.ZeroInt {
+		return t, errors.Errorf("invalid row index %d", row)
+	}
+
+	err = r.MapToStructByRowIndex(newStructPointer(sm, isPtr, &t), row, tag)
+	if err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// getStructMappingOfType returns the struct mapping of type T, and if T is a pointer type
+func getStructMappingOfType[T any](tag string) (*StructMapping, bool, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	isPtr := typ.Kind() == reflect.Ptr
+	if isPtr {
+		typ = typ.Elem()
+	}
+
+	sm, err := GetStructMapping(typ, tag)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return sm, isPtr, nil
+}
+
+// newStructPointer returns the pointer to the struct which out holds,
+// if T is a pointer type, a new struct will be allocated and set to out
+func newStructPointer[T any](sm *StructMapping, isPtr bool, out *T) interface{} {
+	if !isPtr {
+		return out
+	}
+
+	ptr := reflect.New(sm.Type)
+	reflect.ValueOf(out).Elem().Set(ptr)
+
+	return ptr.Interface()
+}
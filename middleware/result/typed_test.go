@@ -0,0 +1,107 @@
+package result
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/romberli/go-util/constant"
+)
+
+type testTypedStruct struct {
+	ID        int        `middleware:"id"`
+	Name      string     `middleware:"name"`
+	Score     float32    `middleware:"score"`
+	Enabled   bool       `middleware:"enabled"`
+	Tags      []string   `middleware:"tags"`
+	Remark    *string    `middleware:"remark"`
+	CreatedAt time.Time  `middleware:"created_at"`
+	UpdatedAt *time.Time `middleware:"updated_at"`
+	Ignored   string
+}
+
+func testNewTypedRows() *Rows {
+	fieldSlice := []string{"id", "name", "score", "enabled", "tags", "remark", "created_at", "updated_at"}
+	fieldMap := make(map[string]int)
+	for i, field := range fieldSlice {
+		fieldMap[field] = i
+	}
+
+	return NewRows(fieldSlice, fieldMap, [][]driver.Value{
+		{int64(1), []byte("a"), "1.5", int64(1), []byte(`["x","y"]`), []byte("r1"), "2024-01-02 03:04:05", nil},
+		{int64(2), "b", float64(2.5), int64(0), nil, nil, []byte("2024-01-03"), "2024-01-03 00:00:00.000"},
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	asst := assert.New(t)
+
+	r := testNewTypedRows()
+
+	list, err := Unmarshal[testTypedStruct](r, constant.DefaultMiddlewareTag)
+	asst.Nil(err, "test Unmarshal() failed")
+	asst.Equal(2, len(list), "test Unmarshal() failed")
+	asst.Equal(1, list[0].ID, "test Unmarshal() failed")
+	asst.Equal("a", list[0].Name, "test Unmarshal() failed")
+	asst.Equal(float32(1.5), list[0].Score, "test Unmarshal() failed")
+	asst.True(list[0].Enabled, "test Unmarshal() failed")
+	asst.Equal([]string{"x", "y"}, list[0].Tags, "test Unmarshal() failed")
+	asst.Equal("r1", *list[0].Remark, "test Unmarshal() failed")
+	asst.Equal(2024, list[0].CreatedAt.Year(), "test Unmarshal() failed")
+	asst.Nil(list[0].UpdatedAt, "test Unmarshal() failed")
+	asst.Nil(list[1].Remark, "test Unmarshal() failed")
+	asst.Nil(list[1].Tags, "test Unmarshal() failed")
+	asst.NotNil(list[1].UpdatedAt, "test Unmarshal() failed")
+
+	ptrList, err := Unmarshal[*testTypedStruct](r, constant.DefaultMiddlewareTag)
+	asst.Nil(err, "test Unmarshal() failed")
+	asst.Equal("b", ptrList[1].Name, "test Unmarshal() failed")
+
+	ts, err := UnmarshalRow[testTypedStruct](r, constant.OneInt, constant.DefaultMiddlewareTag)
+	asst.Nil(err, "test UnmarshalRow() failed")
+	asst.Equal(2, ts.ID, "test UnmarshalRow() failed")
+
+	_, err = UnmarshalRow[testTypedStruct](r, constant.TwoInt, constant.DefaultMiddlewareTag)
+	asst.NotNil(err, "test UnmarshalRow() failed")
+}
+
+func TestUnmarshal_ColumnTypeError(t *testing.T) {
+	asst := assert.New(t)
+
+	r := testNewTypedRows()
+	r.Values[1][0] = "abc"
+
+	_, err := Unmarshal[testTypedStruct](r, constant.DefaultMiddlewareTag)
+	asst.NotNil(err, "test Unmarshal() failed")
+
+	var cte *ColumnTypeError
+	asst.True(errors.As(err, &cte), "test Unmarshal() failed")
+	asst.Equal(constant.OneInt, cte.Row, "test Unmarshal() failed")
+	asst.Equal("id", cte.Column, "test Unmarshal() failed")
+	asst.Equal("ID", cte.Field, "test Unmarshal() failed")
+
+	err = r.MapToStructByRowIndex(&testTypedStruct{}, constant.OneInt, constant.DefaultMiddlewareTag)
+	asst.True(errors.As(err, &cte), "test MapToStructByRowIndex() failed")
+	asst.Equal("id", cte.Column, "test MapToStructByRowIndex() failed")
+	t.Logf("column type error: %s", err.Error())
+}
+
+func TestGetStructMapping(t *testing.T) {
+	asst := assert.New(t)
+
+	typ := reflect.TypeOf(testTypedStruct{})
+	sm1, err := GetStructMapping(typ, constant.DefaultMiddlewareTag)
+	asst.Nil(err, "test GetStructMapping() failed")
+	asst.Equal(8, len(sm1.Fields), "test GetStructMapping() failed")
+
+	sm2, err := GetStructMapping(typ, constant.DefaultMiddlewareTag)
+	asst.Nil(err, "test GetStructMapping() failed")
+	asst.True(sm1 == sm2, "test GetStructMapping() failed")
+
+	_, err = GetStructMapping(reflect.TypeOf(constant.ZeroInt), constant.DefaultMiddlewareTag)
+	asst.NotNil(err, "test GetStructMapping() failed")
+}
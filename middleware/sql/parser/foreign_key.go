@@ -0,0 +1,250 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/romberli/go-multierror"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	ForeignKeyConstraintString = "CONSTRAINT"
+	ForeignKeyForeignKeyString = "FOREIGN KEY"
+	ForeignKeyReferencesString = "REFERENCES"
+	ForeignKeyOnDeleteString   = "ON DELETE"
+	ForeignKeyOnUpdateString   = "ON UPDATE"
+	// ForeignKeyGeneratedNameInfix is used to generate the name of the foreign key without a constraint name,
+	// the generated name is like <table name>_ibfk_<n>, the same as mysql does
+	ForeignKeyGeneratedNameInfix = "_ibfk_"
+
+	ForeignKeyDiffTypeUnknown ForeignKeyDiffType = 0
+	ForeignKeyDiffTypeAdd     ForeignKeyDiffType = 1
+	ForeignKeyDiffTypeDrop    ForeignKeyDiffType = 2
+)
+
+type ForeignKeyDiffType int
+
+func (fdt *ForeignKeyDiffType) String() string {
+	switch *fdt {
+	case ForeignKeyDiffTypeAdd:
+		return AddKeyword
+	case ForeignKeyDiffTypeDrop:
+		return DropKeyWord
+	default:
+		return UnknownKeyWord
+	}
+}
+
+func (fdt *ForeignKeyDiffType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fdt.String())
+}
+
+func (fdt *ForeignKeyDiffType) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case AddKeyword:
+		*fdt = ForeignKeyDiffTypeAdd
+	case DropKeyWord:
+		*fdt = ForeignKeyDiffTypeDrop
+	default:
+		*fdt = ForeignKeyDiffTypeUnknown
+	}
+
+	return nil
+}
+
+type ForeignKeyDefinition struct {
+	TableSchema    string   `json:"tableSchema"`
+	TableName      string   `json:"tableName"`
+	ConstraintName string   `json:"constraintName"`
+	Columns        []string `json:"columns"`
+	RefTableSchema string   `json:"refTableSchema"`
+	RefTableName   string   `json:"refTableName"`
+	RefColumns     []string `json:"refColumns"`
+	OnDelete       string   `json:"onDelete"`
+	OnUpdate       string   `json:"onUpdate"`
+
+	Errors *multierror.Error `json:"errors,omitempty"`
+}
+
+// NewForeignKeyDefinition returns a new *ForeignKeyDefinition
+func NewForeignKeyDefinition(tableSchema, tableName, constraintName string) *ForeignKeyDefinition {
+	return &ForeignKeyDefinition{
+		TableSchema:    tableSchema,
+		TableName:      tableName,
+		ConstraintName: constraintName,
+		Errors:         &multierror.Error{},
+	}
+}
+
+// NewEmptyForeignKeyDefinition returns a new empty *ForeignKeyDefinition
+func NewEmptyForeignKeyDefinition() *ForeignKeyDefinition {
+	return &ForeignKeyDefinition{
+		Errors: &multierror.Error{},
+	}
+}
+
+// GetRefFullTableName gets the full table name of the referenced table
+func (fd *ForeignKeyDefinition) GetRefFullTableName() string {
+	var tableSchema string
+	if fd.RefTableSchema != constant.EmptyString {
+		tableSchema = constant.BackTickString + fd.RefTableSchema + constant.BackTickString + constant.DotString
+	}
+
+	return tableSchema + constant.BackTickString + fd.RefTableName + constant.BackTickString
+}
+
+// String returns the string of ForeignKeyDefinition
+func (fd *ForeignKeyDefinition) String() string {
+	if fd == nil {
+		return constant.EmptyString
+	}
+
+	s := ForeignKeyConstraintString + constant.SpaceString + constant.BackTickString + fd.ConstraintName + constant.BackTickString +
+		constant.SpaceString + ForeignKeyForeignKeyString + constant.SpaceString + getQuotedColumnList(fd.Columns) +
+		constant.SpaceString + ForeignKeyReferencesString + constant.SpaceString + fd.GetRefFullTableName() +
+		constant.SpaceString + getQuotedColumnList(fd.RefColumns)
+	if fd.OnDelete != constant.EmptyString {
+		s += constant.SpaceString + ForeignKeyOnDeleteString + constant.SpaceString + fd.OnDelete
+	}
+	if fd.OnUpdate != constant.EmptyString {
+		s += constant.SpaceString + ForeignKeyOnUpdateString + constant.SpaceString + fd.OnUpdate
+	}
+
+	return s
+}
+
+// Clone returns a new *ForeignKeyDefinition
+func (fd *ForeignKeyDefinition) Clone() *ForeignKeyDefinition {
+	return &ForeignKeyDefinition{
+		TableSchema:    fd.TableSchema,
+		TableName:      fd.TableName,
+		ConstraintName: fd.ConstraintName,
+		Columns:        append([]string{}, fd.Columns...),
+		RefTableSchema: fd.RefTableSchema,
+		RefTableName:   fd.RefTableName,
+		RefColumns:     append([]string{}, fd.RefColumns...),
+		OnDelete:       fd.OnDelete,
+		OnUpdate:       fd.OnUpdate,
+		Errors:         fd.Errors,
+	}
+}
+
+// Error returns the error of ForeignKeyDefinition
+func (fd *ForeignKeyDefinition) Error() error {
+	return fd.Errors.ErrorOrNil()
+}
+
+// AddError adds error to ForeignKeyDefinition
+func (fd *ForeignKeyDefinition) AddError(err error) {
+	if err == nil {
+		return
+	}
+	if fd.Errors == nil {
+		fd.Errors = &multierror.Error{}
+	}
+	fd.Errors = multierror.Append(fd.Errors, err)
+}
+
+// Equal checks whether two ForeignKeyDefinition objects are equal
+func (fd *ForeignKeyDefinition) Equal(other *ForeignKeyDefinition) bool {
+	if fd == nil && other == nil {
+		return true
+	}
+	if fd != nil && other != nil &&
+		fd.ConstraintName == other.ConstraintName &&
+		strings.Join(fd.Columns, constant.CommaString) == strings.Join(other.Columns, constant.CommaString) &&
+		fd.RefTableSchema == other.RefTableSchema &&
+		fd.RefTableName == other.RefTableName &&
+		strings.Join(fd.RefColumns, constant.CommaString) == strings.Join(other.RefColumns, constant.CommaString) &&
+		fd.OnDelete == other.OnDelete &&
+		fd.OnUpdate == other.OnUpdate {
+		return true
+	}
+
+	return false
+}
+
+// Diff returns the difference between two foreign key definitions
+func (fd *ForeignKeyDefinition) Diff(source *ForeignKeyDefinition) []*ForeignKeyDiff {
+	if source == nil {
+		return []*ForeignKeyDiff{NewForeignKeyDiff(ForeignKeyDiffTypeAdd, nil, fd)}
+	}
+
+	if fd.Equal(source) {
+		return nil
+	}
+
+	drop := NewForeignKeyDiff(ForeignKeyDiffTypeDrop, source, nil)
+	add := NewForeignKeyDiff(ForeignKeyDiffTypeAdd, nil, fd)
+
+	return []*ForeignKeyDiff{drop, add}
+}
+
+type ForeignKeyDiff struct {
+	DiffType ForeignKeyDiffType    `json:"diffType"`
+	Source   *ForeignKeyDefinition `json:"source"`
+	Target   *ForeignKeyDefinition `json:"target"`
+}
+
+// NewForeignKeyDiff returns a new *ForeignKeyDiff
+func NewForeignKeyDiff(diffType ForeignKeyDiffType, source, target *ForeignKeyDefinition) *ForeignKeyDiff {
+	return &ForeignKeyDiff{
+		DiffType: diffType,
+		Source:   source,
+		Target:   target,
+	}
+}
+
+// GetMigrationSQL returns the migration sql of ForeignKeyDiff
+func (fd *ForeignKeyDiff) GetMigrationSQL() string {
+	switch fd.DiffType {
+	case ForeignKeyDiffTypeAdd:
+		return AddKeyword + constant.SpaceString + fd.Target.String()
+	case ForeignKeyDiffTypeDrop:
+		return DropKeyWord + constant.SpaceString + ForeignKeyForeignKeyString + constant.SpaceString +
+			constant.BackTickString + fd.Source.ConstraintName + constant.BackTickString
+	default:
+		return UnknownKeyWord
+	}
+}
+
+// MarshalJSON returns the json format of ForeignKeyDiff
+func (fd *ForeignKeyDiff) MarshalJSON() ([]byte, error) {
+	type Alias struct {
+		DiffType ForeignKeyDiffType `json:"diffType"`
+		Source   string             `json:"source"`
+		Target   string             `json:"target"`
+	}
+
+	aux := &Alias{
+		DiffType: fd.DiffType,
+		Source:   fd.Source.String(),
+		Target:   fd.Target.String(),
+	}
+
+	jsonBytes, err := json.Marshal(aux)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return jsonBytes, nil
+}
+
+// getQuotedColumnList returns the quoted column list, for example: (`col1`, `col2`)
+func getQuotedColumnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = constant.BackTickString + column + constant.BackTickString
+	}
+
+	return constant.LeftParenthesisString + strings.Join(quoted, constant.CommaString+constant.SpaceString) + constant.RightParenthesisString
+}
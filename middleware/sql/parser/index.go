@@ -263,6 +263,7 @@ func (is *IndexSpec) Clone() *IndexSpec {
 		Column:     is.Column.Clone(),
 		Descending: is.Descending,
 		Length:     is.Length,
+		Expr:       is.Expr,
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/tidb/pkg/parser"
 	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/format"
 	"github.com/romberli/go-multierror"
 
 	"github.com/romberli/go-util/constant"
@@ -155,6 +156,50 @@ func (p *Parser) Split(multiSQL string) ([]string, error) {
 	return sqlList, nil
 }
 
+// QualifyTableNames returns the sql statements in which the table names without db name are qualified with given db name,
+// each argument in the input sql could contain multiple sql statements
+func (p *Parser) QualifyTableNames(sql, dbName string) ([]string, error) {
+	stmtNodes, err := p.GetStatementNodes(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlList := make([]string, len(stmtNodes))
+	qualifier := &tableNameQualifier{dbName: dbName}
+	for i, stmtNode := range stmtNodes {
+		stmtNode.Accept(qualifier)
+
+		var builder strings.Builder
+		err = stmtNode.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &builder))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		sqlList[i] = builder.String()
+	}
+
+	return sqlList, nil
+}
+
+// tableNameQualifier sets the db name of the table names which do not have db name
+type tableNameQualifier struct {
+	dbName string
+}
+
+// Enter implements ast.Visitor interface
+func (tnq *tableNameQualifier) Enter(in ast.Node) (ast.Node, bool) {
+	tableName, ok := in.(*ast.TableName)
+	if ok && tableName.Schema.O == constant.EmptyString {
+		tableName.Schema = ast.NewCIStr(tnq.dbName)
+	}
+
+	return in, false
+}
+
+// Leave implements ast.Visitor interface
+func (tnq *tableNameQualifier) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
 // MergeDDLStatements merges ddl statements by table names.
 // note that only alter table statement and create index statement will be merged,
 // inputting other sql statements will return error,
@@ -143,6 +143,17 @@ func TestParser_Split(t *testing.T) {
 	asst.Equal(3, len(sqlList))
 }
 
+func TestParser_QualifyTableNames(t *testing.T) {
+	asst := assert.New(t)
+
+	sql := "drop table t01, db02.t02"
+	p := NewParserWithDefault()
+
+	sqlList, err := p.QualifyTableNames(sql, "db01")
+	asst.Nil(err, "test QualifyTableNames() failed")
+	asst.Equal([]string{"DROP TABLE `db01`.`t01`, `db02`.`t02`"}, sqlList, "test QualifyTableNames() failed")
+}
+
 func TestParser_MergeDDLStatements(t *testing.T) {
 	asst := assert.New(t)
 
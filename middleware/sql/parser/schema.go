@@ -0,0 +1,431 @@
+package parser
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+
+	"github.com/romberli/go-util/constant"
+)
+
+const (
+	SchemaRenameTableString = "RENAME TABLE"
+	SchemaToString          = "TO"
+)
+
+// SchemaDefinition is the definitions of a set of tables, for example, all the tables of a database
+type SchemaDefinition struct {
+	Tables map[string]*TableFullDefinition `json:"tables"`
+}
+
+// NewEmptySchemaDefinition returns a new empty *SchemaDefinition
+func NewEmptySchemaDefinition() *SchemaDefinition {
+	return &SchemaDefinition{
+		Tables: make(map[string]*TableFullDefinition),
+	}
+}
+
+// AddTable adds a table definition to the schema definition
+func (sd *SchemaDefinition) AddTable(td *TableFullDefinition) {
+	sd.Tables[getSchemaTableKey(td.Table.TableSchema, td.Table.TableName)] = td
+}
+
+// GetTable gets the table definition by table schema and table name
+func (sd *SchemaDefinition) GetTable(tableSchema, tableName string) *TableFullDefinition {
+	return sd.Tables[getSchemaTableKey(tableSchema, tableName)]
+}
+
+// GetTableKeys returns the sorted keys of the tables, the key is formatted as schema.table,
+// if the table schema is empty, the key is the table name
+func (sd *SchemaDefinition) GetTableKeys() []string {
+	keys := make([]string, constant.ZeroInt, len(sd.Tables))
+	for key := range sd.Tables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Clone returns a new *SchemaDefinition
+func (sd *SchemaDefinition) Clone() *SchemaDefinition {
+	newSD := NewEmptySchemaDefinition()
+	for key, td := range sd.Tables {
+		newSD.Tables[key] = td.Clone()
+	}
+
+	return newSD
+}
+
+// Diff returns the difference between two schema definitions, the receiver is the target schema,
+// neither of the schema definitions will be changed
+func (sd *SchemaDefinition) Diff(source *SchemaDefinition) *SchemaDiff {
+	diff := diffSchema(source, sd)
+	diff.RollbackSQL = diffSchema(sd, source).MigrationSQL
+
+	return diff
+}
+
+// ParseSchemaDefinition parses the create table statements of the given sql and returns the schema definition,
+// the other statements will be ignored, so the output of "mysqldump --no-data" could be used directly
+func (p *Parser) ParseSchemaDefinition(sql string) (*SchemaDefinition, error) {
+	stmtNodes, err := p.GetStatementNodes(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := NewEmptySchemaDefinition()
+	for _, stmtNode := range stmtNodes {
+		_, ok := stmtNode.(*ast.CreateTableStmt)
+		if !ok {
+			continue
+		}
+
+		// the visitor keeps the state of the table definition, so use a new parser for each table
+		td, err := NewParserWithDefault().ParseTableDefinition(stmtNode.Text())
+		if err != nil {
+			return nil, err
+		}
+		sd.AddTable(td)
+	}
+
+	return sd, nil
+}
+
+// DiffSchema parses the create table statements of the source sql and the target sql,
+// and returns the difference between them
+func (p *Parser) DiffSchema(sourceSQL, targetSQL string) (*SchemaDiff, error) {
+	source, err := p.ParseSchemaDefinition(sourceSQL)
+	if err != nil {
+		return nil, errors.Annotate(err, "parse source schema failed")
+	}
+	target, err := p.ParseSchemaDefinition(targetSQL)
+	if err != nil {
+		return nil, errors.Annotate(err, "parse target schema failed")
+	}
+
+	return target.Diff(source), nil
+}
+
+type TableRename struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// NewTableRename returns a new *TableRename
+func NewTableRename(source, target string) *TableRename {
+	return &TableRename{
+		Source: source,
+		Target: target,
+	}
+}
+
+// GetMigrationSQL returns the migration sql of TableRename
+func (tr *TableRename) GetMigrationSQL() string {
+	return SchemaRenameTableString + constant.SpaceString + tr.Source + constant.SpaceString +
+		SchemaToString + constant.SpaceString + tr.Target + constant.SemicolonString
+}
+
+// SchemaDiff is the difference between two schema definitions,
+// a table which is dropped from the source and created in the target with the same definition is considered as renamed
+type SchemaDiff struct {
+	Created []*TableDefinitionDiff `json:"created"`
+	Dropped []*TableDefinitionDiff `json:"dropped"`
+	Renamed []*TableRename         `json:"renamed"`
+	Altered []*TableDefinitionDiff `json:"altered"`
+	// MigrationSQL is the ordered sql statements which migrate the source schema to the target schema
+	MigrationSQL []string `json:"migrationSQL"`
+	// RollbackSQL is the ordered sql statements which migrate the target schema back to the source schema
+	RollbackSQL []string `json:"rollbackSQL"`
+}
+
+// IsEmpty returns if there is no difference between the two schema definitions
+func (sd *SchemaDiff) IsEmpty() bool {
+	return len(sd.Created) == constant.ZeroInt && len(sd.Dropped) == constant.ZeroInt &&
+		len(sd.Renamed) == constant.ZeroInt && len(sd.Altered) == constant.ZeroInt
+}
+
+// GetMigrationSQL returns the ordered migration sql statements, the order is:
+// 1. drop the removed or changed foreign keys of the altered tables and the foreign keys of the dropped tables
+// 2. rename the tables
+// 3. create the tables without the foreign keys, the referenced tables are created before the referencing tables
+// 4. alter the tables
+// 5. add the new or changed foreign keys of the altered tables and the foreign keys of the created tables
+// 6. drop the tables, the referencing tables are dropped before the referenced tables,
+// as the foreign keys are added after all the columns and indexes exist, and dropped before any of them is dropped,
+// the foreign key checks do not need to be disabled even if there are circular references,
+// note that the drop statements are commented out, see TableDropPrefix
+func (sd *SchemaDiff) GetMigrationSQL() []string {
+	return sd.MigrationSQL
+}
+
+// GetRollbackSQL returns the ordered sql statements which revert the migration
+func (sd *SchemaDiff) GetRollbackSQL() []string {
+	return sd.RollbackSQL
+}
+
+// GetJSONReport returns the json report of the schema diff
+func (sd *SchemaDiff) GetJSONReport() ([]byte, error) {
+	jsonBytes, err := json.Marshal(sd)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return jsonBytes, nil
+}
+
+// diffSchema returns the difference between the source and the target schema definitions without the rollback sql
+func diffSchema(source, target *SchemaDefinition) *SchemaDiff {
+	// the diff functions may change the definitions
+	source = source.Clone()
+	target = target.Clone()
+
+	var droppedKeys, createdKeys, commonKeys []string
+	for _, key := range source.GetTableKeys() {
+		_, ok := target.Tables[key]
+		if ok {
+			commonKeys = append(commonKeys, key)
+			continue
+		}
+		droppedKeys = append(droppedKeys, key)
+	}
+	for _, key := range target.GetTableKeys() {
+		_, ok := source.Tables[key]
+		if !ok {
+			createdKeys = append(createdKeys, key)
+		}
+	}
+
+	diff := &SchemaDiff{}
+	// rename
+	renamedKeys := make(map[string]string)
+	matchedKeys := make(map[string]bool)
+	for _, sourceKey := range droppedKeys {
+		for _, targetKey := range createdKeys {
+			if !matchedKeys[targetKey] && isTableRenamed(source.Tables[sourceKey], target.Tables[targetKey]) {
+				renamedKeys[sourceKey] = targetKey
+				matchedKeys[targetKey] = true
+				diff.Renamed = append(diff.Renamed, NewTableRename(source.Tables[sourceKey].Table.GetFullTableName(),
+					target.Tables[targetKey].Table.GetFullTableName()))
+				break
+			}
+		}
+	}
+	// mysql changes the foreign keys which reference the renamed tables automatically
+	for _, td := range source.Tables {
+		for _, fd := range td.ForeignKeys {
+			targetKey, ok := renamedKeys[getSchemaTableKey(fd.RefTableSchema, fd.RefTableName)]
+			if ok {
+				fd.RefTableSchema = target.Tables[targetKey].Table.TableSchema
+				fd.RefTableName = target.Tables[targetKey].Table.TableName
+			}
+		}
+	}
+
+	var dropped, created []*TableFullDefinition
+	for _, key := range droppedKeys {
+		_, ok := renamedKeys[key]
+		if !ok {
+			dropped = append(dropped, source.Tables[key])
+		}
+	}
+	for _, key := range createdKeys {
+		if !matchedKeys[key] {
+			created = append(created, target.Tables[key])
+		}
+	}
+
+	// create, the foreign keys are added after all the tables are created and altered
+	for _, td := range sortTablesByForeignKeys(created) {
+		tdd := NewTableDefinitionDiff(constant.EmptyString, td.Table.GetFullTableName(),
+			NewTableDiff(TableDiffTypeCreate, nil, td.Table), nil, nil)
+		tdd.ForeignKeyDiff = getForeignKeyDiffs(td, ForeignKeyDiffTypeAdd)
+		diff.Created = append(diff.Created, tdd)
+	}
+	// drop, the referencing tables should be dropped first,
+	// the foreign keys are dropped before any table is altered
+	dropOrder := sortTablesByForeignKeys(dropped)
+	for i := len(dropOrder) - constant.OneInt; i >= constant.ZeroInt; i-- {
+		td := dropOrder[i]
+		tdd := NewTableDefinitionDiff(td.Table.GetFullTableName(), constant.EmptyString,
+			NewTableDiff(TableDiffTypeDrop, td.Table, nil), nil, nil)
+		tdd.ForeignKeyDiff = getForeignKeyDiffs(td, ForeignKeyDiffTypeDrop)
+		diff.Dropped = append(diff.Dropped, tdd)
+	}
+	// alter
+	for _, key := range commonKeys {
+		tdd := target.Tables[key].Diff(source.Tables[key])
+		if tdd != nil {
+			diff.Altered = append(diff.Altered, tdd)
+		}
+	}
+
+	diff.MigrationSQL = diff.getMigrationSQL()
+
+	return diff
+}
+
+// getMigrationSQL returns the ordered migration sql statements, see GetMigrationSQL() for the order
+func (sd *SchemaDiff) getMigrationSQL() []string {
+	var sqls []string
+	for _, tdds := range [][]*TableDefinitionDiff{sd.Altered, sd.Dropped} {
+		for _, tdd := range tdds {
+			sql := tdd.GetDropForeignKeySQL()
+			if sql != constant.EmptyString {
+				sqls = append(sqls, sql)
+			}
+		}
+	}
+	for _, tr := range sd.Renamed {
+		sqls = append(sqls, tr.GetMigrationSQL())
+	}
+	for _, tdd := range sd.Created {
+		sqls = append(sqls, tdd.GetTableMigrationSQLWithoutForeignKeys())
+	}
+	for _, tdd := range sd.Altered {
+		sql := tdd.GetTableMigrationSQLWithoutForeignKeys()
+		if sql != constant.EmptyString {
+			sqls = append(sqls, sql)
+		}
+	}
+	for _, tdds := range [][]*TableDefinitionDiff{sd.Altered, sd.Created} {
+		for _, tdd := range tdds {
+			sql := tdd.GetAddForeignKeySQL()
+			if sql != constant.EmptyString {
+				sqls = append(sqls, sql)
+			}
+		}
+	}
+	for _, tdd := range sd.Dropped {
+		sqls = append(sqls, tdd.GetTableMigrationSQL())
+	}
+
+	return sqls
+}
+
+// getForeignKeyDiffs returns the diffs which add or drop all the foreign keys of the table, sorted by the constraint names
+func getForeignKeyDiffs(td *TableFullDefinition, diffType ForeignKeyDiffType) []*ForeignKeyDiff {
+	constraintNames := make([]string, constant.ZeroInt, len(td.ForeignKeys))
+	for constraintName := range td.ForeignKeys {
+		constraintNames = append(constraintNames, constraintName)
+	}
+	sort.Strings(constraintNames)
+
+	foreignKeyDiffs := make([]*ForeignKeyDiff, len(constraintNames))
+	for i, constraintName := range constraintNames {
+		if diffType == ForeignKeyDiffTypeDrop {
+			foreignKeyDiffs[i] = NewForeignKeyDiff(diffType, td.ForeignKeys[constraintName], nil)
+			continue
+		}
+		foreignKeyDiffs[i] = NewForeignKeyDiff(diffType, nil, td.ForeignKeys[constraintName])
+	}
+
+	return foreignKeyDiffs
+}
+
+// isTableRenamed checks if the target table has the same definition as the source table except the table name
+func isTableRenamed(source, target *TableFullDefinition) bool {
+	sourceTable := source.Table.Clone()
+	sourceTable.TableSchema = target.Table.TableSchema
+	sourceTable.TableName = target.Table.TableName
+	if !sourceTable.Equal(target.Table) ||
+		len(source.Columns) != len(target.Columns) ||
+		len(source.Indexes) != len(target.Indexes) ||
+		len(source.ForeignKeys) != len(target.ForeignKeys) {
+		return false
+	}
+
+	for _, column := range target.Columns {
+		if !column.Equal(source.GetColumnDefinition(column.ColumnName)) {
+			return false
+		}
+	}
+	for _, index := range target.Indexes {
+		if !index.Equal(source.Indexes[index.IndexName]) {
+			return false
+		}
+	}
+	for _, foreignKey := range target.ForeignKeys {
+		if !foreignKey.Equal(source.ForeignKeys[foreignKey.ConstraintName]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortTablesByForeignKeys sorts the tables by the foreign key references between them,
+// the referenced tables are in front of the referencing tables, the references to the other tables are ignored,
+// if there are circular references, the tables in the cycles are appended in the original order
+func sortTablesByForeignKeys(tables []*TableFullDefinition) []*TableFullDefinition {
+	tableMap := make(map[string]*TableFullDefinition, len(tables))
+	for _, td := range tables {
+		tableMap[getSchemaTableKey(td.Table.TableSchema, td.Table.TableName)] = td
+	}
+
+	// dependencies is the number of the referenced tables of each table,
+	// referencedBy is the referencing tables of each table
+	dependencies := make(map[string]int, len(tables))
+	referencedBy := make(map[string][]string, len(tables))
+	for key, td := range tableMap {
+		refKeys := make(map[string]bool)
+		for _, fd := range td.ForeignKeys {
+			refKey := getSchemaTableKey(fd.RefTableSchema, fd.RefTableName)
+			_, ok := tableMap[refKey]
+			if !ok || refKey == key || refKeys[refKey] {
+				continue
+			}
+			refKeys[refKey] = true
+			dependencies[key]++
+			referencedBy[refKey] = append(referencedBy[refKey], key)
+		}
+	}
+
+	var (
+		sorted []*TableFullDefinition
+		queue  []string
+	)
+	visited := make(map[string]bool, len(tables))
+	for _, td := range tables {
+		key := getSchemaTableKey(td.Table.TableSchema, td.Table.TableName)
+		if dependencies[key] == constant.ZeroInt {
+			queue = append(queue, key)
+		}
+	}
+	for len(queue) > constant.ZeroInt {
+		key := queue[constant.ZeroInt]
+		queue = queue[constant.OneInt:]
+		visited[key] = true
+		sorted = append(sorted, tableMap[key])
+
+		refKeys := referencedBy[key]
+		sort.Strings(refKeys)
+		for _, refKey := range refKeys {
+			dependencies[refKey]--
+			if dependencies[refKey] == constant.ZeroInt {
+				queue = append(queue, refKey)
+			}
+		}
+	}
+
+	if len(sorted) < len(tables) {
+		for _, td := range tables {
+			if !visited[getSchemaTableKey(td.Table.TableSchema, td.Table.TableName)] {
+				sorted = append(sorted, td)
+			}
+		}
+	}
+
+	return sorted
+}
+
+// getSchemaTableKey returns the key of the table in the schema definition
+func getSchemaTableKey(tableSchema, tableName string) string {
+	if tableSchema == constant.EmptyString {
+		return tableName
+	}
+
+	return tableSchema + constant.DotString + tableName
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSchemaSourceSQL = `
+		/*!40101 SET @saved_cs_client     = @@character_set_client */;
+		/*!50503 SET character_set_client = utf8mb4 */;
+		DROP TABLE IF EXISTS t_user;
+		CREATE TABLE t_user (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  name varchar(64) NOT NULL,
+		  PRIMARY KEY (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+		CREATE TABLE t_log (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  content varchar(256) NOT NULL,
+		  PRIMARY KEY (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+		CREATE TABLE t_tmp (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  PRIMARY KEY (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+		/*!40101 SET character_set_client = @saved_cs_client */;
+	`
+	testSchemaTargetSQL = `
+		CREATE TABLE t_order_item (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  order_id bigint NOT NULL,
+		  PRIMARY KEY (id),
+		  KEY idx01_order_id (order_id),
+		  CONSTRAINT fk_order_item_order FOREIGN KEY (order_id) REFERENCES t_order (id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+		CREATE TABLE t_order (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  user_id bigint NOT NULL,
+		  PRIMARY KEY (id),
+		  KEY idx01_user_id (user_id),
+		  CONSTRAINT fk_order_user FOREIGN KEY (user_id) REFERENCES t_user (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+		CREATE TABLE t_user (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  name varchar(64) NOT NULL,
+		  email varchar(128) NOT NULL,
+		  PRIMARY KEY (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+		CREATE TABLE t_audit_log (
+		  id bigint NOT NULL AUTO_INCREMENT,
+		  content varchar(256) NOT NULL,
+		  PRIMARY KEY (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+	`
+)
+
+func TestParser_ParseSchemaDefinition(t *testing.T) {
+	asst := assert.New(t)
+
+	p := NewParserWithDefault()
+	sd, err := p.ParseSchemaDefinition(testSchemaTargetSQL)
+	asst.Nil(err, "test ParseSchemaDefinition() failed")
+	asst.Equal([]string{"t_audit_log", "t_order", "t_order_item", "t_user"}, sd.GetTableKeys(), "test ParseSchemaDefinition() failed")
+
+	fd := sd.GetTable("", "t_order_item").ForeignKeys["fk_order_item_order"]
+	asst.NotNil(fd, "test ParseSchemaDefinition() failed")
+	asst.Equal([]string{"order_id"}, fd.Columns, "test ParseSchemaDefinition() failed")
+	asst.Equal("t_order", fd.RefTableName, "test ParseSchemaDefinition() failed")
+	asst.Equal("CASCADE", fd.OnDelete, "test ParseSchemaDefinition() failed")
+}
+
+func TestParser_DiffSchema(t *testing.T) {
+	asst := assert.New(t)
+
+	p := NewParserWithDefault()
+	diff, err := p.DiffSchema(testSchemaSourceSQL, testSchemaTargetSQL)
+	asst.Nil(err, "test DiffSchema() failed")
+	asst.False(diff.IsEmpty(), "test DiffSchema() failed")
+
+	asst.Equal(1, len(diff.Renamed), "test DiffSchema() failed")
+	asst.Equal("RENAME TABLE `t_log` TO `t_audit_log`;", diff.Renamed[0].GetMigrationSQL(), "test DiffSchema() failed")
+	asst.Equal(2, len(diff.Created), "test DiffSchema() failed")
+	// the referenced table should be created first
+	asst.Equal("`t_order`", diff.Created[0].Target, "test DiffSchema() failed")
+	asst.Equal("`t_order_item`", diff.Created[1].Target, "test DiffSchema() failed")
+	asst.Equal(1, len(diff.Dropped), "test DiffSchema() failed")
+	asst.Equal(1, len(diff.Altered), "test DiffSchema() failed")
+
+	migrationSQL := diff.GetMigrationSQL()
+	asst.Equal(7, len(migrationSQL), "test DiffSchema() failed")
+	asst.Equal("RENAME TABLE `t_log` TO `t_audit_log`;", migrationSQL[0], "test DiffSchema() failed")
+	asst.Equal("ALTER TABLE `t_user` ADD COLUMN `email` varchar(128) NOT NULL AFTER `name`;", migrationSQL[3], "test DiffSchema() failed")
+	// the foreign keys of the created tables are added after all the tables are created and altered
+	asst.Equal("ALTER TABLE `t_order` ADD CONSTRAINT `fk_order_user` FOREIGN KEY (`user_id`) REFERENCES `t_user` (`id`);",
+		migrationSQL[4], "test DiffSchema() failed")
+	asst.Equal(TableDropPrefix+"DROP TABLE `t_tmp`;", migrationSQL[6], "test DiffSchema() failed")
+	for _, sql := range migrationSQL {
+		t.Log(sql)
+	}
+
+	rollbackSQL := diff.GetRollbackSQL()
+	asst.Equal(7, len(rollbackSQL), "test DiffSchema() failed")
+	// the foreign keys of the dropped tables are dropped first
+	asst.Equal("ALTER TABLE `t_order_item` DROP FOREIGN KEY `fk_order_item_order`;", rollbackSQL[0], "test DiffSchema() failed")
+	asst.Equal("RENAME TABLE `t_audit_log` TO `t_log`;", rollbackSQL[2], "test DiffSchema() failed")
+	// the referencing table should be dropped first
+	asst.Equal(TableDropPrefix+"DROP TABLE `t_order_item`;", rollbackSQL[5], "test DiffSchema() failed")
+	asst.Equal(TableDropPrefix+"DROP TABLE `t_order`;", rollbackSQL[6], "test DiffSchema() failed")
+	for _, sql := range rollbackSQL {
+		t.Log(sql)
+	}
+
+	jsonBytes, err := diff.GetJSONReport()
+	asst.Nil(err, "test DiffSchema() failed")
+	t.Log(string(jsonBytes))
+}
+
+func TestSchemaDefinition_Diff(t *testing.T) {
+	asst := assert.New(t)
+
+	p := NewParserWithDefault()
+	source, err := p.ParseSchemaDefinition(`
+		CREATE TABLE t01 (id int NOT NULL, pid int, PRIMARY KEY (id), KEY idx01 (pid));
+		CREATE TABLE t02 (id int NOT NULL, PRIMARY KEY (id));
+	`)
+	asst.Nil(err, "test Diff() failed")
+	target, err := p.ParseSchemaDefinition(`
+		CREATE TABLE t01 (id int NOT NULL, pid int, PRIMARY KEY (id), KEY idx01 (pid),
+		  CONSTRAINT fk01 FOREIGN KEY (pid) REFERENCES t03 (id));
+		CREATE TABLE t03 (id int NOT NULL, PRIMARY KEY (id), CONSTRAINT fk03 FOREIGN KEY (id) REFERENCES t04 (id));
+		CREATE TABLE t04 (id int NOT NULL, PRIMARY KEY (id), CONSTRAINT fk04 FOREIGN KEY (id) REFERENCES t03 (id));
+	`)
+	asst.Nil(err, "test Diff() failed")
+
+	diff := target.Diff(source)
+	migrationSQL := diff.GetMigrationSQL()
+	for _, sql := range migrationSQL {
+		t.Log(sql)
+	}
+	// circular references between the created tables, the tables are created without the foreign keys
+	asst.Equal(6, len(migrationSQL), "test Diff() failed")
+	asst.Equal("CREATE TABLE `t03` (`id` INT NOT NULL,PRIMARY KEY(`id`));", migrationSQL[0], "test Diff() failed")
+	asst.Equal("CREATE TABLE `t04` (`id` INT NOT NULL,PRIMARY KEY(`id`));", migrationSQL[1], "test Diff() failed")
+	// the foreign keys are added after all the tables are created
+	asst.Equal("ALTER TABLE `t01` ADD CONSTRAINT `fk01` FOREIGN KEY (`pid`) REFERENCES `t03` (`id`);", migrationSQL[2], "test Diff() failed")
+	asst.Equal("ALTER TABLE `t03` ADD CONSTRAINT `fk03` FOREIGN KEY (`id`) REFERENCES `t04` (`id`);", migrationSQL[3], "test Diff() failed")
+	asst.Equal("ALTER TABLE `t04` ADD CONSTRAINT `fk04` FOREIGN KEY (`id`) REFERENCES `t03` (`id`);", migrationSQL[4], "test Diff() failed")
+	// the diff should not change the definitions
+	asst.Equal(migrationSQL, target.Diff(source).GetMigrationSQL(), "test Diff() failed")
+	// circular references between the dropped tables, the foreign keys are dropped first
+	rollbackSQL := diff.GetRollbackSQL()
+	for _, sql := range rollbackSQL {
+		t.Log(sql)
+	}
+	asst.Equal(6, len(rollbackSQL), "test Diff() failed")
+	asst.Equal("ALTER TABLE `t01` DROP FOREIGN KEY `fk01`;", rollbackSQL[0], "test Diff() failed")
+	asst.Equal("ALTER TABLE `t04` DROP FOREIGN KEY `fk04`;", rollbackSQL[1], "test Diff() failed")
+	asst.Equal("ALTER TABLE `t03` DROP FOREIGN KEY `fk03`;", rollbackSQL[2], "test Diff() failed")
+
+	// the referenced column and index of the created table are added to the existing table
+	source, err = p.ParseSchemaDefinition(`
+		CREATE TABLE t_user (id bigint NOT NULL, PRIMARY KEY (id));
+	`)
+	asst.Nil(err, "test Diff() failed")
+	target, err = p.ParseSchemaDefinition(`
+		CREATE TABLE t_user (id bigint NOT NULL, code varchar(32) NOT NULL, PRIMARY KEY (id), UNIQUE KEY uk_code (code));
+		CREATE TABLE t_order (id bigint NOT NULL, user_code varchar(32) NOT NULL, PRIMARY KEY (id), KEY idx01_user_code (user_code),
+		  CONSTRAINT fk_order_user FOREIGN KEY (user_code) REFERENCES t_user (code));
+	`)
+	asst.Nil(err, "test Diff() failed")
+	diff = target.Diff(source)
+	migrationSQL = diff.GetMigrationSQL()
+	for _, sql := range migrationSQL {
+		t.Log(sql)
+	}
+	asst.Equal(3, len(migrationSQL), "test Diff() failed")
+	asst.Equal("CREATE TABLE `t_order` (`id` BIGINT NOT NULL,`user_code` VARCHAR(32) NOT NULL,PRIMARY KEY(`id`),INDEX `idx01_user_code`(`user_code`));",
+		migrationSQL[0], "test Diff() failed")
+	asst.Contains(migrationSQL[1], "ALTER TABLE `t_user` ADD COLUMN `code`", "test Diff() failed")
+	asst.Equal("ALTER TABLE `t_order` ADD CONSTRAINT `fk_order_user` FOREIGN KEY (`user_code`) REFERENCES `t_user` (`code`);",
+		migrationSQL[2], "test Diff() failed")
+	// the foreign key is dropped before the referenced column and index
+	rollbackSQL = diff.GetRollbackSQL()
+	for _, sql := range rollbackSQL {
+		t.Log(sql)
+	}
+	asst.Equal(3, len(rollbackSQL), "test Diff() failed")
+	asst.Equal("ALTER TABLE `t_order` DROP FOREIGN KEY `fk_order_user`;", rollbackSQL[0], "test Diff() failed")
+	asst.Contains(rollbackSQL[1], "ALTER TABLE `t_user` DROP INDEX `uk_code`", "test Diff() failed")
+	asst.Equal(TableDropPrefix+"DROP TABLE `t_order`;", rollbackSQL[2], "test Diff() failed")
+
+	// the unnamed foreign keys are named as mysql does, so they do not overwrite each other
+	target, err = p.ParseSchemaDefinition(`
+		CREATE TABLE t01 (id int NOT NULL, pid int, PRIMARY KEY (id), KEY idx01 (pid),
+		  FOREIGN KEY (pid) REFERENCES t02 (id), FOREIGN KEY (id) REFERENCES t02 (id));
+		CREATE TABLE t02 (id int NOT NULL, PRIMARY KEY (id));
+	`)
+	asst.Nil(err, "test Diff() failed")
+	td := target.GetTable("", "t01")
+	asst.Equal(2, len(td.ForeignKeys), "test Diff() failed")
+	asst.Equal([]string{"pid"}, td.ForeignKeys["t01_ibfk_1"].Columns, "test Diff() failed")
+	asst.Equal([]string{"id"}, td.ForeignKeys["t01_ibfk_2"].Columns, "test Diff() failed")
+	migrationSQL = target.Diff(source).GetMigrationSQL()
+	asst.Equal(4, len(migrationSQL), "test Diff() failed")
+	asst.Equal("ALTER TABLE `t01` ADD CONSTRAINT `t01_ibfk_1` FOREIGN KEY (`pid`) REFERENCES `t02` (`id`), "+
+		"ADD CONSTRAINT `t01_ibfk_2` FOREIGN KEY (`id`) REFERENCES `t02` (`id`);", migrationSQL[2], "test Diff() failed")
+	// the duplicate constraint name is an error
+	_, err = p.ParseSchemaDefinition(`
+		CREATE TABLE t01 (id int NOT NULL, pid int, PRIMARY KEY (id), KEY idx01 (pid),
+		  FOREIGN KEY (pid) REFERENCES t02 (id), CONSTRAINT t01_ibfk_1 FOREIGN KEY (id) REFERENCES t02 (id));
+	`)
+	asst.NotNil(err, "test Diff() failed")
+}
@@ -72,24 +72,25 @@ func (tdt *TableDiffType) UnmarshalJSON(b []byte) error {
 // Caution: for now, table definition does not include information below:
 // - only base table, no view
 // - no partition info
-// - no foreign key info
 // - some table options are not included, for example: auto_increment, compression, encryption, etc...
 //
 // - also see index.go for more limitations
 type TableFullDefinition struct {
-	CreateTableSQL string                       `json:"createTableSQL"`
-	Table          *TableDefinition             `json:"table"`
-	Columns        []*ColumnDefinition          `json:"columns"`
-	ColumnMap      map[string]*ColumnDefinition `json:"-"`
-	Indexes        map[string]*IndexDefinition  `json:"indexes"`
+	CreateTableSQL string                           `json:"createTableSQL"`
+	Table          *TableDefinition                 `json:"table"`
+	Columns        []*ColumnDefinition              `json:"columns"`
+	ColumnMap      map[string]*ColumnDefinition     `json:"-"`
+	Indexes        map[string]*IndexDefinition      `json:"indexes"`
+	ForeignKeys    map[string]*ForeignKeyDefinition `json:"foreignKeys,omitempty"`
 }
 
 // NewEmptyTableFullDefinition returns a new empty *TableFullDefinition
 func NewEmptyTableFullDefinition() *TableFullDefinition {
 	return &TableFullDefinition{
-		Table:     NewEmptyTableDefinition(),
-		ColumnMap: make(map[string]*ColumnDefinition),
-		Indexes:   make(map[string]*IndexDefinition),
+		Table:       NewEmptyTableDefinition(),
+		ColumnMap:   make(map[string]*ColumnDefinition),
+		Indexes:     make(map[string]*IndexDefinition),
+		ForeignKeys: make(map[string]*ForeignKeyDefinition),
 	}
 }
 
@@ -109,6 +110,10 @@ func (td *TableFullDefinition) Error() error {
 	for _, index := range td.Indexes {
 		merr = multierror.Append(merr, index.Errors)
 	}
+	// foreign key
+	for _, foreignKey := range td.ForeignKeys {
+		merr = multierror.Append(merr, foreignKey.Errors)
+	}
 
 	return merr.ErrorOrNil()
 }
@@ -142,8 +147,9 @@ func (td *TableFullDefinition) Equal(other *TableFullDefinition) bool {
 // Diff returns the difference between two table definitions
 func (td *TableFullDefinition) Diff(source *TableFullDefinition) *TableDefinitionDiff {
 	var (
-		columnDiffList []*ColumnDiff
-		indexDiffList  []*IndexDiff
+		columnDiffList     []*ColumnDiff
+		indexDiffList      []*IndexDiff
+		foreignKeyDiffList []*ForeignKeyDiff
 	)
 	// table
 	tableDiff := td.Table.Diff(source.Table)
@@ -192,8 +198,23 @@ func (td *TableFullDefinition) Diff(source *TableFullDefinition) *TableDefinitio
 		}
 	}
 
-	if tableDiff != nil || len(columnDiffList) > constant.ZeroInt || len(indexDiffList) > constant.ZeroInt {
-		return NewTableDefinitionDiff(source.Table.GetFullTableName(), td.Table.GetFullTableName(), tableDiff, columnDiffList, indexDiffList)
+	// foreign key
+	for _, sourceForeignKey := range source.ForeignKeys {
+		_, ok := td.ForeignKeys[sourceForeignKey.ConstraintName]
+		if !ok {
+			foreignKeyDiffList = append(foreignKeyDiffList, NewForeignKeyDiff(ForeignKeyDiffTypeDrop, sourceForeignKey, nil))
+		}
+	}
+	for _, targetForeignKey := range td.ForeignKeys {
+		foreignKeyDiffList = append(foreignKeyDiffList, targetForeignKey.Diff(source.ForeignKeys[targetForeignKey.ConstraintName])...)
+	}
+
+	if tableDiff != nil || len(columnDiffList) > constant.ZeroInt || len(indexDiffList) > constant.ZeroInt ||
+		len(foreignKeyDiffList) > constant.ZeroInt {
+		tdd := NewTableDefinitionDiff(source.Table.GetFullTableName(), td.Table.GetFullTableName(), tableDiff, columnDiffList, indexDiffList)
+		tdd.ForeignKeyDiff = foreignKeyDiffList
+
+		return tdd
 	}
 
 	return nil
@@ -210,6 +231,33 @@ func (td *TableFullDefinition) AddIndex(id *IndexDefinition) {
 	td.Indexes[id.IndexName] = id
 }
 
+// AddForeignKey adds a foreign key to the table definition
+func (td *TableFullDefinition) AddForeignKey(fd *ForeignKeyDefinition) {
+	if td.ForeignKeys == nil {
+		td.ForeignKeys = make(map[string]*ForeignKeyDefinition)
+	}
+	td.ForeignKeys[fd.ConstraintName] = fd
+}
+
+// Clone returns a new *TableFullDefinition, the diff functions may change the ordinal positions of the columns,
+// clone the definition if it will be used more than once
+func (td *TableFullDefinition) Clone() *TableFullDefinition {
+	newTD := NewEmptyTableFullDefinition()
+	newTD.CreateTableSQL = td.CreateTableSQL
+	newTD.Table = td.Table.Clone()
+	for _, column := range td.Columns {
+		newTD.AddColumn(column.Clone())
+	}
+	for _, index := range td.Indexes {
+		newTD.AddIndex(index.Clone())
+	}
+	for _, foreignKey := range td.ForeignKeys {
+		newTD.AddForeignKey(foreignKey.Clone())
+	}
+
+	return newTD
+}
+
 // MaintainOrdinalPosition maintains the ordinal position of the columns
 func (td *TableFullDefinition) MaintainOrdinalPosition(diffType ColumnDiffType, ordinalPosition int) {
 	switch diffType {
@@ -236,6 +284,8 @@ type TableDefinitionDiff struct {
 	TableDiff  *TableDiff    `json:"tableDiff"`
 	ColumnDiff []*ColumnDiff `json:"columnDiff"`
 	IndexDiff  []*IndexDiff  `json:"indexDiff"`
+
+	ForeignKeyDiff []*ForeignKeyDiff `json:"foreignKeyDiff,omitempty"`
 }
 
 // NewTableDefinitionDiff returns a new *TableDefinitionDiff
@@ -257,22 +307,83 @@ func NewEmptyTableDefinitionDiff() *TableDefinitionDiff {
 
 // GetTableMigrationSQL returns the migration sql of TableDefinitionDiff
 func (tdd *TableDefinitionDiff) GetTableMigrationSQL() string {
+	return tdd.getTableMigrationSQL(true)
+}
+
+// GetTableMigrationSQLWithoutForeignKeys returns the migration sql of TableDefinitionDiff without the foreign key changes,
+// it's used to migrate multiple tables, the foreign keys should be dropped before and added after all the tables had been migrated,
+// see GetDropForeignKeySQL() and GetAddForeignKeySQL()
+func (tdd *TableDefinitionDiff) GetTableMigrationSQLWithoutForeignKeys() string {
+	return tdd.getTableMigrationSQL(false)
+}
+
+// GetDropForeignKeySQL returns the sql which drops the removed or changed foreign keys,
+// if there is no such foreign key, it returns empty string
+func (tdd *TableDefinitionDiff) GetDropForeignKeySQL() string {
+	return tdd.getForeignKeySQL(ForeignKeyDiffTypeDrop)
+}
+
+// GetAddForeignKeySQL returns the sql which adds the new or changed foreign keys,
+// if there is no such foreign key, it returns empty string
+func (tdd *TableDefinitionDiff) GetAddForeignKeySQL() string {
+	return tdd.getForeignKeySQL(ForeignKeyDiffTypeAdd)
+}
+
+// getForeignKeySQL returns the alter table sql of the foreign key diffs with given diff type
+func (tdd *TableDefinitionDiff) getForeignKeySQL(diffType ForeignKeyDiffType) string {
+	var clauses []string
+	for _, fd := range tdd.ForeignKeyDiff {
+		if fd.DiffType == diffType {
+			clauses = append(clauses, fd.GetMigrationSQL())
+		}
+	}
+	if len(clauses) == constant.ZeroInt {
+		return constant.EmptyString
+	}
+
+	// the foreign keys are dropped before renaming the table and added after renaming the table
+	tableName := tdd.Target
+	if diffType == ForeignKeyDiffTypeDrop {
+		tableName = tdd.Source
+	}
+
+	return AlterKeyWord + constant.SpaceString + TableTableString + constant.SpaceString + tableName + constant.SpaceString +
+		strings.Join(clauses, constant.CommaString+constant.SpaceString) + constant.SemicolonString
+}
+
+// getTableMigrationSQL returns the migration sql of TableDefinitionDiff
+func (tdd *TableDefinitionDiff) getTableMigrationSQL(withForeignKeys bool) string {
 	var sql string
 	// table
 	if tdd.TableDiff != nil {
+		if !withForeignKeys && tdd.TableDiff.DiffType == TableDiffTypeCreate &&
+			tdd.TableDiff.Target.CreateTableSQLWithoutForeignKeys != constant.EmptyString {
+			return tdd.TableDiff.Target.CreateTableSQLWithoutForeignKeys + constant.SemicolonString
+		}
 		sql += tdd.TableDiff.GetTableMigrationSQL()
 		if tdd.TableDiff.DiffType == TableDiffTypeCreate || tdd.TableDiff.DiffType == TableDiffTypeDrop {
 			return sql
 		}
 		sql += constant.CommaString + constant.SpaceString
 	}
+
+	var foreignKeyDiff []*ForeignKeyDiff
+	if withForeignKeys {
+		foreignKeyDiff = tdd.ForeignKeyDiff
+	}
 	// column
-	if len(tdd.ColumnDiff) > constant.ZeroInt || len(tdd.IndexDiff) > constant.ZeroInt {
+	if len(tdd.ColumnDiff) > constant.ZeroInt || len(tdd.IndexDiff) > constant.ZeroInt || len(foreignKeyDiff) > constant.ZeroInt {
 		if tdd.TableDiff == nil {
 			sql = AlterKeyWord + constant.SpaceString + TableTableString + constant.SpaceString +
 				tdd.Target + constant.SpaceString
 		}
 
+		// drop foreign key, the index used by the foreign key could not be dropped before the foreign key is dropped
+		for _, fd := range foreignKeyDiff {
+			if fd.DiffType == ForeignKeyDiffTypeDrop {
+				sql += fd.GetMigrationSQL() + constant.CommaString + constant.SpaceString
+			}
+		}
 		// drop index
 		for _, id := range tdd.IndexDiff {
 			if id.DiffType == IndexDiffTypeDrop {
@@ -303,11 +414,19 @@ func (tdd *TableDefinitionDiff) GetTableMigrationSQL() string {
 				sql += id.GetMigrationSQL() + constant.CommaString + constant.SpaceString
 			}
 		}
+		// add foreign key
+		for _, fd := range foreignKeyDiff {
+			if fd.DiffType == ForeignKeyDiffTypeAdd {
+				sql += fd.GetMigrationSQL() + constant.CommaString + constant.SpaceString
+			}
+		}
+	}
 
-		sql = strings.TrimSuffix(strings.TrimSpace(sql), constant.CommaString) + constant.SemicolonString
+	if sql == constant.EmptyString {
+		return sql
 	}
 
-	return sql
+	return strings.TrimSuffix(strings.TrimSpace(sql), constant.CommaString) + constant.SemicolonString
 }
 
 type TableDiff struct {
@@ -385,6 +504,10 @@ type TableDefinition struct {
 	Collation      string `json:"collation,omitempty"`
 	RowFormat      string `json:"rowFormat,omitempty"`
 	TableComment   string `json:"tableComment,omitempty"`
+
+	// CreateTableSQLWithoutForeignKeys is the create table statement without the foreign keys,
+	// it's empty if the table does not have any foreign key
+	CreateTableSQLWithoutForeignKeys string `json:"-"`
 }
 
 // NewTableDefinition returns a new *TableDefinition
@@ -423,14 +546,15 @@ func (td *TableDefinition) GetTableName() string {
 // Clone returns a new *TableDefinition
 func (td *TableDefinition) Clone() *TableDefinition {
 	return &TableDefinition{
-		CreateTableSQL: td.CreateTableSQL,
-		TableSchema:    td.TableSchema,
-		TableName:      td.TableName,
-		TableEngine:    td.TableEngine,
-		Charset:        td.Charset,
-		Collation:      td.Collation,
-		RowFormat:      td.RowFormat,
-		TableComment:   td.TableComment,
+		CreateTableSQL:                   td.CreateTableSQL,
+		TableSchema:                      td.TableSchema,
+		TableName:                        td.TableName,
+		TableEngine:                      td.TableEngine,
+		Charset:                          td.Charset,
+		Collation:                        td.Collation,
+		RowFormat:                        td.RowFormat,
+		TableComment:                     td.TableComment,
+		CreateTableSQLWithoutForeignKeys: td.CreateTableSQLWithoutForeignKeys,
 	}
 }
 
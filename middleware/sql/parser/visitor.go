@@ -3,11 +3,13 @@ package parser
 import (
 	"bytes"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/tidb/pkg/parser/ast"
 	"github.com/pingcap/tidb/pkg/parser/charset"
+	"github.com/pingcap/tidb/pkg/parser/format"
 	"github.com/pingcap/tidb/pkg/parser/types"
 
 	"github.com/romberli/go-util/common"
@@ -342,6 +344,9 @@ func (v *Visitor) visitCreateTableStmt(node *ast.CreateTableStmt) {
 					is := NewIndexSpec(cd, column.Desc, column.Length, expression)
 					id.AddIndexSpec(is)
 				}
+			case ast.ConstraintForeignKey:
+				v.visitForeignKeyConstraint(tableSchema, tableName, constraint)
+				continue
 			default:
 				err := errors.Errorf("unknown index type. indexName: %s, indexType: %d", indexName, constraint.Tp)
 				id.AddError(err)
@@ -349,6 +354,87 @@ func (v *Visitor) visitCreateTableStmt(node *ast.CreateTableStmt) {
 
 			v.tableDefinition.AddIndex(id)
 		}
+		if len(v.tableDefinition.ForeignKeys) > constant.ZeroInt {
+			v.setCreateTableSQLWithoutForeignKeys(node)
+		}
+	}
+}
+
+// setCreateTableSQLWithoutForeignKeys sets the create table statement without the foreign keys,
+// it's used to create the tables before the referenced columns and indexes exist, the foreign keys will be added later
+func (v *Visitor) setCreateTableSQLWithoutForeignKeys(node *ast.CreateTableStmt) {
+	stmt := *node
+	stmt.Constraints = nil
+	for _, constraint := range node.Constraints {
+		if constraint.Tp != ast.ConstraintForeignKey {
+			stmt.Constraints = append(stmt.Constraints, constraint)
+		}
+	}
+
+	var builder strings.Builder
+	err := stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &builder))
+	if err != nil {
+		for _, fd := range v.tableDefinition.ForeignKeys {
+			fd.AddError(errors.Annotatef(err, "restore create table statement without foreign keys failed. tableName: %s",
+				node.Table.Name.L))
+		}
+		return
+	}
+
+	v.tableDefinition.Table.CreateTableSQLWithoutForeignKeys = builder.String()
+}
+
+// visitForeignKeyConstraint visits the foreign key constraint of the create table statement
+// if the constraint name is not specified, the name is generated as mysql does
+func (v *Visitor) visitForeignKeyConstraint(tableSchema, tableName string, constraint *ast.Constraint) {
+	constraintName := constraint.Name
+	if constraintName == constant.EmptyString {
+		constraintName = v.getGeneratedForeignKeyName(tableName)
+	}
+
+	fd := NewForeignKeyDefinition(tableSchema, tableName, constraintName)
+	if v.tableDefinition.ForeignKeys[constraintName] != nil {
+		fd.AddError(errors.Errorf("duplicate foreign key constraint name. tableName: %s, constraintName: %s",
+			tableName, constraintName))
+	}
+	for _, column := range constraint.Keys {
+		if column.Column == nil {
+			fd.AddError(errors.Errorf("foreign key column should not be an expression. tableName: %s, constraintName: %s",
+				tableName, constraintName))
+			continue
+		}
+		fd.Columns = append(fd.Columns, column.Column.Name.L)
+	}
+
+	if constraint.Refer == nil || constraint.Refer.Table == nil {
+		fd.AddError(errors.Errorf("could not find the referenced table of the foreign key. tableName: %s, constraintName: %s",
+			tableName, constraintName))
+	} else {
+		fd.RefTableSchema = constraint.Refer.Table.Schema.L
+		fd.RefTableName = constraint.Refer.Table.Name.L
+		for _, column := range constraint.Refer.IndexPartSpecifications {
+			if column.Column != nil {
+				fd.RefColumns = append(fd.RefColumns, column.Column.Name.L)
+			}
+		}
+		if constraint.Refer.OnDelete != nil {
+			fd.OnDelete = constraint.Refer.OnDelete.ReferOpt.String()
+		}
+		if constraint.Refer.OnUpdate != nil {
+			fd.OnUpdate = constraint.Refer.OnUpdate.ReferOpt.String()
+		}
+	}
+
+	v.tableDefinition.AddForeignKey(fd)
+}
+
+// getGeneratedForeignKeyName returns the first unused name like <table name>_ibfk_<n>, n starts from 1
+func (v *Visitor) getGeneratedForeignKeyName(tableName string) string {
+	for i := constant.OneInt; ; i++ {
+		constraintName := tableName + ForeignKeyGeneratedNameInfix + strconv.Itoa(i)
+		if v.tableDefinition.ForeignKeys[constraintName] == nil {
+			return constraintName
+		}
 	}
 }
 
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/romberli/go-util/constant"
+)
+
+// DefaultWaitDurationBuckets is the default upper bounds(in seconds) of the buckets of the get-wait duration histogram
+var DefaultWaitDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+
+type PoolStats struct {
+	// MaxConnections is the maximum number of connections of the pool
+	MaxConnections int `json:"max_connections"`
+	// UsedConnections is the number of connections which are in use
+	UsedConnections int `json:"used_connections"`
+	// IdleConnections is the number of connections which are in the pool and not in use
+	IdleConnections int `json:"idle_connections"`
+	// WaitingCount is the number of callers which are waiting for a connection currently
+	WaitingCount int `json:"waiting_count"`
+	// GetCount is the total number of getting connection from the pool
+	GetCount uint64 `json:"get_count"`
+	// GetFailedCount is the total number of failures of getting connection from the pool
+	GetFailedCount uint64 `json:"get_failed_count"`
+	// WaitCount is the total number of getting connection which could not get a connection immediately
+	WaitCount uint64 `json:"wait_count"`
+	// WaitDuration is the total time spent on getting connection from the pool
+	WaitDuration time.Duration `json:"wait_duration"`
+	// WaitDurationBuckets is the cumulative count of getting connection for each bucket,
+	// key is the upper bound of the bucket in seconds
+	WaitDurationBuckets map[float64]uint64 `json:"wait_duration_buckets"`
+	// CreatedCount is the total number of connections which were created by the pool
+	CreatedCount uint64 `json:"created_count"`
+	// InvalidCount is the total number of connections which were found invalid and disconnected by the pool
+	InvalidCount uint64 `json:"invalid_count"`
+	// KeepAliveFailedCount is the total number of connections which were found invalid when keeping alive
+	KeepAliveFailedCount uint64 `json:"keep_alive_failed_count"`
+	// ReconnectCount is the total number of connections which were created to replace the invalid connections
+	ReconnectCount uint64 `json:"reconnect_count"`
+}
+
+// PoolStatsRecorder records the statistics of a pool, it's routine safe
+type PoolStatsRecorder struct {
+	sync.Mutex
+	buckets              []float64
+	bucketCounts         []uint64
+	waitingCount         int
+	getCount             uint64
+	getFailedCount       uint64
+	waitCount            uint64
+	waitDuration         time.Duration
+	createdCount         uint64
+	invalidCount         uint64
+	keepAliveFailedCount uint64
+	reconnectCount       uint64
+	pendingReconnect     uint64
+}
+
+// NewPoolStatsRecorder returns a new *PoolStatsRecorder with given buckets of the get-wait duration histogram,
+// if buckets is empty, DefaultWaitDurationBuckets will be used
+func NewPoolStatsRecorder(buckets ...float64) *PoolStatsRecorder {
+	if len(buckets) == constant.ZeroInt {
+		buckets = DefaultWaitDurationBuckets
+	}
+
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+
+	return &PoolStatsRecorder{
+		buckets:      b,
+		bucketCounts: make([]uint64, len(b)),
+	}
+}
+
+// StartWaiting records that a caller starts to wait for a connection
+func (psr *PoolStatsRecorder) StartWaiting() {
+	psr.Lock()
+	defer psr.Unlock()
+
+	psr.waitingCount++
+	psr.waitCount++
+}
+
+// StopWaiting records that a caller stops waiting for a connection
+func (psr *PoolStatsRecorder) StopWaiting() {
+	psr.Lock()
+	defer psr.Unlock()
+
+	psr.waitingCount--
+}
+
+// ObserveGet records a getting connection with the time spent and the error
+func (psr *PoolStatsRecorder) ObserveGet(duration time.Duration, err error) {
+	psr.Lock()
+	defer psr.Unlock()
+
+	psr.getCount++
+	if err != nil {
+		psr.getFailedCount++
+	}
+	psr.waitDuration += duration
+
+	seconds := duration.Seconds()
+	for i, bucket := range psr.buckets {
+		if seconds <= bucket {
+			psr.bucketCounts[i]++
+		}
+	}
+}
+
+// IncCreated records that a new connection was created,
+// if there are invalid connections which had not been replaced, it will be counted as a reconnection
+func (psr *PoolStatsRecorder) IncCreated() {
+	psr.Lock()
+	defer psr.Unlock()
+
+	psr.createdCount++
+	if psr.pendingReconnect > constant.ZeroInt {
+		psr.pendingReconnect--
+		psr.reconnectCount++
+	}
+}
+
+// IncInvalid records that an invalid connection was found and disconnected,
+// isKeepAlive means if the connection was found when keeping alive
+func (psr *PoolStatsRecorder) IncInvalid(isKeepAlive bool) {
+	psr.Lock()
+	defer psr.Unlock()
+
+	psr.invalidCount++
+	psr.pendingReconnect++
+	if isKeepAlive {
+		psr.keepAliveFailedCount++
+	}
+}
+
+// Stats returns the statistics with given connection numbers
+func (psr *PoolStatsRecorder) Stats(maxConnections, usedConnections, idleConnections int) PoolStats {
+	psr.Lock()
+	defer psr.Unlock()
+
+	buckets := make(map[float64]uint64, len(psr.buckets))
+	for i, bucket := range psr.buckets {
+		buckets[bucket] = psr.bucketCounts[i]
+	}
+
+	return PoolStats{
+		MaxConnections:       maxConnections,
+		UsedConnections:      usedConnections,
+		IdleConnections:      idleConnections,
+		WaitingCount:         psr.waitingCount,
+		GetCount:             psr.getCount,
+		GetFailedCount:       psr.getFailedCount,
+		WaitCount:            psr.waitCount,
+		WaitDuration:         psr.waitDuration,
+		WaitDurationBuckets:  buckets,
+		CreatedCount:         psr.createdCount,
+		InvalidCount:         psr.invalidCount,
+		KeepAliveFailedCount: psr.keepAliveFailedCount,
+		ReconnectCount:       psr.reconnectCount,
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"time"
+
+	"github.com/romberli/go-util/constant"
+)
+
+// Waiter represents a caller which is waiting for a connection
+type Waiter[T any] struct {
+	elem *list.Element
+	ch   chan T
+}
+
+// C returns the channel which the connection will be sent to,
+// if the channel is closed, it means the pool had been closed
+func (w *Waiter[T]) C() <-chan T {
+	return w.ch
+}
+
+// WaitQueue is a first-in first-out queue of the callers which are waiting for a connection,
+// when a connection is returned to the pool, it will be handed over to the first waiter directly,
+// it is not routine safe, caller should protect it with the lock of the pool
+type WaitQueue[T any] struct {
+	waiters *list.List
+}
+
+// NewWaitQueue returns a new *WaitQueue
+func NewWaitQueue[T any]() *WaitQueue[T] {
+	return &WaitQueue[T]{waiters: list.New()}
+}
+
+// Len returns the number of the waiters
+func (wq *WaitQueue[T]) Len() int {
+	return wq.waiters.Len()
+}
+
+// Push adds a new waiter to the end of the queue and returns it
+func (wq *WaitQueue[T]) Push() *Waiter[T] {
+	w := &Waiter[T]{ch: make(chan T, constant.OneInt)}
+	w.elem = wq.waiters.PushBack(w)
+
+	return w
+}
+
+// Remove removes given waiter from the queue,
+// it returns false if the waiter is not in the queue, that means it had been served or the queue had been closed,
+// in this case, caller should receive from the channel of the waiter and deal with the connection
+func (wq *WaitQueue[T]) Remove(w *Waiter[T]) bool {
+	if w.elem == nil {
+		return false
+	}
+
+	wq.waiters.Remove(w.elem)
+	w.elem = nil
+
+	return true
+}
+
+// Serve hands over given connection to the first waiter,
+// it returns false if there is no waiter in the queue
+func (wq *WaitQueue[T]) Serve(t T) bool {
+	front := wq.waiters.Front()
+	if front == nil {
+		return false
+	}
+
+	w := wq.waiters.Remove(front).(*Waiter[T])
+	w.elem = nil
+	w.ch <- t
+
+	return true
+}
+
+// Close removes all the waiters from the queue and closes their channels
+func (wq *WaitQueue[T]) Close() {
+	for front := wq.waiters.Front(); front != nil; front = wq.waiters.Front() {
+		w := wq.waiters.Remove(front).(*Waiter[T])
+		w.elem = nil
+		close(w.ch)
+	}
+}
+
+// GetWaitContext returns a context for getting connection from the pool with given maximum wait time in seconds,
+// if maxWaitTime is smaller than 0, it means waiting without limit
+func GetWaitContext(maxWaitTime int) (context.Context, context.CancelFunc) {
+	if maxWaitTime < constant.ZeroInt {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), time.Duration(maxWaitTime)*time.Second)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitQueue(t *testing.T) {
+	asst := assert.New(t)
+
+	wq := NewWaitQueue[int]()
+	asst.False(wq.Serve(1), "test Serve() failed")
+
+	w1 := wq.Push()
+	w2 := wq.Push()
+	w3 := wq.Push()
+	asst.Equal(3, wq.Len(), "test Push() failed")
+
+	// first in first out
+	asst.True(wq.Serve(1), "test Serve() failed")
+	asst.Equal(1, <-w1.C(), "test Serve() failed")
+	// removed waiter will not be served
+	asst.True(wq.Remove(w2), "test Remove() failed")
+	asst.True(wq.Serve(3), "test Serve() failed")
+	asst.Equal(3, <-w3.C(), "test Serve() failed")
+	// served waiter could not be removed
+	asst.False(wq.Remove(w3), "test Remove() failed")
+	asst.Zero(wq.Len(), "test Len() failed")
+
+	w4 := wq.Push()
+	wq.Close()
+	_, ok := <-w4.C()
+	asst.False(ok, "test Close() failed")
+	asst.False(wq.Remove(w4), "test Remove() failed")
+}
+
+func TestGetWaitContext(t *testing.T) {
+	asst := assert.New(t)
+
+	ctx, cancel := GetWaitContext(-1)
+	_, ok := ctx.Deadline()
+	asst.False(ok, "test GetWaitContext() failed")
+	cancel()
+	asst.ErrorIs(ctx.Err(), context.Canceled, "test GetWaitContext() failed")
+
+	ctx, cancel = GetWaitContext(1)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	asst.True(ok, "test GetWaitContext() failed")
+	asst.WithinDuration(time.Now().Add(time.Second), deadline, 100*time.Millisecond, "test GetWaitContext() failed")
+}